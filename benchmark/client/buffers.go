@@ -0,0 +1,215 @@
+package client
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// BufferMagicByte starts every binary frame on the /buffers WebSocket
+const BufferMagicByte = 0xbf
+
+// BufferFrame is a decoded binary frame from the /buffers WebSocket. The
+// payload is either a buffer snapshot or, from servers that forward the
+// stream as JSON, an event such as {"type":"output",...}.
+type BufferFrame struct {
+	SessionID string
+	Received  time.Time
+	Size      int // Bytes of the whole frame
+	Snapshot  *BufferSnapshot
+	Event     map[string]interface{}
+}
+
+// BufferStream is a /buffers WebSocket connection
+type BufferStream struct {
+	conn    *websocket.Conn
+	writeMu sync.Mutex // The connection allows one writer at a time
+	Frames  chan BufferFrame
+	Errors  chan error
+	done    chan struct{}
+}
+
+// ConnectBuffers opens the /buffers WebSocket. Subscribe to sessions to
+// receive their frames.
+func (c *VibeTunnelClient) ConnectBuffers() (*BufferStream, error) {
+	header := http.Header{}
+	if c.authToken != "" {
+		header.Set("Authorization", "Bearer "+c.authToken)
+	}
+
+	dialer := websocket.Dialer{HandshakeTimeout: 10 * time.Second}
+	conn, resp, err := dialer.Dial(strings.Replace(c.baseURL, "http", "ws", 1)+"/buffers", header)
+	if err != nil {
+		if resp != nil {
+			return nil, fmt.Errorf("dial buffers: %w (HTTP %d)", err, resp.StatusCode)
+		}
+		return nil, fmt.Errorf("dial buffers: %w", err)
+	}
+
+	stream := &BufferStream{
+		conn:   conn,
+		Frames: make(chan BufferFrame, 100),
+		Errors: make(chan error, 10),
+		done:   make(chan struct{}),
+	}
+
+	go stream.readLoop()
+
+	return stream, nil
+}
+
+// Subscribe starts streaming a session's buffer
+func (s *BufferStream) Subscribe(sessionID string) error {
+	return s.send(map[string]string{"type": "subscribe", "sessionId": sessionID})
+}
+
+// Unsubscribe stops streaming a session's buffer
+func (s *BufferStream) Unsubscribe(sessionID string) error {
+	return s.send(map[string]string{"type": "unsubscribe", "sessionId": sessionID})
+}
+
+func (s *BufferStream) send(msg map[string]string) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("marshal message: %w", err)
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if err := s.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+		return fmt.Errorf("write message: %w", err)
+	}
+	return nil
+}
+
+// Close closes the WebSocket connection
+func (s *BufferStream) Close() error {
+	close(s.done)
+	return s.conn.Close()
+}
+
+// readLoop decodes frames until the connection closes. Frames that can't be
+// decoded are reported on Errors as a *DecodeError.
+func (s *BufferStream) readLoop() {
+	defer close(s.Frames)
+	defer close(s.Errors)
+
+	for {
+		messageType, data, err := s.conn.ReadMessage()
+		if err != nil {
+			select {
+			case <-s.done:
+			default:
+				if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+					s.reportError(fmt.Errorf("read buffers: %w", err))
+				}
+			}
+			return
+		}
+
+		switch messageType {
+		case websocket.TextMessage:
+			s.handleText(data)
+
+		case websocket.BinaryMessage:
+			frame, err := DecodeBufferFrame(data)
+			if err != nil {
+				s.reportError(err)
+				continue
+			}
+
+			select {
+			case s.Frames <- *frame:
+			case <-s.done:
+				return
+			}
+		}
+	}
+}
+
+// handleText answers keep-alive pings and reports server errors
+func (s *BufferStream) handleText(data []byte) {
+	var msg struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		s.reportError(fmt.Errorf("unmarshal message: %w", err))
+		return
+	}
+
+	switch msg.Type {
+	case "ping":
+		if err := s.send(map[string]string{"type": "pong"}); err != nil {
+			s.reportError(err)
+		}
+	case "error":
+		s.reportError(fmt.Errorf("server error: %s", msg.Message))
+	}
+}
+
+func (s *BufferStream) reportError(err error) {
+	select {
+	case s.Errors <- err:
+	case <-s.done:
+	}
+}
+
+// DecodeError reports a /buffers frame that doesn't match the protocol
+type DecodeError struct {
+	SessionID string
+	Err       error
+}
+
+func (e *DecodeError) Error() string {
+	if e.SessionID == "" {
+		return "decode buffer frame: " + e.Err.Error()
+	}
+	return fmt.Sprintf("decode buffer frame for session %s: %v", e.SessionID, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeBufferFrame decodes a binary /buffers frame:
+// [magic byte (1)] [session ID length (4, little endian)] [session ID] [payload]
+func DecodeBufferFrame(data []byte) (*BufferFrame, error) {
+	if len(data) < 5 {
+		return nil, &DecodeError{Err: fmt.Errorf("frame too short: %d bytes", len(data))}
+	}
+	if data[0] != BufferMagicByte {
+		return nil, &DecodeError{Err: fmt.Errorf("invalid magic byte 0x%02x", data[0])}
+	}
+	idLen := binary.LittleEndian.Uint32(data[1:])
+	if uint64(idLen) > uint64(len(data)-5) {
+		return nil, &DecodeError{Err: fmt.Errorf("session ID length %d exceeds frame", idLen)}
+	}
+
+	frame := &BufferFrame{
+		SessionID: string(data[5 : 5+idLen]),
+		Received:  time.Now(),
+		Size:      len(data),
+	}
+	payload := data[5+idLen:]
+
+	if IsSnapshot(payload) {
+		snapshot, err := DecodeSnapshot(payload)
+		if err != nil {
+			return nil, &DecodeError{SessionID: frame.SessionID, Err: fmt.Errorf("snapshot: %w", err)}
+		}
+		frame.Snapshot = snapshot
+		return frame, nil
+	}
+
+	if err := json.Unmarshal(payload, &frame.Event); err != nil {
+		return nil, &DecodeError{SessionID: frame.SessionID, Err: fmt.Errorf("payload: %w", err)}
+	}
+	return frame, nil
+}
@@ -2,10 +2,13 @@ package client
 
 import (
 	"bytes"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -28,18 +31,31 @@ type SessionConfig struct {
 	Env        map[string]string `json:"env"`
 }
 
-// SessionInfo represents session metadata
+// SessionInfo represents session metadata as returned by GET /api/sessions
+// and GET /api/sessions/{id}
 type SessionInfo struct {
-	ID       string    `json:"id"`
-	Name     string    `json:"name"`
-	Status   string    `json:"status"`
-	Created  time.Time `json:"created"`
-	ExitCode *int      `json:"exit_code"`
-	Cmdline  string    `json:"cmdline"`
-	Width    int       `json:"width"`
-	Height   int       `json:"height"`
-	Cwd      string    `json:"cwd"`
-	Term     string    `json:"term"`
+	ID           string            `json:"id"`
+	Name         string            `json:"name"`
+	Command      string            `json:"command"`
+	WorkingDir   string            `json:"workingDir"`
+	Pid          int               `json:"pid"`
+	Status       string            `json:"status"`
+	ExitCode     *int              `json:"exitCode"`
+	StartedAt    time.Time         `json:"startedAt"`
+	LastModified time.Time         `json:"lastModified"`
+	Term         string            `json:"term"`
+	Width        int               `json:"width"`
+	Height       int               `json:"height"`
+	Env          map[string]string `json:"env"`
+	Labels       map[string]string `json:"labels"`
+}
+
+// createResponse is the envelope returned by POST /api/sessions
+type createResponse struct {
+	Success   bool   `json:"success"`
+	Message   string `json:"message"`
+	Error     string `json:"error"`
+	SessionID string `json:"sessionId"`
 }
 
 // AsciinemaEvent represents terminal output events
@@ -66,12 +82,45 @@ func NewClient(hostname string, port int) *VibeTunnelClient {
 	}
 }
 
+// TransportOptions tunes connection reuse between the client and server
+type TransportOptions struct {
+	MaxIdleConnsPerHost int           // Idle connections kept per host; 0 uses Go's default of 2
+	IdleConnTimeout     time.Duration // How long idle connections are kept; 0 keeps them forever
+	DisableKeepAlives   bool          // Open a new connection for every request
+	HTTP2               bool          // Negotiate HTTP/2; only possible with https servers
+}
+
+// NewTransport creates a transport with the given tuning. Share one between
+// clients so they draw from the same connection pool.
+func NewTransport(opts TransportOptions) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = opts.MaxIdleConnsPerHost
+	if opts.MaxIdleConnsPerHost > transport.MaxIdleConns {
+		transport.MaxIdleConns = opts.MaxIdleConnsPerHost
+	}
+	transport.IdleConnTimeout = opts.IdleConnTimeout
+	transport.DisableKeepAlives = opts.DisableKeepAlives
+	transport.ForceAttemptHTTP2 = opts.HTTP2
+	if !opts.HTTP2 {
+		// A non-nil empty map turns HTTP/2 off
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	return transport
+}
+
+// SetTransport replaces the transport used for HTTP requests
+func (c *VibeTunnelClient) SetTransport(transport http.RoundTripper) {
+	c.httpClient.Transport = transport
+}
+
 // SetAuth sets authentication token for requests
 func (c *VibeTunnelClient) SetAuth(token string) {
 	c.authToken = token
 }
 
-// CreateSession creates a new terminal session
+// CreateSession creates a new terminal session. The server only returns
+// the new session's ID; the rest of the result comes from config. Use
+// CreateSessionAndFetch for the session as the server sees it.
 func (c *VibeTunnelClient) CreateSession(config SessionConfig) (*SessionInfo, error) {
 	data, err := json.Marshal(config)
 	if err != nil {
@@ -99,12 +148,45 @@ func (c *VibeTunnelClient) CreateSession(config SessionConfig) (*SessionInfo, er
 		return nil, fmt.Errorf("API error %d: %s", resp.StatusCode, string(body))
 	}
 
-	var session SessionInfo
-	if err := json.NewDecoder(resp.Body).Decode(&session); err != nil {
+	var created createResponse
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
 		return nil, fmt.Errorf("decode response: %w", err)
 	}
+	if !created.Success || created.SessionID == "" {
+		reason := created.Error
+		if reason == "" {
+			reason = created.Message
+		}
+		return nil, fmt.Errorf("session not created: %s", reason)
+	}
+
+	// The response only carries the ID; fill in what was asked for
+	return &SessionInfo{
+		ID:         created.SessionID,
+		Name:       config.Name,
+		Command:    strings.Join(config.Command, " "),
+		WorkingDir: config.WorkingDir,
+		Status:     "starting",
+		Term:       config.Term,
+		Width:      config.Width,
+		Height:     config.Height,
+		Env:        config.Env,
+	}, nil
+}
 
-	return &session, nil
+// CreateSessionAndFetch creates a session and then fetches its full
+// details, such as the PID and the size the server actually used
+func (c *VibeTunnelClient) CreateSessionAndFetch(config SessionConfig) (*SessionInfo, error) {
+	created, err := c.CreateSession(config)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := c.GetSession(created.ID)
+	if err != nil {
+		return created, fmt.Errorf("fetch created session %s: %w", created.ID, err)
+	}
+	return session, nil
 }
 
 // GetSession retrieves session information by ID
@@ -191,7 +273,8 @@ func (c *VibeTunnelClient) SendInput(sessionID, input string) error {
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
+	// Servers answer 200 or 204 No Content
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
 		body, _ := io.ReadAll(resp.Body)
 		return fmt.Errorf("API error %d: %s", resp.StatusCode, string(body))
 	}
@@ -207,9 +290,33 @@ type SSEStream struct {
 	done   chan struct{}
 }
 
+// StreamOptions controls the output replayed when a stream opens
+type StreamOptions struct {
+	FullReplay bool // Replay the whole recording instead of just its end
+	Tail       int  // Replay only the last Tail bytes, if > 0
+}
+
 // StreamSession opens an SSE connection to stream session events
 func (c *VibeTunnelClient) StreamSession(sessionID string) (*SSEStream, error) {
-	req, err := http.NewRequest("GET", c.baseURL+"/api/sessions/"+sessionID+"/stream", nil)
+	return c.StreamSessionWithOptions(sessionID, StreamOptions{})
+}
+
+// StreamSessionWithOptions opens an SSE connection with the given replay
+// options
+func (c *VibeTunnelClient) StreamSessionWithOptions(sessionID string, opts StreamOptions) (*SSEStream, error) {
+	query := url.Values{}
+	if opts.FullReplay {
+		query.Set("full", "true")
+	}
+	if opts.Tail > 0 {
+		query.Set("tail", strconv.Itoa(opts.Tail))
+	}
+	streamURL := c.baseURL + "/api/sessions/" + sessionID + "/stream"
+	if len(query) > 0 {
+		streamURL += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequest("GET", streamURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("create request: %w", err)
 	}
@@ -288,8 +395,8 @@ func (s *SSEStream) readLoop() {
 			if strings.HasPrefix(eventData, "data: ") {
 				jsonData := strings.TrimPrefix(eventData, "data: ")
 
-				var event StreamEvent
-				if err := json.Unmarshal([]byte(jsonData), &event); err != nil {
+				event, err := parseStreamEvent([]byte(jsonData))
+				if err != nil {
 					s.Errors <- fmt.Errorf("unmarshal event: %w", err)
 					continue
 				}
@@ -307,6 +414,50 @@ func (s *SSEStream) readLoop() {
 	}
 }
 
+// parseStreamEvent decodes an SSE event. Servers send the asciinema stream
+// as is: a header object first, then [time, type, data] arrays, and an
+// ["exit", code, session ID] array when the session ends.
+func parseStreamEvent(data []byte) (StreamEvent, error) {
+	var event StreamEvent
+	if len(data) > 0 && data[0] == '[' {
+		var fields []json.RawMessage
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return event, err
+		}
+		if len(fields) != 3 {
+			return event, fmt.Errorf("event has %d fields, want 3", len(fields))
+		}
+
+		// The session ended: ["exit", code, session ID]
+		var kind string
+		if json.Unmarshal(fields[0], &kind) == nil && kind == "exit" {
+			return StreamEvent{Type: "exit"}, nil
+		}
+
+		asciinema := &AsciinemaEvent{}
+		if err := json.Unmarshal(fields[0], &asciinema.Time); err != nil {
+			return event, fmt.Errorf("event time: %w", err)
+		}
+		if err := json.Unmarshal(fields[1], &asciinema.Type); err != nil {
+			return event, fmt.Errorf("event type: %w", err)
+		}
+		if err := json.Unmarshal(fields[2], &asciinema.Data); err != nil {
+			return event, fmt.Errorf("event data: %w", err)
+		}
+		return StreamEvent{Type: "event", Event: asciinema}, nil
+	}
+
+	var header struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(data, &header); err == nil && header.Version > 0 {
+		return StreamEvent{Type: "header"}, nil
+	}
+
+	err := json.Unmarshal(data, &event)
+	return event, err
+}
+
 // DeleteSession deletes a session
 func (c *VibeTunnelClient) DeleteSession(sessionID string) error {
 	req, err := http.NewRequest("DELETE", c.baseURL+"/api/sessions/"+sessionID, nil)
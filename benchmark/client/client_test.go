@@ -0,0 +1,107 @@
+package client
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// newStubClient serves handler and returns a client for it
+func newStubClient(t *testing.T, handler http.Handler) *VibeTunnelClient {
+	t.Helper()
+	ts := httptest.NewServer(handler)
+	t.Cleanup(ts.Close)
+	host, portStr, err := net.SplitHostPort(strings.TrimPrefix(ts.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewClient(host, port)
+}
+
+// stubServer answers session creation and lookup like the server does
+func stubServer(t *testing.T) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/sessions", func(w http.ResponseWriter, r *http.Request) {
+		var config SessionConfig
+		if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+			t.Errorf("invalid create request: %v", err)
+		}
+		if config.Name == "fail" {
+			w.Write([]byte(`{"success":false,"message":"Failed to create session","error":"no PTY"}`))
+			return
+		}
+		w.Write([]byte(`{"success":true,"message":"Session created successfully","sessionId":"5d9e8c0e-6c4f-4d3e-9a53-0a8f3c1c2b11"}`))
+	})
+	mux.HandleFunc("GET /api/sessions/5d9e8c0e-6c4f-4d3e-9a53-0a8f3c1c2b11", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{
+			"id": "5d9e8c0e-6c4f-4d3e-9a53-0a8f3c1c2b11",
+			"name": "bench",
+			"command": "bash -l",
+			"workingDir": "/home/user",
+			"pid": 4242,
+			"status": "running",
+			"startedAt": "2026-10-17T01:00:00Z",
+			"lastModified": "2026-10-17T01:00:05Z",
+			"term": "xterm-256color",
+			"width": 120,
+			"height": 30,
+			"env": {"LANG": "C.UTF-8"},
+			"labels": {"team": "bench"}
+		}`))
+	})
+	return mux
+}
+
+func TestCreateSessionDecodesEnvelope(t *testing.T) {
+	c := newStubClient(t, stubServer(t))
+	session, err := c.CreateSession(SessionConfig{Name: "bench", Command: []string{"bash", "-l"}, Width: 100, Height: 40})
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	if session.ID != "5d9e8c0e-6c4f-4d3e-9a53-0a8f3c1c2b11" {
+		t.Errorf("ID = %q, want the sessionId of the response", session.ID)
+	}
+	if session.Command != "bash -l" || session.Width != 100 || session.Height != 40 {
+		t.Errorf("session = %+v, want the requested command and size", session)
+	}
+}
+
+func TestCreateSessionAndFetch(t *testing.T) {
+	c := newStubClient(t, stubServer(t))
+	session, err := c.CreateSessionAndFetch(SessionConfig{Name: "bench", Command: []string{"bash", "-l"}})
+	if err != nil {
+		t.Fatalf("CreateSessionAndFetch: %v", err)
+	}
+	// The server's values, not the request's
+	if session.Pid != 4242 || session.Width != 120 || session.Height != 30 || session.Status != "running" {
+		t.Errorf("session = %+v, want the fetched pid, size and status", session)
+	}
+	if session.WorkingDir != "/home/user" || session.Term != "xterm-256color" {
+		t.Errorf("workingDir %q, term %q, want the fetched values", session.WorkingDir, session.Term)
+	}
+	if session.Env["LANG"] != "C.UTF-8" || session.Labels["team"] != "bench" {
+		t.Errorf("env %v, labels %v, want the fetched values", session.Env, session.Labels)
+	}
+}
+
+func TestCreateSessionFailures(t *testing.T) {
+	c := newStubClient(t, stubServer(t))
+	if _, err := c.CreateSession(SessionConfig{Name: "fail"}); err == nil || !strings.Contains(err.Error(), "no PTY") {
+		t.Errorf("err = %v, want the server's reason", err)
+	}
+
+	c = newStubClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":"bad request"}`, http.StatusBadRequest)
+	}))
+	if _, err := c.CreateSession(SessionConfig{Name: "bench"}); err == nil || !strings.Contains(err.Error(), "400") {
+		t.Errorf("err = %v, want the status code", err)
+	}
+}
@@ -0,0 +1,201 @@
+package client
+
+import (
+	"encoding/binary"
+	"fmt"
+	"unicode/utf8"
+)
+
+// Binary buffer snapshot format, as encoded by the server's terminal manager
+const (
+	SnapshotMagic      = 0x5654 // "VT", little-endian
+	SnapshotVersion    = 0x01
+	SnapshotHeaderSize = 32
+
+	snapshotEmptyRows = 0xfe
+	snapshotRow       = 0xfd
+)
+
+// BufferCell is a single decoded terminal cell
+type BufferCell struct {
+	Char       string
+	Attributes uint8
+	Fg         *int
+	Bg         *int
+}
+
+// BufferSnapshot is a decoded terminal buffer snapshot
+type BufferSnapshot struct {
+	Cols      uint32
+	Rows      uint32
+	ViewportY int32
+	CursorX   int32
+	CursorY   int32
+	Cells     [][]BufferCell
+}
+
+// IsSnapshot reports whether data starts with the snapshot magic
+func IsSnapshot(data []byte) bool {
+	return len(data) >= 2 && binary.LittleEndian.Uint16(data) == SnapshotMagic
+}
+
+// DecodeSnapshot decodes a binary buffer snapshot, validating its header and
+// that every row and cell lies within the data
+func DecodeSnapshot(data []byte) (*BufferSnapshot, error) {
+	if len(data) < SnapshotHeaderSize {
+		return nil, fmt.Errorf("snapshot too short: %d bytes", len(data))
+	}
+	if magic := binary.LittleEndian.Uint16(data); magic != SnapshotMagic {
+		return nil, fmt.Errorf("invalid snapshot magic 0x%04x", magic)
+	}
+	if version := data[2]; version != SnapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d", version)
+	}
+
+	snapshot := &BufferSnapshot{
+		Cols:      binary.LittleEndian.Uint32(data[4:]),
+		Rows:      binary.LittleEndian.Uint32(data[8:]),
+		ViewportY: int32(binary.LittleEndian.Uint32(data[12:])),
+		CursorX:   int32(binary.LittleEndian.Uint32(data[16:])),
+		CursorY:   int32(binary.LittleEndian.Uint32(data[20:])),
+	}
+
+	d := &snapshotDecoder{data: data, offset: SnapshotHeaderSize}
+	for d.offset < len(data) {
+		marker, err := d.byte()
+		if err != nil {
+			return nil, err
+		}
+
+		switch marker {
+		case snapshotEmptyRows:
+			count, err := d.byte()
+			if err != nil {
+				return nil, err
+			}
+			for i := 0; i < int(count); i++ {
+				snapshot.Cells = append(snapshot.Cells, []BufferCell{{Char: " "}})
+			}
+
+		case snapshotRow:
+			countBytes, err := d.bytes(2)
+			if err != nil {
+				return nil, err
+			}
+			count := int(binary.LittleEndian.Uint16(countBytes))
+			row := make([]BufferCell, 0, count)
+			for i := 0; i < count; i++ {
+				cell, err := d.cell()
+				if err != nil {
+					return nil, fmt.Errorf("row %d cell %d: %w", len(snapshot.Cells), i, err)
+				}
+				row = append(row, cell)
+			}
+			snapshot.Cells = append(snapshot.Cells, row)
+
+		default:
+			return nil, fmt.Errorf("invalid row marker 0x%02x at offset %d", marker, d.offset-1)
+		}
+	}
+
+	if uint32(len(snapshot.Cells)) > snapshot.Rows {
+		return nil, fmt.Errorf("snapshot has %d rows, header says %d", len(snapshot.Cells), snapshot.Rows)
+	}
+	return snapshot, nil
+}
+
+// snapshotDecoder reads snapshot data with bounds checking
+type snapshotDecoder struct {
+	data   []byte
+	offset int
+}
+
+func (d *snapshotDecoder) bytes(n int) ([]byte, error) {
+	if d.offset+n > len(d.data) {
+		return nil, fmt.Errorf("unexpected end of snapshot at offset %d", d.offset)
+	}
+	b := d.data[d.offset : d.offset+n]
+	d.offset += n
+	return b, nil
+}
+
+func (d *snapshotDecoder) byte() (byte, error) {
+	b, err := d.bytes(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+// cell decodes one cell. The type byte holds, from the high bit: extended
+// data, Unicode, has fg, has bg, RGB fg, RGB bg, then a 2-bit char type
+// (0 space, 1 ASCII, 2 Unicode).
+func (d *snapshotDecoder) cell() (BufferCell, error) {
+	typeByte, err := d.byte()
+	if err != nil {
+		return BufferCell{}, err
+	}
+	if typeByte == 0x00 {
+		return BufferCell{Char: " "}, nil
+	}
+
+	var cell BufferCell
+	switch {
+	case typeByte&0x03 == 0x00:
+		cell.Char = " "
+	case typeByte&0x40 != 0:
+		n, err := d.byte()
+		if err != nil {
+			return cell, err
+		}
+		b, err := d.bytes(int(n))
+		if err != nil {
+			return cell, err
+		}
+		if !utf8.Valid(b) {
+			return cell, fmt.Errorf("invalid UTF-8 character % x", b)
+		}
+		cell.Char = string(b)
+	default:
+		b, err := d.byte()
+		if err != nil {
+			return cell, err
+		}
+		cell.Char = string(rune(b))
+	}
+
+	if typeByte&0x80 == 0 {
+		return cell, nil
+	}
+	if cell.Attributes, err = d.byte(); err != nil {
+		return cell, err
+	}
+	if typeByte&0x20 != 0 {
+		if cell.Fg, err = d.color(typeByte&0x08 != 0); err != nil {
+			return cell, err
+		}
+	}
+	if typeByte&0x10 != 0 {
+		if cell.Bg, err = d.color(typeByte&0x04 != 0); err != nil {
+			return cell, err
+		}
+	}
+	return cell, nil
+}
+
+// color decodes a palette index or a 24-bit RGB color
+func (d *snapshotDecoder) color(rgb bool) (*int, error) {
+	n := 1
+	if rgb {
+		n = 3
+	}
+	b, err := d.bytes(n)
+	if err != nil {
+		return nil, err
+	}
+	c := int(b[0])
+	if rgb {
+		c = int(b[0])<<16 | int(b[1])<<8 | int(b[2])
+	}
+	return &c, nil
+}
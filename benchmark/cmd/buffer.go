@@ -0,0 +1,294 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vibetunnel/benchmark/client"
+)
+
+var bufferCmd = &cobra.Command{
+	Use:   "buffer",
+	Short: "Benchmark binary WebSocket buffer streaming",
+	Long: `Test the /buffers WebSocket: subscribe to sessions, measure time to the
+first frame, frames and bytes per second, and decode every frame (including
+binary 0x5654 snapshots) to check the protocol.`,
+	RunE: runBufferBenchmark,
+}
+
+var (
+	bufferSessions   int
+	bufferDuration   time.Duration
+	bufferCommands   []string
+	bufferInputDelay time.Duration
+)
+
+func init() {
+	rootCmd.AddCommand(bufferCmd)
+
+	bufferCmd.Flags().IntVarP(&bufferSessions, "sessions", "s", 3, "Number of sessions to subscribe to")
+	bufferCmd.Flags().DurationVarP(&bufferDuration, "duration", "d", 30*time.Second, "Benchmark duration")
+	bufferCmd.Flags().StringSliceVar(&bufferCommands, "commands", []string{"echo hello", "ls -la", "date"}, "Commands to execute")
+	bufferCmd.Flags().DurationVar(&bufferInputDelay, "input-delay", 2*time.Second, "Delay between command inputs")
+}
+
+func runBufferBenchmark(cmd *cobra.Command, args []string) error {
+	client := client.NewClient(hostname, port)
+
+	fmt.Printf("🚀 VibeTunnel Buffer WebSocket Benchmark\n")
+	fmt.Printf("Target: %s:%d\n", hostname, port)
+	fmt.Printf("Sessions: %d\n", bufferSessions)
+	fmt.Printf("Duration: %v\n\n", bufferDuration)
+
+	// Test connectivity
+	fmt.Print("Testing connectivity... ")
+	if err := client.Ping(); err != nil {
+		return fmt.Errorf("server connectivity failed: %w", err)
+	}
+	fmt.Println("✅ Connected")
+
+	fmt.Printf("\n📊 Concurrent Buffer Stream Benchmark\n")
+
+	var wg sync.WaitGroup
+	results := make(chan *BufferResult, bufferSessions)
+	startTime := time.Now()
+
+	for i := 0; i < bufferSessions; i++ {
+		wg.Add(1)
+		go func(sessionNum int) {
+			defer wg.Done()
+			results <- benchmarkSingleBuffer(client, sessionNum, bufferDuration, bufferCommands, bufferInputDelay)
+		}(i)
+	}
+
+	wg.Wait()
+	close(results)
+
+	totalDuration := time.Since(startTime)
+
+	var allResults []*BufferResult
+	for result := range results {
+		allResults = append(allResults, result)
+	}
+
+	return analyzeBufferResults(allResults, totalDuration)
+}
+
+type BufferResult struct {
+	SessionNum     int
+	SessionID      string
+	FramesReceived int
+	SnapshotFrames int
+	BytesReceived  int64
+	FirstFrameTime time.Duration // From subscribing to the first frame
+	StreamDuration time.Duration // From subscribing to the end of the run
+	TotalDuration  time.Duration
+	DecodeErrors   int
+	Errors         []error
+}
+
+// benchmarkSingleBuffer creates a session, subscribes to it over its own
+// /buffers connection and decodes frames for the given duration while
+// sending commands to generate output
+func benchmarkSingleBuffer(c *client.VibeTunnelClient, sessionNum int, duration time.Duration, commands []string, inputDelay time.Duration) *BufferResult {
+	result := &BufferResult{SessionNum: sessionNum}
+	startTime := time.Now()
+
+	config := client.SessionConfig{
+		Name:       fmt.Sprintf("buffer-bench-%d", sessionNum),
+		Command:    []string{"/bin/bash", "-i"},
+		WorkingDir: "/tmp",
+		Width:      80,
+		Height:     24,
+		Term:       "xterm-256color",
+		Env:        map[string]string{"BENCH": "true"},
+	}
+
+	session, err := c.CreateSession(config)
+	if err != nil {
+		result.Errors = append(result.Errors, fmt.Errorf("create session: %w", err))
+		return result
+	}
+
+	result.SessionID = session.ID
+	defer c.DeleteSession(session.ID)
+
+	if verbose {
+		fmt.Printf("  Session %d: Created %s\n", sessionNum+1, session.ID)
+	}
+
+	stream, err := c.ConnectBuffers()
+	if err != nil {
+		result.Errors = append(result.Errors, fmt.Errorf("connect buffers: %w", err))
+		return result
+	}
+	defer stream.Close()
+
+	subscribed := time.Now()
+	if err := stream.Subscribe(session.ID); err != nil {
+		result.Errors = append(result.Errors, fmt.Errorf("subscribe: %w", err))
+		return result
+	}
+
+	// Send commands to generate output
+	var inputErrs []error
+	var inputMu sync.Mutex
+	go func() {
+		time.Sleep(500 * time.Millisecond) // Wait for the subscription to start
+
+		for i, command := range commands {
+			if err := c.SendInput(session.ID, command+"\n"); err != nil {
+				inputMu.Lock()
+				inputErrs = append(inputErrs, fmt.Errorf("send command %d: %w", i, err))
+				inputMu.Unlock()
+				continue
+			}
+
+			if i < len(commands)-1 {
+				time.Sleep(inputDelay)
+			}
+		}
+	}()
+
+	timeout := time.NewTimer(duration)
+	defer timeout.Stop()
+
+	finish := func() *BufferResult {
+		result.StreamDuration = time.Since(subscribed)
+		result.TotalDuration = time.Since(startTime)
+		inputMu.Lock()
+		result.Errors = append(result.Errors, inputErrs...)
+		inputMu.Unlock()
+		return result
+	}
+
+	for {
+		select {
+		case frame, ok := <-stream.Frames:
+			if !ok {
+				return finish()
+			}
+			if frame.SessionID != session.ID {
+				result.DecodeErrors++
+				result.Errors = append(result.Errors, fmt.Errorf("frame for unexpected session %q", frame.SessionID))
+				continue
+			}
+
+			result.FramesReceived++
+			result.BytesReceived += int64(frame.Size)
+			if frame.Snapshot != nil {
+				result.SnapshotFrames++
+			}
+			if result.FramesReceived == 1 {
+				result.FirstFrameTime = frame.Received.Sub(subscribed)
+				if verbose {
+					fmt.Printf("  Session %d: First frame after %.1fms\n",
+						sessionNum+1, float64(result.FirstFrameTime.Nanoseconds())/1e6)
+				}
+			}
+
+		case err, ok := <-stream.Errors:
+			if !ok {
+				return finish()
+			}
+			var decodeErr *client.DecodeError
+			if errors.As(err, &decodeErr) {
+				result.DecodeErrors++
+			}
+			result.Errors = append(result.Errors, err)
+
+		case <-timeout.C:
+			return finish()
+		}
+	}
+}
+
+func analyzeBufferResults(results []*BufferResult, totalDuration time.Duration) error {
+	fmt.Printf("\n📈 Buffer Stream Performance Statistics\n")
+	fmt.Printf("Total Duration: %.2fs\n", totalDuration.Seconds())
+
+	var (
+		totalFrames     int
+		totalSnapshots  int
+		totalBytes      int64
+		totalErrors     int
+		totalDecodeErrs int
+		streamTime      time.Duration
+		avgFirstFrame   time.Duration
+		firstFrames     []time.Duration
+	)
+
+	successfulSessions := 0
+
+	for _, result := range results {
+		totalFrames += result.FramesReceived
+		totalSnapshots += result.SnapshotFrames
+		totalBytes += result.BytesReceived
+		totalErrors += len(result.Errors)
+		totalDecodeErrs += result.DecodeErrors
+		streamTime += result.StreamDuration
+
+		if len(result.Errors) == 0 && result.FramesReceived > 0 {
+			successfulSessions++
+			avgFirstFrame += result.FirstFrameTime
+			firstFrames = append(firstFrames, result.FirstFrameTime)
+		}
+
+		if verbose {
+			fmt.Printf("\nSession %d (%s):\n", result.SessionNum+1, result.SessionID)
+			fmt.Printf("  Frames: %d (%d snapshots)\n", result.FramesReceived, result.SnapshotFrames)
+			fmt.Printf("  Bytes: %d\n", result.BytesReceived)
+			fmt.Printf("  First Frame: %.1fms\n", float64(result.FirstFrameTime.Nanoseconds())/1e6)
+			fmt.Printf("  Duration: %.2fs\n", result.TotalDuration.Seconds())
+			fmt.Printf("  Errors: %d\n", len(result.Errors))
+
+			for i, err := range result.Errors {
+				fmt.Printf("    Error %d: %v\n", i+1, err)
+			}
+		}
+	}
+
+	if successfulSessions > 0 {
+		avgFirstFrame /= time.Duration(successfulSessions)
+	}
+
+	fmt.Printf("\nOverall Results:\n")
+	fmt.Printf("  Sessions: %d total, %d successful\n", len(results), successfulSessions)
+	fmt.Printf("  Frames: %d total, %d snapshots\n", totalFrames, totalSnapshots)
+	fmt.Printf("  Data: %.2f KB\n", float64(totalBytes)/1024)
+	fmt.Printf("  Decode Errors: %d\n", totalDecodeErrs)
+	fmt.Printf("  Errors: %d\n", totalErrors)
+
+	if successfulSessions > 0 {
+		fmt.Printf("\nLatency (average):\n")
+		fmt.Printf("  First Frame: %.1fms\n", float64(avgFirstFrame.Nanoseconds())/1e6)
+
+		fmt.Printf("\nFirst Frame Latency:\n")
+		printPercentiles(firstFrames)
+
+		if histogram {
+			fmt.Printf("\nFirst Frame Distribution:\n")
+			printHistogram(firstFrames)
+		}
+
+		// Rates are per session so concurrent sessions don't inflate them
+		fmt.Printf("\nThroughput (per session):\n")
+		fmt.Printf("  Frames/sec: %.1f\n", float64(totalFrames)/streamTime.Seconds())
+		fmt.Printf("  KB/sec: %.2f\n", float64(totalBytes)/1024/streamTime.Seconds())
+		fmt.Printf("  Success Rate: %.1f%%\n", float64(successfulSessions)/float64(len(results))*100)
+	}
+
+	if totalDecodeErrs > 0 {
+		return fmt.Errorf("%d buffer frames failed to decode", totalDecodeErrs)
+	}
+	if totalErrors > 0 {
+		fmt.Printf("\n⚠️  %d errors encountered during benchmark\n", totalErrors)
+	} else {
+		fmt.Printf("\n✅ All buffer streams completed successfully\n")
+	}
+
+	return nil
+}
@@ -29,7 +29,7 @@ func init() {
 	compareCmd.Flags().IntVar(&goPort, "go-port", 4031, "Go server port")
 	compareCmd.Flags().IntVar(&rustPort, "rust-port", 4044, "Rust server port")
 	compareCmd.Flags().IntVarP(&runs, "runs", "r", 10, "Number of test runs (10-1000)")
-	compareCmd.Flags().StringVarP(&testType, "test", "t", "session", "Test type: session, stream, or both")
+	compareCmd.Flags().StringVarP(&testType, "test", "t", "session", "Test type: session, stream, buffer, both (session and stream), or all")
 }
 
 type BenchmarkResult struct {
@@ -40,6 +40,10 @@ type BenchmarkResult struct {
 	AvgLatency    time.Duration
 	MinLatency    time.Duration
 	MaxLatency    time.Duration
+	P50Latency    time.Duration
+	P95Latency    time.Duration
+	P99Latency    time.Duration
+	Latencies     []time.Duration
 	Throughput    float64
 	SuccessRate   float64
 	ErrorCount    int
@@ -49,6 +53,11 @@ func runCompareBenchmark(cmd *cobra.Command, args []string) error {
 	if runs < 10 || runs > 1000 {
 		return fmt.Errorf("runs must be between 10 and 1000")
 	}
+	switch testType {
+	case "session", "stream", "buffer", "both", "all":
+	default:
+		return fmt.Errorf("unknown test type %q", testType)
+	}
 
 	fmt.Printf("🚀 VibeTunnel Server Comparison Benchmark\n")
 	fmt.Printf("==========================================\n")
@@ -66,7 +75,7 @@ func runCompareBenchmark(cmd *cobra.Command, args []string) error {
 	if err := goClient.Ping(); err != nil {
 		fmt.Printf("❌ Go server not accessible: %v\n\n", err)
 	} else {
-		if testType == "session" || testType == "both" {
+		if testType == "session" || testType == "both" || testType == "all" {
 			result, err := runSessionBenchmarkRuns(goClient, "Go", runs)
 			if err != nil {
 				fmt.Printf("❌ Go session benchmark failed: %v\n", err)
@@ -75,7 +84,7 @@ func runCompareBenchmark(cmd *cobra.Command, args []string) error {
 			}
 		}
 
-		if testType == "stream" || testType == "both" {
+		if testType == "stream" || testType == "both" || testType == "all" {
 			result, err := runStreamBenchmarkRuns(goClient, "Go", runs)
 			if err != nil {
 				fmt.Printf("❌ Go stream benchmark failed: %v\n", err)
@@ -83,6 +92,15 @@ func runCompareBenchmark(cmd *cobra.Command, args []string) error {
 				goResults = append(goResults, result)
 			}
 		}
+
+		if testType == "buffer" || testType == "all" {
+			result, err := runBufferBenchmarkRuns(goClient, "Go", runs)
+			if err != nil {
+				fmt.Printf("❌ Go buffer benchmark failed: %v\n", err)
+			} else {
+				goResults = append(goResults, result)
+			}
+		}
 	}
 
 	fmt.Printf("\n📊 Testing Rust Server (port %d)\n", rustPort)
@@ -92,7 +110,7 @@ func runCompareBenchmark(cmd *cobra.Command, args []string) error {
 	if err := rustClient.Ping(); err != nil {
 		fmt.Printf("❌ Rust server not accessible: %v\n\n", err)
 	} else {
-		if testType == "session" || testType == "both" {
+		if testType == "session" || testType == "both" || testType == "all" {
 			result, err := runSessionBenchmarkRuns(rustClient, "Rust", runs)
 			if err != nil {
 				fmt.Printf("❌ Rust session benchmark failed: %v\n", err)
@@ -101,7 +119,7 @@ func runCompareBenchmark(cmd *cobra.Command, args []string) error {
 			}
 		}
 
-		if testType == "stream" || testType == "both" {
+		if testType == "stream" || testType == "both" || testType == "all" {
 			result, err := runStreamBenchmarkRuns(rustClient, "Rust", runs)
 			if err != nil {
 				fmt.Printf("❌ Rust stream benchmark failed: %v\n", err)
@@ -109,6 +127,15 @@ func runCompareBenchmark(cmd *cobra.Command, args []string) error {
 				rustResults = append(rustResults, result)
 			}
 		}
+
+		if testType == "buffer" || testType == "all" {
+			result, err := runBufferBenchmarkRuns(rustClient, "Rust", runs)
+			if err != nil {
+				fmt.Printf("❌ Rust buffer benchmark failed: %v\n", err)
+			} else {
+				rustResults = append(rustResults, result)
+			}
+		}
 	}
 
 	// Display comparison
@@ -207,6 +234,8 @@ func runSessionBenchmarkRuns(c *client.VibeTunnelClient, serverType string, numR
 
 	fmt.Printf("✅ Completed %d/%d runs (%.1f%% success rate)\n", len(totalLatencies), numRuns, successRate)
 
+	sorted := sortedDurations(totalLatencies)
+
 	return BenchmarkResult{
 		ServerType:    serverType,
 		TestType:      "session",
@@ -215,6 +244,10 @@ func runSessionBenchmarkRuns(c *client.VibeTunnelClient, serverType string, numR
 		AvgLatency:    avgLatency,
 		MinLatency:    min,
 		MaxLatency:    max,
+		P50Latency:    percentile(sorted, 50),
+		P95Latency:    percentile(sorted, 95),
+		P99Latency:    percentile(sorted, 99),
+		Latencies:     totalLatencies,
 		Throughput:    throughput,
 		SuccessRate:   successRate,
 		ErrorCount:    errors,
@@ -330,6 +363,8 @@ func runStreamBenchmarkRuns(c *client.VibeTunnelClient, serverType string, numRu
 
 	fmt.Printf("✅ Completed %d/%d stream runs (%.1f%% success rate)\n", len(totalLatencies), numRuns, successRate)
 
+	sorted := sortedDurations(totalLatencies)
+
 	return BenchmarkResult{
 		ServerType:    serverType,
 		TestType:      "stream",
@@ -338,6 +373,99 @@ func runStreamBenchmarkRuns(c *client.VibeTunnelClient, serverType string, numRu
 		AvgLatency:    avgLatency,
 		MinLatency:    min,
 		MaxLatency:    max,
+		P50Latency:    percentile(sorted, 50),
+		P95Latency:    percentile(sorted, 95),
+		P99Latency:    percentile(sorted, 99),
+		Latencies:     totalLatencies,
+		Throughput:    throughput,
+		SuccessRate:   successRate,
+		ErrorCount:    errors,
+	}, nil
+}
+
+// runBufferBenchmarkRuns subscribes to a fresh session over /buffers on each
+// run. Latency is the time to the first frame and throughput is frames per
+// second; a run with decode errors or no frames fails.
+func runBufferBenchmarkRuns(c *client.VibeTunnelClient, serverType string, numRuns int) (BenchmarkResult, error) {
+	fmt.Printf("Running %d buffer stream tests...\n", numRuns)
+
+	var firstFrameLatencies []time.Duration
+	var errors, frames int
+	var streamTime time.Duration
+	startTime := time.Now()
+
+	for run := 1; run <= numRuns; run++ {
+		if verbose {
+			fmt.Printf("  Buffer run %d/%d... ", run, numRuns)
+		}
+
+		result := benchmarkSingleBuffer(c, run, 2*time.Second, []string{"echo hello"}, 0)
+		frames += result.FramesReceived
+		streamTime += result.StreamDuration
+
+		if len(result.Errors) > 0 || result.FramesReceived == 0 {
+			errors += len(result.Errors)
+			if verbose {
+				if len(result.Errors) > 0 {
+					fmt.Printf("❌ %v\n", result.Errors[0])
+				} else {
+					fmt.Printf("❌ No frames received\n")
+				}
+			}
+			continue
+		}
+		firstFrameLatencies = append(firstFrameLatencies, result.FirstFrameTime)
+
+		if verbose {
+			fmt.Printf("✅ %d frames, first after %.2fms\n", result.FramesReceived, float64(result.FirstFrameTime.Nanoseconds())/1e6)
+		}
+	}
+
+	totalDuration := time.Since(startTime)
+
+	// Calculate statistics
+	var min, max, total time.Duration
+	if len(firstFrameLatencies) > 0 {
+		min = firstFrameLatencies[0]
+		max = firstFrameLatencies[0]
+		for _, lat := range firstFrameLatencies {
+			total += lat
+			if lat < min {
+				min = lat
+			}
+			if lat > max {
+				max = lat
+			}
+		}
+	}
+
+	var avgLatency time.Duration
+	if len(firstFrameLatencies) > 0 {
+		avgLatency = total / time.Duration(len(firstFrameLatencies))
+	}
+
+	successRate := float64(len(firstFrameLatencies)) / float64(numRuns) * 100
+	var throughput float64
+	if streamTime > 0 {
+		throughput = float64(frames) / streamTime.Seconds()
+	}
+
+	fmt.Printf("✅ Completed %d/%d buffer runs (%.1f%% success rate)\n", len(firstFrameLatencies), numRuns, successRate)
+
+	sorted := sortedDurations(firstFrameLatencies)
+
+	return BenchmarkResult{
+		ServerType:    serverType,
+		TestType:      "buffer",
+		Runs:          numRuns,
+		TotalDuration: totalDuration,
+		AvgLatency:    avgLatency,
+		MinLatency:    min,
+		MaxLatency:    max,
+		P50Latency:    percentile(sorted, 50),
+		P95Latency:    percentile(sorted, 95),
+		P99Latency:    percentile(sorted, 99),
+		Latencies:     firstFrameLatencies,
 		Throughput:    throughput,
 		SuccessRate:   successRate,
 		ErrorCount:    errors,
@@ -350,35 +478,48 @@ func displayComparison(goResults, rustResults []BenchmarkResult) {
 		return
 	}
 
-	fmt.Printf("%-12s %-8s %-6s %-12s %-12s %-12s %-10s %-8s\n",
-		"Server", "Test", "Runs", "Avg Latency", "Min Latency", "Max Latency", "Throughput", "Success%")
-	fmt.Printf("%-12s %-8s %-6s %-12s %-12s %-12s %-10s %-8s\n",
-		"------", "----", "----", "-----------", "-----------", "-----------", "----------", "--------")
+	fmt.Printf("%-12s %-8s %-6s %-12s %-12s %-12s %-12s %-12s %-12s %-10s %-8s\n",
+		"Server", "Test", "Runs", "Avg Latency", "Min Latency", "Max Latency", "p50", "p95", "p99", "Throughput", "Success%")
+	fmt.Printf("%-12s %-8s %-6s %-12s %-12s %-12s %-12s %-12s %-12s %-10s %-8s\n",
+		"------", "----", "----", "-----------", "-----------", "-----------", "---", "---", "---", "----------", "--------")
 
 	for _, result := range goResults {
-		fmt.Printf("%-12s %-8s %-6d %-12s %-12s %-12s %-10.1f %-8.1f\n",
+		fmt.Printf("%-12s %-8s %-6d %-12s %-12s %-12s %-12s %-12s %-12s %-10.1f %-8.1f\n",
 			result.ServerType,
 			result.TestType,
 			result.Runs,
 			formatDuration(result.AvgLatency),
 			formatDuration(result.MinLatency),
 			formatDuration(result.MaxLatency),
+			formatDuration(result.P50Latency),
+			formatDuration(result.P95Latency),
+			formatDuration(result.P99Latency),
 			result.Throughput,
 			result.SuccessRate)
 	}
 
 	for _, result := range rustResults {
-		fmt.Printf("%-12s %-8s %-6d %-12s %-12s %-12s %-10.1f %-8.1f\n",
+		fmt.Printf("%-12s %-8s %-6d %-12s %-12s %-12s %-12s %-12s %-12s %-10.1f %-8.1f\n",
 			result.ServerType,
 			result.TestType,
 			result.Runs,
 			formatDuration(result.AvgLatency),
 			formatDuration(result.MinLatency),
 			formatDuration(result.MaxLatency),
+			formatDuration(result.P50Latency),
+			formatDuration(result.P95Latency),
+			formatDuration(result.P99Latency),
 			result.Throughput,
 			result.SuccessRate)
 	}
 
+	if histogram {
+		for _, result := range append(append([]BenchmarkResult(nil), goResults...), rustResults...) {
+			fmt.Printf("\n%s %s latency distribution:\n", result.ServerType, result.TestType)
+			printHistogram(result.Latencies)
+		}
+	}
+
 	// Show winner analysis
 	fmt.Printf("\n🏆 Performance Analysis:\n")
 	analyzeResults(goResults, rustResults)
@@ -406,6 +547,17 @@ func analyzeResults(goResults, rustResults []BenchmarkResult) {
 			fmt.Printf("  🤝 Similar average latency\n")
 		}
 
+		// Compare tail latency
+		if goResult.P99Latency < rustResult.P99Latency {
+			improvement := float64(rustResult.P99Latency-goResult.P99Latency) / float64(rustResult.P99Latency) * 100
+			fmt.Printf("  🥇 Go is %.1f%% faster (p99 latency)\n", improvement)
+		} else if rustResult.P99Latency < goResult.P99Latency {
+			improvement := float64(goResult.P99Latency-rustResult.P99Latency) / float64(goResult.P99Latency) * 100
+			fmt.Printf("  🥇 Rust is %.1f%% faster (p99 latency)\n", improvement)
+		} else {
+			fmt.Printf("  🤝 Similar p99 latency\n")
+		}
+
 		// Compare throughput
 		if goResult.Throughput > rustResult.Throughput {
 			improvement := (goResult.Throughput - rustResult.Throughput) / rustResult.Throughput * 100
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// histogramBuckets are the upper bounds of the latency histogram buckets;
+// anything slower falls in a final open-ended bucket
+var histogramBuckets = []time.Duration{
+	1 * time.Millisecond,
+	2 * time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	20 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	200 * time.Millisecond,
+	500 * time.Millisecond,
+	1 * time.Second,
+}
+
+// histogramWidth is the length of the longest histogram bar
+const histogramWidth = 40
+
+// sortedDurations returns a sorted copy of latencies
+func sortedDurations(latencies []time.Duration) []time.Duration {
+	sorted := append([]time.Duration(nil), latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted
+}
+
+// percentile returns the nearest-rank p-th percentile of sorted latencies
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// printPercentiles prints the p50/p95/p99 of latencies
+func printPercentiles(latencies []time.Duration) {
+	sorted := sortedDurations(latencies)
+	fmt.Printf("  p50: %.2fms\n", float64(percentile(sorted, 50).Nanoseconds())/1e6)
+	fmt.Printf("  p95: %.2fms\n", float64(percentile(sorted, 95).Nanoseconds())/1e6)
+	fmt.Printf("  p99: %.2fms\n", float64(percentile(sorted, 99).Nanoseconds())/1e6)
+}
+
+// printHistogram prints the distribution of latencies over histogramBuckets
+func printHistogram(latencies []time.Duration) {
+	if len(latencies) == 0 {
+		return
+	}
+
+	counts := make([]int, len(histogramBuckets)+1)
+	for _, lat := range latencies {
+		i := sort.Search(len(histogramBuckets), func(i int) bool { return lat <= histogramBuckets[i] })
+		counts[i]++
+	}
+
+	maxCount := 0
+	for _, count := range counts {
+		if count > maxCount {
+			maxCount = count
+		}
+	}
+
+	for i, count := range counts {
+		var label string
+		if i < len(histogramBuckets) {
+			label = "≤ " + formatBucket(histogramBuckets[i])
+		} else {
+			label = "> " + formatBucket(histogramBuckets[len(histogramBuckets)-1])
+		}
+		bar := strings.Repeat("█", int(math.Ceil(float64(count)/float64(maxCount)*histogramWidth)))
+		fmt.Printf("  %8s | %-*s %d (%.1f%%)\n", label, histogramWidth, bar, count, float64(count)/float64(len(latencies))*100)
+	}
+}
+
+func formatBucket(d time.Duration) string {
+	if d >= time.Second {
+		return fmt.Sprintf("%.0fs", d.Seconds())
+	}
+	return fmt.Sprintf("%dms", d.Milliseconds())
+}
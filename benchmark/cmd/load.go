@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"net/http"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -23,6 +24,11 @@ var (
 	loadDuration   time.Duration
 	loadRampUp     time.Duration
 	loadOperations []string
+
+	loadMaxIdlePerHost    int
+	loadIdleConnTimeout   time.Duration
+	loadDisableKeepAlives bool
+	loadHTTP2             bool
 )
 
 func init() {
@@ -32,17 +38,49 @@ func init() {
 	loadCmd.Flags().DurationVarP(&loadDuration, "duration", "d", 60*time.Second, "Load test duration")
 	loadCmd.Flags().DurationVar(&loadRampUp, "ramp-up", 10*time.Second, "Ramp-up period to reach full load")
 	loadCmd.Flags().StringSliceVar(&loadOperations, "operations", []string{"session", "stream"}, "Operations to test (session, stream, both)")
+	loadCmd.Flags().IntVar(&loadMaxIdlePerHost, "max-idle-conns-per-host", 0, "Idle connections kept for reuse; 0 keeps one per concurrent user")
+	loadCmd.Flags().DurationVar(&loadIdleConnTimeout, "idle-conn-timeout", 90*time.Second, "How long idle connections are kept for reuse")
+	loadCmd.Flags().BoolVar(&loadDisableKeepAlives, "disable-keep-alives", false, "Open a new connection for every request")
+	loadCmd.Flags().BoolVar(&loadHTTP2, "http2", false, "Negotiate HTTP/2 (https servers only)")
+}
+
+// loadTransport is shared by all simulated users so they draw from one
+// connection pool, as a browser's tabs would
+var loadTransport *http.Transport
+
+// newLoadClient creates a client using the shared load test transport
+func newLoadClient() *client.VibeTunnelClient {
+	c := client.NewClient(hostname, port)
+	c.SetTransport(loadTransport)
+	return c
 }
 
 func runLoadBenchmark(cmd *cobra.Command, args []string) error {
-	client := client.NewClient(hostname, port)
+	maxIdle := loadMaxIdlePerHost
+	if maxIdle == 0 {
+		maxIdle = loadConcurrent
+	}
+	loadTransport = client.NewTransport(client.TransportOptions{
+		MaxIdleConnsPerHost: maxIdle,
+		IdleConnTimeout:     loadIdleConnTimeout,
+		DisableKeepAlives:   loadDisableKeepAlives,
+		HTTP2:               loadHTTP2,
+	})
+	defer loadTransport.CloseIdleConnections()
+
+	client := newLoadClient()
 
 	fmt.Printf("🚀 VibeTunnel Concurrent Load Benchmark\n")
 	fmt.Printf("Target: %s:%d\n", hostname, port)
 	fmt.Printf("Concurrent Users: %d\n", loadConcurrent)
 	fmt.Printf("Duration: %v\n", loadDuration)
 	fmt.Printf("Ramp-up: %v\n", loadRampUp)
-	fmt.Printf("Operations: %v\n\n", loadOperations)
+	fmt.Printf("Operations: %v\n", loadOperations)
+	if loadDisableKeepAlives {
+		fmt.Printf("Connections: new per request\n\n")
+	} else {
+		fmt.Printf("Connections: %d idle per host, %v idle timeout\n\n", maxIdle, loadIdleConnTimeout)
+	}
 
 	// Test connectivity
 	fmt.Print("Testing connectivity... ")
@@ -123,7 +161,7 @@ func runConcurrentLoad(c *client.VibeTunnelClient) error {
 func simulateUser(c *client.VibeTunnelClient, userID int, stats *LoadStats, wg *sync.WaitGroup, stopChan chan struct{}) {
 	defer wg.Done()
 
-	userClient := client.NewClient(hostname, port)
+	userClient := newLoadClient()
 	var sessions []string
 
 	for {
@@ -311,6 +349,12 @@ func printFinalStats(stats *LoadStats, totalDuration time.Duration) error {
 		fmt.Printf("  Average: %.2fms\n", float64(avg.Nanoseconds())/1e6)
 		fmt.Printf("  Min: %.2fms\n", float64(min.Nanoseconds())/1e6)
 		fmt.Printf("  Max: %.2fms\n", float64(max.Nanoseconds())/1e6)
+		printPercentiles(responseTimes)
+
+		if histogram {
+			fmt.Printf("\nResponse Time Distribution:\n")
+			printHistogram(responseTimes)
+		}
 	}
 
 	fmt.Printf("\nThroughput:\n")
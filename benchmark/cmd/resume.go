@@ -0,0 +1,259 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vibetunnel/benchmark/client"
+)
+
+var resumeCmd = &cobra.Command{
+	Use:   "resume",
+	Short: "Benchmark how quickly a reconnecting client catches up",
+	Long: `Fill a session with a known amount of output, then repeatedly open a fresh
+stream and measure the time to the first event and to being fully caught up.
+Compares replaying the whole recording with the default replay, which starts
+at the last clear screen within the server's replay limit.`,
+	RunE: runResumeBenchmark,
+}
+
+var (
+	resumePrefillBytes int
+	resumeRuns         int
+	resumeTimeout      time.Duration
+)
+
+func init() {
+	rootCmd.AddCommand(resumeCmd)
+
+	resumeCmd.Flags().IntVar(&resumePrefillBytes, "prefill-bytes", 4*1024*1024, "Bytes of output to fill the session with before reconnecting")
+	resumeCmd.Flags().IntVarP(&resumeRuns, "runs", "r", 5, "Reconnects per replay mode")
+	resumeCmd.Flags().DurationVar(&resumeTimeout, "timeout", 60*time.Second, "Maximum time to wait for the prefill or a catch-up")
+}
+
+// replayMode is a way of opening the stream whose catch-up is measured
+type replayMode struct {
+	Name    string
+	Options client.StreamOptions
+}
+
+var replayModes = []replayMode{
+	{Name: "full", Options: client.StreamOptions{FullReplay: true}},
+	{Name: "tail", Options: client.StreamOptions{}},
+}
+
+type ResumeResult struct {
+	Mode           string
+	FirstEvent     []time.Duration
+	CaughtUp       []time.Duration
+	BytesReplayed  []int64
+	EventsReplayed []int
+	Errors         []error
+}
+
+func runResumeBenchmark(cmd *cobra.Command, args []string) error {
+	c := client.NewClient(hostname, port)
+
+	fmt.Printf("🚀 VibeTunnel Reconnect Catch-up Benchmark\n")
+	fmt.Printf("Target: %s:%d\n", hostname, port)
+	fmt.Printf("Prefill: %.2f KB\n", float64(resumePrefillBytes)/1024)
+	fmt.Printf("Runs: %d per mode\n\n", resumeRuns)
+
+	// Test connectivity
+	fmt.Print("Testing connectivity... ")
+	if err := c.Ping(); err != nil {
+		return fmt.Errorf("server connectivity failed: %w", err)
+	}
+	fmt.Println("✅ Connected")
+
+	config := client.SessionConfig{
+		Name:       "resume-bench",
+		Command:    []string{"/bin/bash", "-i"},
+		WorkingDir: "/tmp",
+		Width:      80,
+		Height:     24,
+		Term:       "xterm-256color",
+		Env:        map[string]string{"BENCH": "true"},
+	}
+
+	session, err := c.CreateSession(config)
+	if err != nil {
+		return fmt.Errorf("create session: %w", err)
+	}
+	defer c.DeleteSession(session.ID)
+
+	fmt.Printf("\n📊 Filling session with output... ")
+	marker := fmt.Sprintf("vtbench-done-%d", time.Now().UnixNano())
+	start := time.Now()
+	if err := prefillSession(c, session.ID, marker); err != nil {
+		return fmt.Errorf("prefill session: %w", err)
+	}
+	fmt.Printf("✅ %.2fs\n", time.Since(start).Seconds())
+
+	var results []*ResumeResult
+	for _, mode := range replayModes {
+		result := &ResumeResult{Mode: mode.Name}
+		for run := 1; run <= resumeRuns; run++ {
+			if verbose {
+				fmt.Printf("  %s replay run %d/%d... ", mode.Name, run, resumeRuns)
+			}
+
+			firstEvent, caughtUp, bytes, events, err := measureCatchUp(c, session.ID, mode.Options, marker)
+			if err != nil {
+				result.Errors = append(result.Errors, err)
+				if verbose {
+					fmt.Printf("❌ %v\n", err)
+				}
+				continue
+			}
+			result.FirstEvent = append(result.FirstEvent, firstEvent)
+			result.CaughtUp = append(result.CaughtUp, caughtUp)
+			result.BytesReplayed = append(result.BytesReplayed, bytes)
+			result.EventsReplayed = append(result.EventsReplayed, events)
+
+			if verbose {
+				fmt.Printf("✅ first event %.1fms, caught up %.1fms, %.2f KB\n",
+					float64(firstEvent.Nanoseconds())/1e6, float64(caughtUp.Nanoseconds())/1e6, float64(bytes)/1024)
+			}
+		}
+		results = append(results, result)
+	}
+
+	return analyzeResumeResults(results)
+}
+
+// prefillSession writes about resumePrefillBytes of output to the session,
+// ends it with a clear screen and marker, and waits until the marker is
+// streamed so all of it is recorded before reconnecting. The marker is
+// printed with printf so the echoed command line doesn't contain it.
+func prefillSession(c *client.VibeTunnelClient, sessionID, marker string) error {
+	stream, err := c.StreamSession(sessionID)
+	if err != nil {
+		return fmt.Errorf("start stream: %w", err)
+	}
+	defer stream.Close()
+
+	prefix, suffix, _ := strings.Cut(marker, "-done-")
+	command := fmt.Sprintf("yes 'vibetunnel benchmark prefill output' | head -c %d; printf '\\033[H\\033[2J%%s-done-%%s\\n' %s %s\n",
+		resumePrefillBytes, prefix, suffix)
+	if err := c.SendInput(sessionID, command); err != nil {
+		return fmt.Errorf("send input: %w", err)
+	}
+
+	_, _, _, _, err = waitForMarker(stream, marker, time.Now())
+	return err
+}
+
+// measureCatchUp opens a fresh stream and times the first event and the
+// marker, which ends the prefilled output, being replayed
+func measureCatchUp(c *client.VibeTunnelClient, sessionID string, opts client.StreamOptions, marker string) (firstEvent, caughtUp time.Duration, bytes int64, events int, err error) {
+	start := time.Now()
+	stream, err := c.StreamSessionWithOptions(sessionID, opts)
+	if err != nil {
+		return 0, 0, 0, 0, fmt.Errorf("start stream: %w", err)
+	}
+	defer stream.Close()
+
+	return waitForMarker(stream, marker, start)
+}
+
+// waitForMarker reads events until marker appears in the output. The end of
+// the previous event is kept so a marker split across events is found.
+func waitForMarker(stream *client.SSEStream, marker string, start time.Time) (firstEvent, caughtUp time.Duration, bytes int64, events int, err error) {
+	timeout := time.NewTimer(resumeTimeout)
+	defer timeout.Stop()
+
+	var tail string
+	errs := stream.Errors
+	for {
+		select {
+		case event, ok := <-stream.Events:
+			if !ok {
+				return firstEvent, 0, bytes, events, fmt.Errorf("stream ended before catching up")
+			}
+			if event.Event == nil || event.Event.Type != "o" {
+				continue
+			}
+
+			events++
+			if events == 1 {
+				firstEvent = time.Since(start)
+			}
+			bytes += int64(len(event.Event.Data))
+
+			text := tail + event.Event.Data
+			if strings.Contains(text, marker) {
+				return firstEvent, time.Since(start), bytes, events, nil
+			}
+			if len(text) > len(marker) {
+				text = text[len(text)-len(marker):]
+			}
+			tail = text
+
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil // Closed with Events; wait for that
+				continue
+			}
+			return firstEvent, 0, bytes, events, err
+
+		case <-timeout.C:
+			return firstEvent, 0, bytes, events, fmt.Errorf("not caught up after %v", resumeTimeout)
+		}
+	}
+}
+
+func analyzeResumeResults(results []*ResumeResult) error {
+	fmt.Printf("\n📈 Catch-up Statistics\n")
+
+	totalErrors := 0
+	for _, result := range results {
+		totalErrors += len(result.Errors)
+
+		fmt.Printf("\n%s replay (%d/%d runs succeeded):\n", result.Mode, len(result.CaughtUp), len(result.CaughtUp)+len(result.Errors))
+		if len(result.CaughtUp) == 0 {
+			for i, err := range result.Errors {
+				fmt.Printf("    Error %d: %v\n", i+1, err)
+			}
+			continue
+		}
+
+		var bytes int64
+		var events int
+		for i := range result.BytesReplayed {
+			bytes += result.BytesReplayed[i]
+			events += result.EventsReplayed[i]
+		}
+		fmt.Printf("  Replayed: %.2f KB in %d events (average)\n",
+			float64(bytes)/float64(len(result.BytesReplayed))/1024, events/len(result.EventsReplayed))
+
+		fmt.Printf("  Time to First Event:\n")
+		printPercentiles(result.FirstEvent)
+		fmt.Printf("  Time to Caught Up:\n")
+		printPercentiles(result.CaughtUp)
+
+		if histogram {
+			fmt.Printf("  Caught Up Distribution:\n")
+			printHistogram(result.CaughtUp)
+		}
+	}
+
+	if len(results) == 2 && len(results[0].CaughtUp) > 0 && len(results[1].CaughtUp) > 0 {
+		full := percentile(sortedDurations(results[0].CaughtUp), 50)
+		tail := percentile(sortedDurations(results[1].CaughtUp), 50)
+		if tail > 0 {
+			fmt.Printf("\n🏆 %s replay catches up %.1fx faster than %s replay (p50)\n",
+				results[1].Mode, float64(full)/float64(tail), results[0].Mode)
+		}
+	}
+
+	if totalErrors > 0 {
+		fmt.Printf("\n⚠️  %d errors encountered during benchmark\n", totalErrors)
+	} else {
+		fmt.Printf("\n✅ All reconnects caught up\n")
+	}
+
+	return nil
+}
@@ -8,9 +8,10 @@ import (
 )
 
 var (
-	hostname string
-	port     int
-	verbose  bool
+	hostname  string
+	port      int
+	verbose   bool
+	histogram bool
 )
 
 var rootCmd = &cobra.Command{
@@ -29,6 +30,7 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&hostname, "host", "localhost", "VibeTunnel server hostname")
 	rootCmd.PersistentFlags().IntVar(&port, "port", 4026, "VibeTunnel server port")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose output")
+	rootCmd.PersistentFlags().BoolVar(&histogram, "histogram", false, "Print a latency distribution histogram")
 }
 
 func Execute() {
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -115,7 +116,78 @@ type StreamResult struct {
 	LastEventTime  time.Duration
 	TotalDuration  time.Duration
 	Errors         []error
-	EventLatencies []time.Duration
+	EventLatencies []time.Duration // Input-to-echo round trips
+	InputsSent     int
+}
+
+// echoTailSize is how much of the previous output is kept when looking for
+// markers, so one split across events is still found; it must be longer
+// than any marker
+const echoTailSize = 64
+
+// echoTracker times the round trip from sending input to seeing it echoed
+// in the output. Each input carries a unique marker as a shell comment,
+// which the terminal echoes back without the command printing it.
+type echoTracker struct {
+	mu      sync.Mutex
+	pending map[string]time.Time
+	tail    string
+	inputs  int
+}
+
+func newEchoTracker() *echoTracker {
+	return &echoTracker{pending: make(map[string]time.Time)}
+}
+
+// sent records when the input carrying marker was sent
+func (t *echoTracker) sent(marker string, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pending[marker] = at
+	t.inputs++
+}
+
+// unsent forgets a marker whose input failed to send
+func (t *echoTracker) unsent(marker string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.pending, marker)
+	t.inputs--
+}
+
+// sentCount returns how many inputs were sent
+func (t *echoTracker) sentCount() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.inputs
+}
+
+// match scans output received at the given time for pending markers,
+// returning the round trip of each one found
+func (t *echoTracker) match(data string, at time.Time) []time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	text := t.tail + data
+	var latencies []time.Duration
+	for marker, sentAt := range t.pending {
+		if strings.Contains(text, marker) {
+			latencies = append(latencies, at.Sub(sentAt))
+			delete(t.pending, marker)
+		}
+	}
+
+	if len(text) > echoTailSize {
+		text = text[len(text)-echoTailSize:]
+	}
+	t.tail = text
+	return latencies
+}
+
+// echoMarker returns the marker for a session's i-th input. The trailing
+// dash keeps one marker from being a prefix of another.
+func echoMarker(sessionNum, i int) string {
+	return fmt.Sprintf("vtbench-%d-%d-", sessionNum, i)
 }
 
 func benchmarkSingleStream(c *client.VibeTunnelClient, sessionNum int) *StreamResult {
@@ -159,11 +231,16 @@ func benchmarkSingleStream(c *client.VibeTunnelClient, sessionNum int) *StreamRe
 	defer stream.Close()
 
 	// Send commands and monitor stream
+	echoes := newEchoTracker()
+	defer func() { result.InputsSent = echoes.sentCount() }()
 	go func() {
 		time.Sleep(500 * time.Millisecond) // Wait for stream to establish
 
 		for i, command := range streamCommands {
-			if err := c.SendInput(session.ID, command+"\n"); err != nil {
+			marker := echoMarker(sessionNum, i)
+			echoes.sent(marker, time.Now())
+			if err := c.SendInput(session.ID, command+" #"+marker+"\n"); err != nil {
+				echoes.unsent(marker)
 				result.Errors = append(result.Errors, fmt.Errorf("send command %d: %w", i, err))
 				continue
 			}
@@ -201,6 +278,9 @@ func benchmarkSingleStream(c *client.VibeTunnelClient, sessionNum int) *StreamRe
 			// Calculate event data size
 			if event.Event != nil {
 				result.BytesReceived += int64(len(event.Event.Data))
+				if event.Event.Type == "o" {
+					result.EventLatencies = append(result.EventLatencies, echoes.match(event.Event.Data, time.Now())...)
+				}
 			}
 
 			if verbose && result.EventsReceived <= 5 {
@@ -233,6 +313,8 @@ func analyzeStreamResults(results []*StreamResult, totalDuration time.Duration)
 		totalSessions int
 		avgFirstEvent time.Duration
 		avgLastEvent  time.Duration
+		latencies     []time.Duration
+		inputsSent    int
 	)
 
 	successfulSessions := 0
@@ -242,6 +324,8 @@ func analyzeStreamResults(results []*StreamResult, totalDuration time.Duration)
 		totalEvents += result.EventsReceived
 		totalBytes += result.BytesReceived
 		totalErrors += len(result.Errors)
+		latencies = append(latencies, result.EventLatencies...)
+		inputsSent += result.InputsSent
 
 		if len(result.Errors) == 0 && result.EventsReceived > 0 {
 			successfulSessions++
@@ -253,6 +337,7 @@ func analyzeStreamResults(results []*StreamResult, totalDuration time.Duration)
 			fmt.Printf("\nSession %d (%s):\n", result.SessionNum+1, result.SessionID)
 			fmt.Printf("  Events: %d\n", result.EventsReceived)
 			fmt.Printf("  Bytes: %d\n", result.BytesReceived)
+			fmt.Printf("  Echoes: %d of %d inputs\n", len(result.EventLatencies), result.InputsSent)
 			fmt.Printf("  First Event: %.1fms\n", float64(result.FirstEventTime.Nanoseconds())/1e6)
 			fmt.Printf("  Last Event: %.1fms\n", float64(result.LastEventTime.Nanoseconds())/1e6)
 			fmt.Printf("  Duration: %.2fs\n", result.TotalDuration.Seconds())
@@ -280,6 +365,18 @@ func analyzeStreamResults(results []*StreamResult, totalDuration time.Duration)
 		fmt.Printf("  First Event: %.1fms\n", float64(avgFirstEvent.Nanoseconds())/1e6)
 		fmt.Printf("  Last Event: %.1fms\n", float64(avgLastEvent.Nanoseconds())/1e6)
 
+		if inputsSent > 0 {
+			fmt.Printf("\nInput Echo Latency (%d of %d inputs echoed):\n", len(latencies), inputsSent)
+		}
+		if len(latencies) > 0 {
+			printPercentiles(latencies)
+
+			if histogram {
+				fmt.Printf("\nInput Echo Latency Distribution:\n")
+				printHistogram(latencies)
+			}
+		}
+
 		fmt.Printf("\nThroughput:\n")
 		fmt.Printf("  Events/sec: %.1f\n", float64(totalEvents)/totalDuration.Seconds())
 		fmt.Printf("  KB/sec: %.2f\n", float64(totalBytes)/1024/totalDuration.Seconds())
@@ -1,23 +1,40 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime/debug"
+	"slices"
 	"strconv"
 	"strings"
+	"syscall"
+	"text/tabwriter"
 	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/vibetunnel/linux/pkg/api"
 	"github.com/vibetunnel/linux/pkg/config"
+	"github.com/vibetunnel/linux/pkg/ngrok"
 	"github.com/vibetunnel/linux/pkg/session"
+	"golang.org/x/term"
 )
 
+// tunnelConnectTimeout bounds how long startup waits to print a tunnel URL
+const tunnelConnectTimeout = 30 * time.Second
+
 var (
 	// Version injected at build time
 	version = "dev"
+	// Git commit injected at build time; see buildCommit
+	commit = ""
 
 	// Session management flags
 	controlPath       string
@@ -28,18 +45,24 @@ var (
 	signalCmd         string
 	stopSession       bool
 	killSession       bool
+	allSessions       bool
+	controlJSON       bool
 	cleanupExited     bool
 	detachedSessionID string
+	inheritEnv        bool
+	attachReadOnly    bool
 
 	// Server flags
 	serve      bool
 	staticPath string
 
 	// Network and access configuration
-	port      string
-	bindAddr  string
-	localhost bool
-	network   bool
+	port           string
+	bindAddr       string
+	localhost      bool
+	network        bool
+	unixSocket     string
+	unixSocketMode string
 
 	// Security flags
 	password        string
@@ -53,21 +76,49 @@ var (
 	tlsCertPath     string
 	tlsKeyPath      string
 	tlsAutoRedirect bool
+	tlsMinVersion   string
+	tlsSANs         []string
 
 	// ngrok integration
 	ngrokEnabled bool
 	ngrokToken   string
+	ngrokRegion  string
+	ngrokDomain  string
+
+	// Cloudflare Tunnel integration
+	cloudflareEnabled  bool
+	cloudflareToken    string
+	cloudflareHostname string
 
 	// Advanced options
-	debugMode           bool
-	cleanupStartup      bool
-	serverMode          string
-	updateChannel       string
-	noSpawn             bool
-	doNotAllowColumnSet bool
+	debugMode               bool
+	cleanupStartup          bool
+	serverMode              string
+	updateChannel           string
+	noSpawn                 bool
+	doNotAllowColumnSet     bool
+	strictCwd               bool
+	allowSessionUser        bool
+	recordInput             bool
+	maxRuntime              int
+	maxConnections          int
+	maxStreamBacklog        int
+	maxReplay               int
+	maxInput                int
+	sseKeepAlive            int
+	insecureAllowAllOrigins bool
+	defaultCols             int
+	defaultRows             int
+	defaultTerm             string
 
 	// Configuration file
-	configFile string
+	configFile     string
+	validateConfig bool
+
+	// Summarize command flags
+	summarizeStatus       string
+	summarizeJSON         bool
+	summarizeErrorPattern string
 )
 
 var rootCmd = &cobra.Command{
@@ -89,13 +140,17 @@ func init() {
 	rootCmd.Flags().StringVar(&controlPath, "control-path", defaultControlPath, "Control directory path")
 	rootCmd.Flags().StringVar(&sessionName, "session-name", "", "Session name")
 	rootCmd.Flags().BoolVar(&listSessions, "list-sessions", false, "List all sessions")
-	rootCmd.Flags().StringVar(&sendKey, "send-key", "", "Send key to session")
+	rootCmd.Flags().StringVar(&sendKey, "send-key", "", "Send key to session, by name (e.g. ctrl_c, f5, arrow_up) or as raw bytes")
 	rootCmd.Flags().StringVar(&sendText, "send-text", "", "Send text to session")
-	rootCmd.Flags().StringVar(&signalCmd, "signal", "", "Send signal to session")
+	rootCmd.Flags().StringVar(&signalCmd, "signal", "", "Send signal to session (name or number, e.g. SIGHUP or 1)")
 	rootCmd.Flags().BoolVar(&stopSession, "stop", false, "Stop session (SIGTERM)")
 	rootCmd.Flags().BoolVar(&killSession, "kill", false, "Kill session (SIGKILL)")
+	rootCmd.Flags().BoolVar(&allSessions, "all", false, "With --stop or --kill, act on every running session")
+	rootCmd.Flags().BoolVar(&controlJSON, "json", false, "With --signal, --stop or --kill, print the outcome as JSON")
 	rootCmd.Flags().BoolVar(&cleanupExited, "cleanup-exited", false, "Clean up exited sessions")
 	rootCmd.Flags().StringVar(&detachedSessionID, "detached-session", "", "Run as detached session with given ID")
+	rootCmd.Flags().BoolVar(&attachReadOnly, "attach-readonly", false, "With --session-name, watch the session's output without sending input")
+	rootCmd.Flags().BoolVar(&inheritEnv, "inherit-env", false, "Pass the full environment to new sessions (minus the configured blocklist)")
 
 	// Server flags
 	rootCmd.Flags().BoolVar(&serve, "serve", false, "Start HTTP server")
@@ -106,6 +161,8 @@ func init() {
 	rootCmd.Flags().StringVar(&bindAddr, "bind", "", "Bind address (auto-detected if empty)")
 	rootCmd.Flags().BoolVar(&localhost, "localhost", false, "Bind to localhost only (127.0.0.1)")
 	rootCmd.Flags().BoolVar(&network, "network", false, "Bind to all interfaces (0.0.0.0)")
+	rootCmd.Flags().StringVar(&unixSocket, "unix-socket", "", "Listen on this Unix socket instead of a TCP port")
+	rootCmd.Flags().StringVar(&unixSocketMode, "unix-socket-mode", fmt.Sprintf("%#o", api.DefaultUnixSocketMode), "Permissions of the Unix socket (octal)")
 
 	// Security flags (compatible with VibeTunnel dashboard settings)
 	rootCmd.Flags().StringVar(&password, "password", "", "Dashboard password for Basic Auth")
@@ -119,10 +176,19 @@ func init() {
 	rootCmd.Flags().StringVar(&tlsCertPath, "tls-cert", "", "Custom TLS certificate path")
 	rootCmd.Flags().StringVar(&tlsKeyPath, "tls-key", "", "Custom TLS key path")
 	rootCmd.Flags().BoolVar(&tlsAutoRedirect, "tls-redirect", false, "Redirect HTTP to HTTPS")
+	rootCmd.Flags().StringVar(&tlsMinVersion, "tls-min-version", "1.2", "Minimum TLS version (1.2 or 1.3)")
+	rootCmd.Flags().StringArrayVar(&tlsSANs, "tls-san", nil, "Extra hostname or IP for the self-signed certificate (repeatable)")
 
 	// ngrok integration (compatible with VibeTunnel ngrok service)
 	rootCmd.Flags().BoolVar(&ngrokEnabled, "ngrok", false, "Enable ngrok tunnel")
 	rootCmd.Flags().StringVar(&ngrokToken, "ngrok-token", "", "ngrok auth token")
+	rootCmd.Flags().StringVar(&ngrokRegion, "ngrok-region", "", "ngrok region (us, us-cal-1, eu, ap, au, sa, jp, in)")
+	rootCmd.Flags().StringVar(&ngrokDomain, "ngrok-domain", "", "Domain reserved on your ngrok account")
+
+	// Cloudflare Tunnel integration (requires cloudflared in PATH)
+	rootCmd.Flags().BoolVar(&cloudflareEnabled, "cloudflare", false, "Enable Cloudflare Tunnel (quick tunnel unless a token is given)")
+	rootCmd.Flags().StringVar(&cloudflareToken, "cloudflare-token", "", "Cloudflare named tunnel token")
+	rootCmd.Flags().StringVar(&cloudflareHostname, "cloudflare-hostname", "", "Public hostname of the named tunnel, for display")
 
 	// Advanced options (compatible with VibeTunnel advanced settings)
 	rootCmd.Flags().BoolVar(&debugMode, "debug", false, "Enable debug mode")
@@ -131,6 +197,19 @@ func init() {
 	rootCmd.Flags().StringVar(&updateChannel, "update-channel", "stable", "Update channel (stable, prerelease)")
 	rootCmd.Flags().BoolVar(&noSpawn, "no-spawn", false, "Disable terminal spawning")
 	rootCmd.Flags().BoolVar(&doNotAllowColumnSet, "do-not-allow-column-set", true, "Disable terminal resizing for all sessions (spawned and detached)")
+	rootCmd.Flags().BoolVar(&strictCwd, "strict-cwd", false, "Reject sessions whose working directory is not accessible instead of using the home directory")
+	rootCmd.Flags().BoolVar(&allowSessionUser, "allow-session-user", false, "Let API clients run sessions as another user (server must run as root)")
+	rootCmd.Flags().BoolVar(&recordInput, "record-input", false, "Record session input in recordings (input typed with echo off is never recorded)")
+	rootCmd.Flags().IntVar(&maxRuntime, "max-runtime", 0, "Seconds after which sessions are killed regardless of activity (0 = unlimited)")
+	rootCmd.Flags().IntVar(&maxConnections, "max-connections", 256, "Maximum concurrent non-streaming connections (0 = unlimited)")
+	rootCmd.Flags().IntVar(&maxStreamBacklog, "max-stream-backlog", 16, "MB of output a streaming client may fall behind before older output is skipped (0 = unlimited)")
+	rootCmd.Flags().IntVar(&maxReplay, "max-replay", 1024, "KB of output replayed to stream clients on connect, from the last clear screen (0 = unlimited)")
+	rootCmd.Flags().IntVar(&maxInput, "max-input", 1024, "KB of input a client may send to a session in one request (0 = unlimited)")
+	rootCmd.Flags().IntVar(&sseKeepAlive, "sse-keepalive", 15, "Seconds a session stream may be silent before a keep-alive comment is sent (0 = off)")
+	rootCmd.Flags().IntVar(&defaultCols, "default-cols", 120, "Terminal columns for sessions that don't specify a size")
+	rootCmd.Flags().IntVar(&defaultRows, "default-rows", 30, "Terminal rows for sessions that don't specify a size")
+	rootCmd.Flags().StringVar(&defaultTerm, "default-term", "", "TERM for sessions that don't set one (default: host TERM, then xterm-256color)")
+	rootCmd.Flags().BoolVar(&insecureAllowAllOrigins, "insecure-allow-all-origins", false, "Accept WebSocket connections from any origin (allows cross-site access to terminals)")
 
 	// Configuration file
 	rootCmd.Flags().StringVarP(&configFile, "config", "c", defaultConfigPath, "Configuration file path")
@@ -140,23 +219,113 @@ func init() {
 		Use:   "version",
 		Short: "Show version information",
 		Run: func(cmd *cobra.Command, args []string) {
-			fmt.Printf("VibeTunnel Linux v%s\n", version)
+			fmt.Printf("VibeTunnel Linux v%s (%s)\n", version, buildCommit())
 			fmt.Println("Compatible with VibeTunnel macOS app")
 		},
 	})
 
 	// Add config command
-	rootCmd.AddCommand(&cobra.Command{
+	configCmd := &cobra.Command{
 		Use:   "config",
 		Short: "Show configuration",
-		Run: func(cmd *cobra.Command, args []string) {
+		RunE: func(cmd *cobra.Command, args []string) error {
 			cfg := config.LoadConfig(configFile)
+			if validateConfig {
+				if err := cfg.Validate(); err != nil {
+					cmd.SilenceUsage = true
+					cmd.SilenceErrors = true // main prints it
+					return fmt.Errorf("invalid configuration in %s:\n%w", configFile, err)
+				}
+				fmt.Printf("Configuration in %s is valid\n", configFile)
+				return nil
+			}
 			cfg.Print()
+			return nil
 		},
-	})
+	}
+	configCmd.Flags().StringVarP(&configFile, "config", "c", defaultConfigPath, "Configuration file path")
+	configCmd.Flags().BoolVar(&validateConfig, "validate", false, "Check the configuration file for errors instead of printing it")
+	rootCmd.AddCommand(configCmd)
+
+	// Add summarize command
+	summarizeCmd := &cobra.Command{
+		Use:   "summarize",
+		Short: "Summarize session recordings",
+		Long: `Reads each matching session's recording and reports command, duration,
+exit code, output size and whether the output contained error-like lines.`,
+		Args: cobra.NoArgs,
+		RunE: runSummarize,
+	}
+	summarizeCmd.Flags().StringVar(&controlPath, "control-path", defaultControlPath, "Control directory path")
+	summarizeCmd.Flags().StringVar(&summarizeStatus, "status", "", "Only include sessions with this status (running, exited)")
+	summarizeCmd.Flags().BoolVar(&summarizeJSON, "json", false, "Output as JSON")
+	summarizeCmd.Flags().StringVar(&summarizeErrorPattern, "error-pattern", session.DefaultErrorPattern, "Regular expression for error-like output lines")
+	rootCmd.AddCommand(summarizeCmd)
+}
+
+func runSummarize(cmd *cobra.Command, args []string) error {
+	cfg := config.LoadConfig(configFile)
+	if cfg.ControlPath != "" && !cmd.Flags().Changed("control-path") {
+		controlPath = cfg.ControlPath
+	}
+
+	errorPattern, err := regexp.Compile(summarizeErrorPattern)
+	if err != nil {
+		return fmt.Errorf("invalid error pattern: %w", err)
+	}
+
+	manager := session.NewManager(controlPath)
+	summaries, err := manager.Summarize(summarizeStatus, errorPattern)
+	if err != nil {
+		return err
+	}
+
+	if summarizeJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(summaries)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tStatus\tExit\tDuration\tBytes\tErrors\tCommand")
+	for _, s := range summaries {
+		exitCode := "-"
+		if s.ExitCode != nil {
+			exitCode = strconv.Itoa(*s.ExitCode)
+		}
+		errors := "no"
+		if s.HasErrors {
+			errors = "yes"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%.1fs\t%d\t%s\t%s\n",
+			shortID(s.ID), s.Status, exitCode, s.Duration, s.OutputBytes, errors, s.Command)
+	}
+	return tw.Flush()
+}
+
+// shortID abbreviates a session ID for display. IDs come from directory
+// names in the control path, so they aren't always full UUIDs.
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
+
+// envFlags are the server flags without a config field that can also be
+// set through VIBETUNNEL_* environment variables
+var envFlags = []string{
+	"bind", "tls", "tls-port", "tls-domain", "tls-self-signed", "tls-cert",
+	"tls-key", "tls-redirect", "tls-min-version", "tls-san", "no-spawn",
+	"unix-socket", "unix-socket-mode",
 }
 
 func run(cmd *cobra.Command, args []string) error {
+	// Settings only available as flags can come from the environment too
+	if err := config.ApplyEnvToFlags(cmd.Flags(), envFlags...); err != nil {
+		return err
+	}
+
 	// Load configuration from file and merge with CLI flags
 	cfg := config.LoadConfig(configFile)
 	cfg.MergeFlags(cmd.Flags())
@@ -169,15 +338,28 @@ func run(cmd *cobra.Command, args []string) error {
 		port = cfg.Server.Port
 	}
 
-	// Handle detached session mode
+	manager := newManager(controlPath, cfg)
+	defer manager.FlushWebhook()
+
+	// Handle detached session mode: run a session prepared by another
+	// process until it exits, serving its control FIFO
 	if detachedSessionID != "" {
-		// We're running as a detached session
-		// TODO: Implement RunDetachedSession
-		return fmt.Errorf("detached session mode not yet implemented")
+		// In a spawned terminal window the session owns the screen, so keep
+		// logs out of it, in the session's own log
+		if term.IsTerminal(int(os.Stdin.Fd())) {
+			logFile, err := os.OpenFile(session.SessionLogPath(controlPath, detachedSessionID), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+			if err == nil {
+				log.SetOutput(logFile)
+				defer func() {
+					if err := logFile.Close(); err != nil {
+						fmt.Fprintf(os.Stderr, "Failed to close log file: %v\n", err)
+					}
+				}()
+			}
+		}
+		return manager.RunDetachedSession(detachedSessionID)
 	}
 
-	manager := session.NewManager(controlPath)
-
 	// Handle cleanup on startup if enabled
 	if cfg.Advanced.CleanupStartup || cleanupStartup {
 		fmt.Println("Updating session statuses on startup...")
@@ -195,7 +377,7 @@ func run(cmd *cobra.Command, args []string) error {
 		}
 		fmt.Printf("ID\t\tName\t\tStatus\t\tCommand\n")
 		for _, s := range sessions {
-			fmt.Printf("%s\t%s\t\t%s\t\t%s\n", s.ID[:8], s.Name, s.Status, s.Cmdline)
+			fmt.Printf("%s\t%s\t\t%s\t\t%s\n", shortID(s.ID), s.Name, s.Status, s.Cmdline)
 		}
 		return nil
 	}
@@ -205,33 +387,52 @@ func run(cmd *cobra.Command, args []string) error {
 		return manager.RemoveExitedSessions()
 	}
 
+	// Stop or kill every running session
+	if allSessions && (stopSession || killSession) {
+		return stopAllSessions(manager, killSession)
+	}
+
 	// Handle session input/control operations
-	if sessionName != "" && (sendKey != "" || sendText != "" || signalCmd != "" || stopSession || killSession) {
+	if sessionName != "" && (sendKey != "" || sendText != "") {
 		sess, err := manager.FindSession(sessionName)
 		if err != nil {
 			return fmt.Errorf("failed to find session: %w", err)
 		}
 
 		if sendKey != "" {
+			// Key names like ctrl_c; anything else is sent as is
+			if seq, ok := session.KeySequence(sendKey); ok {
+				return sess.SendKey(seq)
+			}
 			return sess.SendKey(sendKey)
 		}
-		if sendText != "" {
-			return sess.SendText(sendText)
-		}
-		if signalCmd != "" {
-			return sess.Signal(signalCmd)
-		}
-		if stopSession {
-			return sess.Stop()
-		}
-		if killSession {
-			return sess.Kill()
-		}
+		return sess.SendText(sendText)
+	}
+	if sessionName != "" && (signalCmd != "" || stopSession || killSession) {
+		return signalSession(cmd, manager)
 	}
 
 	// Handle server mode
 	if serve {
-		return startServer(cfg, manager)
+		// Catch misconfiguration before binding rather than failing halfway
+		if err := cfg.Validate(); err != nil {
+			cmd.SilenceUsage = true
+			cmd.SilenceErrors = true // main prints it
+			return fmt.Errorf("invalid configuration:\n%w", err)
+		}
+		return startServer(cfg, manager, cmd.Flags())
+	}
+
+	// Attach to an existing session from this terminal
+	if sessionName != "" && len(args) == 0 {
+		sess, err := manager.FindSession(sessionName)
+		if err != nil {
+			return fmt.Errorf("failed to find session: %w", err)
+		}
+		if attachReadOnly {
+			return sess.AttachReadOnly()
+		}
+		return sess.Attach()
 	}
 
 	// Handle direct command execution (create new session)
@@ -242,10 +443,11 @@ func run(cmd *cobra.Command, args []string) error {
 	}
 
 	sess, err := manager.CreateSession(session.Config{
-		Name:      sessionName,
-		Cmdline:   args,
-		Cwd:       ".",
-		IsSpawned: false, // Command line sessions are detached, not spawned
+		Name:       sessionName,
+		Cmdline:    args,
+		Cwd:        ".",
+		IsSpawned:  false, // Command line sessions are detached, not spawned
+		InheritEnv: inheritEnv,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to create session: %w", err)
@@ -255,7 +457,56 @@ func run(cmd *cobra.Command, args []string) error {
 	return sess.Attach()
 }
 
-func startServer(cfg *config.Config, manager *session.Manager) error {
+// buildCommit returns the git commit the binary was built from: the one
+// injected at build time, else the one go build recorded, else "unknown"
+func buildCommit() string {
+	if commit != "" {
+		return commit
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	revision, dirty := "", false
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			dirty = setting.Value == "true"
+		}
+	}
+	if revision == "" {
+		return "unknown"
+	}
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+	if dirty {
+		revision += "-dirty"
+	}
+	return revision
+}
+
+// newManager creates a session manager with the session settings from cfg
+func newManager(controlPath string, cfg *config.Config) *session.Manager {
+	manager := session.NewManager(controlPath)
+	manager.SetEnvAllowlist(cfg.Session.EnvAllowlist)
+	manager.SetEnvBlocklist(cfg.Session.EnvBlocklist)
+	manager.SetEnvRedact(cfg.Session.EnvRedact)
+	manager.SetMaxRecordingSize(int64(cfg.Session.MaxRecordingMB) * 1024 * 1024)
+	manager.SetOutputCoalesce(time.Duration(cfg.Session.OutputCoalesceMS) * time.Millisecond)
+	manager.SetRecordInput(cfg.Session.RecordInput)
+	manager.SetMaxRuntime(time.Duration(cfg.Session.MaxRuntimeSeconds) * time.Second)
+	manager.SetDefaultSize(cfg.Server.DefaultCols, cfg.Server.DefaultRows)
+	manager.SetDefaultTerm(cfg.Server.DefaultTerm)
+	if cfg.Webhook.URL != "" {
+		manager.SetWebhook(session.NewWebhook(cfg.Webhook.URL, cfg.Webhook.Secret))
+	}
+	return manager
+}
+
+func startServer(cfg *config.Config, manager *session.Manager, flags *pflag.FlagSet) error {
 	// Terminal spawning behavior:
 	// 1. When spawn_terminal=true in API requests, we first try to connect to the Mac app's socket
 	// 2. If Mac app is running, it handles the terminal spawn via TerminalSpawnService
@@ -274,10 +525,7 @@ func startServer(cfg *config.Config, manager *session.Manager) error {
 	}
 
 	// Determine password
-	serverPassword := password
-	if cfg.Security.PasswordEnabled && cfg.Security.Password != "" {
-		serverPassword = cfg.Security.Password
-	}
+	serverPassword := determinePassword(cfg)
 
 	// Determine bind address
 	bindAddress := determineBind(cfg)
@@ -290,11 +538,74 @@ func startServer(cfg *config.Config, manager *session.Manager) error {
 
 	// Create and configure server
 	server := api.NewServer(manager, staticPath, serverPassword, portInt)
+	server.SetVersion(version)
+	server.SetCommit(buildCommit())
 	server.SetNoSpawn(noSpawn)
 	server.SetDoNotAllowColumnSet(doNotAllowColumnSet)
+	server.SetStrictCwd(strictCwd)
+	server.SetAllowSessionUser(allowSessionUser)
+	server.SetMaxConnections(cfg.Server.MaxConnections)
+	server.SetMaxStreamBacklog(int64(cfg.Server.MaxStreamBacklogMB) * 1024 * 1024)
+	server.SetMaxReplay(int64(cfg.Server.MaxReplayKB) * 1024)
+	server.SetMaxInput(int64(cfg.Server.MaxInputKB) * 1024)
+	server.SetSSEKeepAlive(time.Duration(cfg.Server.SSEKeepAliveSeconds) * time.Second)
+	server.SetAllowedOrigins(cfg.Server.CORS.AllowedOrigins)
+	server.SetTrustedProxies(cfg.Server.TrustedProxies)
+
+	if unixSocket != "" {
+		// Tunnels and TLS are set up against the TCP port
+		if tlsEnabled || cfg.Ngrok.Enabled || ngrokEnabled || cfg.Cloudflare.Enabled || cloudflareEnabled {
+			return fmt.Errorf("--unix-socket can't be combined with TLS, ngrok or Cloudflare tunnels")
+		}
+		mode, err := strconv.ParseUint(unixSocketMode, 8, 32)
+		if err != nil || mode > 0777 {
+			return fmt.Errorf("invalid --unix-socket-mode %q: expected octal permissions like 0660", unixSocketMode)
+		}
+		server.SetUnixSocket(unixSocket, os.FileMode(mode))
+	}
+
+	reloadOnHangup(server, cfg, flags)
+
+	// Remove exited sessions in the background if configured
+	if cfg.Cleanup.IntervalSeconds > 0 {
+		stopReaper := manager.StartReaper(
+			time.Duration(cfg.Cleanup.IntervalSeconds)*time.Second,
+			time.Duration(cfg.Cleanup.MaxAgeSeconds)*time.Second,
+		)
+		defer stopReaper()
+	}
+	if insecureAllowAllOrigins {
+		fmt.Println("WARNING: WebSocket origin checks disabled; any website can connect to your terminals")
+		server.SetAllowAllOrigins(true)
+	}
+
+	// Both tunnels would publish the same server; refuse rather than guess
+	if (cfg.Ngrok.Enabled || ngrokEnabled) && (cfg.Cloudflare.Enabled || cloudflareEnabled) {
+		return fmt.Errorf("ngrok and Cloudflare tunnels are mutually exclusive; enable only one")
+	}
+
+	// Configure Cloudflare Tunnel if enabled
+	if cfg.Cloudflare.Enabled || cloudflareEnabled {
+		if err := server.StartCloudflare(cfg.Cloudflare.Token, cfg.Cloudflare.Hostname); err != nil {
+			fmt.Printf("Warning: Cloudflare tunnel failed to start: %v\n", err)
+		} else {
+			fmt.Printf("Cloudflare tunnel starting...\n")
+			go func() {
+				url, err := server.WaitForCloudflareURL(tunnelConnectTimeout)
+				if err != nil {
+					fmt.Printf("Warning: Cloudflare tunnel unavailable: %v\n", err)
+					return
+				}
+				if url == "" {
+					fmt.Printf("Cloudflare tunnel connected (public hostname as configured in the Cloudflare dashboard)\n")
+					return
+				}
+				fmt.Printf("Cloudflare tunnel: %s\n", url)
+			}()
+		}
+	}
 
 	// Configure ngrok if enabled
-	var ngrokURL string
 	if cfg.Ngrok.Enabled || ngrokEnabled {
 		authToken := ngrokToken
 		if authToken == "" && cfg.Ngrok.AuthToken != "" {
@@ -302,10 +613,22 @@ func startServer(cfg *config.Config, manager *session.Manager) error {
 		}
 		if authToken != "" {
 			// Start ngrok through the server's service
-			if err := server.StartNgrok(authToken); err != nil {
+			if err := server.StartNgrok(authToken, ngrok.Options{
+				Region: cfg.Ngrok.Region,
+				Domain: cfg.Ngrok.Domain,
+			}); err != nil {
 				fmt.Printf("Warning: ngrok failed to start: %v\n", err)
 			} else {
 				fmt.Printf("Ngrok tunnel starting...\n")
+				// The tunnel connects in the background while the server starts
+				go func() {
+					url, err := server.WaitForNgrokURL(tunnelConnectTimeout)
+					if err != nil {
+						fmt.Printf("Warning: ngrok tunnel unavailable: %v\n", err)
+						return
+					}
+					fmt.Printf("ngrok tunnel: %s\n", url)
+				}()
 			}
 		} else {
 			fmt.Printf("Warning: ngrok enabled but no auth token provided\n")
@@ -329,6 +652,8 @@ func startServer(cfg *config.Config, manager *session.Manager) error {
 			CertPath:     tlsCertPath,
 			KeyPath:      tlsKeyPath,
 			AutoRedirect: tlsAutoRedirect,
+			MinVersion:   tlsMinVersion,
+			ExtraSANs:    tlsSANs,
 		}
 
 		// Create TLS server
@@ -343,7 +668,7 @@ func startServer(cfg *config.Config, manager *session.Manager) error {
 		fmt.Printf("Control directory: %s\n", controlPath)
 
 		if tlsSelfSigned {
-			fmt.Printf("TLS: Using self-signed certificates for localhost\n")
+			fmt.Printf("TLS: Using self-signed certificates for localhost and this machine's addresses\n")
 		} else if tlsDomain != "" {
 			fmt.Printf("TLS: Using Let's Encrypt for domain: %s\n", tlsDomain)
 		} else if tlsCertPath != "" && tlsKeyPath != "" {
@@ -354,10 +679,6 @@ func startServer(cfg *config.Config, manager *session.Manager) error {
 			fmt.Printf("Basic auth enabled with username: admin\n")
 		}
 
-		if ngrokURL != "" {
-			fmt.Printf("ngrok tunnel: %s\n", ngrokURL)
-		}
-
 		if cfg.Advanced.DebugMode || debugMode {
 			fmt.Printf("Debug mode enabled\n")
 		}
@@ -373,7 +694,11 @@ func startServer(cfg *config.Config, manager *session.Manager) error {
 	}
 
 	// Default HTTP behavior (like Rust version)
-	fmt.Printf("Starting VibeTunnel server on %s:%s\n", bindAddress, port)
+	if unixSocket != "" {
+		fmt.Printf("Starting VibeTunnel server on unix:%s\n", unixSocket)
+	} else {
+		fmt.Printf("Starting VibeTunnel server on %s:%s\n", bindAddress, port)
+	}
 	fmt.Printf("Serving web UI from: %s\n", staticPath)
 	fmt.Printf("Control directory: %s\n", controlPath)
 
@@ -381,10 +706,6 @@ func startServer(cfg *config.Config, manager *session.Manager) error {
 		fmt.Printf("Basic auth enabled with username: admin\n")
 	}
 
-	if ngrokURL != "" {
-		fmt.Printf("ngrok tunnel: %s\n", ngrokURL)
-	}
-
 	if cfg.Advanced.DebugMode || debugMode {
 		fmt.Printf("Debug mode enabled\n")
 	}
@@ -392,8 +713,282 @@ func startServer(cfg *config.Config, manager *session.Manager) error {
 	return server.Start(fmt.Sprintf("%s:%s", bindAddress, port))
 }
 
+// Exit codes of --signal, --stop and --kill besides 0 (signaled) and 1
+// (failed), so scripts can tell the outcomes apart
+const (
+	exitSessionNotFound  = 3
+	exitSessionExited    = 4
+	exitSessionAmbiguous = 5
+)
+
+// exitCodeError ends the process with a specific exit code. err, if set,
+// is printed first.
+type exitCodeError struct {
+	code int
+	err  error
+}
+
+func (e *exitCodeError) Error() string {
+	if e.err == nil {
+		return fmt.Sprintf("exit status %d", e.code)
+	}
+	return e.err.Error()
+}
+
+// signalResult is the --json output of --signal, --stop and --kill
+type signalResult struct {
+	SessionID string `json:"sessionId"`
+	Action    string `json:"action"` // "signal", "stop" or "kill"
+	Signal    string `json:"signal,omitempty"`
+	Result    string `json:"result"` // "signaled", "already_exited", "not_found", "ambiguous" or "failed"
+	WasAlive  bool   `json:"wasAlive"`
+	ExitCode  *int   `json:"exitCode"`
+	Error     string `json:"error,omitempty"`
+}
+
+// signalExitWait is how long --stop and --kill wait for the session to exit
+// to report its exit code
+const signalExitWait = 2 * time.Second
+
+// signalSession handles --signal, --stop and --kill for --session-name. The
+// exit code tells whether the session was signaled, had already exited or
+// wasn't found; with --json the outcome is also printed.
+func signalSession(cmd *cobra.Command, manager *session.Manager) error {
+	// Errors carry their own exit code, which usage text would only obscure
+	cmd.SilenceUsage = true
+	cmd.SilenceErrors = true
+
+	result := signalResult{SessionID: sessionName, Action: "kill"}
+	switch {
+	case signalCmd != "":
+		result.Action = "signal"
+		result.Signal = signalCmd
+	case stopSession:
+		result.Action = "stop"
+	}
+
+	finish := func(outcome string, code int, err error) error {
+		result.Result = outcome
+		if err != nil {
+			result.Error = err.Error()
+		}
+		if controlJSON {
+			if encErr := json.NewEncoder(os.Stdout).Encode(result); encErr != nil {
+				return encErr
+			}
+			err = nil // Already reported in the JSON
+		}
+		if code == 0 {
+			return nil
+		}
+		return &exitCodeError{code: code, err: err}
+	}
+
+	sess, err := manager.FindSession(sessionName)
+	switch {
+	case errors.Is(err, session.ErrSessionNotFound):
+		return finish("not_found", exitSessionNotFound, err)
+	case errors.Is(err, session.ErrAmbiguousSession):
+		return finish("ambiguous", exitSessionAmbiguous, err)
+	case err != nil:
+		return finish("failed", 1, fmt.Errorf("failed to find session: %w", err))
+	}
+	result.SessionID = sess.ID
+
+	if err := sess.UpdateStatus(); err != nil {
+		log.Printf("[WARN] Failed to update session status for %s: %v", sess.ID, err)
+	}
+	result.WasAlive = sess.IsAlive()
+	if !result.WasAlive {
+		result.ExitCode = sess.GetInfo().ExitCode
+		return finish("already_exited", exitSessionExited, fmt.Errorf("session %s has already exited", shortID(sess.ID)))
+	}
+
+	switch result.Action {
+	case "signal":
+		err = sess.Signal(signalCmd)
+	case "stop":
+		err = sess.Stop()
+	default:
+		err = sess.Kill()
+	}
+	if err != nil {
+		return finish("failed", 1, err)
+	}
+
+	// The session's owner records the exit code once the process is gone
+	if result.Action != "signal" {
+		deadline := time.Now().Add(signalExitWait)
+		for time.Now().Before(deadline) {
+			if info, err := session.LoadInfo(sess.Path()); err == nil && info.ExitCode != nil {
+				result.ExitCode = info.ExitCode
+				break
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+	return finish("signaled", 0, nil)
+}
+
+// stopAllSessions stops, or with kill kills, every running session, printing
+// each one it signals
+func stopAllSessions(manager *session.Manager, kill bool) error {
+	// Refresh the stored statuses so sessions whose process is gone are
+	// skipped rather than signalled
+	if err := manager.UpdateAllSessionStatuses(); err != nil {
+		return fmt.Errorf("failed to update session statuses: %w", err)
+	}
+	sessions, err := manager.ListSessions()
+	if err != nil {
+		return fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	var failed int
+	for _, info := range sessions {
+		if info.Status != string(session.StatusRunning) {
+			continue
+		}
+		sess, err := manager.GetSession(info.ID)
+		if err == nil {
+			if kill {
+				err = sess.Kill()
+			} else {
+				err = sess.Stop()
+			}
+		}
+
+		label := shortID(info.ID)
+		if info.Name != "" {
+			label += " (" + info.Name + ")"
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to stop session %s: %v\n", label, err)
+			failed++
+		} else if kill {
+			fmt.Printf("Killed session %s\n", label)
+		} else {
+			fmt.Printf("Stopped session %s\n", label)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to stop %d sessions", failed)
+	}
+	return nil
+}
+
+// determinePassword returns the dashboard password: the configured one if
+// enabled, otherwise the --password flag
+func determinePassword(cfg *config.Config) string {
+	if cfg.Security.PasswordEnabled && cfg.Security.Password != "" {
+		return cfg.Security.Password
+	}
+	return password
+}
+
+// reloadableSettings are the config fields a running server picks up on
+// SIGHUP; changing any other field needs a restart
+var reloadableSettings = []string{
+	"security.password_enabled",
+	"security.password",
+	"server.cors.allowed_origins",
+	"server.trusted_proxies",
+	"server.max_connections",
+	"server.max_stream_backlog_mb",
+	"server.max_replay_kb",
+	"server.max_input_kb",
+	"server.sse_keepalive_seconds",
+}
+
+// reloadOnHangup re-reads the config file whenever the server gets SIGHUP
+// and applies the reloadable settings. Flags from the command line keep
+// precedence over the file, as at startup. Sessions and listeners are
+// unaffected.
+func reloadOnHangup(server *api.Server, cfg *config.Config, flags *pflag.FlagSet) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	go func() {
+		current := cfg
+		for range hup {
+			next, err := config.ReadConfig(configFile)
+			if err != nil {
+				log.Printf("[ERROR] Config reload failed, keeping current settings: %v", err)
+				continue
+			}
+			next.MergeFlags(flags)
+			if err := next.Validate(); err != nil {
+				log.Printf("[ERROR] Config reload failed, keeping current settings: %v", err)
+				continue
+			}
+			applyReloadedConfig(server, cfg, current, next)
+			current = next
+		}
+	}()
+}
+
+// applyReloadedConfig applies the reloadable settings of next and logs which
+// of them changed from current, and which other fields differ from the
+// config the server started with
+func applyReloadedConfig(server *api.Server, started, current, next *config.Config) {
+	server.SetPassword(determinePassword(next))
+	server.SetAllowedOrigins(next.Server.CORS.AllowedOrigins)
+	server.SetTrustedProxies(next.Server.TrustedProxies)
+	server.SetMaxConnections(next.Server.MaxConnections)
+	server.SetMaxStreamBacklog(int64(next.Server.MaxStreamBacklogMB) * 1024 * 1024)
+	server.SetMaxReplay(int64(next.Server.MaxReplayKB) * 1024)
+	server.SetMaxInput(int64(next.Server.MaxInputKB) * 1024)
+	server.SetSSEKeepAlive(time.Duration(next.Server.SSEKeepAliveSeconds) * time.Second)
+
+	var applied, needRestart []string
+	for _, field := range changedConfigFields(reflect.ValueOf(*current), reflect.ValueOf(*next), "") {
+		if slices.Contains(reloadableSettings, field) {
+			applied = append(applied, field)
+		}
+	}
+	for _, field := range changedConfigFields(reflect.ValueOf(*started), reflect.ValueOf(*next), "") {
+		if !slices.Contains(reloadableSettings, field) {
+			needRestart = append(needRestart, field)
+		}
+	}
+
+	switch {
+	case len(applied) > 0:
+		log.Printf("[INFO] Config reloaded, applied: %s", strings.Join(applied, ", "))
+	default:
+		log.Printf("[INFO] Config reloaded, no reloadable settings changed")
+	}
+	if len(needRestart) > 0 {
+		log.Printf("[WARN] Config changes that need a restart: %s", strings.Join(needRestart, ", "))
+	}
+}
+
+// changedConfigFields lists the fields, by dotted YAML path, that differ
+// between two config values
+func changedConfigFields(old, next reflect.Value, prefix string) []string {
+	if old.Kind() != reflect.Struct {
+		if reflect.DeepEqual(old.Interface(), next.Interface()) {
+			return nil
+		}
+		return []string{prefix}
+	}
+
+	var changed []string
+	for i := 0; i < old.NumField(); i++ {
+		name, _, _ := strings.Cut(old.Type().Field(i).Tag.Get("yaml"), ",")
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		changed = append(changed, changedConfigFields(old.Field(i), next.Field(i), name)...)
+	}
+	return changed
+}
+
 func determineBind(cfg *config.Config) string {
-	// CLI flags take precedence
+	// CLI flags take precedence, an explicit address first
+	if bindAddr != "" {
+		return bindAddr
+	}
 	if localhost {
 		return "127.0.0.1"
 	}
@@ -421,6 +1016,8 @@ func showHelp() {
 	fmt.Println("    vt --shell [args...]")
 	fmt.Println("    vt -i [args...]")
 	fmt.Println("    vt --no-shell-wrap [command] [args...]")
+	fmt.Println("    vt list")
+	fmt.Println("    vt kill <session> | --all")
 	fmt.Println("    vt --show-session-info")
 	fmt.Println("    vt --show-session-id")
 	fmt.Println("    vt -S [command] [args...]")
@@ -442,6 +1039,7 @@ func showHelp() {
 	fmt.Println("    vt --claude               # Auto-locate and run Claude")
 	fmt.Println("    vt --claude --help        # Run Claude with --help option")
 	fmt.Println("    vt --claude-yolo          # Run Claude with --dangerously-skip-permissions")
+	fmt.Println("    vt --claude-yolo --model opus  # ...and pass further arguments to Claude")
 	fmt.Println("    vt --shell                # Launch current shell (equivalent to vt $SHELL)")
 	fmt.Println("    vt -i                     # Launch current shell (short form)")
 	fmt.Println("    vt -S ls -la              # List files without shell alias resolution")
@@ -455,6 +1053,9 @@ func showHelp() {
 	fmt.Println("    --show-session-info       Show current session info")
 	fmt.Println("    --show-session-id         Show current session ID only")
 	fmt.Println()
+	fmt.Println("ENVIRONMENT:")
+	fmt.Println("    VT_CLAUDE_ARGS            Default arguments for --claude and --claude-yolo")
+	fmt.Println()
 	fmt.Println("NOTE:")
 	fmt.Println("    This script automatically uses the tty-fwd executable bundled with")
 	fmt.Println("    VibeTunnel from the Resources folder.")
@@ -464,8 +1065,20 @@ func main() {
 	// Check if we're being run with TTY_SESSION_ID (spawned by Mac app)
 	if sessionID := os.Getenv("TTY_SESSION_ID"); sessionID != "" {
 		// We're running in a terminal spawned by the Mac app
-		// Redirect logs to avoid polluting the terminal
-		logFile, err := os.OpenFile("/tmp/vibetunnel-session.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		// Use the existing session ID instead of creating a new one
+		homeDir, _ := os.UserHomeDir()
+		defaultControlPath := filepath.Join(homeDir, ".vibetunnel", "control")
+		cfg := config.LoadConfig(filepath.Join(homeDir, ".vibetunnel", "config.yaml"))
+		if cfg.ControlPath != "" {
+			defaultControlPath = cfg.ControlPath
+		}
+
+		// Redirect logs to avoid polluting the terminal, into the session's
+		// log if the server has already created its directory
+		logFile, err := os.OpenFile(session.SessionLogPath(defaultControlPath, sessionID), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			logFile, err = os.OpenFile("/tmp/vibetunnel-session.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		}
 		if err == nil {
 			log.SetOutput(logFile)
 			defer func() {
@@ -475,14 +1088,6 @@ func main() {
 			}()
 		}
 
-		// Use the existing session ID instead of creating a new one
-		homeDir, _ := os.UserHomeDir()
-		defaultControlPath := filepath.Join(homeDir, ".vibetunnel", "control")
-		cfg := config.LoadConfig(filepath.Join(homeDir, ".vibetunnel", "config.yaml"))
-		if cfg.ControlPath != "" {
-			defaultControlPath = cfg.ControlPath
-		}
-
 		manager := session.NewManager(defaultControlPath)
 
 		// Wait for the session to be created by the API server
@@ -522,7 +1127,7 @@ func main() {
 
 		// Get the command and check if first arg is a subcommand
 		args := os.Args[1:]
-		if len(args) > 0 && (args[0] == "version" || args[0] == "config") {
+		if len(args) > 0 && (args[0] == "version" || args[0] == "config" || args[0] == "summarize") {
 			// This is a subcommand, let Cobra handle it normally
 		} else {
 			// Check if we have a -- separator (everything after it is the command)
@@ -534,7 +1139,19 @@ func main() {
 				}
 			}
 
+			// --detached-session <id> -- <cmd> runs a prepared session; the
+			// command after -- only labels the process, so leave it to Cobra
+			detached := false
 			if dashDashIndex >= 0 {
+				for _, arg := range args[:dashDashIndex] {
+					if arg == "--detached-session" || strings.HasPrefix(arg, "--detached-session=") {
+						detached = true
+						break
+					}
+				}
+			}
+
+			if dashDashIndex >= 0 && !detached {
 				// We have a -- separator, everything after it is the command to execute
 				cmdArgs := args[dashDashIndex+1:]
 				if len(cmdArgs) > 0 {
@@ -545,7 +1162,7 @@ func main() {
 						defaultControlPath = cfg.ControlPath
 					}
 
-					manager := session.NewManager(defaultControlPath)
+					manager := newManager(defaultControlPath, cfg)
 					sess, err := manager.CreateSession(session.Config{
 						Name:      "",
 						Cmdline:   cmdArgs,
@@ -562,9 +1179,10 @@ func main() {
 						fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 						os.Exit(1)
 					}
+					manager.FlushWebhook()
 					return
 				}
-			} else {
+			} else if dashDashIndex < 0 {
 				// No -- separator, check if any args look like VibeTunnel flags
 				hasVibeTunnelFlags := false
 				for _, arg := range args {
@@ -574,14 +1192,19 @@ func main() {
 						flag = strings.Split(flag, "=")[0] // Handle --flag=value format
 
 						knownFlags := []string{
-							"serve", "port", "p", "bind", "localhost", "network",
+							"serve", "port", "p", "bind", "localhost", "network", "unix-socket", "unix-socket-mode",
 							"password", "password-enabled", "tls", "tls-port", "tls-domain",
 							"tls-self-signed", "tls-cert", "tls-key", "tls-redirect",
-							"ngrok", "ngrok-token", "debug", "cleanup-startup",
+							"tls-min-version", "tls-san",
+							"ngrok", "ngrok-token", "ngrok-region", "ngrok-domain", "debug", "cleanup-startup",
+							"cloudflare", "cloudflare-token", "cloudflare-hostname",
 							"server-mode", "update-channel", "config", "c",
 							"control-path", "session-name", "list-sessions",
 							"send-key", "send-text", "signal", "stop", "kill",
 							"cleanup-exited", "detached-session", "static-path", "help", "h",
+							"max-connections", "max-stream-backlog", "max-replay", "max-input", "sse-keepalive", "inherit-env", "insecure-allow-all-origins",
+							"attach-readonly", "default-cols", "default-rows", "default-term",
+							"strict-cwd", "allow-session-user", "record-input", "max-runtime",
 						}
 
 						for _, known := range knownFlags {
@@ -605,7 +1228,7 @@ func main() {
 						defaultControlPath = cfg.ControlPath
 					}
 
-					manager := session.NewManager(defaultControlPath)
+					manager := newManager(defaultControlPath, cfg)
 					sess, err := manager.CreateSession(session.Config{
 						Name:      "",
 						Cmdline:   args,
@@ -622,6 +1245,7 @@ func main() {
 						fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 						os.Exit(1)
 					}
+					manager.FlushWebhook()
 					return
 				}
 			}
@@ -630,6 +1254,13 @@ func main() {
 
 	// Fall back to Cobra command handling for flags and structured commands
 	if err := rootCmd.Execute(); err != nil {
+		var exitErr *exitCodeError
+		if errors.As(err, &exitErr) {
+			if exitErr.err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", exitErr.err)
+			}
+			os.Exit(exitErr.code)
+		}
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/vibetunnel/linux/pkg/api"
+	"github.com/vibetunnel/linux/pkg/config"
+	"github.com/vibetunnel/linux/pkg/session"
+)
+
+// saveStaleSession saves a session that is recorded as running although
+// its process has exited, as after a crash or reboot
+func saveStaleSession(t *testing.T, m *session.Manager, id string) {
+	t.Helper()
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	info := &session.Info{
+		ID:     id,
+		Name:   "stale",
+		Args:   []string{"true"},
+		Pid:    cmd.Process.Pid,
+		Status: string(session.StatusRunning),
+		Width:  80,
+		Height: 24,
+	}
+	path := filepath.Join(m.ControlPath(), id)
+	if err := os.MkdirAll(path, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := info.Save(path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStopAllSessionsSkipsExited(t *testing.T) {
+	m := session.NewManager(t.TempDir())
+	saveStaleSession(t, m, "0c1d2e3f-4a5b-4c6d-8e7f-8a9b0c1d2e3f")
+
+	running, err := m.CreateSession(session.Config{Cmdline: []string{"sleep", "30"}})
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	defer running.Wait()
+
+	if err := stopAllSessions(m, true); err != nil {
+		t.Errorf("stopAllSessions: %v", err)
+	}
+	running.Wait()
+	if running.IsAlive() {
+		t.Error("running session wasn't killed")
+	}
+}
+
+// runKill runs --session-name name --kill --json against m and returns the
+// JSON it printed and the exit code it asked for
+func runKill(t *testing.T, m *session.Manager, name string) (signalResult, int) {
+	t.Helper()
+	origName, origKill, origJSON, origStdout := sessionName, killSession, controlJSON, os.Stdout
+	t.Cleanup(func() {
+		sessionName, killSession, controlJSON, os.Stdout = origName, origKill, origJSON, origStdout
+	})
+	sessionName, killSession, controlJSON = name, true, true
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout = w
+	err = signalSession(&cobra.Command{}, m)
+	os.Stdout = origStdout
+	if closeErr := w.Close(); closeErr != nil {
+		t.Fatal(closeErr)
+	}
+	output, readErr := io.ReadAll(r)
+	if readErr != nil {
+		t.Fatal(readErr)
+	}
+
+	code := 0
+	if err != nil {
+		var exitErr *exitCodeError
+		if !errors.As(err, &exitErr) {
+			t.Fatalf("signalSession returned %v without an exit code", err)
+		}
+		code = exitErr.code
+	}
+	var result signalResult
+	if err := json.Unmarshal(output, &result); err != nil {
+		t.Fatalf("invalid JSON %q: %v", output, err)
+	}
+	return result, code
+}
+
+func TestSignalSessionOutcomes(t *testing.T) {
+	m := session.NewManager(t.TempDir())
+	start := func(name string, cmdline ...string) *session.Session {
+		t.Helper()
+		sess, err := m.CreateSession(session.Config{Name: name, Cmdline: cmdline})
+		if err != nil {
+			t.Fatalf("CreateSession: %v", err)
+		}
+		t.Cleanup(func() {
+			if err := sess.Kill(); err != nil {
+				t.Logf("Failed to kill session: %v", err)
+			}
+			sess.Wait()
+		})
+		return sess
+	}
+	running := start("running", "sleep", "30")
+	exited := start("exited", "true")
+	exited.Wait()
+	start("build-a", "sleep", "30")
+	start("build-b", "sleep", "30")
+
+	tests := []struct {
+		name     string
+		session  string
+		want     string
+		wantCode int
+		wasAlive bool
+	}{
+		{"signaled", "running", "signaled", 0, true},
+		{"already exited", "exited", "already_exited", exitSessionExited, false},
+		{"not found", "missing", "not_found", exitSessionNotFound, false},
+		{"ambiguous", "build", "ambiguous", exitSessionAmbiguous, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, code := runKill(t, m, tt.session)
+			if result.Result != tt.want || code != tt.wantCode {
+				t.Errorf("result %q with exit code %d, want %q with %d", result.Result, code, tt.want, tt.wantCode)
+			}
+			if result.WasAlive != tt.wasAlive {
+				t.Errorf("wasAlive = %v, want %v", result.WasAlive, tt.wasAlive)
+			}
+		})
+	}
+
+	running.Wait()
+	if running.IsAlive() {
+		t.Error("running session wasn't killed")
+	}
+}
+
+func TestSignalSessionListingFails(t *testing.T) {
+	// A control path that isn't a directory can't be listed
+	controlPath := filepath.Join(t.TempDir(), "control")
+	if err := os.WriteFile(controlPath, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	result, code := runKill(t, session.NewManager(controlPath), "anything")
+	if result.Result != "failed" || code != 1 {
+		t.Errorf("result %q with exit code %d, want failed with 1", result.Result, code)
+	}
+}
+
+func TestReloadPasswordOnHangup(t *testing.T) {
+	dir := t.TempDir()
+	origConfigFile := configFile
+	t.Cleanup(func() { configFile = origConfigFile })
+	configFile = filepath.Join(dir, "config.yaml")
+	writePassword := func(password string) {
+		t.Helper()
+		content := "security:\n  password_enabled: true\n  password: " + password + "\n"
+		if err := os.WriteFile(configFile, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writePassword("old-secret")
+
+	cfg, err := config.ReadConfig(configFile)
+	if err != nil {
+		t.Fatalf("ReadConfig: %v", err)
+	}
+	server := api.NewServer(session.NewManager(filepath.Join(dir, "control")), "", determinePassword(cfg), 0)
+	socket := filepath.Join(dir, "api.sock")
+	server.SetUnixSocket(socket, 0600)
+	go func() {
+		if err := server.Start(""); err != nil {
+			t.Errorf("Start: %v", err)
+		}
+	}()
+	reloadOnHangup(server, cfg, pflag.NewFlagSet("test", pflag.ContinueOnError))
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		},
+	}}
+	status := func(password string) int {
+		req, err := http.NewRequest(http.MethodGet, "http://vibetunnel/api/sessions", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.SetBasicAuth("admin", password)
+		resp, err := client.Do(req)
+		if err != nil {
+			return 0 // Not listening yet
+		}
+		if err := resp.Body.Close(); err != nil {
+			t.Logf("Failed to close response body: %v", err)
+		}
+		return resp.StatusCode
+	}
+	waitFor := func(password string, want int) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for status(password) != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("password %q: status %d, want %d", password, status(password), want)
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+	waitFor("old-secret", http.StatusOK)
+
+	writePassword("new-secret")
+	if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+	waitFor("new-secret", http.StatusOK)
+	if got := status("old-secret"); got != http.StatusUnauthorized {
+		t.Errorf("old password: status %d, want 401", got)
+	}
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// The vt wrapper is a bash script; these tests run it with bash, mostly
+// against a stub vibetunnel that prints its own path and then the
+// arguments it was given, one per line.
+
+const stubVibetunnel = `#!/bin/sh
+printf '%s\n' "$0"
+for arg in "$@"; do
+	printf '%s\n' "$arg"
+done
+`
+
+// writeExecutable creates an executable file named name in a new
+// temporary directory and returns its path
+func writeExecutable(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// writeConfig writes ~/.vibetunnel/config.json under home
+func writeConfig(t *testing.T, home, content string) {
+	t.Helper()
+	dir := filepath.Join(home, ".vibetunnel")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// vtEnv returns an environment with a fresh HOME and no session or
+// override variables from the environment running the tests
+func vtEnv(t *testing.T, extra ...string) []string {
+	t.Helper()
+	return vtEnvWithHome(t, t.TempDir(), extra...)
+}
+
+func vtEnvWithHome(t *testing.T, home string, extra ...string) []string {
+	t.Helper()
+	env := []string{
+		"HOME=" + home,
+		"PATH=" + os.Getenv("PATH"),
+		"SHELL=/bin/sh",
+	}
+	return append(env, extra...)
+}
+
+// runVT runs the vt script with env and args and returns its stdout and
+// stderr. A non-zero exit is reported through err.
+func runVT(t *testing.T, env []string, args ...string) (stdout, stderr string, err error) {
+	t.Helper()
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash is not installed")
+	}
+	cmd := exec.Command("bash", append([]string{"vt"}, args...)...)
+	cmd.Env = env
+	var out, errOut strings.Builder
+	cmd.Stdout = &out
+	cmd.Stderr = &errOut
+	err = cmd.Run()
+	return out.String(), errOut.String(), err
+}
+
+// runStub runs vt and returns the lines printed by the stub it ran: the
+// stub's path followed by its arguments
+func runStub(t *testing.T, env []string, args ...string) []string {
+	t.Helper()
+	stdout, stderr, err := runVT(t, env, args...)
+	if err != nil {
+		t.Fatalf("vt %q: %v\n%s", args, err, stderr)
+	}
+	return strings.Split(strings.TrimSuffix(stdout, "\n"), "\n")
+}
+
+func TestShowSessionID(t *testing.T) {
+	tests := []struct {
+		name string
+		env  []string
+	}{
+		{"VIBETUNNEL_SESSION_ID", []string{"VIBETUNNEL_SESSION_ID=abc-123"}},
+		{"TTY_SESSION_ID", []string{"TTY_SESSION_ID=abc-123"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout, stderr, err := runVT(t, vtEnv(t, tt.env...), "--show-session-id")
+			if err != nil {
+				t.Fatalf("vt --show-session-id: %v\n%s", err, stderr)
+			}
+			if stdout != "abc-123\n" {
+				t.Errorf("stdout = %q, want %q", stdout, "abc-123\n")
+			}
+		})
+	}
+}
+
+func TestShowSessionIDOutsideSession(t *testing.T) {
+	stdout, stderr, err := runVT(t, vtEnv(t), "--show-session-id")
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 1 {
+		t.Fatalf("err = %v, want exit status 1", err)
+	}
+	if stdout != "" {
+		t.Errorf("stdout = %q, want nothing", stdout)
+	}
+	if !strings.Contains(stderr, "not inside a VibeTunnel session") {
+		t.Errorf("stderr = %q does not explain the failure", stderr)
+	}
+}
+
+func TestBinaryDiscovery(t *testing.T) {
+	envStub := writeExecutable(t, "vibetunnel", stubVibetunnel)
+	configStub := writeExecutable(t, "vibetunnel", stubVibetunnel)
+	pathStub := writeExecutable(t, "vibetunnel", stubVibetunnel)
+	path := filepath.Dir(pathStub) + ":" + os.Getenv("PATH")
+
+	tests := []struct {
+		name   string
+		env    []string
+		config string
+		want   string
+	}{
+		{"env override", []string{"VT_BINARY=" + envStub}, "", envStub},
+		{"config override", nil, `{"vt_binary": "` + configStub + `"}`, configStub},
+		{"env wins over config", []string{"VT_BINARY=" + envStub}, `{"vt_binary": "` + configStub + `"}`, envStub},
+		// Found on PATH, and the stub sees its resolved path
+		{"PATH fallback", nil, "", pathStub},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			home := t.TempDir()
+			if tt.config != "" {
+				writeConfig(t, home, tt.config)
+			}
+			env := append(vtEnvWithHome(t, home, tt.env...), "PATH="+path)
+
+			got := runStub(t, env, "-S", "echo", "hi")
+			want := []string{tt.want, "--do-not-allow-column-set=true", "--", "echo", "hi"}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("vibetunnel ran as %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestMissingConfiguredBinary(t *testing.T) {
+	// A vibetunnel on PATH must not be used instead
+	pathStub := writeExecutable(t, "vibetunnel", stubVibetunnel)
+	path := filepath.Dir(pathStub) + ":" + os.Getenv("PATH")
+	missing := filepath.Join(t.TempDir(), "vibetunnel")
+
+	t.Run("env", func(t *testing.T) {
+		env := append(vtEnv(t, "VT_BINARY="+missing), "PATH="+path)
+		stdout, stderr, err := runVT(t, env, "-S", "echo", "hi")
+		if err == nil {
+			t.Fatalf("vt succeeded with output %q", stdout)
+		}
+		if !strings.Contains(stderr, "$VT_BINARY is set to '"+missing+"'") {
+			t.Errorf("stderr = %q does not name the setting", stderr)
+		}
+	})
+
+	t.Run("config", func(t *testing.T) {
+		home := t.TempDir()
+		writeConfig(t, home, `{"vt_binary": "`+missing+`"}`)
+		env := append(vtEnvWithHome(t, home), "PATH="+path)
+		stdout, stderr, err := runVT(t, env, "-S", "echo", "hi")
+		if err == nil {
+			t.Fatalf("vt succeeded with output %q", stdout)
+		}
+		if !strings.Contains(stderr, "vt_binary in ") {
+			t.Errorf("stderr = %q does not name the setting", stderr)
+		}
+	})
+}
+
+func TestClaudeDiscovery(t *testing.T) {
+	stub := writeExecutable(t, "vibetunnel", stubVibetunnel)
+	envClaude := writeExecutable(t, "claude", "#!/bin/sh\n")
+	configClaude := writeExecutable(t, "claude", "#!/bin/sh\n")
+
+	tests := []struct {
+		name   string
+		env    []string
+		config string
+		want   string
+	}{
+		{"env override", []string{"VT_CLAUDE=" + envClaude}, "", envClaude},
+		{"config override", nil, `{"vt_claude": "` + configClaude + `"}`, configClaude},
+		{"env wins over config", []string{"VT_CLAUDE=" + envClaude}, `{"vt_claude": "` + configClaude + `"}`, envClaude},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			home := t.TempDir()
+			if tt.config != "" {
+				writeConfig(t, home, tt.config)
+			}
+			env := vtEnvWithHome(t, home, append(tt.env, "VT_BINARY="+stub)...)
+
+			got := runStub(t, env, "--claude", "--help")
+			// The command runs through the login shell, $SHELL -l -c <command>
+			if command := got[len(got)-1]; command != tt.want+" --help" {
+				t.Errorf("command = %q, want %q", command, tt.want+" --help")
+			}
+		})
+	}
+
+	t.Run("missing", func(t *testing.T) {
+		missing := filepath.Join(t.TempDir(), "claude")
+		env := vtEnv(t, "VT_BINARY="+stub, "VT_CLAUDE="+missing)
+		stdout, stderr, err := runVT(t, env, "--claude")
+		if err == nil {
+			t.Fatalf("vt succeeded with output %q", stdout)
+		}
+		if !strings.Contains(stderr, "$VT_CLAUDE is set to '"+missing+"'") {
+			t.Errorf("stderr = %q does not name the setting", stderr)
+		}
+	})
+}
+
+func TestShellWrap(t *testing.T) {
+	stub := writeExecutable(t, "vibetunnel", stubVibetunnel)
+	prefix := []string{stub, "--do-not-allow-column-set=true", "--"}
+
+	t.Run("unwrapped", func(t *testing.T) {
+		for _, flag := range []string{"-S", "--no-shell-wrap"} {
+			got := runStub(t, vtEnv(t, "VT_BINARY="+stub), flag, "echo", "a b")
+			want := append(prefix, "echo", "a b")
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%s: vibetunnel ran as %q, want %q", flag, got, want)
+			}
+		}
+	})
+
+	t.Run("login shell", func(t *testing.T) {
+		got := runStub(t, vtEnv(t, "VT_BINARY="+stub), "echo", "hi")
+		want := append(prefix, "/bin/sh", "-l", "-c", "echo hi")
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("vibetunnel ran as %q, want %q", got, want)
+		}
+	})
+
+	t.Run("bash by default", func(t *testing.T) {
+		var env []string
+		for _, v := range vtEnv(t, "VT_BINARY="+stub) {
+			if !strings.HasPrefix(v, "SHELL=") {
+				env = append(env, v)
+			}
+		}
+		got := runStub(t, env, "echo", "hi")
+		// Bash gets the command on its own line after enabling aliases,
+		// which the stub prints as two lines
+		want := append(prefix, "/bin/bash", "-l", "-c", "shopt -s expand_aliases; source ~/.bashrc 2>/dev/null || true", "echo hi")
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("vibetunnel ran as %q, want %q", got, want)
+		}
+	})
+}
+
+func TestShellWrapQuoting(t *testing.T) {
+	stub := writeExecutable(t, "vibetunnel", stubVibetunnel)
+	args := []string{"a b", "it's", `"double"`, "$HOME", "`id`", "", "tab\there", "semi;colon", "plain"}
+
+	got := runStub(t, vtEnv(t, "VT_BINARY="+stub), append([]string{"printf", `%s\n`}, args...)...)
+	command := got[len(got)-1]
+
+	// The shell must see every argument verbatim
+	out, err := exec.Command("/bin/sh", "-c", command).Output()
+	if err != nil {
+		t.Fatalf("running %q: %v", command, err)
+	}
+	if want := strings.Join(args, "\n") + "\n"; string(out) != want {
+		t.Errorf("%q printed %q, want %q", command, out, want)
+	}
+}
+
+func TestClaudeArguments(t *testing.T) {
+	stub := writeExecutable(t, "vibetunnel", stubVibetunnel)
+	claude := writeExecutable(t, "claude", "#!/bin/sh\n")
+
+	tests := []struct {
+		name string
+		env  []string
+		args []string
+		want string
+	}{
+		{"claude", nil, []string{"--claude", "--model", "opus"}, claude + " --model opus"},
+		{"claude-yolo", nil, []string{"--claude-yolo", "--model", "opus"}, claude + " --dangerously-skip-permissions --model opus"},
+		{"claude-yolo alone", nil, []string{"--claude-yolo"}, claude + " --dangerously-skip-permissions"},
+		{"VT_CLAUDE_ARGS", []string{"VT_CLAUDE_ARGS=--verbose  --model sonnet"}, []string{"--claude", "-c"}, claude + " --verbose --model sonnet -c"},
+		{"VT_CLAUDE_ARGS with yolo", []string{"VT_CLAUDE_ARGS=--verbose"}, []string{"--claude-yolo", "-c"}, claude + " --dangerously-skip-permissions --verbose -c"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := vtEnv(t, append(tt.env, "VT_BINARY="+stub, "VT_CLAUDE="+claude)...)
+			got := runStub(t, env, tt.args...)
+			// The command runs through the login shell, $SHELL -l -c <command>
+			if command := got[len(got)-1]; command != tt.want {
+				t.Errorf("command = %q, want %q", command, tt.want)
+			}
+		})
+	}
+}
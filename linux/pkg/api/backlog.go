@@ -0,0 +1,40 @@
+package api
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// DefaultMaxStreamBacklog bounds how far a stream client may fall behind
+// stream-out before the output in between is skipped
+const DefaultMaxStreamBacklog = 16 * 1024 * 1024
+
+// skipBacklog returns the offset a client that has seen seen bytes of a
+// stream of size bytes should continue from, keeping at most maxBacklog
+// bytes of unsent output. The new offset is always at the start of a line.
+// skipped is the number of bytes dropped, zero if the client is keeping up.
+func skipBacklog(file *os.File, seen, size, maxBacklog int64) (next, skipped int64, err error) {
+	if maxBacklog <= 0 || size-seen <= maxBacklog {
+		return seen, 0, nil
+	}
+
+	// Resume after the first line break in the part that is kept
+	start := size - maxBacklog
+	buf := make([]byte, 32*1024)
+	for pos := start; pos < size; {
+		n, err := file.ReadAt(buf, pos)
+		if i := bytes.IndexByte(buf[:n], '\n'); i >= 0 {
+			next = pos + int64(i) + 1
+			return next, next - seen, nil
+		}
+		pos += int64(n)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return seen, 0, err
+		}
+	}
+	return size, size - seen, nil
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"bufio"
+	"net/http"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/vibetunnel/linux/pkg/session"
+)
+
+// heapPeak samples the heap until stop is called and returns the largest
+// growth over the heap in use when it started
+func heapPeak() (stop func() uint64) {
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	base := stats.HeapAlloc
+
+	var peak atomic.Uint64
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(5 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				var stats runtime.MemStats
+				runtime.ReadMemStats(&stats)
+				if stats.HeapAlloc > base && stats.HeapAlloc-base > peak.Load() {
+					peak.Store(stats.HeapAlloc - base)
+				}
+			}
+		}
+	}()
+	return func() uint64 {
+		close(done)
+		<-finished
+		return peak.Load()
+	}
+}
+
+func TestFastOutputStaysBounded(t *testing.T) {
+	if testing.Short() {
+		t.Skip("stress test")
+	}
+	const outputSize = 48 * 1024 * 1024
+	const maxBacklog = 1024 * 1024
+
+	s, ts := newTestServer(t)
+	s.SetMaxStreamBacklog(maxBacklog)
+	stopSampling := heapPeak()
+
+	// Wait for the first line so the client is streaming while most of
+	// the output is produced
+	sess, err := s.manager.CreateSession(session.Config{
+		Cmdline: []string{"/bin/sh", "-c", `read line; yes "$line" | head -c ` + strconv.Itoa(outputSize) + `; echo; echo end-of-output`},
+	})
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	defer sess.Wait()
+
+	// The response starts with the first output, so the input that
+	// starts it is sent once the stream is open
+	go func() {
+		for len(s.streams.List()) == 0 {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if err := sess.SendText(strings.Repeat("x", 60) + "\n"); err != nil {
+			t.Errorf("SendText: %v", err)
+		}
+	}()
+
+	resp, err := http.Get(ts.URL + "/api/sessions/" + sess.ID + "/stream")
+	if err != nil {
+		t.Fatalf("GET stream: %v", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			t.Logf("Failed to close response body: %v", err)
+		}
+	}()
+
+	// Read like a slow client until the last output arrives
+	reader := bufio.NewReader(resp.Body)
+	truncated := false
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("stream ended before the last output: %v", err)
+		}
+		if strings.Contains(line, `"type":"truncated"`) {
+			truncated = true
+		}
+		if strings.Contains(line, "end-of-output") {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	peak := stopSampling()
+
+	stat, err := os.Stat(sess.StreamOutPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Size() < outputSize {
+		t.Errorf("recorded %d bytes, want all %d bytes of output", stat.Size(), outputSize)
+	}
+	if !truncated {
+		t.Error("the slow client was never sent a truncated event")
+	}
+	// The recording, not memory, holds the output; allow for the backlog,
+	// a few read buffers and the runtime
+	if limit := uint64(outputSize / 3); peak > limit {
+		t.Errorf("heap grew by %d MiB while streaming %d MiB, want under %d MiB", peak>>20, outputSize>>20, limit>>20)
+	}
+	t.Logf("heap grew by at most %d MiB", peak>>20)
+}
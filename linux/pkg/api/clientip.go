@@ -0,0 +1,127 @@
+package api
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+)
+
+type clientIPContextKey struct{}
+
+// trustedProxies are the peers whose X-Forwarded-For and X-Real-IP headers
+// are believed. unix trusts every peer on a Unix socket (--unix-socket).
+type trustedProxies struct {
+	nets []*net.IPNet
+	unix bool
+}
+
+// parseTrustedProxies parses IP addresses, CIDR ranges and "unix". Invalid
+// entries are logged and skipped; the config validates them beforehand.
+func parseTrustedProxies(entries []string) trustedProxies {
+	var proxies trustedProxies
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "unix" {
+			proxies.unix = true
+			continue
+		}
+		// A single address is a range of one
+		if ip := net.ParseIP(entry); ip != nil {
+			if ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			log.Printf("[WARN] Ignoring invalid trusted proxy %q", entry)
+			continue
+		}
+		proxies.nets = append(proxies.nets, ipNet)
+	}
+	return proxies
+}
+
+// trusts reports whether a peer, given as an IP address or, for Unix
+// socket peers, whatever else the connection reports, is a trusted proxy
+func (p trustedProxies) trusts(peer string) bool {
+	ip := net.ParseIP(peer)
+	if ip == nil {
+		return p.unix
+	}
+	for _, ipNet := range p.nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve returns the client IP of r. Forwarding headers are only used if
+// the peer is trusted. X-Forwarded-For is read from the right, skipping
+// trusted proxies, since entries further left can be forged by the client.
+func (p trustedProxies) resolve(r *http.Request) string {
+	peer := remoteHost(r)
+	if !p.trusts(peer) {
+		return peer
+	}
+
+	var forwarded []string
+	for _, value := range r.Header.Values("X-Forwarded-For") {
+		forwarded = append(forwarded, strings.Split(value, ",")...)
+	}
+	if len(forwarded) > 0 {
+		client := peer
+		for i := len(forwarded) - 1; i >= 0; i-- {
+			ip := net.ParseIP(strings.TrimSpace(forwarded[i]))
+			if ip == nil {
+				break // Malformed; everything left of it is untrustworthy
+			}
+			client = ip.String()
+			if !p.trusts(client) {
+				break
+			}
+		}
+		return client
+	}
+
+	if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); ip != nil {
+		return ip.String()
+	}
+	return peer
+}
+
+// clientIPMiddleware stores the client IP of each request, resolved through
+// the trusted proxies, in the request context for clientIP
+func (s *Server) clientIPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.settingsMu.RLock()
+		proxies := s.trustedProxies
+		s.settingsMu.RUnlock()
+
+		ip := proxies.resolve(r)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientIPContextKey{}, ip)))
+	})
+}
+
+// clientIP returns the IP address of the client that made a request, which
+// may be behind a trusted proxy
+func clientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPContextKey{}).(string); ok {
+		return ip
+	}
+	return remoteHost(r)
+}
+
+// remoteHost returns the address of the peer r was received from, without
+// the port
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
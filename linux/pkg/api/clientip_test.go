@@ -0,0 +1,72 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIPFromForwardingHeaders(t *testing.T) {
+	proxies := parseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1", "unix"})
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		realIP     string
+		want       string
+	}{
+		{"direct client", "203.0.113.5:4000", nil, "", "203.0.113.5"},
+		{"untrusted peer forging X-Forwarded-For", "203.0.113.5:4000", []string{"198.51.100.7"}, "", "203.0.113.5"},
+		{"untrusted peer forging X-Real-IP", "203.0.113.5:4000", nil, "198.51.100.7", "203.0.113.5"},
+		{"trusted proxy", "10.1.2.3:4000", []string{"198.51.100.7"}, "", "198.51.100.7"},
+		{"trusted single address", "192.168.1.1:4000", []string{"198.51.100.7"}, "", "198.51.100.7"},
+		{"address next to a trusted one", "192.168.1.2:4000", []string{"198.51.100.7"}, "", "192.168.1.2"},
+		{"chain of trusted proxies", "10.1.2.3:4000", []string{"198.51.100.7, 10.9.9.9"}, "", "198.51.100.7"},
+		{"client forging entries left of the proxy", "10.1.2.3:4000", []string{"1.1.1.1, 198.51.100.7"}, "", "198.51.100.7"},
+		{"headers split across lines", "10.1.2.3:4000", []string{"1.1.1.1", "198.51.100.7"}, "", "198.51.100.7"},
+		{"malformed entry", "10.1.2.3:4000", []string{"198.51.100.7, garbage"}, "", "10.1.2.3"},
+		{"only trusted entries", "10.1.2.3:4000", []string{"10.9.9.9"}, "", "10.9.9.9"},
+		{"trusted proxy with X-Real-IP", "10.1.2.3:4000", nil, "198.51.100.7", "198.51.100.7"},
+		{"X-Forwarded-For wins over X-Real-IP", "10.1.2.3:4000", []string{"198.51.100.7"}, "198.51.100.8", "198.51.100.7"},
+		{"IPv6 client", "[2001:db8::1]:4000", []string{"198.51.100.7"}, "", "2001:db8::1"},
+		{"Unix socket peer", "@", []string{"198.51.100.7"}, "", "198.51.100.7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/health", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+			if got := proxies.resolve(r); got != tt.want {
+				t.Errorf("client IP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientIPWithoutTrustedProxies(t *testing.T) {
+	s := NewServer(nil, "", "", 0)
+	var got string
+	handler := s.clientIPMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = clientIP(r)
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/api/health", nil)
+	r.RemoteAddr = "10.1.2.3:4000"
+	r.Header.Set("X-Forwarded-For", "198.51.100.7")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	if got != "10.1.2.3" {
+		t.Errorf("client IP = %q, want the peer when no proxies are trusted", got)
+	}
+
+	s.SetTrustedProxies([]string{"10.0.0.0/8"})
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	if got != "198.51.100.7" {
+		t.Errorf("client IP = %q, want the forwarded address once the peer is trusted", got)
+	}
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/vibetunnel/linux/pkg/cloudflare"
+)
+
+// errTunnelConflict is returned when starting one tunnel provider while the
+// other is running; both would publish the same server
+var errTunnelConflict = errors.New("ngrok and Cloudflare tunnels are mutually exclusive; stop the running tunnel first")
+
+// Cloudflare Tunnel Handlers
+
+func (s *Server) handleCloudflareStart(w http.ResponseWriter, r *http.Request) {
+	var req cloudflare.StartRequest
+	// An empty body starts a quick tunnel
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid_request", "Invalid request body", nil)
+			return
+		}
+	}
+
+	if s.ngrokService.IsRunning() {
+		writeJSONError(w, http.StatusConflict, "tunnel_conflict", errTunnelConflict.Error(), nil)
+		return
+	}
+
+	// Check if the tunnel is already running
+	if s.cloudflareService.IsRunning() {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": "Cloudflare tunnel is already running",
+			"tunnel":  s.cloudflareService.GetStatus(),
+		}); err != nil {
+			log.Printf("Failed to encode response: %v", err)
+		}
+		return
+	}
+
+	if err := s.cloudflareService.Start(req.Token, req.Hostname, s.port); err != nil {
+		log.Printf("[ERROR] Failed to start Cloudflare tunnel: %v", err)
+		status, code := http.StatusInternalServerError, "tunnel_failed"
+		if err == cloudflare.ErrNotInstalled {
+			status, code = http.StatusServiceUnavailable, "cloudflared_not_installed"
+		}
+		writeJSONError(w, status, code, err.Error(), nil)
+		return
+	}
+
+	// Return immediate response - tunnel status will be updated asynchronously
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Cloudflare tunnel is starting",
+		"tunnel":  s.cloudflareService.GetStatus(),
+	}); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
+
+func (s *Server) handleCloudflareStop(w http.ResponseWriter, r *http.Request) {
+	if !s.cloudflareService.IsRunning() {
+		writeJSONError(w, http.StatusBadRequest, "tunnel_not_running", "Cloudflare tunnel is not running", nil)
+		return
+	}
+
+	if err := s.cloudflareService.Stop(); err != nil {
+		log.Printf("[ERROR] Failed to stop Cloudflare tunnel: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "internal_error", err.Error(), nil)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Cloudflare tunnel stopped",
+	}); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
+
+func (s *Server) handleCloudflareStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"tunnel":  s.cloudflareService.GetStatus(),
+	}); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
+
+// StartCloudflare is a convenience method for CLI integration
+func (s *Server) StartCloudflare(token, hostname string) error {
+	if s.ngrokService.IsRunning() {
+		return errTunnelConflict
+	}
+	return s.cloudflareService.Start(token, hostname, s.port)
+}
+
+// WaitForCloudflareURL returns the public Cloudflare URL once the tunnel is up
+func (s *Server) WaitForCloudflareURL(timeout time.Duration) (string, error) {
+	return s.cloudflareService.WaitForURL(timeout)
+}
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultMaxConnections bounds concurrent connections on the HTTP server
+const DefaultMaxConnections = 256
+
+type connContextKey struct{}
+
+// connLimitListener caps the number of concurrently open connections.
+// Connections accepted beyond the cap are answered with 503 and closed
+// instead of being left to queue. Connections serving long-lived streams
+// don't count while the stream runs (see exemptFromConnLimit), so open SSE
+// and WebSocket clients can't starve ordinary API requests.
+type connLimitListener struct {
+	net.Listener
+	max    atomic.Int64 // 0 for no limit
+	active atomic.Int64
+}
+
+func newConnLimitListener(l net.Listener, max int) *connLimitListener {
+	listener := &connLimitListener{Listener: l}
+	listener.setMax(max)
+	return listener
+}
+
+// setMax changes the limit for connections accepted from now on. Zero or
+// less removes it.
+func (l *connLimitListener) setMax(max int) {
+	if max < 0 {
+		max = 0
+	}
+	l.max.Store(int64(max))
+}
+
+func (l *connLimitListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		max := l.max.Load()
+		if active := l.active.Add(1); max > 0 && active > max {
+			l.active.Add(-1)
+			debugLog("[DEBUG] Connection limit of %d reached, rejecting %s", max, conn.RemoteAddr())
+			go rejectConn(conn)
+			continue
+		}
+
+		return &limitedConn{Conn: conn, listener: l, counted: true}, nil
+	}
+}
+
+// rejectConn answers a connection over the limit with 503 and closes it
+func rejectConn(conn net.Conn) {
+	defer func() {
+		if err := conn.Close(); err != nil {
+			debugLog("[DEBUG] Failed to close rejected connection: %v", err)
+		}
+	}()
+
+	// Consume the request first; closing with unread data would reset the
+	// connection before the client sees the response
+	if err := conn.SetDeadline(time.Now().Add(time.Second)); err != nil {
+		return
+	}
+	buf := make([]byte, 4096)
+	_, _ = conn.Read(buf)
+
+	body := "Server busy, too many connections\n"
+	// Best effort; the client may already be gone
+	_, _ = fmt.Fprintf(conn, "HTTP/1.1 503 Service Unavailable\r\n"+
+		"Content-Type: text/plain; charset=utf-8\r\n"+
+		"Content-Length: %d\r\n"+
+		"Retry-After: 1\r\n"+
+		"Connection: close\r\n\r\n%s", len(body), body)
+}
+
+// limitedConn holds a slot of the listener's limit while open, except while
+// it serves a request exempted from the limit
+type limitedConn struct {
+	net.Conn
+	listener *connLimitListener
+
+	mu      sync.Mutex
+	counted bool // Whether the connection currently holds a slot
+	closed  bool
+}
+
+// setCounted takes or returns the connection's slot. Taking it back can go
+// over the limit, since an open connection can't be turned away; the limit
+// then applies to new connections until enough close.
+func (c *limitedConn) setCounted(counted bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed || c.counted == counted {
+		return
+	}
+	c.counted = counted
+	if counted {
+		c.listener.active.Add(1)
+	} else {
+		c.listener.active.Add(-1)
+	}
+}
+
+func (c *limitedConn) Close() error {
+	c.mu.Lock()
+	if !c.closed {
+		c.closed = true
+		if c.counted {
+			c.counted = false
+			c.listener.active.Add(-1)
+		}
+	}
+	c.mu.Unlock()
+	return c.Conn.Close()
+}
+
+// withConn stores the accepted connection in the request context so
+// handlers can reach it; used as http.Server.ConnContext
+func withConn(ctx context.Context, c net.Conn) context.Context {
+	return context.WithValue(ctx, connContextKey{}, c)
+}
+
+// exemptFromConnLimit wraps long-lived streaming handlers so their
+// connection doesn't count against the connection limit while they run. A
+// keep-alive connection counts again for the requests that follow.
+func exemptFromConnLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if conn, ok := r.Context().Value(connContextKey{}).(*limitedConn); ok {
+			conn.setCounted(false)
+			defer conn.setCounted(true)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,173 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// startLimitedServer serves handler on a loopback listener limited to max
+// connections
+func startLimitedServer(t *testing.T, max int, handler http.Handler) (*connLimitListener, string) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener := newConnLimitListener(l, max)
+	server := &http.Server{Handler: handler, ConnContext: withConn}
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(func() {
+		if err := server.Close(); err != nil {
+			t.Logf("Failed to close server: %v", err)
+		}
+	})
+	return listener, "http://" + l.Addr().String()
+}
+
+// get fetches url with client and returns the status code
+func get(t *testing.T, client *http.Client, url string) int {
+	t.Helper()
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode
+}
+
+// waitForActive waits for the server to notice connections closed by clients
+func waitForActive(t *testing.T, listener *connLimitListener, want int64) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for listener.active.Load() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("active connections = %d, want %d", listener.active.Load(), want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestConnLimitExemptionIsPerRequest(t *testing.T) {
+	streaming := make(chan struct{})
+	finish := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.Handle("/stream", exemptFromConnLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(streaming)
+		<-finish
+	})))
+	mux.HandleFunc("/plain", func(w http.ResponseWriter, r *http.Request) {})
+	listener, url := startLimitedServer(t, 1, mux)
+
+	// Each client has its own keep-alive connection
+	streamClient := &http.Client{Transport: &http.Transport{}}
+	otherClient := &http.Client{Transport: &http.Transport{}}
+	defer streamClient.CloseIdleConnections()
+	defer otherClient.CloseIdleConnections()
+
+	done := make(chan int)
+	go func() {
+		resp, err := streamClient.Get(url + "/stream")
+		if err != nil {
+			done <- 0
+			return
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+		done <- resp.StatusCode
+	}()
+	<-streaming
+
+	// While the stream runs its connection leaves room for another
+	if status := get(t, otherClient, url+"/plain"); status != http.StatusOK {
+		t.Fatalf("request during a stream = %d, want 200", status)
+	}
+	otherClient.CloseIdleConnections()
+	waitForActive(t, listener, 0)
+
+	close(finish)
+	if status := <-done; status != http.StatusOK {
+		t.Fatalf("stream = %d, want 200", status)
+	}
+
+	// Once the stream has ended its idle keep-alive connection counts again
+	waitForActive(t, listener, 1)
+	if status := get(t, otherClient, url+"/plain"); status != http.StatusServiceUnavailable {
+		t.Errorf("request over the limit = %d, want 503", status)
+	}
+	if status := get(t, streamClient, url+"/plain"); status != http.StatusOK {
+		t.Errorf("request on the kept-alive connection = %d, want 200", status)
+	}
+}
+
+// probeWriter records how many connections the listener counts when the
+// handler first writes, then cancels the request
+type probeWriter struct {
+	*httptest.ResponseRecorder
+	listener *connLimitListener
+	cancel   context.CancelFunc
+	active   int64 // -1 until the first write
+}
+
+func (w *probeWriter) Write(p []byte) (int, error) {
+	if w.active < 0 {
+		w.active = w.listener.active.Load()
+		w.cancel()
+	}
+	return w.ResponseRecorder.Write(p)
+}
+
+func TestStreamingRoutesAreExemptFromConnLimit(t *testing.T) {
+	s, _ := newTestServer(t)
+	handler := s.createHandler()
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "notes.txt"), []byte("notes"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		exempt bool
+	}{
+		{"health", "/api/health", false},
+		{"search", "/api/fs/search?pattern=*.txt&root=" + url.QueryEscape(root), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listener := &connLimitListener{}
+			listener.active.Store(1)
+			conn := &limitedConn{listener: listener, counted: true}
+			ctx, cancel := context.WithCancel(withConn(context.Background(), conn))
+			defer cancel()
+
+			w := &probeWriter{ResponseRecorder: httptest.NewRecorder(), listener: listener, cancel: cancel, active: -1}
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil).WithContext(ctx))
+			if w.active < 0 {
+				t.Fatalf("handler wrote nothing; status %d", w.Code)
+			}
+			want := int64(1)
+			if tt.exempt {
+				want = 0
+			}
+			if w.active != want {
+				t.Errorf("counted connections while responding = %d, want %d", w.active, want)
+			}
+			if active := listener.active.Load(); active != 1 {
+				t.Errorf("counted connections after the response = %d, want 1", active)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/vibetunnel/linux/pkg/session"
+)
+
+// errorResponse is the body of every /api error
+type errorResponse struct {
+	Error apiError `json:"error"`
+}
+
+// apiError describes a failed request. Code is stable for programs to
+// branch on, Message is meant for people, and Details holds the values the
+// error is about, such as the command that wasn't found.
+type apiError struct {
+	Code    string                 `json:"code"`
+	Message string                 `json:"message"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// writeJSONError responds with status and an errorResponse
+func writeJSONError(w http.ResponseWriter, status int, code, message string, details map[string]interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(errorResponse{Error: apiError{
+		Code:    code,
+		Message: message,
+		Details: details,
+	}}); err != nil {
+		log.Printf("Failed to encode error response: %v", err)
+	}
+}
+
+// writeSessionError responds to an error from the session package, with
+// the status, code and details of the typed errors it knows. Anything else
+// is a 500.
+func writeSessionError(w http.ResponseWriter, err error) {
+	var notFound *session.CommandNotFoundError
+	var invalidDir *session.InvalidWorkingDirError
+	var invalidUser *session.UserError
+	var notExecutable *session.CommandNotExecutableError
+	var ptyErr *session.PTYCreationError
+	var conflict *session.ImportConflictError
+	switch {
+	case errors.As(err, &notFound):
+		writeJSONError(w, http.StatusBadRequest, "command_not_found", err.Error(), map[string]interface{}{"command": notFound.Command})
+	case errors.As(err, &invalidDir):
+		writeJSONError(w, http.StatusBadRequest, "invalid_working_dir", err.Error(), map[string]interface{}{"workingDir": invalidDir.Path})
+	case errors.As(err, &invalidUser):
+		writeJSONError(w, http.StatusBadRequest, "invalid_user", err.Error(), map[string]interface{}{"user": invalidUser.User})
+	case errors.As(err, &notExecutable):
+		writeJSONError(w, http.StatusBadRequest, "command_not_executable", err.Error(), map[string]interface{}{"command": notExecutable.Command})
+	case errors.As(err, &ptyErr):
+		writeJSONError(w, http.StatusServiceUnavailable, "pty_creation_failed", err.Error(), map[string]interface{}{"hint": ptyErr.Hint})
+	case errors.As(err, &conflict):
+		writeJSONError(w, http.StatusConflict, "session_exists", err.Error(), map[string]interface{}{"ids": conflict.IDs})
+	case errors.Is(err, session.ErrInvalidExport):
+		writeJSONError(w, http.StatusBadRequest, "invalid_export", err.Error(), nil)
+	case errors.Is(err, session.ErrReadOnly):
+		writeJSONError(w, http.StatusConflict, "session_read_only", err.Error(), nil)
+	case errors.Is(err, session.ErrInputBlocked):
+		writeJSONError(w, http.StatusServiceUnavailable, "input_blocked", err.Error(), nil)
+	case errors.Is(err, session.ErrAttached):
+		writeJSONError(w, http.StatusConflict, "session_attached", err.Error(), nil)
+	case errors.Is(err, session.ErrRecordingNotFound):
+		writeJSONError(w, http.StatusNotFound, "recording_not_found", err.Error(), nil)
+	default:
+		writeJSONError(w, http.StatusInternalServerError, "internal_error", err.Error(), nil)
+	}
+}
+
+// writeSessionNotFound responds that the session in the URL doesn't exist
+func writeSessionNotFound(w http.ResponseWriter) {
+	writeJSONError(w, http.StatusNotFound, "session_not_found", "Session not found", nil)
+}
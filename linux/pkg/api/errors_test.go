@@ -0,0 +1,108 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vibetunnel/linux/pkg/session"
+)
+
+func TestErrorResponseShape(t *testing.T) {
+	s, ts := newTestServer(t)
+	missingDir := filepath.Join(t.TempDir(), "missing")
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		body    string
+		status  int
+		code    string
+		details map[string]interface{}
+	}{
+		{"unknown session", "GET", "/api/sessions/5d9e8c0e-6c4f-4d3e-9a53-0a8f3c1c2b11", "", http.StatusNotFound, "session_not_found", nil},
+		{"malformed body", "POST", "/api/sessions", "{", http.StatusBadRequest, "invalid_request", nil},
+		{"command not found", "POST", "/api/sessions", `{"command": ["vibetunnel-no-such-command"]}`, http.StatusBadRequest, "command_not_found", map[string]interface{}{"command": "vibetunnel-no-such-command"}},
+		{"missing directory", "GET", "/api/fs/browse?path=" + url.QueryEscape(missingDir), "", http.StatusNotFound, "path_not_found", map[string]interface{}{"path": missingDir}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, ts.URL+tt.path, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("%s %s: %v", tt.method, tt.path, err)
+			}
+			checkErrorResponse(t, resp, tt.status, tt.code, tt.details)
+		})
+	}
+
+	t.Run("unauthorized", func(t *testing.T) {
+		s.SetPassword("secret")
+		defer s.SetPassword("")
+		resp, err := http.Get(ts.URL + "/api/sessions")
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkErrorResponse(t, resp, http.StatusUnauthorized, "unauthorized", nil)
+	})
+
+	t.Run("session error", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		writeSessionError(w, fmt.Errorf("failed to resize: %w", session.ErrReadOnly))
+		checkErrorResponse(t, w.Result(), http.StatusConflict, "session_read_only", nil)
+	})
+}
+
+// checkErrorResponse checks that resp has status and a body of exactly
+// {"error": {"code", "message", "details"}} with code and details, and
+// closes it
+func checkErrorResponse(t *testing.T, resp *http.Response, status int, code string, details map[string]interface{}) {
+	t.Helper()
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			t.Logf("Failed to close response body: %v", err)
+		}
+	}()
+	if resp.StatusCode != status {
+		t.Errorf("status = %d, want %d", resp.StatusCode, status)
+	}
+	if contentType := resp.Header.Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", contentType)
+	}
+
+	var body map[string]json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("invalid JSON response: %v", err)
+	}
+	if len(body) != 1 || body["error"] == nil {
+		t.Fatalf("response %v, want only an error object", body)
+	}
+	var apiErr map[string]interface{}
+	if err := json.Unmarshal(body["error"], &apiErr); err != nil {
+		t.Fatalf("error %s is not an object: %v", body["error"], err)
+	}
+	for key := range apiErr {
+		if key != "code" && key != "message" && key != "details" {
+			t.Errorf("unexpected field %q in %v", key, apiErr)
+		}
+	}
+	if apiErr["code"] != code {
+		t.Errorf("code = %v, want %q", apiErr["code"], code)
+	}
+	if message, _ := apiErr["message"].(string); message == "" {
+		t.Errorf("error %v has no message", apiErr)
+	}
+	got, hasDetails := apiErr["details"].(map[string]interface{})
+	if hasDetails != (details != nil) || fmt.Sprint(got) != fmt.Sprint(details) {
+		t.Errorf("details = %v, want %v", apiErr["details"], details)
+	}
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/vibetunnel/linux/pkg/session"
+)
+
+// maxImportUpload bounds the size of an imported session bundle. Bundles are
+// written to disk as they're read, so this limits disk use, not memory.
+const maxImportUpload = 1024 * 1024 * 1024
+
+// handleExportSessions streams every session's metadata and recording as a
+// bundle for POST /api/sessions/import on another server.
+// ?recordings=false leaves the recordings out.
+func (s *Server) handleExportSessions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="vibetunnel-sessions.json"`)
+	// The response has started by the time most errors happen; the client
+	// is left with a truncated bundle, which import rejects
+	if err := s.manager.Export(w, r.URL.Query().Get("recordings") != "false"); err != nil {
+		log.Printf("[ERROR] Failed to export sessions: %v", err)
+	}
+}
+
+// handleImportSessions restores a bundle from GET /api/sessions/export. The
+// sessions are marked exited. Existing IDs are a 409 unless ?overwrite=true.
+func (s *Server) handleImportSessions(w http.ResponseWriter, r *http.Request) {
+	body := http.MaxBytesReader(w, r.Body, maxImportUpload)
+	imported, err := s.manager.Import(body, r.URL.Query().Get("overwrite") == "true")
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeJSONError(w, http.StatusRequestEntityTooLarge, "export_too_large", "Export bundle too large", nil)
+		return
+	}
+	if err != nil {
+		// Conflicts and invalid bundles get their own status and code
+		if !errors.Is(err, session.ErrSessionExists) && !errors.Is(err, session.ErrInvalidExport) {
+			log.Printf("[ERROR] Failed to import sessions: %v", err)
+		}
+		writeSessionError(w, err)
+		return
+	}
+
+	logRequestf(r, "[INFO] Imported %d sessions", len(imported))
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"imported": imported,
+	}); err != nil {
+		log.Printf("Failed to encode import response: %v", err)
+	}
+}
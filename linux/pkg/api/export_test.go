@@ -0,0 +1,85 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vibetunnel/linux/pkg/session"
+)
+
+func TestExportImportStaysBounded(t *testing.T) {
+	if testing.Short() {
+		t.Skip("stress test")
+	}
+	const recordingSize = 48 * 1024 * 1024
+	const id = "5d9e8c0e-6c4f-4d3e-9a53-0a8f3c1c2b11"
+
+	// A large finished session on the exporting server
+	from, fromServer := newTestServer(t)
+	dir := filepath.Join(from.manager.ControlPath(), id)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	info := &session.Info{ID: id, Name: "large", Args: []string{"bash"}, Status: string(session.StatusExited)}
+	if err := info.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Create(filepath.Join(dir, "stream-out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := bufio.NewWriter(file)
+	fmt.Fprintln(out, `{"version":2,"width":80,"height":24}`)
+	line := strings.Repeat("x", 1000)
+	for written := 0; written < recordingSize; written += len(line) {
+		fmt.Fprintf(out, "[0.1,\"o\",%q]\n", line)
+	}
+	if err := out.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Downloaded and posted straight to the importing server
+	_, toServer := newTestServer(t)
+	stopSampling := heapPeak()
+	resp, err := http.Get(fromServer.URL + "/api/sessions/export")
+	if err != nil {
+		t.Fatalf("GET export: %v", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			t.Logf("Failed to close response body: %v", err)
+		}
+	}()
+	imported, err := http.Post(toServer.URL+"/api/sessions/import", "application/json", resp.Body)
+	if err != nil {
+		t.Fatalf("POST import: %v", err)
+	}
+	defer func() {
+		if err := imported.Body.Close(); err != nil {
+			t.Logf("Failed to close response body: %v", err)
+		}
+	}()
+	peak := stopSampling()
+
+	var result map[string]interface{}
+	if err := json.NewDecoder(imported.Body).Decode(&result); err != nil && err != io.EOF {
+		t.Fatal(err)
+	}
+	if imported.StatusCode != http.StatusOK {
+		t.Fatalf("import: status %d, response %v", imported.StatusCode, result)
+	}
+	if limit := uint64(recordingSize / 4); peak > limit {
+		t.Errorf("heap grew by %d MiB moving a %d MiB recording, want under %d MiB", peak>>20, recordingSize>>20, limit>>20)
+	}
+	t.Logf("heap grew by at most %d MiB", peak>>20)
+}
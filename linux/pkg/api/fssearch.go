@@ -0,0 +1,389 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Search limits; a request may lower the depth and result count but not
+// raise them past the maximums
+const (
+	defaultSearchDepth   = 8
+	maxSearchDepth       = 32
+	defaultSearchResults = 200
+	maxSearchResults     = 1000
+
+	maxSearchFileSize = 1024 * 1024 // Larger files aren't searched for content
+	maxSearchLineLen  = 200         // Matched lines are cut to this many bytes
+)
+
+// FSSearchOptions describe a file search
+type FSSearchOptions struct {
+	Root      string // Absolute directory to search under
+	Pattern   string // Glob matched against file names, case-insensitively
+	Content   string // If set, only files containing this text match
+	MaxDepth  int
+	Limit     int
+	GitIgnore bool // Skip files ignored by .gitignore files under Root
+}
+
+// FSSearchResult is a file that matched. Line and Text are the first line
+// containing the searched content.
+type FSSearchResult struct {
+	FSEntry
+	Line int    `json:"line,omitempty"`
+	Text string `json:"text,omitempty"`
+}
+
+// errSearchLimit stops the walk once enough results are found
+var errSearchLimit = errors.New("search result limit reached")
+
+// SearchFiles walks opts.Root, calling found for each match, until the
+// whole tree is searched, opts.Limit results are found or ctx is done.
+// Symlinks are not followed, so the search stays under Root, and the .git
+// directory is never searched. It reports whether the limit was reached.
+func SearchFiles(ctx context.Context, opts FSSearchOptions, found func(FSSearchResult) error) (bool, error) {
+	pattern := strings.ToLower(opts.Pattern)
+	if pattern == "" {
+		pattern = "*"
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return false, fmt.Errorf("invalid pattern: %w", err)
+	}
+
+	// Ignore rules that apply inside each directory walked so far
+	ignores := map[string][]ignoreRule{}
+	count := 0
+
+	err := filepath.WalkDir(opts.Root, func(p string, d fs.DirEntry, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			if p == opts.Root {
+				return err
+			}
+			// Unreadable directories are skipped, not fatal
+			debugLog("[DEBUG] Search: Skipping %s: %v", p, err)
+			return nil
+		}
+
+		if p != opts.Root {
+			if d.IsDir() && d.Name() == ".git" {
+				return fs.SkipDir
+			}
+			if opts.GitIgnore && ignored(ignores[filepath.Dir(p)], p, d.IsDir()) {
+				if d.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+		}
+
+		// What to return for this entry: directories at the maximum depth
+		// may match but aren't searched
+		next := error(nil)
+		if d.IsDir() {
+			if p == opts.Root {
+				if opts.GitIgnore {
+					ignores[p] = loadGitIgnore(p)
+				}
+				return nil
+			}
+			rel, _ := filepath.Rel(opts.Root, p)
+			if strings.Count(rel, string(filepath.Separator))+1 >= opts.MaxDepth {
+				next = fs.SkipDir
+			} else if opts.GitIgnore {
+				rules := ignores[filepath.Dir(p)]
+				ignores[p] = append(rules[:len(rules):len(rules)], loadGitIgnore(p)...)
+			}
+			if opts.Content != "" {
+				return next
+			}
+		}
+
+		if matched, _ := path.Match(pattern, strings.ToLower(d.Name())); !matched {
+			return next
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return next
+		}
+		result := FSSearchResult{FSEntry: FSEntry{
+			Name:    d.Name(),
+			Path:    p,
+			IsDir:   d.IsDir(),
+			Size:    info.Size(),
+			Mode:    info.Mode().String(),
+			ModTime: info.ModTime(),
+		}}
+		if opts.Content != "" {
+			if !info.Mode().IsRegular() || info.Size() > maxSearchFileSize {
+				return nil
+			}
+			result.Line, result.Text = grepFile(p, opts.Content)
+			if result.Line == 0 {
+				return nil
+			}
+		}
+
+		if err := found(result); err != nil {
+			return err
+		}
+		count++
+		if count >= opts.Limit {
+			return errSearchLimit
+		}
+		return next
+	})
+	if errors.Is(err, errSearchLimit) {
+		return true, nil
+	}
+	return false, err
+}
+
+// grepFile returns the number and text of the first line of the file at p
+// containing text, or 0 if none does or the file looks binary
+func grepFile(p, text string) (int, string) {
+	file, err := os.Open(p)
+	if err != nil {
+		return 0, ""
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			debugLog("[DEBUG] Search: Failed to close %s: %v", p, err)
+		}
+	}()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxSearchFileSize))
+	if err != nil || bytes.IndexByte(data, 0) >= 0 {
+		return 0, ""
+	}
+	idx := bytes.Index(data, []byte(text))
+	if idx < 0 {
+		return 0, ""
+	}
+
+	start := bytes.LastIndexByte(data[:idx], '\n') + 1
+	end := len(data)
+	if n := bytes.IndexByte(data[idx:], '\n'); n >= 0 {
+		end = idx + n
+	}
+	line := strings.TrimRight(string(data[start:end]), "\r")
+	if len(line) > maxSearchLineLen {
+		line = line[:maxSearchLineLen]
+	}
+	return bytes.Count(data[:start], []byte{'\n'}) + 1, line
+}
+
+// ignoreRule is one pattern from a .gitignore file
+type ignoreRule struct {
+	dir      string // Directory of the .gitignore file
+	pattern  string
+	negate   bool // "!pattern" re-includes what earlier rules ignored
+	dirOnly  bool // "pattern/" only matches directories
+	anchored bool // Patterns with a slash match from dir, others match any name
+}
+
+// loadGitIgnore reads the rules of dir's .gitignore, if it has one. Only
+// the common syntax is supported: comments, "!", a trailing "/" and globs
+// with "**" for any number of directories.
+func loadGitIgnore(dir string) []ignoreRule {
+	file, err := os.Open(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		return nil
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			debugLog("[DEBUG] Search: Failed to close .gitignore: %v", err)
+		}
+	}()
+
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule := ignoreRule{dir: dir}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		rule.anchored = strings.Contains(line, "/")
+		rule.pattern = strings.TrimPrefix(line, "/")
+		if rule.pattern != "" {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// ignored applies rules to p in order; the last rule to match decides
+func ignored(rules []ignoreRule, p string, isDir bool) bool {
+	result := false
+	for _, rule := range rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		var matched bool
+		if rule.anchored {
+			rel, err := filepath.Rel(rule.dir, p)
+			if err != nil {
+				continue
+			}
+			matched = matchGlobPath(strings.Split(rule.pattern, "/"), strings.Split(filepath.ToSlash(rel), "/"))
+		} else {
+			matched, _ = path.Match(rule.pattern, filepath.Base(p))
+		}
+		if matched {
+			result = !rule.negate
+		}
+	}
+	return result
+}
+
+// matchGlobPath matches path segments against pattern segments, where a
+// "**" segment matches any number of path segments
+func matchGlobPath(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchGlobPath(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	if matched, _ := path.Match(pattern[0], segments[0]); !matched {
+		return false
+	}
+	return matchGlobPath(pattern[1:], segments[1:])
+}
+
+// handleSearchFS streams the files under root matching a name glob and,
+// optionally, containing some text as SSE events, so results show up while
+// large trees are still being searched
+func (s *Server) handleSearchFS(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	opts := FSSearchOptions{
+		Pattern:   query.Get("pattern"),
+		Content:   query.Get("content"),
+		MaxDepth:  defaultSearchDepth,
+		Limit:     defaultSearchResults,
+		GitIgnore: query.Get("gitignore") == "true",
+	}
+	if opts.Pattern == "" && opts.Content == "" {
+		writeJSONError(w, http.StatusBadRequest, "invalid_request", "pattern or content is required", nil)
+		return
+	}
+	if _, err := path.Match(strings.ToLower(opts.Pattern), ""); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_request", fmt.Sprintf("Invalid pattern: %v", err), map[string]interface{}{"pattern": opts.Pattern})
+		return
+	}
+	for name, value := range map[string]*int{"maxDepth": &opts.MaxDepth, "limit": &opts.Limit} {
+		if v := query.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				writeJSONError(w, http.StatusBadRequest, "invalid_request", fmt.Sprintf("%s must be a positive number", name), nil)
+				return
+			}
+			*value = n
+		}
+	}
+	opts.MaxDepth = min(opts.MaxDepth, maxSearchDepth)
+	opts.Limit = min(opts.Limit, maxSearchResults)
+
+	root := query.Get("root")
+	if root == "" {
+		root = "~"
+	}
+	root, err := expandHome(root)
+	if err != nil {
+		log.Printf("[ERROR] Failed to get home directory: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "internal_error", "Failed to get home directory", nil)
+		return
+	}
+	opts.Root, err = filepath.Abs(root)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_request", "Invalid path", nil)
+		return
+	}
+	if fi, err := os.Stat(opts.Root); err != nil || !fi.IsDir() {
+		writeJSONError(w, http.StatusNotFound, "path_not_found", "Directory not found", map[string]interface{}{"path": opts.Root})
+		return
+	}
+
+	debugLog("[DEBUG] Search request under %s: pattern=%q content=%q", opts.Root, opts.Pattern, opts.Content)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+
+	send := func(event interface{}) error {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+			return err // Client disconnected
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	}
+
+	count := 0
+	truncated, err := SearchFiles(r.Context(), opts, func(result FSSearchResult) error {
+		count++
+		return send(struct {
+			Type string `json:"type"`
+			FSSearchResult
+		}{"match", result})
+	})
+	if err != nil {
+		if r.Context().Err() != nil {
+			return // Client disconnected
+		}
+		log.Printf("[ERROR] Search under %s failed: %v", opts.Root, err)
+		if err := send(map[string]interface{}{"type": "error", "message": err.Error()}); err != nil {
+			debugLog("[DEBUG] Search: Client disconnected during error event: %v", err)
+		}
+		return
+	}
+	if err := send(map[string]interface{}{
+		"type":      "done",
+		"count":     count,
+		"truncated": truncated,
+	}); err != nil {
+		debugLog("[DEBUG] Search: Client disconnected during done event: %v", err)
+	}
+}
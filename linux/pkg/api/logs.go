@@ -0,0 +1,143 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/vibetunnel/linux/pkg/session"
+)
+
+// LogStreamer sends the lines of a session's log to an SSE client as they
+// are written, one line per event
+type LogStreamer struct {
+	w        http.ResponseWriter
+	ctx      context.Context
+	session  *session.Session
+	offset   int64
+	flusher  http.Flusher
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewLogStreamer creates a streamer that starts at offset, a byte position
+// in the session log as returned by Session.TailLog
+func NewLogStreamer(w http.ResponseWriter, r *http.Request, session *session.Session, offset int64) *LogStreamer {
+	flusher, _ := w.(http.Flusher)
+	return &LogStreamer{
+		w:       w,
+		ctx:     r.Context(),
+		session: session,
+		offset:  offset,
+		flusher: flusher,
+		done:    make(chan struct{}),
+	}
+}
+
+// Stop ends the log stream before the session does, for cancelling it
+// through /api/streams. Calling it again has no effect.
+func (l *LogStreamer) Stop() {
+	l.stopOnce.Do(func() {
+		close(l.done)
+	})
+}
+
+// Stream sends the log from the starting offset, then follows it until the
+// client goes away or the session is no longer running
+func (l *LogStreamer) Stream() {
+	l.w.Header().Set("Content-Type", "text/event-stream")
+	l.w.Header().Set("Cache-Control", "no-cache")
+	l.w.Header().Set("Connection", "keep-alive")
+	l.w.Header().Set("X-Accel-Buffering", "no")
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Printf("[ERROR] Logs: Failed to create file watcher: %v", err)
+		writeJSONError(l.w, http.StatusInternalServerError, "internal_error", "Failed to watch session log", nil)
+		return
+	}
+	defer func() {
+		if err := watcher.Close(); err != nil {
+			log.Printf("[ERROR] Logs: Failed to close watcher: %v", err)
+		}
+	}()
+
+	// The log is created by its first line, so watch the session directory
+	// rather than the file
+	if err := watcher.Add(l.session.Path()); err != nil {
+		log.Printf("[ERROR] Logs: Failed to watch session directory: %v", err)
+		writeJSONError(l.w, http.StatusInternalServerError, "internal_error", "Failed to watch session log", nil)
+		return
+	}
+	logName := filepath.Base(l.session.LogPath())
+
+	l.w.WriteHeader(http.StatusOK)
+	if err := l.sendNew(); err != nil || !l.session.IsAlive() {
+		return
+	}
+
+	aliveCheck := time.NewTicker(30 * time.Second)
+	defer aliveCheck.Stop()
+
+	for {
+		select {
+		case <-l.done:
+			return
+
+		case <-l.ctx.Done():
+			return
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Base(event.Name) != logName || event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+				continue
+			}
+			if err := l.sendNew(); err != nil {
+				return
+			}
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("[ERROR] Logs: File watcher error: %v", err)
+
+		case <-aliveCheck.C:
+			// Nothing is logged after the process exits, so send what is
+			// left and end the stream
+			if !l.session.IsAlive() {
+				_ = l.sendNew()
+				return
+			}
+		}
+	}
+}
+
+// sendNew sends the lines written since the last call; an error means the
+// client is gone
+func (l *LogStreamer) sendNew() error {
+	lines, next, err := l.session.ReadLog(l.offset)
+	if err != nil {
+		log.Printf("[ERROR] Logs: Failed to read session log: %v", err)
+		return nil
+	}
+	l.offset = next
+
+	for _, line := range lines {
+		if _, err := fmt.Fprintf(l.w, "data: %s\n\n", line); err != nil {
+			debugLog("[DEBUG] Logs: Client disconnected: %v", err)
+			return err
+		}
+	}
+	if l.flusher != nil {
+		l.flusher.Flush()
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -14,12 +15,23 @@ import (
 	"github.com/vibetunnel/linux/pkg/session"
 )
 
+// maxMultistreamSessions bounds how many sessions a discovering multistream
+// follows at once; further sessions are reported but not streamed
+const maxMultistreamSessions = 32
+
+// multistreamDiscoverInterval is how often a discovering multistream looks
+// for sessions that started or exited
+const multistreamDiscoverInterval = time.Second
+
 type MultiSSEStreamer struct {
 	w          http.ResponseWriter
 	manager    *session.Manager
 	sessionIDs []string
+	discover   bool // Follow all running sessions instead of sessionIDs
 	flusher    http.Flusher
+	writeMu    sync.Mutex // Session goroutines share the response
 	done       chan struct{}
+	stopOnce   sync.Once
 	wg         sync.WaitGroup
 }
 
@@ -34,23 +46,127 @@ func NewMultiSSEStreamer(w http.ResponseWriter, manager *session.Manager, sessio
 	}
 }
 
+// NewDiscoveringMultiSSEStreamer creates a multistream of all running
+// sessions. Sessions started later are added as they appear, announced with
+// a "session-added" event, and exited ones end with "session-removed".
+func NewDiscoveringMultiSSEStreamer(w http.ResponseWriter, manager *session.Manager) *MultiSSEStreamer {
+	m := NewMultiSSEStreamer(w, manager, nil)
+	m.discover = true
+	return m
+}
+
+// Stop ends the streams of every session in the multistream, whether it was
+// cancelled through /api/streams or a write to the client failed. Several
+// session goroutines can fail at once, so only the first call has an effect.
+func (m *MultiSSEStreamer) Stop() {
+	m.stopOnce.Do(func() {
+		close(m.done)
+	})
+}
+
 func (m *MultiSSEStreamer) Stream() {
 	m.w.Header().Set("Content-Type", "text/event-stream")
 	m.w.Header().Set("Cache-Control", "no-cache")
 	m.w.Header().Set("Connection", "keep-alive")
 	m.w.Header().Set("X-Accel-Buffering", "no")
 
-	// Start a goroutine for each session
-	for _, sessionID := range m.sessionIDs {
-		m.wg.Add(1)
-		go m.streamSession(sessionID)
+	if m.discover {
+		m.discoverSessions()
+	} else {
+		// Start a goroutine for each session
+		for _, sessionID := range m.sessionIDs {
+			m.wg.Add(1)
+			go m.streamSession(sessionID, nil, false)
+		}
 	}
 
 	// Wait for all streams to complete
 	m.wg.Wait()
 }
 
-func (m *MultiSSEStreamer) streamSession(sessionID string) {
+// discoverSessions keeps a stream running for every running session until
+// the multistream is stopped
+func (m *MultiSSEStreamer) discoverSessions() {
+	active := make(map[string]chan struct{})
+	skipped := make(map[string]bool) // Running but over the session limit
+	defer func() {
+		for _, stop := range active {
+			close(stop)
+		}
+	}()
+
+	ticker := time.NewTicker(multistreamDiscoverInterval)
+	defer ticker.Stop()
+
+	// Sessions running when the client connects are followed from now on
+	// like explicitly requested ones; later sessions from their beginning
+	initial := true
+	for {
+		sessions, err := m.manager.ListSessions()
+		if err != nil {
+			log.Printf("[ERROR] MultiStream: Failed to list sessions: %v", err)
+		}
+
+		running := make(map[string]bool)
+		for _, info := range sessions {
+			if info.Status == string(session.StatusRunning) {
+				running[info.ID] = true
+			}
+		}
+
+		for id, stop := range active {
+			if running[id] {
+				continue
+			}
+			close(stop)
+			delete(active, id)
+			if err := m.sendEvent(id, &protocol.StreamEvent{Type: "session-removed"}); err != nil {
+				return
+			}
+		}
+		for id := range skipped {
+			if !running[id] {
+				delete(skipped, id)
+			}
+		}
+
+		// Sessions are listed newest first, so the newest are streamed
+		// when there are too many
+		for _, info := range sessions {
+			id := info.ID
+			if !running[id] || active[id] != nil || skipped[id] {
+				continue
+			}
+			if len(active) >= maxMultistreamSessions {
+				skipped[id] = true
+				if err := m.sendError(id, fmt.Sprintf("Not streamed, already streaming %d sessions", maxMultistreamSessions)); err != nil {
+					return
+				}
+				continue
+			}
+
+			if err := m.sendEvent(id, &protocol.StreamEvent{Type: "session-added"}); err != nil {
+				return
+			}
+			stop := make(chan struct{})
+			active[id] = stop
+			m.wg.Add(1)
+			go m.streamSession(id, stop, !initial)
+		}
+		initial = false
+
+		select {
+		case <-m.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// streamSession follows one session's output, from the start of the
+// current stream-out segment if fromStart is set, until the multistream is
+// stopped or, for discovered sessions, stop is closed
+func (m *MultiSSEStreamer) streamSession(sessionID string, stop <-chan struct{}, fromStart bool) {
 	defer m.wg.Done()
 
 	sess, err := m.manager.GetSession(sessionID)
@@ -77,44 +193,128 @@ func (m *MultiSSEStreamer) streamSession(sessionID string) {
 	}()
 
 	// Seek to end for live streaming
-	if _, err := file.Seek(0, io.SeekEnd); err != nil {
-		log.Printf("Failed to seek to end of stream file: %v", err)
+	if !fromStart {
+		if _, err := file.Seek(0, io.SeekEnd); err != nil {
+			log.Printf("Failed to seek to end of stream file: %v", err)
+		}
 	}
 
-	reader := protocol.NewStreamReader(file)
+	// stream-out is followed line by line; the decoder-based StreamReader
+	// can't resume once it has hit the end of a growing file
+	var partial []byte
 	ticker := time.NewTicker(100 * time.Millisecond)
 	defer ticker.Stop()
 
-	for {
+	for tick := 1; ; tick++ {
 		select {
 		case <-m.done:
 			return
+		case <-stop:
+			return
 		case <-ticker.C:
-			for {
-				event, err := reader.Next()
-				if err != nil {
-					if err != io.EOF {
-						if err := m.sendError(sessionID, fmt.Sprintf("Stream read error: %v", err)); err != nil {
-							log.Printf("Failed to send stream error to client: %v", err)
-						}
-						return
-					}
-					break
-				}
+		}
 
-				if err := m.sendEvent(sessionID, event); err != nil {
-					return
-				}
+		// Check about once a second whether the session has exited, after
+		// which its remaining output is sent and the stream ends
+		exited := tick%10 == 0 && !sess.IsAlive()
 
-				if event.Type == "end" {
-					return
+		// Once stream-out is rotated nothing more is written to the file
+		// we have open, so finish it and then follow the new segment
+		rotated := streamRotated(file, streamPath)
+
+		data, err := io.ReadAll(file)
+		if err != nil {
+			if err := m.sendError(sessionID, fmt.Sprintf("Stream read error: %v", err)); err != nil {
+				log.Printf("Failed to send stream error to client: %v", err)
+			}
+			return
+		}
+		data = append(partial, data...)
+		for {
+			end := bytes.IndexByte(data, '\n')
+			if end < 0 {
+				break
+			}
+			event := parseStreamLine(data[:end])
+			data = data[end+1:]
+			if event == nil {
+				continue
+			}
+			if err := m.sendEvent(sessionID, event); err != nil {
+				return
+			}
+		}
+		partial = append([]byte(nil), data...)
+
+		if rotated {
+			next, err := os.Open(streamPath)
+			if err != nil {
+				if err := m.sendError(sessionID, fmt.Sprintf("Failed to open rotated stream: %v", err)); err != nil {
+					log.Printf("Failed to send error message: %v", err)
 				}
+				return
 			}
+			if err := file.Close(); err != nil {
+				log.Printf("Failed to close stream file: %v", err)
+			}
+			file = next
+			partial = nil
+		}
+
+		if exited {
+			if err := m.sendEvent(sessionID, &protocol.StreamEvent{Type: "end"}); err != nil {
+				debugLog("[DEBUG] MultiStream: Client disconnected during end event: %v", err)
+			}
+			return
 		}
 	}
 }
 
+// parseStreamLine converts a stream-out line, the header or an event, into a
+// stream event; nil means the line is malformed
+func parseStreamLine(line []byte) *protocol.StreamEvent {
+	if len(line) > 0 && line[0] == '{' {
+		var header protocol.AsciinemaHeader
+		if err := json.Unmarshal(line, &header); err != nil {
+			return nil
+		}
+		return &protocol.StreamEvent{Type: "header", Header: &header}
+	}
+
+	var array []interface{}
+	if err := json.Unmarshal(line, &array); err != nil || len(array) != 3 {
+		return nil
+	}
+	timestamp, ok1 := array[0].(float64)
+	eventType, ok2 := array[1].(string)
+	data, ok3 := array[2].(string)
+	if !ok1 || !ok2 || !ok3 {
+		return nil
+	}
+	return &protocol.StreamEvent{
+		Type: "event",
+		Event: &protocol.AsciinemaEvent{
+			Time: timestamp,
+			Type: protocol.EventType(eventType),
+			Data: data,
+		},
+	}
+}
+
+// sendEvent writes an event for sessionID. Once a write fails the client is
+// gone, and the whole multistream is stopped.
 func (m *MultiSSEStreamer) sendEvent(sessionID string, event *protocol.StreamEvent) error {
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+
+	if err := m.writeEvent(sessionID, event); err != nil {
+		m.Stop()
+		return err
+	}
+	return nil
+}
+
+func (m *MultiSSEStreamer) writeEvent(sessionID string, event *protocol.StreamEvent) error {
 	// Match Rust format: send raw arrays for terminal events
 	if event.Type == "event" && event.Event != nil {
 		// For terminal events, send as raw array
@@ -164,3 +364,26 @@ func (m *MultiSSEStreamer) sendError(sessionID string, message string) error {
 	}
 	return m.sendEvent(sessionID, event)
 }
+
+// streamRotated reports whether path now names a different file than file,
+// which happens when the recording is rotated
+func streamRotated(file *os.File, path string) bool {
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	current, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	return !os.SameFile(info, current)
+}
+
+// newSegment reports whether info, the stream-out file just opened, is a
+// new segment since last was read, and remembers it in last. Readers that
+// reopen stream-out by path use it to notice rotations.
+func newSegment(last *os.FileInfo, info os.FileInfo) bool {
+	rotated := *last != nil && !os.SameFile(*last, info)
+	*last = info
+	return rotated
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRotationIsDetectedByFileIdentity(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stream-out")
+	if err := os.WriteFile(path, []byte("first segment\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var segment os.FileInfo
+	info, err := file.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newSegment(&segment, info) {
+		t.Error("first read reported as a new segment")
+	}
+	if streamRotated(file, path) {
+		t.Error("rotation reported before the file was rotated")
+	}
+
+	// Rotate the way StreamWriter does; the new segment is larger, so its
+	// size alone doesn't give the rotation away
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("second, longer segment\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if !streamRotated(file, path) {
+		t.Error("rotation not detected for the open file")
+	}
+	info, err = os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !newSegment(&segment, info) {
+		t.Error("rotation not detected when reopening by path")
+	}
+	if newSegment(&segment, info) {
+		t.Error("same segment reported as new twice")
+	}
+}
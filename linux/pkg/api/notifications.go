@@ -0,0 +1,302 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/vibetunnel/linux/pkg/session"
+)
+
+// NotificationStreamer sends a session's notifications (started, exited,
+// bell) to an SSE client as they are written to its notification-stream
+type NotificationStreamer struct {
+	w        http.ResponseWriter
+	session  *session.Session
+	flusher  http.Flusher
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewNotificationStreamer(w http.ResponseWriter, session *session.Session) *NotificationStreamer {
+	flusher, _ := w.(http.Flusher)
+	return &NotificationStreamer{
+		w:       w,
+		session: session,
+		flusher: flusher,
+		done:    make(chan struct{}),
+	}
+}
+
+// Stop ends the session's notification stream early, for /api/streams
+// cancellation; later calls are ignored.
+func (n *NotificationStreamer) Stop() {
+	n.stopOnce.Do(func() {
+		close(n.done)
+	})
+}
+
+// Stream follows the notification-stream until the session exits or the
+// client goes away. Only notifications written after connecting are sent.
+func (n *NotificationStreamer) Stream() {
+	n.w.Header().Set("Content-Type", "text/event-stream")
+	n.w.Header().Set("Cache-Control", "no-cache")
+	n.w.Header().Set("Connection", "keep-alive")
+	n.w.Header().Set("X-Accel-Buffering", "no")
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Printf("[ERROR] Notifications: Failed to create file watcher: %v", err)
+		writeJSONError(n.w, http.StatusInternalServerError, "internal_error", "Failed to watch notifications", nil)
+		return
+	}
+	defer func() {
+		if err := watcher.Close(); err != nil {
+			log.Printf("[ERROR] Notifications: Failed to close watcher: %v", err)
+		}
+	}()
+
+	// The stream file is created by the first notification, so watch the
+	// session directory rather than the file
+	if err := watcher.Add(n.session.Path()); err != nil {
+		log.Printf("[ERROR] Notifications: Failed to watch session directory: %v", err)
+		writeJSONError(n.w, http.StatusInternalServerError, "internal_error", "Failed to watch notifications", nil)
+		return
+	}
+	streamName := filepath.Base(n.session.NotificationPath())
+
+	offset := n.session.NotificationStreamSize()
+	n.w.WriteHeader(http.StatusOK)
+	if n.flusher != nil {
+		n.flusher.Flush()
+	}
+
+	for {
+		select {
+		case <-n.done:
+			return
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Base(event.Name) != streamName || event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+				continue
+			}
+			notifications, next, err := n.session.ReadNotifications(offset)
+			if err != nil {
+				log.Printf("[ERROR] Notifications: Failed to read notification stream: %v", err)
+				continue
+			}
+			offset = next
+			for _, notification := range notifications {
+				if err := n.send(notification); err != nil {
+					debugLog("[DEBUG] Notifications: Client disconnected: %v", err)
+					return
+				}
+				if notification.Type == session.NotificationExited {
+					return
+				}
+			}
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("[ERROR] Notifications: File watcher error: %v", err)
+
+		case <-time.After(30 * time.Second):
+			// A session whose owner died never writes its exited notification
+			if !n.session.IsAlive() {
+				return
+			}
+		}
+	}
+}
+
+func (n *NotificationStreamer) send(notification session.Notification) error {
+	data, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(n.w, "data: %s\n\n", data); err != nil {
+		return err // Client disconnected
+	}
+	if n.flusher != nil {
+		n.flusher.Flush()
+	}
+	return nil
+}
+
+// EventsStreamer merges the notifications of all sessions into one SSE
+// stream. It watches the control directory, so it also picks up sessions
+// created later, including ones started by other processes.
+type EventsStreamer struct {
+	w           http.ResponseWriter
+	controlPath string
+	flusher     http.Flusher
+	done        chan struct{}
+	stopOnce    sync.Once
+}
+
+func NewEventsStreamer(w http.ResponseWriter, controlPath string) *EventsStreamer {
+	flusher, _ := w.(http.Flusher)
+	return &EventsStreamer{
+		w:           w,
+		controlPath: controlPath,
+		flusher:     flusher,
+		done:        make(chan struct{}),
+	}
+}
+
+// Stop ends the server-wide events stream when it is cancelled through
+// /api/streams. Only the first call closes it.
+func (e *EventsStreamer) Stop() {
+	e.stopOnce.Do(func() {
+		close(e.done)
+	})
+}
+
+// Stream sends notifications written after connecting until the client
+// goes away
+func (e *EventsStreamer) Stream() {
+	e.w.Header().Set("Content-Type", "text/event-stream")
+	e.w.Header().Set("Cache-Control", "no-cache")
+	e.w.Header().Set("Connection", "keep-alive")
+	e.w.Header().Set("X-Accel-Buffering", "no")
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Printf("[ERROR] Events: Failed to create file watcher: %v", err)
+		writeJSONError(e.w, http.StatusInternalServerError, "internal_error", "Failed to watch sessions", nil)
+		return
+	}
+	defer func() {
+		if err := watcher.Close(); err != nil {
+			log.Printf("[ERROR] Events: Failed to close watcher: %v", err)
+		}
+	}()
+
+	if err := os.MkdirAll(e.controlPath, 0755); err != nil {
+		log.Printf("[ERROR] Events: Failed to create control directory: %v", err)
+	}
+	if err := watcher.Add(e.controlPath); err != nil {
+		log.Printf("[ERROR] Events: Failed to watch control directory: %v", err)
+		writeJSONError(e.w, http.StatusInternalServerError, "internal_error", "Failed to watch sessions", nil)
+		return
+	}
+
+	// Offsets into each session's notification-stream. Existing sessions
+	// start at the end; sessions created later from the beginning, so their
+	// started notification is included.
+	offsets := make(map[string]int64)
+	entries, err := os.ReadDir(e.controlPath)
+	if err != nil {
+		log.Printf("[ERROR] Events: Failed to list sessions: %v", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			e.watchSession(watcher, offsets, entry.Name(), true)
+		}
+	}
+
+	e.w.WriteHeader(http.StatusOK)
+	if e.flusher != nil {
+		e.flusher.Flush()
+	}
+
+	for {
+		select {
+		case <-e.done:
+			return
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+
+			dir, name := filepath.Split(event.Name)
+			dir = filepath.Clean(dir)
+			switch {
+			case dir == filepath.Clean(e.controlPath):
+				// A session directory appeared or went away
+				if event.Op&fsnotify.Create != 0 {
+					e.watchSession(watcher, offsets, name, false)
+					// The stream may have been written before the watch
+					if err := e.sendNew(offsets, name); err != nil {
+						return
+					}
+				} else if event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+					delete(offsets, name)
+				}
+
+			case name == "notification-stream" && event.Op&(fsnotify.Write|fsnotify.Create) != 0:
+				if err := e.sendNew(offsets, filepath.Base(dir)); err != nil {
+					return
+				}
+			}
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("[ERROR] Events: File watcher error: %v", err)
+		}
+	}
+}
+
+// watchSession starts following the session directory id
+func (e *EventsStreamer) watchSession(watcher *fsnotify.Watcher, offsets map[string]int64, id string, fromEnd bool) {
+	dir := filepath.Join(e.controlPath, id)
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return
+	}
+	if err := watcher.Add(dir); err != nil {
+		log.Printf("[ERROR] Events: Failed to watch session %s: %v", id, err)
+		return
+	}
+
+	offsets[id] = 0
+	if fromEnd {
+		if info, err := os.Stat(filepath.Join(dir, "notification-stream")); err == nil {
+			offsets[id] = info.Size()
+		}
+	}
+}
+
+// sendNew sends the notifications of session id written since the last
+// call; an error means the client is gone
+func (e *EventsStreamer) sendNew(offsets map[string]int64, id string) error {
+	offset, ok := offsets[id]
+	if !ok {
+		return nil
+	}
+
+	notifications, next, err := session.ReadNotificationStream(filepath.Join(e.controlPath, id, "notification-stream"), offset)
+	if err != nil {
+		log.Printf("[ERROR] Events: Failed to read notifications of session %s: %v", id, err)
+		return nil
+	}
+	offsets[id] = next
+
+	for _, notification := range notifications {
+		data, err := json.Marshal(notification)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(e.w, "data: %s\n\n", data); err != nil {
+			debugLog("[DEBUG] Events: Client disconnected: %v", err)
+			return err
+		}
+	}
+	if len(notifications) > 0 && e.flusher != nil {
+		e.flusher.Flush()
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// dialBuffers opens a WebSocket to /buffers with the given Origin header,
+// none if empty, and returns the handshake's status code
+func dialBuffers(t *testing.T, serverURL, origin string) int {
+	t.Helper()
+	header := http.Header{}
+	if origin != "" {
+		header.Set("Origin", origin)
+	}
+	conn, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(serverURL, "http")+"/buffers", header)
+	if conn != nil {
+		if err := conn.Close(); err != nil {
+			t.Logf("Failed to close WebSocket: %v", err)
+		}
+	}
+	if resp == nil {
+		t.Fatalf("WebSocket handshake failed: %v", err)
+	}
+	return resp.StatusCode
+}
+
+func TestWebSocketOrigins(t *testing.T) {
+	s, ts := newTestServer(t)
+	s.SetAllowedOrigins([]string{"https://allowed.example", "https://slash.example/"})
+
+	tests := []struct {
+		name   string
+		origin string
+		want   int
+	}{
+		{"same origin", ts.URL, http.StatusSwitchingProtocols},
+		{"allowlisted", "https://allowed.example", http.StatusSwitchingProtocols},
+		{"allowlisted with a trailing slash", "https://slash.example", http.StatusSwitchingProtocols},
+		{"other site", "https://evil.example", http.StatusForbidden},
+		{"same host other scheme and port", "https://allowed.example:8443", http.StatusForbidden},
+		{"no origin", "", http.StatusSwitchingProtocols},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dialBuffers(t, ts.URL, tt.origin); got != tt.want {
+				t.Errorf("handshake status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebSocketAllowAllOrigins(t *testing.T) {
+	s, ts := newTestServer(t)
+	s.SetAllowAllOrigins(true)
+	if got := dialBuffers(t, ts.URL, "https://evil.example"); got != http.StatusSwitchingProtocols {
+		t.Errorf("handshake status = %d with all origins allowed, want 101", got)
+	}
+}
+
+func TestCORSOnlyForAllowlistedOrigins(t *testing.T) {
+	for _, allowAll := range []bool{false, true} {
+		s, ts := newTestServer(t)
+		s.SetAllowedOrigins([]string{"https://allowed.example"})
+		// Relaxes WebSocket origin checks only, never CORS
+		s.SetAllowAllOrigins(allowAll)
+
+		tests := []struct {
+			origin    string
+			preflight bool
+			want      bool
+		}{
+			{"https://allowed.example", false, true},
+			{"https://allowed.example", true, true},
+			{"https://evil.example", false, false},
+			{"https://evil.example", true, false},
+		}
+		for _, tt := range tests {
+			method := http.MethodGet
+			if tt.preflight {
+				method = http.MethodOptions
+			}
+			req, err := http.NewRequest(method, ts.URL+"/api/sessions", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Origin", tt.origin)
+			if tt.preflight {
+				req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("%s from %s: %v", method, tt.origin, err)
+			}
+			if err := resp.Body.Close(); err != nil {
+				t.Logf("Failed to close response body: %v", err)
+			}
+
+			allowOrigin := resp.Header.Get("Access-Control-Allow-Origin")
+			credentials := resp.Header.Get("Access-Control-Allow-Credentials")
+			if tt.want && (allowOrigin != tt.origin || credentials != "true") {
+				t.Errorf("allow-all %v, %s from %s: Allow-Origin %q, Allow-Credentials %q, want the origin with credentials", allowAll, method, tt.origin, allowOrigin, credentials)
+			}
+			if !tt.want && (allowOrigin != "" || credentials != "") {
+				t.Errorf("allow-all %v, %s from %s: Allow-Origin %q, Allow-Credentials %q, want no CORS headers", allowAll, method, tt.origin, allowOrigin, credentials)
+			}
+		}
+	}
+}
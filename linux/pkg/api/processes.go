@@ -0,0 +1,138 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/vibetunnel/linux/pkg/session"
+)
+
+// processCacheTTL bounds how often a session's process tree is rebuilt, so
+// rapid polling doesn't repeatedly walk /proc
+const processCacheTTL = time.Second
+
+type cachedProcesses struct {
+	processes []session.ProcessInfo
+	fetchedAt time.Time
+}
+
+// processCache holds recently computed process trees keyed by session ID
+type processCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedProcesses
+}
+
+func newProcessCache() *processCache {
+	return &processCache{
+		entries: make(map[string]cachedProcesses),
+	}
+}
+
+// get returns the process tree for sess, reusing a cached result if it is
+// younger than processCacheTTL
+func (c *processCache) get(sess *session.Session) ([]session.ProcessInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if entry, ok := c.entries[sess.ID]; ok && now.Sub(entry.fetchedAt) < processCacheTTL {
+		return entry.processes, nil
+	}
+
+	processes, err := sess.Processes()
+	if err != nil {
+		return nil, err
+	}
+
+	// Drop stale entries so sessions that are no longer polled don't linger
+	for id, entry := range c.entries {
+		if now.Sub(entry.fetchedAt) >= processCacheTTL {
+			delete(c.entries, id)
+		}
+	}
+	c.entries[sess.ID] = cachedProcesses{processes: processes, fetchedAt: now}
+
+	return processes, nil
+}
+
+// resourceSampleTTL is how long a session's last resource sample is kept
+// as the baseline for its CPU rate
+const resourceSampleTTL = time.Minute
+
+type cachedResources struct {
+	usage     *session.ResourceUsage
+	fetchedAt time.Time
+}
+
+// resourceCache holds recent resource samples keyed by session ID. Samples
+// younger than processCacheTTL are reused; older ones are the baseline the
+// next sample's CPU percentage is measured from.
+type resourceCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedResources
+}
+
+func newResourceCache() *resourceCache {
+	return &resourceCache{
+		entries: make(map[string]cachedResources),
+	}
+}
+
+// get returns the resource usage of sess, nil once it has exited
+func (c *resourceCache) get(sess *session.Session) (*session.ResourceUsage, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	prev, ok := c.entries[sess.ID]
+	if ok && now.Sub(prev.fetchedAt) < processCacheTTL {
+		return prev.usage, nil
+	}
+
+	usage, err := sess.Resources()
+	if err != nil {
+		return nil, err
+	}
+
+	// CPU used since the last sample is a better picture of what the session
+	// is doing now than the lifetime average
+	if ok && usage != nil && prev.usage != nil && now.Sub(prev.fetchedAt) < resourceSampleTTL {
+		if used := usage.CPUTime - prev.usage.CPUTime; used >= 0 {
+			usage.CPUPercent = used / now.Sub(prev.fetchedAt).Seconds() * 100
+		}
+	}
+
+	for id, entry := range c.entries {
+		if now.Sub(entry.fetchedAt) >= resourceSampleTTL {
+			delete(c.entries, id)
+		}
+	}
+	c.entries[sess.ID] = cachedResources{usage: usage, fetchedAt: now}
+
+	return usage, nil
+}
+
+func (s *Server) handleSessionProcesses(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	sess, err := s.manager.GetSession(vars["id"])
+	if err != nil {
+		writeSessionNotFound(w)
+		return
+	}
+
+	processes, err := s.processes.get(sess)
+	if err != nil {
+		log.Printf("[ERROR] Failed to list processes for session %s: %v", sess.ID, err)
+		writeJSONError(w, http.StatusInternalServerError, "internal_error", "Failed to list processes", nil)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(processes); err != nil {
+		log.Printf("Failed to encode processes response: %v", err)
+	}
+}
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/vibetunnel/linux/pkg/protocol"
+	"github.com/vibetunnel/linux/pkg/session"
+)
+
+const (
+	// maxRecordingUpload bounds the size of an uploaded cast file
+	maxRecordingUpload = 100 * 1024 * 1024
+
+	// maxPlaybackSpeed bounds the ?speed= multiplier for recording playback
+	maxPlaybackSpeed = 100
+)
+
+func (s *Server) handleListRecordings(w http.ResponseWriter, r *http.Request) {
+	recordings, err := s.manager.ListRecordings()
+	if err != nil {
+		log.Printf("[ERROR] Failed to list recordings: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "internal_error", "Failed to list recordings", nil)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(recordings); err != nil {
+		log.Printf("Failed to encode recordings response: %v", err)
+	}
+}
+
+// handleUploadRecording imports an asciinema v2 cast sent as the request
+// body. ?name= overrides the cast's title.
+func (s *Server) handleUploadRecording(w http.ResponseWriter, r *http.Request) {
+	body := http.MaxBytesReader(w, r.Body, maxRecordingUpload)
+
+	info, err := s.manager.ImportRecording(body, r.URL.Query().Get("name"))
+	if err != nil {
+		if _, ok := err.(*http.MaxBytesError); ok {
+			writeJSONError(w, http.StatusRequestEntityTooLarge, "recording_too_large", "Recording too large", nil)
+			return
+		}
+		writeJSONError(w, http.StatusBadRequest, "invalid_request", err.Error(), nil)
+		return
+	}
+
+	log.Printf("[INFO] Imported recording %s (%q, %d events)", info.ID[:8], info.Name, info.Events)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(info); err != nil {
+		log.Printf("Failed to encode recording response: %v", err)
+	}
+}
+
+// handleStreamRecording plays an imported recording over SSE in the same
+// format as a live session stream, honoring the original event timing.
+// ?speed= scales playback (e.g. 2 plays twice as fast).
+func (s *Server) handleStreamRecording(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	info, err := s.manager.GetRecording(id)
+	if err != nil {
+		if err == session.ErrRecordingNotFound {
+			writeJSONError(w, http.StatusNotFound, "recording_not_found", "Recording not found", nil)
+			return
+		}
+		log.Printf("[ERROR] Failed to load recording %s: %v", id, err)
+		writeJSONError(w, http.StatusInternalServerError, "internal_error", "Failed to load recording", nil)
+		return
+	}
+
+	speed := 1.0
+	if value := r.URL.Query().Get("speed"); value != "" {
+		speed, err = strconv.ParseFloat(value, 64)
+		if err != nil || speed <= 0 || speed > maxPlaybackSpeed {
+			writeJSONError(w, http.StatusBadRequest, "invalid_request", fmt.Sprintf("speed must be a number between 0 and %d", maxPlaybackSpeed), nil)
+			return
+		}
+	}
+
+	file, err := os.Open(s.manager.RecordingPath(info.ID))
+	if err != nil {
+		log.Printf("[ERROR] Failed to open recording %s: %v", info.ID, err)
+		writeJSONError(w, http.StatusInternalServerError, "internal_error", "Failed to open recording", nil)
+		return
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Printf("[ERROR] Failed to close recording %s: %v", info.ID, err)
+		}
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	streamer := NewSSEStreamer(w, r, nil)
+	streamID := s.streams.Register(info.ID, "playback", clientIP(r), streamer.Stop)
+	defer s.streams.Unregister(streamID)
+
+	// Size the client terminal first, as a live stream's resize event would
+	dims := fmt.Sprintf("%dx%d", info.Width, info.Height)
+	if err := streamer.sendRawEvent(&protocol.StreamEvent{
+		Type:  "event",
+		Event: &protocol.AsciinemaEvent{Time: 0, Type: protocol.EventResize, Data: dims},
+	}, ""); err != nil {
+		return
+	}
+
+	reader := protocol.NewStreamReader(file)
+	start := time.Now()
+	for {
+		event, err := reader.Next()
+		if err != nil {
+			// Validated on import, so this only happens if the file changed
+			if err := streamer.sendError(fmt.Sprintf("Failed to read recording: %v", err)); err != nil {
+				debugLog("[DEBUG] Playback: Failed to send error: %v", err)
+			}
+			return
+		}
+
+		switch event.Type {
+		case "event":
+			due := start.Add(time.Duration(event.Event.Time / speed * float64(time.Second)))
+			if wait := time.Until(due); wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-timer.C:
+				case <-streamer.done:
+					timer.Stop()
+					return
+				case <-streamer.ctx.Done():
+					timer.Stop()
+					return
+				}
+			}
+			if err := streamer.sendRawEvent(event, ""); err != nil {
+				debugLog("[DEBUG] Playback: Client disconnected: %v", err)
+				return
+			}
+		case "end":
+			if err := streamer.sendEvent(&protocol.StreamEvent{Type: "end"}); err != nil {
+				debugLog("[DEBUG] Playback: Client disconnected during end event: %v", err)
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+)
+
+// requestIDHeader carries the request ID in both directions, so a proxy or
+// client can pass its own ID and match its logs with the server's
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds client-supplied IDs, which end up in the logs
+const maxRequestIDLength = 64
+
+type requestIDContextKey struct{}
+
+// requestIDMiddleware assigns every request an ID, reusing a well-formed
+// incoming X-Request-ID, stores it in the request context and echoes it in
+// the response
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDContextKey{}, id)))
+	})
+}
+
+// validRequestID accepts IDs that are safe to log verbatim: short and made
+// of letters, digits, '-', '_' and '.'
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	// crypto/rand.Read never fails on supported platforms
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// requestID returns the ID assigned to r, or "" if the request didn't pass
+// through requestIDMiddleware
+func requestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDContextKey{}).(string)
+	return id
+}
+
+// logRequestf logs like log.Printf with r's request ID and client IP
+// appended, so all log lines of a request can be found by its ID
+func logRequestf(r *http.Request, format string, args ...interface{}) {
+	log.Printf(format+" [req=%s ip=%s]", append(args, requestID(r), clientIP(r))...)
+}
@@ -2,21 +2,31 @@ package api
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/exec"
 	"os/signal"
 	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/vibetunnel/linux/pkg/cloudflare"
 	"github.com/vibetunnel/linux/pkg/ngrok"
+	"github.com/vibetunnel/linux/pkg/protocol"
 	"github.com/vibetunnel/linux/pkg/session"
 	"github.com/vibetunnel/linux/pkg/terminal"
 	"github.com/vibetunnel/linux/pkg/termsocket"
@@ -32,23 +42,67 @@ func debugLog(format string, args ...interface{}) {
 type Server struct {
 	manager             *session.Manager
 	staticPath          string
-	password            string
 	ngrokService        *ngrok.Service
+	cloudflareService   *cloudflare.Service
 	port                int
 	noSpawn             bool
 	doNotAllowColumnSet bool
+	strictCwd           bool
+	allowSessionUser    bool
+	streams             *StreamRegistry
+	processes           *processCache
+	resources           *resourceCache
+	allowAllOrigins     bool
+	version             string
+	commit              string
+	tlsEnabled          bool
+	unixSocket          string
+	unixSocketMode      os.FileMode
+
+	// Settings that can change while the server runs, guarded by settingsMu
+	settingsMu       sync.RWMutex
+	password         string
+	maxConnections   int
+	maxStreamBacklog int64
+	maxReplay        int64
+	maxInput         int64
+	sseKeepAlive     time.Duration
+	allowedOrigins   []string
+	trustedProxies   trustedProxies
+	connLimit        *connLimitListener
+	buffers          *BufferWebSocketHandler
 }
 
 func NewServer(manager *session.Manager, staticPath, password string, port int) *Server {
 	return &Server{
-		manager:      manager,
-		staticPath:   staticPath,
-		password:     password,
-		ngrokService: ngrok.NewService(),
-		port:         port,
+		manager:           manager,
+		staticPath:        staticPath,
+		password:          password,
+		ngrokService:      ngrok.NewService(),
+		cloudflareService: cloudflare.NewService(),
+		port:              port,
+		streams:           NewStreamRegistry(),
+		processes:         newProcessCache(),
+		resources:         newResourceCache(),
+		maxConnections:    DefaultMaxConnections,
+		maxStreamBacklog:  DefaultMaxStreamBacklog,
+		maxReplay:         DefaultMaxReplay,
+		maxInput:          DefaultMaxInput,
+		sseKeepAlive:      DefaultSSEKeepAlive,
 	}
 }
 
+// SetVersion sets the build version reported by the health and version
+// endpoints
+func (s *Server) SetVersion(version string) {
+	s.version = version
+}
+
+// SetCommit sets the git commit reported by the version endpoint
+func (s *Server) SetCommit(commit string) {
+	s.commit = commit
+}
+
 func (s *Server) SetNoSpawn(noSpawn bool) {
 	s.noSpawn = noSpawn
 }
@@ -57,14 +111,142 @@ func (s *Server) SetDoNotAllowColumnSet(doNotAllowColumnSet bool) {
 	s.doNotAllowColumnSet = doNotAllowColumnSet
 }
 
+// SetStrictCwd makes session creation fail with an invalid working directory
+// error instead of falling back to the home directory
+func (s *Server) SetStrictCwd(strictCwd bool) {
+	s.strictCwd = strictCwd
+}
+
+// SetAllowSessionUser lets API clients choose the user sessions run as. Only
+// enable it when every client may act as any user on the host.
+func (s *Server) SetAllowSessionUser(allow bool) {
+	s.allowSessionUser = allow
+}
+
+// SetUnixSocket makes Start listen on a Unix socket at path, created with
+// the given permissions, instead of its TCP address. An empty path keeps TCP.
+func (s *Server) SetUnixSocket(path string, mode os.FileMode) {
+	s.unixSocket = path
+	s.unixSocketMode = mode
+}
+
+// The setters below may also be called while the server runs, e.g. when
+// the configuration is reloaded. Streams already open keep their settings.
+
+// SetPassword sets the password required for the API, with username
+// "admin". An empty password disables authentication.
+func (s *Server) SetPassword(password string) {
+	s.settingsMu.Lock()
+	defer s.settingsMu.Unlock()
+	s.password = password
+}
+
+// SetMaxConnections bounds concurrent connections, excluding long-lived
+// streams. Zero or less removes the limit.
+func (s *Server) SetMaxConnections(maxConnections int) {
+	s.settingsMu.Lock()
+	defer s.settingsMu.Unlock()
+	s.maxConnections = maxConnections
+	if s.connLimit != nil {
+		s.connLimit.setMax(maxConnections)
+	}
+}
+
+// SetMaxStreamBacklog sets how many bytes of output a stream client may fall
+// behind by before older output is skipped. 0 disables the limit.
+func (s *Server) SetMaxStreamBacklog(maxBacklog int64) {
+	s.settingsMu.Lock()
+	defer s.settingsMu.Unlock()
+	s.maxStreamBacklog = maxBacklog
+	if s.buffers != nil {
+		s.buffers.SetMaxBacklog(maxBacklog)
+	}
+}
+
+// SetMaxReplay caps the output replayed to stream clients on connect, which
+// otherwise starts at the last clear screen. 0 removes the cap.
+func (s *Server) SetMaxReplay(maxReplay int64) {
+	s.settingsMu.Lock()
+	defer s.settingsMu.Unlock()
+	s.maxReplay = maxReplay
+}
+
+// SetMaxInput caps the bytes of input a single POST to a session's input
+// endpoint may send. 0 removes the cap.
+func (s *Server) SetMaxInput(maxInput int64) {
+	s.settingsMu.Lock()
+	defer s.settingsMu.Unlock()
+	s.maxInput = maxInput
+}
+
+// SetSSEKeepAlive sets how long session streams may be silent before a
+// keep-alive comment is sent. 0 disables keep-alives.
+func (s *Server) SetSSEKeepAlive(interval time.Duration) {
+	s.settingsMu.Lock()
+	defer s.settingsMu.Unlock()
+	s.sseKeepAlive = interval
+}
+
+// SetAllowedOrigins sets the cross-origin callers, as full origins such as
+// "https://example.com", that may use the server besides its own origin
+func (s *Server) SetAllowedOrigins(origins []string) {
+	s.settingsMu.Lock()
+	defer s.settingsMu.Unlock()
+	s.allowedOrigins = origins
+}
+
+// SetTrustedProxies sets the proxies, as IP addresses, CIDR ranges or
+// "unix" for Unix socket peers, whose X-Forwarded-For and X-Real-IP headers
+// give the client IP used in logs and stream listings
+func (s *Server) SetTrustedProxies(proxies []string) {
+	trusted := parseTrustedProxies(proxies)
+	s.settingsMu.Lock()
+	defer s.settingsMu.Unlock()
+	s.trustedProxies = trusted
+}
+
+// currentPassword returns the password clients must send, empty if none
+func (s *Server) currentPassword() string {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	return s.password
+}
+
+// SetAllowAllOrigins disables the origin check on WebSocket upgrades. This
+// lets any website a user visits connect to their terminals and should only
+// be used on trusted networks. CORS still only allows the configured origins.
+func (s *Server) SetAllowAllOrigins(allowAll bool) {
+	s.allowAllOrigins = allowAll
+}
+
 func (s *Server) Start(addr string) error {
 	handler := s.createHandler()
 
-	// Setup graceful shutdown
+	// Setup graceful shutdown. Header and idle timeouts evict slow or idle
+	// clients; there is no write timeout since streams stay open indefinitely.
 	srv := &http.Server{
-		Addr:    addr,
-		Handler: handler,
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       120 * time.Second,
+		ConnContext:       withConn,
+	}
+
+	var listener net.Listener
+	var err error
+	if s.unixSocket != "" {
+		listener, err = listenUnix(s.unixSocket, s.unixSocketMode)
+	} else {
+		listener, err = net.Listen("tcp", addr)
+	}
+	if err != nil {
+		return err
 	}
+	// Installed even without a limit, so one can be set later
+	s.settingsMu.Lock()
+	s.connLimit = newConnLimitListener(listener, s.maxConnections)
+	s.settingsMu.Unlock()
+	listener = s.connLimit
 
 	// Handle shutdown signals
 	sigChan := make(chan os.Signal, 1)
@@ -87,6 +269,9 @@ func (s *Server) Start(addr string) error {
 			}
 		}
 
+		// cloudflared is a separate process and would outlive the server
+		s.cloudflareService.Cleanup()
+
 		// Shutdown HTTP server
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
@@ -95,31 +280,48 @@ func (s *Server) Start(addr string) error {
 		}
 	}()
 
-	return srv.ListenAndServe()
+	return srv.Serve(listener)
 }
 
 func (s *Server) createHandler() http.Handler {
 	r := mux.NewRouter()
 
+	// Public so a login page can show it; registered ahead of the
+	// authenticated /api routes, which would match it too
+	r.HandleFunc("/api/version", s.handleVersion).Methods("GET")
+
 	api := r.PathPrefix("/api").Subrouter()
-	if s.password != "" {
-		api.Use(s.basicAuthMiddleware)
-	}
+	api.Use(s.basicAuthMiddleware)
 
 	api.HandleFunc("/health", s.handleHealth).Methods("GET")
 	api.HandleFunc("/sessions", s.handleListSessions).Methods("GET")
 	api.HandleFunc("/sessions", s.handleCreateSession).Methods("POST")
+	api.HandleFunc("/sessions", s.handleDeleteSessions).Methods("DELETE")
+	// Registered before /sessions/{id}, which would match it too
+	api.HandleFunc("/sessions/export", s.handleExportSessions).Methods("GET")
+	api.HandleFunc("/sessions/import", s.handleImportSessions).Methods("POST")
+	api.Handle("/sessions/multistream", exemptFromConnLimit(http.HandlerFunc(s.handleMultistream))).Methods("GET")
 	api.HandleFunc("/sessions/{id}", s.handleGetSession).Methods("GET")
-	api.HandleFunc("/sessions/{id}/stream", s.handleStreamSession).Methods("GET")
+	api.Handle("/sessions/{id}/stream", exemptFromConnLimit(http.HandlerFunc(s.handleStreamSession))).Methods("GET")
+	api.Handle("/sessions/{id}/notifications", exemptFromConnLimit(http.HandlerFunc(s.handleSessionNotifications))).Methods("GET")
+	api.Handle("/sessions/{id}/logs", exemptFromConnLimit(http.HandlerFunc(s.handleSessionLogs))).Methods("GET")
 	api.HandleFunc("/sessions/{id}/snapshot", s.handleSnapshotSession).Methods("GET")
+	api.HandleFunc("/sessions/{id}/recording", s.handleDownloadRecording).Methods("GET")
+	api.HandleFunc("/sessions/{id}/processes", s.handleSessionProcesses).Methods("GET")
 	api.HandleFunc("/sessions/{id}/input", s.handleSendInput).Methods("POST")
 	api.HandleFunc("/sessions/{id}", s.handleKillSession).Methods("DELETE")
 	api.HandleFunc("/sessions/{id}/cleanup", s.handleCleanupSession).Methods("DELETE")
 	api.HandleFunc("/sessions/{id}/cleanup", s.handleCleanupSession).Methods("POST") // Alternative method
 	api.HandleFunc("/sessions/{id}/resize", s.handleResizeSession).Methods("POST")
-	api.HandleFunc("/sessions/multistream", s.handleMultistream).Methods("GET")
 	api.HandleFunc("/cleanup-exited", s.handleCleanupExited).Methods("POST")
+	api.Handle("/events", exemptFromConnLimit(http.HandlerFunc(s.handleEvents))).Methods("GET")
+	api.HandleFunc("/streams", s.handleListStreams).Methods("GET")
+	api.HandleFunc("/streams/{streamId}", s.handleCancelStream).Methods("DELETE")
+	api.HandleFunc("/recordings", s.handleListRecordings).Methods("GET")
+	api.HandleFunc("/recordings", s.handleUploadRecording).Methods("POST")
+	api.Handle("/recordings/{id}/stream", exemptFromConnLimit(http.HandlerFunc(s.handleStreamRecording))).Methods("GET")
 	api.HandleFunc("/fs/browse", s.handleBrowseFS).Methods("GET")
+	api.Handle("/fs/search", exemptFromConnLimit(http.HandlerFunc(s.handleSearchFS))).Methods("GET")
 	api.HandleFunc("/mkdir", s.handleMkdir).Methods("POST")
 
 	// Ngrok endpoints
@@ -127,25 +329,36 @@ func (s *Server) createHandler() http.Handler {
 	api.HandleFunc("/ngrok/stop", s.handleNgrokStop).Methods("POST")
 	api.HandleFunc("/ngrok/status", s.handleNgrokStatus).Methods("GET")
 
+	// Cloudflare Tunnel endpoints
+	api.HandleFunc("/cloudflare/start", s.handleCloudflareStart).Methods("POST")
+	api.HandleFunc("/cloudflare/stop", s.handleCloudflareStop).Methods("POST")
+	api.HandleFunc("/cloudflare/status", s.handleCloudflareStatus).Methods("GET")
+
 	// WebSocket endpoint for binary terminal streaming
-	bufferHandler := NewBufferWebSocketHandler(s.manager)
-	// Apply authentication middleware if password is set
-	if s.password != "" {
-		r.Handle("/buffers", s.basicAuthMiddleware(bufferHandler))
-	} else {
-		r.Handle("/buffers", bufferHandler)
-	}
+	wsHandler := NewBufferWebSocketHandler(s.manager, s.streams, s.checkOrigin)
+	s.settingsMu.Lock()
+	wsHandler.SetMaxBacklog(s.maxStreamBacklog)
+	s.buffers = wsHandler
+	s.settingsMu.Unlock()
+	r.Handle("/buffers", s.basicAuthMiddleware(exemptFromConnLimit(wsHandler)))
 
 	if s.staticPath != "" {
 		// Serve static files with index.html fallback for directories
 		r.PathPrefix("/").HandlerFunc(s.serveStaticWithIndex)
 	}
 
-	return r
+	return requestIDMiddleware(s.clientIPMiddleware(s.corsMiddleware(r)))
 }
 
+// basicAuthMiddleware requires the server password, if one is set
 func (s *Server) basicAuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		expected := s.currentPassword()
+		if expected == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
 		auth := r.Header.Get("Authorization")
 		if auth == "" {
 			s.unauthorized(w)
@@ -164,8 +377,17 @@ func (s *Server) basicAuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		parts := strings.SplitN(string(decoded), ":", 2)
-		if len(parts) != 2 || parts[0] != "admin" || parts[1] != s.password {
+		// Reject credentials without a separator before looking at the password
+		username, password, ok := strings.Cut(string(decoded), ":")
+		if !ok {
+			s.unauthorized(w)
+			return
+		}
+
+		// Use constant-time comparison so response timing doesn't leak credentials
+		usernameMatch := subtle.ConstantTimeCompare([]byte(username), []byte("admin"))
+		passwordMatch := subtle.ConstantTimeCompare([]byte(password), []byte(expected))
+		if usernameMatch&passwordMatch != 1 {
 			s.unauthorized(w)
 			return
 		}
@@ -177,9 +399,6 @@ func (s *Server) basicAuthMiddleware(next http.Handler) http.Handler {
 func (s *Server) serveStaticWithIndex(w http.ResponseWriter, r *http.Request) {
 	path := r.URL.Path
 
-	// Add CORS headers (like Rust server)
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-
 	// Clean the path
 	if path == "/" {
 		path = "/index.html"
@@ -229,20 +448,84 @@ func (s *Server) serveStaticWithIndex(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) unauthorized(w http.ResponseWriter) {
 	w.Header().Set("WWW-Authenticate", `Basic realm="VibeTunnel"`)
-	http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	writeJSONError(w, http.StatusUnauthorized, "unauthorized", "Unauthorized", nil)
 }
 
+// processStart is when the server process started, for the uptime in
+// health responses
+var processStart = time.Now()
+
+// HealthResponse is the body of /api/health
+type HealthResponse struct {
+	Status        string            `json:"status"` // "ok" or "unavailable"
+	Version       string            `json:"version"`
+	StartedAt     time.Time         `json:"startedAt"`
+	UptimeSeconds int64             `json:"uptimeSeconds"`
+	Sessions      HealthSessions    `json:"sessions"`
+	TLS           bool              `json:"tls"`
+	Ngrok         bool              `json:"ngrok"`
+	Cloudflare    bool              `json:"cloudflare"`
+	Checks        map[string]string `json:"checks,omitempty"` // Only with ?deep=true
+}
+
+// HealthSessions counts the sessions this server process holds by state
+type HealthSessions struct {
+	Active int `json:"active"`
+	Exited int `json:"exited"`
+}
+
+// handleHealth reports the server's version, uptime and session counts
+// without touching the disk. With ?deep=true it also checks the control
+// directory is writable, answering 503 if it isn't, for readiness probes.
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	health := HealthResponse{
+		Status:        "ok",
+		Version:       s.version,
+		StartedAt:     processStart,
+		UptimeSeconds: int64(time.Since(processStart).Seconds()),
+		TLS:           s.tlsEnabled,
+		Ngrok:         s.ngrokService.IsRunning(),
+		Cloudflare:    s.cloudflareService.IsRunning(),
+	}
+	health.Sessions.Active, health.Sessions.Exited = s.manager.SessionCounts()
+
+	status := http.StatusOK
+	if deep, _ := strconv.ParseBool(r.URL.Query().Get("deep")); deep {
+		health.Checks = map[string]string{"controlDir": "ok"}
+		if err := checkWritable(s.manager.ControlPath()); err != nil {
+			health.Checks["controlDir"] = err.Error()
+			health.Status = "unavailable"
+			status = http.StatusServiceUnavailable
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(map[string]string{"status": "ok"}); err != nil {
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(health); err != nil {
 		log.Printf("Failed to encode health response: %v", err)
 	}
 }
 
+// checkWritable verifies files can be created in dir
+func checkWritable(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(dir, ".health-*")
+	if err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		log.Printf("[ERROR] Failed to close health check file: %v", err)
+	}
+	return os.Remove(file.Name())
+}
+
 func (s *Server) handleListSessions(w http.ResponseWriter, r *http.Request) {
 	sessions, err := s.manager.ListSessions()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, "internal_error", err.Error(), nil)
 		return
 	}
 
@@ -255,79 +538,102 @@ func (s *Server) handleListSessions(w http.ResponseWriter, r *http.Request) {
 		Pid          *int              `json:"pid,omitempty"`
 		Status       string            `json:"status"`
 		ExitCode     *int              `json:"exitCode,omitempty"`
+		ExitReason   string            `json:"exitReason,omitempty"`
 		StartedAt    time.Time         `json:"startedAt"`
 		Term         string            `json:"term"`
 		Width        int               `json:"width"`
 		Height       int               `json:"height"`
 		Env          map[string]string `json:"env,omitempty"`
 		LastModified time.Time         `json:"lastModified"`
+		LastActivity time.Time         `json:"lastActivity"`
 	}
 
 	apiSessions := make([]APISessionInfo, len(sessions))
-	for i, s := range sessions {
+	for i, info := range sessions {
 		// Convert PID to pointer for omitempty behavior
 		var pid *int
-		if s.Pid > 0 {
-			pid = &s.Pid
+		if info.Pid > 0 {
+			pid = &info.Pid
 		}
 
 		apiSessions[i] = APISessionInfo{
-			ID:           s.ID,
-			Name:         s.Name,
-			Command:      s.Cmdline, // Already a string
-			WorkingDir:   s.Cwd,
+			ID:           info.ID,
+			Name:         info.Name,
+			Command:      info.Cmdline, // Already a string
+			WorkingDir:   info.Cwd,
 			Pid:          pid,
-			Status:       s.Status,
-			ExitCode:     s.ExitCode,
-			StartedAt:    s.StartedAt,
-			Term:         s.Term,
-			Width:        s.Width,
-			Height:       s.Height,
-			Env:          s.Env,
-			LastModified: s.StartedAt, // Use StartedAt as LastModified for now
+			Status:       info.Status,
+			ExitCode:     info.ExitCode,
+			ExitReason:   info.ExitReason,
+			StartedAt:    info.StartedAt,
+			Term:         info.Term,
+			Width:        info.Width,
+			Height:       info.Height,
+			Env:          s.manager.RedactEnv(info.Env),
+			LastModified: info.LastActivity,
+			LastActivity: info.LastActivity,
 		}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(apiSessions); err != nil {
 		log.Printf("Failed to encode sessions response: %v", err)
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, "internal_error", "Failed to encode response", nil)
 	}
 }
 
 func (s *Server) handleCreateSession(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		Name          string   `json:"name"`
-		Command       []string `json:"command"`        // Rust API format
-		WorkingDir    string   `json:"workingDir"`     // Rust API format
-		Cols          int      `json:"cols"`           // Terminal columns
-		Rows          int      `json:"rows"`           // Terminal rows
-		SpawnTerminal bool     `json:"spawn_terminal"` // Open in native terminal
-		Term          string   `json:"term"`           // Terminal type (e.g., "ghostty")
+		Name          string            `json:"name"`
+		Command       []string          `json:"command"`           // Rust API format
+		WorkingDir    string            `json:"workingDir"`        // Rust API format
+		Cols          int               `json:"cols"`              // Terminal columns
+		Rows          int               `json:"rows"`              // Terminal rows
+		SpawnTerminal bool              `json:"spawn_terminal"`    // Open in native terminal
+		Term          string            `json:"term"`              // Terminal type (e.g., "ghostty")
+		Encoding      string            `json:"encoding"`          // Output encoding (utf-8 or latin1)
+		Env           map[string]string `json:"env"`               // Extra environment variables
+		Argv0         string            `json:"argv0"`             // Overrides argv[0] seen by the command
+		InheritEnv    bool              `json:"inheritEnv"`        // Pass the full server environment (minus blocklist)
+		StrictCwd     bool              `json:"strictCwd"`         // Fail instead of falling back to home if workingDir is unusable
+		User          string            `json:"user"`              // Run as this user (needs --allow-session-user)
+		MaxRuntime    int               `json:"maxRuntimeSeconds"` // Kill the session after this many seconds
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body. Expected JSON with 'command' array and optional 'workingDir'", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "invalid_request", "Invalid request body. Expected JSON with 'command' array and optional 'workingDir'", nil)
 		return
 	}
 
 	if len(req.Command) == 0 {
-		http.Error(w, "Command array is required", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "invalid_request", "Command array is required", nil)
+		return
+	}
+
+	if _, err := protocol.ParseEncoding(req.Encoding); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_request", err.Error(), nil)
+		return
+	}
+
+	if req.MaxRuntime < 0 {
+		writeJSONError(w, http.StatusBadRequest, "invalid_request", "maxRuntimeSeconds must not be negative", nil)
+		return
+	}
+	maxRuntime := time.Duration(req.MaxRuntime) * time.Second
+
+	if req.User != "" && !s.allowSessionUser {
+		logRequestf(r, "[WARN] Rejected session for user %q: --allow-session-user is not enabled", req.User)
+		writeJSONError(w, http.StatusForbidden, "session_user_disabled", "Running sessions as another user is disabled by server configuration", nil)
 		return
 	}
 
 	cmdline := req.Command
 	cwd := req.WorkingDir
+	env := envMapToSlice(req.Env)
 
-	// Set default terminal dimensions if not provided
+	// Unset dimensions fall back to the manager's default size
 	cols := req.Cols
-	if cols <= 0 {
-		cols = 120 // Better default for modern terminals
-	}
 	rows := req.Rows
-	if rows <= 0 {
-		rows = 30 // Better default for modern terminals
-	}
 
 	// Handle working directory
 	if cwd != "" {
@@ -346,32 +652,57 @@ func (s *Server) handleCreateSession(w http.ResponseWriter, r *http.Request) {
 		}
 
 		// Validate the working directory exists
-		if _, err := os.Stat(cwd); err != nil {
-			log.Printf("[WARN] Working directory '%s' not accessible: %v. Using home directory instead.", cwd, err)
+		if err := session.CheckWorkingDir(cwd); err != nil {
+			if req.StrictCwd || s.strictCwd {
+				writeCreateSessionError(w, r, err)
+				return
+			}
+			logRequestf(r, "[WARN] %v. Using home directory instead.", err)
 			// Fall back to home directory
 			homeDir, err := os.UserHomeDir()
 			if err != nil {
-				log.Printf("[ERROR] Failed to get home directory: %v", err)
+				logRequestf(r, "[ERROR] Failed to get home directory: %v", err)
 				cwd = "" // Let PTY decide the default
 			} else {
 				cwd = homeDir
 			}
 		}
-	} else {
-		// No working directory specified, use home directory
+	} else if req.User == "" {
+		// No working directory specified, use home directory. Sessions
+		// running as another user start in that user's home instead.
 		homeDir, err := os.UserHomeDir()
 		if err == nil {
 			cwd = homeDir
 		}
 	}
 
+	// Reject commands that can't be started before creating anything.
+	// ?validate=true stops here, so clients can check a command up front.
+	resolved, err := session.ResolveCommand(session.Config{Cmdline: cmdline, Cwd: cwd, Env: env})
+	if err != nil {
+		writeCreateSessionError(w, r, err)
+		return
+	}
+	if r.URL.Query().Get("validate") == "true" {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": "Command is valid",
+			"error":   nil,
+			"command": resolved,
+		}); err != nil {
+			logRequestf(r, "Failed to encode response: %v", err)
+		}
+		return
+	}
+
 	// Check if we should spawn in a terminal
 	if req.SpawnTerminal && !s.noSpawn {
 		// Try to use the Mac app's terminal spawn service first
 		if conn, err := termsocket.TryConnect(""); err == nil {
 			defer func() {
 				if err := conn.Close(); err != nil {
-					log.Printf("Failed to close connection: %v", err)
+					logRequestf(r, "Failed to close connection: %v", err)
 				}
 			}()
 
@@ -381,8 +712,8 @@ func (s *Server) handleCreateSession(w http.ResponseWriter, r *http.Request) {
 			// Get vt binary path (not vibetunnel)
 			vtPath := findVTBinary()
 			if vtPath == "" {
-				log.Printf("[ERROR] vt binary not found")
-				http.Error(w, "vt binary not found", http.StatusInternalServerError)
+				logRequestf(r, "[ERROR] vt binary not found")
+				writeJSONError(w, http.StatusInternalServerError, "internal_error", "vt binary not found", nil)
 				return
 			}
 
@@ -397,28 +728,34 @@ func (s *Server) handleCreateSession(w http.ResponseWriter, r *http.Request) {
 
 			// Create the session first with the specified ID
 			sess, err := s.manager.CreateSessionWithID(sessionID, session.Config{
-				Name:      req.Name,
-				Cmdline:   cmdline,
-				Cwd:       cwd,
-				Width:     cols,
-				Height:    rows,
-				IsSpawned: true, // This is a spawned session
+				Name:       req.Name,
+				Cmdline:    cmdline,
+				Cwd:        cwd,
+				Width:      cols,
+				Height:     rows,
+				IsSpawned:  true, // This is a spawned session
+				Encoding:   req.Encoding,
+				Env:        env,
+				Argv0:      req.Argv0,
+				InheritEnv: req.InheritEnv,
+				User:       req.User,
+				MaxRuntime: maxRuntime,
 			})
 			if err != nil {
-				log.Printf("[ERROR] Failed to create session: %v", err)
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				logRequestf(r, "[ERROR] Failed to create session: %v", err)
+				writeSessionError(w, err)
 				return
 			}
 
 			// Send spawn request to Mac app
 			resp, err := termsocket.SendSpawnRequest(conn, spawnReq)
 			if err != nil {
-				log.Printf("[ERROR] Failed to send terminal spawn request: %v", err)
+				logRequestf(r, "[ERROR] Failed to send terminal spawn request: %v", err)
 				// Clean up the session since spawn failed
 				if err := s.manager.RemoveSession(sess.ID); err != nil {
-					log.Printf("Failed to remove session: %v", err)
+					logRequestf(r, "Failed to remove session: %v", err)
 				}
-				http.Error(w, fmt.Sprintf("Failed to spawn terminal: %v", err), http.StatusInternalServerError)
+				writeJSONError(w, http.StatusInternalServerError, "internal_error", fmt.Sprintf("Failed to spawn terminal: %v", err), nil)
 				return
 			}
 
@@ -427,16 +764,16 @@ func (s *Server) handleCreateSession(w http.ResponseWriter, r *http.Request) {
 				if errorMsg == "" {
 					errorMsg = "Unknown error"
 				}
-				log.Printf("[ERROR] Terminal spawn failed: %s", errorMsg)
+				logRequestf(r, "[ERROR] Terminal spawn failed: %s", errorMsg)
 				// Clean up the session since spawn failed
 				if err := s.manager.RemoveSession(sess.ID); err != nil {
-					log.Printf("Failed to remove session: %v", err)
+					logRequestf(r, "Failed to remove session: %v", err)
 				}
-				http.Error(w, fmt.Sprintf("Terminal spawn failed: %s", errorMsg), http.StatusInternalServerError)
+				writeJSONError(w, http.StatusInternalServerError, "internal_error", fmt.Sprintf("Terminal spawn failed: %s", errorMsg), nil)
 				return
 			}
 
-			log.Printf("[INFO] Successfully spawned terminal session via Mac app: %s", sessionID)
+			logRequestf(r, "[INFO] Successfully spawned terminal session via Mac app: %s", sessionID)
 
 			// Return success response
 			w.Header().Set("Content-Type", "application/json")
@@ -446,51 +783,63 @@ func (s *Server) handleCreateSession(w http.ResponseWriter, r *http.Request) {
 				"error":     nil,
 				"sessionId": sessionID,
 			}); err != nil {
-				log.Printf("Failed to encode response: %v", err)
+				logRequestf(r, "Failed to encode response: %v", err)
 			}
 			return
 		} else {
 			// Mac app terminal spawn service not available - fallback to native terminal spawning
-			log.Printf("[INFO] Mac app socket not available (%v), falling back to native terminal spawn", err)
+			logRequestf(r, "[INFO] Mac app socket not available (%v), falling back to native terminal spawn", err)
+
+			config := session.Config{
+				Name:       req.Name,
+				Cmdline:    cmdline,
+				Cwd:        cwd,
+				Width:      cols,
+				Height:     rows,
+				IsSpawned:  true, // This is a spawned session
+				Encoding:   req.Encoding,
+				Env:        env,
+				Argv0:      req.Argv0,
+				InheritEnv: req.InheritEnv,
+				User:       req.User,
+				MaxRuntime: maxRuntime,
+			}
+			if runtime.GOOS == "linux" {
+				s.spawnLinuxTerminal(w, r, config)
+				return
+			}
 
 			// Create session locally
-			sess, err := s.manager.CreateSession(session.Config{
-				Name:      req.Name,
-				Cmdline:   cmdline,
-				Cwd:       cwd,
-				Width:     cols,
-				Height:    rows,
-				IsSpawned: true, // This is a spawned session
-			})
+			sess, err := s.manager.CreateSession(config)
 			if err != nil {
-				log.Printf("[ERROR] Failed to create session: %v", err)
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				logRequestf(r, "[ERROR] Failed to create session: %v", err)
+				writeSessionError(w, err)
 				return
 			}
 
 			// Get vt binary path (not vibetunnel)
 			vtPath := findVTBinary()
 			if vtPath == "" {
-				log.Printf("[ERROR] vt binary not found for native terminal spawn")
+				logRequestf(r, "[ERROR] vt binary not found for native terminal spawn")
 				if err := s.manager.RemoveSession(sess.ID); err != nil {
-					log.Printf("Failed to remove session: %v", err)
+					logRequestf(r, "Failed to remove session: %v", err)
 				}
-				http.Error(w, "vt binary not found", http.StatusInternalServerError)
+				writeJSONError(w, http.StatusInternalServerError, "internal_error", "vt binary not found", nil)
 				return
 			}
 
 			// Spawn terminal using native method
 			if err := terminal.SpawnInTerminal(sess.ID, vtPath, cmdline, cwd); err != nil {
-				log.Printf("[ERROR] Failed to spawn native terminal: %v", err)
+				logRequestf(r, "[ERROR] Failed to spawn native terminal: %v", err)
 				// Clean up the session since terminal spawn failed
 				if err := s.manager.RemoveSession(sess.ID); err != nil {
-					log.Printf("Failed to remove session: %v", err)
+					logRequestf(r, "Failed to remove session: %v", err)
 				}
-				http.Error(w, fmt.Sprintf("Failed to spawn terminal: %v", err), http.StatusInternalServerError)
+				writeJSONError(w, http.StatusInternalServerError, "internal_error", fmt.Sprintf("Failed to spawn terminal: %v", err), nil)
 				return
 			}
 
-			log.Printf("[INFO] Successfully spawned terminal session natively: %s", sess.ID)
+			logRequestf(r, "[INFO] Successfully spawned terminal session natively: %s", sess.ID)
 
 			// Return success response
 			w.Header().Set("Content-Type", "application/json")
@@ -500,7 +849,7 @@ func (s *Server) handleCreateSession(w http.ResponseWriter, r *http.Request) {
 				"error":     nil,
 				"sessionId": sess.ID,
 			}); err != nil {
-				log.Printf("Failed to encode response: %v", err)
+				logRequestf(r, "Failed to encode response: %v", err)
 			}
 			return
 		}
@@ -508,18 +857,26 @@ func (s *Server) handleCreateSession(w http.ResponseWriter, r *http.Request) {
 
 	// Regular session creation
 	sess, err := s.manager.CreateSession(session.Config{
-		Name:      req.Name,
-		Cmdline:   cmdline,
-		Cwd:       cwd,
-		Width:     cols,
-		Height:    rows,
-		IsSpawned: false, // This is not a spawned session (detached)
+		Name:       req.Name,
+		Cmdline:    cmdline,
+		Cwd:        cwd,
+		Width:      cols,
+		Height:     rows,
+		IsSpawned:  false, // This is not a spawned session (detached)
+		Encoding:   req.Encoding,
+		Env:        env,
+		Argv0:      req.Argv0,
+		InheritEnv: req.InheritEnv,
+		User:       req.User,
+		MaxRuntime: maxRuntime,
 	})
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeCreateSessionError(w, r, err)
 		return
 	}
 
+	logRequestf(r, "[INFO] Created session %s", sess.ID)
+
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(map[string]interface{}{
 		"success":   true,
@@ -527,22 +884,44 @@ func (s *Server) handleCreateSession(w http.ResponseWriter, r *http.Request) {
 		"error":     nil,
 		"sessionId": sess.ID,
 	}); err != nil {
-		log.Printf("Failed to encode response: %v", err)
+		logRequestf(r, "Failed to encode response: %v", err)
+	}
+}
+
+// writeCreateSessionError reports a failed session creation. A command that
+// isn't found or can't be executed, an unusable working directory or user is
+// the client's mistake and gets a 400; running out of PTYs or file
+// descriptors gets a 503 with a hint for the administrator.
+func writeCreateSessionError(w http.ResponseWriter, r *http.Request, err error) {
+	logRequestf(r, "[WARN] Rejected session: %v", err)
+	writeSessionError(w, err)
+}
+
+// envMapToSlice converts an environment map to sorted KEY=VALUE entries
+func envMapToSlice(envMap map[string]string) []string {
+	if len(envMap) == 0 {
+		return nil
+	}
+	env := make([]string, 0, len(envMap))
+	for key, value := range envMap {
+		env = append(env, key+"="+value)
 	}
+	sort.Strings(env)
+	return env
 }
 
 func (s *Server) handleGetSession(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	sess, err := s.manager.GetSession(vars["id"])
 	if err != nil {
-		http.Error(w, "Session not found", http.StatusNotFound)
+		writeSessionNotFound(w)
 		return
 	}
 
 	// Get session info and convert to Rust-compatible format
 	info := sess.GetInfo()
 	if info == nil {
-		http.Error(w, "Session info not available", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, "internal_error", "Session info not available", nil)
 		return
 	}
 
@@ -553,17 +932,18 @@ func (s *Server) handleGetSession(w http.ResponseWriter, r *http.Request) {
 
 	// Convert to Rust-compatible format like in handleListSessions
 	rustInfo := session.RustSessionInfo{
-		ID:        info.ID,
-		Name:      info.Name,
-		Cmdline:   info.Args,
-		Cwd:       info.Cwd,
-		Status:    info.Status,
-		ExitCode:  info.ExitCode,
-		Term:      info.Term,
-		SpawnType: "pty",
-		Cols:      &info.Width,
-		Rows:      &info.Height,
-		Env:       info.Env,
+		ID:         info.ID,
+		Name:       info.Name,
+		Cmdline:    info.Args,
+		Cwd:        info.Cwd,
+		Status:     info.Status,
+		ExitCode:   info.ExitCode,
+		ExitReason: info.ExitReason,
+		Term:       info.Term,
+		SpawnType:  info.SpawnType,
+		Cols:       &info.Width,
+		Rows:       &info.Height,
+		Env:        s.manager.RedactEnv(info.Env),
 	}
 
 	if info.Pid > 0 {
@@ -583,6 +963,7 @@ func (s *Server) handleGetSession(w http.ResponseWriter, r *http.Request) {
 		"pid":        rustInfo.Pid,
 		"status":     rustInfo.Status,
 		"exitCode":   rustInfo.ExitCode,
+		"exitReason": rustInfo.ExitReason,
 		"startedAt":  rustInfo.StartedAt,
 		"term":       rustInfo.Term,
 		"width":      rustInfo.Cols,
@@ -594,6 +975,14 @@ func (s *Server) handleGetSession(w http.ResponseWriter, r *http.Request) {
 	if stat, err := os.Stat(sess.Path()); err == nil {
 		response["lastModified"] = stat.ModTime()
 	}
+	response["lastActivity"] = sess.LastActivity()
+
+	// Resource usage of the session's processes, null once it has exited
+	resources, err := s.resources.get(sess)
+	if err != nil {
+		log.Printf("[WARN] Failed to sample resources of session %s: %v", sess.ID, err)
+	}
+	response["resources"] = resources
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(response); err != nil {
@@ -605,25 +994,173 @@ func (s *Server) handleStreamSession(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	sess, err := s.manager.GetSession(vars["id"])
 	if err != nil {
-		http.Error(w, "Session not found", http.StatusNotFound)
+		writeSessionNotFound(w)
 		return
 	}
 
-	streamer := NewSSEStreamer(w, sess)
+	streamer := NewSSEStreamer(w, r, sess)
+	s.settingsMu.RLock()
+	streamer.SetMaxBacklog(s.maxStreamBacklog)
+	streamer.SetReplayLimit(s.maxReplay)
+	streamer.SetKeepAlive(s.sseKeepAlive)
+	s.settingsMu.RUnlock()
+	// ?full=true replays the whole recording instead of just its end
+	streamer.SetFullReplay(r.URL.Query().Get("full") == "true")
+	if tailParam := r.URL.Query().Get("tail"); tailParam != "" {
+		tail, err := strconv.Atoi(tailParam)
+		if err != nil || tail <= 0 {
+			writeJSONError(w, http.StatusBadRequest, "invalid_request", "tail must be a positive number of bytes", nil)
+			return
+		}
+		streamer.SetTail(tail)
+	}
+	// EventSource sends the id of the last event it saw when reconnecting.
+	// Ids that aren't ours get the usual replay rather than an error, which
+	// would stop EventSource from retrying.
+	if lastID := r.Header.Get("Last-Event-ID"); lastID != "" && !streamer.SetLastEventID(lastID) {
+		debugLog("[DEBUG] Ignoring Last-Event-ID %q", lastID)
+	}
+	streamID := s.streams.Register(sess.ID, "sse", clientIP(r), streamer.Stop)
+	defer s.streams.Unregister(streamID)
+
+	logRequestf(r, "[INFO] Stream %s opened for session %s", streamID, sess.ID)
 	streamer.Stream()
+	logRequestf(r, "[INFO] Stream %s closed", streamID)
+}
+
+// handleSessionNotifications streams a session's started, exited and bell
+// notifications as SSE
+func (s *Server) handleSessionNotifications(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	sess, err := s.manager.GetSession(vars["id"])
+	if err != nil {
+		writeSessionNotFound(w)
+		return
+	}
+
+	streamer := NewNotificationStreamer(w, sess)
+	streamID := s.streams.Register(sess.ID, "notifications", clientIP(r), streamer.Stop)
+	defer s.streams.Unregister(streamID)
+
+	streamer.Stream()
+}
+
+// handleSessionLogs returns a session's log as plain text. ?tail=N limits it
+// to the last N lines and ?follow=true streams new lines as SSE.
+func (s *Server) handleSessionLogs(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	sess, err := s.manager.GetSession(vars["id"])
+	if err != nil {
+		writeSessionNotFound(w)
+		return
+	}
+
+	query := r.URL.Query()
+	tail := 0
+	if tailParam := query.Get("tail"); tailParam != "" {
+		tail, err = strconv.Atoi(tailParam)
+		if err != nil || tail <= 0 {
+			writeJSONError(w, http.StatusBadRequest, "invalid_request", "tail must be a positive number of lines", nil)
+			return
+		}
+	}
+	follow := false
+	if followParam := query.Get("follow"); followParam != "" {
+		follow, err = strconv.ParseBool(followParam)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid_request", "follow must be true or false", nil)
+			return
+		}
+	}
+
+	offset, err := sess.TailLog(tail)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "internal_error", err.Error(), nil)
+		return
+	}
+
+	if follow {
+		streamer := NewLogStreamer(w, r, sess, offset)
+		streamID := s.streams.Register(sess.ID, "logs", clientIP(r), streamer.Stop)
+		defer s.streams.Unregister(streamID)
+
+		streamer.Stream()
+		return
+	}
+
+	lines, _, err := sess.ReadLog(offset)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "internal_error", err.Error(), nil)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return // Client disconnected
+		}
+	}
 }
 
 func (s *Server) handleSnapshotSession(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	sess, err := s.manager.GetSession(vars["id"])
 	if err != nil {
-		http.Error(w, "Session not found", http.StatusNotFound)
+		writeSessionNotFound(w)
+		return
+	}
+
+	// ?tail=N returns just the last N bytes of output, served from memory
+	// for running sessions
+	if tailParam := r.URL.Query().Get("tail"); tailParam != "" {
+		tail, err := strconv.Atoi(tailParam)
+		if err != nil || tail <= 0 {
+			writeJSONError(w, http.StatusBadRequest, "invalid_request", "tail must be a positive number of bytes", nil)
+			return
+		}
+
+		snapshot, fromMemory, err := GetSessionTail(sess, tail)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "internal_error", err.Error(), nil)
+			return
+		}
+
+		source := "disk"
+		if fromMemory {
+			source = "memory"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Snapshot-Source", source)
+		if err := json.NewEncoder(w).Encode(snapshot); err != nil {
+			log.Printf("Failed to encode response: %v", err)
+		}
 		return
 	}
 
-	snapshot, err := GetSessionSnapshot(sess)
+	// By default the snapshot starts at the last clear screen. ?full=true
+	// returns the whole history and ?since=T only events after timestamp T.
+	var opts SnapshotOptions
+	query := r.URL.Query()
+	if fullParam := query.Get("full"); fullParam != "" {
+		full, err := strconv.ParseBool(fullParam)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid_request", "full must be true or false", nil)
+			return
+		}
+		opts.Full = full
+	}
+	if sinceParam := query.Get("since"); sinceParam != "" {
+		since, err := strconv.ParseFloat(sinceParam, 64)
+		if err != nil || since < 0 {
+			writeJSONError(w, http.StatusBadRequest, "invalid_request", "since must be a non-negative timestamp in seconds", nil)
+			return
+		}
+		opts.Since = since
+		opts.Full = true // Catch-up never trims to a clear screen
+	}
+
+	snapshot, err := GetSessionSnapshot(sess, opts)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, "internal_error", err.Error(), nil)
 		return
 	}
 
@@ -633,24 +1170,86 @@ func (s *Server) handleSnapshotSession(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (s *Server) handleDownloadRecording(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	sess, err := s.manager.GetSession(vars["id"])
+	if err != nil {
+		writeSessionNotFound(w)
+		return
+	}
+
+	// Long recordings are rotated; ?segment=previous fetches the segment
+	// that was rotated out most recently
+	path := sess.StreamOutPath()
+	filename := sess.ID + ".cast"
+	if r.URL.Query().Get("segment") == "previous" {
+		path = sess.PreviousStreamOutPath()
+		filename = sess.ID + ".1.cast"
+		if _, err := os.Stat(path); err != nil {
+			writeJSONError(w, http.StatusNotFound, "recording_not_found", "No previous recording segment", nil)
+			return
+		}
+	}
+
+	// The cast keeps the initial geometry in its header and records every
+	// resize as an "r" event, so players replay at the correct size
+	info := sess.GetInfo()
+	w.Header().Set("Content-Type", "application/x-asciicast")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Header().Set("X-Terminal-Cols", fmt.Sprintf("%d", info.Width))
+	w.Header().Set("X-Terminal-Rows", fmt.Sprintf("%d", info.Height))
+	http.ServeFile(w, r, path)
+}
+
+// DefaultMaxInput bounds the input sent to a session in one request
+const DefaultMaxInput = 1024 * 1024
+
+// autoDetectedKeys are the key names that are sent as keys even when they
+// come as text, like the Swift/macOS version does
+var autoDetectedKeys = []string{
+	"arrow_up", "arrow_down", "arrow_right", "arrow_left",
+	"escape", "enter", "ctrl_enter", "shift_enter",
+}
+
 func (s *Server) handleSendInput(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	sess, err := s.manager.GetSession(vars["id"])
 	if err != nil {
 		log.Printf("[ERROR] handleSendInput: Session %s not found", vars["id"])
-		http.Error(w, "Session not found", http.StatusNotFound)
+		writeSessionNotFound(w)
+		return
+	}
+
+	// Playback sessions have no PTY to write to
+	if sess.IsPlayback() {
+		writeJSONError(w, http.StatusConflict, "session_read_only", "Session is read-only (playback session has no live process)", nil)
 		return
 	}
 
 	var req struct {
 		Input string `json:"input"`
-		Text  string `json:"text"` // Alternative field name
-		Type  string `json:"type"`
+		Text  string `json:"text"`  // Alternative field name
+		Type  string `json:"type"`  // "key" to send Key
+		Key   string `json:"key"`   // Key name, e.g. "ctrl_c" or "f5"
+		Paste bool   `json:"paste"` // Bracket the text if the program wants pastes marked
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	s.settingsMu.RLock()
+	maxInput := s.maxInput
+	s.settingsMu.RUnlock()
+
+	body := r.Body
+	if maxInput > 0 {
+		// JSON escapes take up to six bytes per input byte
+		body = http.MaxBytesReader(w, r.Body, 6*maxInput+4096)
+	}
+	if err := json.NewDecoder(body).Decode(&req); err != nil {
+		if _, ok := err.(*http.MaxBytesError); ok {
+			writeJSONError(w, http.StatusRequestEntityTooLarge, "input_too_large", fmt.Sprintf("Input exceeds the limit of %d bytes", maxInput), nil)
+			return
+		}
 		log.Printf("[ERROR] handleSendInput: Failed to decode request: %v", err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "invalid_request", err.Error(), nil)
 		return
 	}
 
@@ -659,31 +1258,44 @@ func (s *Server) handleSendInput(w http.ResponseWriter, r *http.Request) {
 	if input == "" && req.Text != "" {
 		input = req.Text
 	}
+	if maxInput > 0 && int64(len(input)) > maxInput {
+		writeJSONError(w, http.StatusRequestEntityTooLarge, "input_too_large", fmt.Sprintf("Input exceeds the limit of %d bytes", maxInput), nil)
+		return
+	}
 
-	// Define special keys exactly as in Swift/macOS version
-	specialKeys := map[string]string{
-		"arrow_up":    "\x1b[A",
-		"arrow_down":  "\x1b[B",
-		"arrow_right": "\x1b[C",
-		"arrow_left":  "\x1b[D",
-		"escape":      "\x1b",
-		"enter":       "\r",       // CR, not LF (to match Swift)
-		"ctrl_enter":  "\r",       // CR for ctrl+enter
-		"shift_enter": "\x1b\x0d", // ESC + CR for shift+enter
+	// Key names are only recognized in text for the keys of the Swift
+	// version; the others would turn ordinary words like "end" into keys
+	key := req.Key
+	if req.Type == "key" && key == "" {
+		key = input
+	} else if key == "" && slices.Contains(autoDetectedKeys, input) {
+		key = input
 	}
 
-	// Check if this is a special key (automatic detection like Swift version)
-	if mappedKey, isSpecialKey := specialKeys[input]; isSpecialKey {
-		debugLog("[DEBUG] handleSendInput: Sending special key '%s' (%q) to session %s", input, mappedKey, sess.ID[:8])
+	if key != "" {
+		mappedKey, ok := session.KeySequence(key)
+		if !ok {
+			writeJSONError(w, http.StatusBadRequest, "unknown_key", fmt.Sprintf("Unknown key %q", key), nil)
+			return
+		}
+		debugLog("[DEBUG] handleSendInput: Sending special key '%s' (%q) to session %s", key, mappedKey, sess.ID[:8])
 		err = sess.SendKey(mappedKey)
+	} else if req.Paste {
+		debugLog("[DEBUG] handleSendInput: Pasting %d bytes to session %s (bracketed: %v)", len(input), sess.ID[:8], sess.IsBracketedPaste())
+		err = sess.SendPaste(input)
 	} else {
-		debugLog("[DEBUG] handleSendInput: Sending text '%s' to session %s", input, sess.ID[:8])
+		if sess.IsEchoEnabled() {
+			debugLog("[DEBUG] handleSendInput: Sending text '%s' to session %s", input, sess.ID[:8])
+		} else {
+			// Echo is off, e.g. at a password prompt; don't log what is typed
+			debugLog("[DEBUG] handleSendInput: Sending %d bytes of text to session %s", len(input), sess.ID[:8])
+		}
 		err = sess.SendText(input)
 	}
 
 	if err != nil {
 		log.Printf("[ERROR] handleSendInput: Failed to send input: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeSessionError(w, err)
 		return
 	}
 
@@ -695,13 +1307,13 @@ func (s *Server) handleKillSession(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	sess, err := s.manager.GetSession(vars["id"])
 	if err != nil {
-		http.Error(w, "Session not found", http.StatusNotFound)
+		writeSessionNotFound(w)
 		return
 	}
 
 	// Update session status before attempting kill
 	if err := sess.UpdateStatus(); err != nil {
-		log.Printf("Failed to update session status: %v", err)
+		logRequestf(r, "Failed to update session status: %v", err)
 	}
 
 	// Check if session is already dead
@@ -714,30 +1326,31 @@ func (s *Server) handleKillSession(w http.ResponseWriter, r *http.Request) {
 			"success": true,
 			"message": "Session already exited",
 		}); err != nil {
-			log.Printf("Failed to encode response: %v", err)
+			logRequestf(r, "Failed to encode response: %v", err)
 		}
 		return
 	}
 
 	if err := sess.Kill(); err != nil {
-		log.Printf("[ERROR] Failed to kill session %s: %v", vars["id"], err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		logRequestf(r, "[ERROR] Failed to kill session %s: %v", vars["id"], err)
+		writeSessionError(w, err)
 		return
 	}
+	logRequestf(r, "[INFO] Killed session %s", sess.ID)
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
 		"message": "Session deleted successfully",
 	}); err != nil {
-		log.Printf("Failed to encode response: %v", err)
+		logRequestf(r, "Failed to encode response: %v", err)
 	}
 }
 
 func (s *Server) handleCleanupSession(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	if err := s.manager.RemoveSession(vars["id"]); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, "internal_error", err.Error(), nil)
 		return
 	}
 
@@ -746,22 +1359,112 @@ func (s *Server) handleCleanupSession(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) handleCleanupExited(w http.ResponseWriter, r *http.Request) {
 	if err := s.manager.RemoveExitedSessions(); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, "internal_error", err.Error(), nil)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// handleDeleteSessions removes the exited sessions, with ?olderThan= (a
+// duration like "1h") only those that exited at least that long ago, and
+// reports which were removed. ?status=exited is required so a bare DELETE
+// can't be mistaken for removing everything.
+func (s *Server) handleDeleteSessions(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("status") != string(session.StatusExited) {
+		writeJSONError(w, http.StatusBadRequest, "invalid_request", "Only exited sessions can be removed; pass status=exited", nil)
+		return
+	}
+
+	var olderThan time.Duration
+	if value := query.Get("olderThan"); value != "" {
+		var err error
+		olderThan, err = time.ParseDuration(value)
+		if err != nil || olderThan < 0 {
+			writeJSONError(w, http.StatusBadRequest, "invalid_request", fmt.Sprintf("Invalid olderThan %q: expected a duration like 30m or 24h", value), nil)
+			return
+		}
+	}
+
+	removed, err := s.manager.ReapExitedSessions(olderThan)
+	if err != nil {
+		logRequestf(r, "[ERROR] Failed to remove exited sessions: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "internal_error", err.Error(), nil)
+		return
+	}
+	logRequestf(r, "[INFO] Removed %d exited sessions", len(removed))
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"removed": len(removed),
+		"ids":     removed,
+	}); err != nil {
+		log.Printf("Failed to encode cleanup response: %v", err)
+	}
+}
+
+// handleMultistream streams the sessions given as session_id parameters, or
+// with all=true every running session, including ones started later
 func (s *Server) handleMultistream(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("all") == "true" {
+		streamer := NewDiscoveringMultiSSEStreamer(w, s.manager)
+		streamID := s.streams.Register("*", "multistream", clientIP(r), streamer.Stop)
+		defer s.streams.Unregister(streamID)
+
+		logRequestf(r, "[INFO] Stream %s opened for all sessions", streamID)
+		streamer.Stream()
+		logRequestf(r, "[INFO] Stream %s closed", streamID)
+		return
+	}
+
 	sessionIDs := r.URL.Query()["session_id"]
 	if len(sessionIDs) == 0 {
-		http.Error(w, "No session IDs provided", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "invalid_request", "No session IDs provided", nil)
 		return
 	}
 
 	streamer := NewMultiSSEStreamer(w, s.manager, sessionIDs)
+	streamID := s.streams.Register(strings.Join(sessionIDs, ","), "multistream", clientIP(r), streamer.Stop)
+	defer s.streams.Unregister(streamID)
+
+	logRequestf(r, "[INFO] Stream %s opened for sessions %s", streamID, strings.Join(sessionIDs, ","))
 	streamer.Stream()
+	logRequestf(r, "[INFO] Stream %s closed", streamID)
+}
+
+// handleEvents streams the notifications of all sessions as SSE
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	streamer := NewEventsStreamer(w, s.manager.ControlPath())
+	streamID := s.streams.Register("", "events", clientIP(r), streamer.Stop)
+	defer s.streams.Unregister(streamID)
+
+	streamer.Stream()
+}
+
+func (s *Server) handleListStreams(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.streams.List()); err != nil {
+		log.Printf("Failed to encode streams response: %v", err)
+	}
+}
+
+func (s *Server) handleCancelStream(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if !s.streams.Cancel(vars["streamId"]) {
+		writeJSONError(w, http.StatusNotFound, "stream_not_found", "Stream not found", nil)
+		return
+	}
+
+	log.Printf("[INFO] Cancelled stream %s", vars["streamId"])
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Stream cancelled",
+	}); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
 }
 
 func (s *Server) handleBrowseFS(w http.ResponseWriter, r *http.Request) {
@@ -773,32 +1476,29 @@ func (s *Server) handleBrowseFS(w http.ResponseWriter, r *http.Request) {
 	log.Printf("[DEBUG] Browse directory request for path: %s", path)
 
 	// Expand ~ to home directory
-	if path == "~" || strings.HasPrefix(path, "~/") {
-		homeDir, err := os.UserHomeDir()
-		if err != nil {
-			log.Printf("[ERROR] Failed to get home directory: %v", err)
-			http.Error(w, "Failed to get home directory", http.StatusInternalServerError)
-			return
-		}
-		if path == "~" {
-			path = homeDir
-		} else {
-			path = filepath.Join(homeDir, path[2:])
-		}
+	path, err := expandHome(path)
+	if err != nil {
+		log.Printf("[ERROR] Failed to get home directory: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "internal_error", "Failed to get home directory", nil)
+		return
 	}
 
 	// Ensure the path is absolute
 	absPath, err := filepath.Abs(path)
 	if err != nil {
 		log.Printf("[ERROR] Failed to get absolute path for %s: %v", path, err)
-		http.Error(w, "Invalid path", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "invalid_request", "Invalid path", nil)
 		return
 	}
 
 	entries, err := BrowseDirectory(absPath)
 	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			writeJSONError(w, http.StatusNotFound, "path_not_found", fmt.Sprintf("Failed to read directory: %v", err), map[string]interface{}{"path": absPath})
+			return
+		}
 		log.Printf("[ERROR] Failed to browse directory %s: %v", absPath, err)
-		http.Error(w, fmt.Sprintf("Failed to read directory: %v", err), http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, "internal_error", fmt.Sprintf("Failed to read directory: %v", err), nil)
 		return
 	}
 
@@ -827,7 +1527,7 @@ func (s *Server) handleMkdir(w http.ResponseWriter, r *http.Request) {
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		log.Printf("[ERROR] Failed to decode mkdir request: %v", err)
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "invalid_request", "Invalid request body", nil)
 		return
 	}
 
@@ -840,31 +1540,24 @@ func (s *Server) handleMkdir(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if fullPath == "" {
-		http.Error(w, "Path is required", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "invalid_request", "Path is required", nil)
 		return
 	}
 
 	log.Printf("[DEBUG] Create directory request for path: %s", fullPath)
 
 	// Expand ~ to home directory
-	if fullPath == "~" || strings.HasPrefix(fullPath, "~/") {
-		homeDir, err := os.UserHomeDir()
-		if err != nil {
-			log.Printf("[ERROR] Failed to get home directory: %v", err)
-			http.Error(w, "Failed to get home directory", http.StatusInternalServerError)
-			return
-		}
-		if fullPath == "~" {
-			fullPath = homeDir
-		} else {
-			fullPath = filepath.Join(homeDir, fullPath[2:])
-		}
+	fullPath, err := expandHome(fullPath)
+	if err != nil {
+		log.Printf("[ERROR] Failed to get home directory: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "internal_error", "Failed to get home directory", nil)
+		return
 	}
 
 	// Create directory with proper permissions
 	if err := os.MkdirAll(fullPath, 0755); err != nil {
 		log.Printf("[ERROR] Failed to create directory %s: %v", fullPath, err)
-		http.Error(w, fmt.Sprintf("Failed to create directory: %v", err), http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, "internal_error", fmt.Sprintf("Failed to create directory: %v", err), nil)
 		return
 	}
 
@@ -883,7 +1576,7 @@ func (s *Server) handleResizeSession(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	sess, err := s.manager.GetSession(vars["id"])
 	if err != nil {
-		http.Error(w, "Session not found", http.StatusNotFound)
+		writeSessionNotFound(w)
 		return
 	}
 
@@ -893,12 +1586,17 @@ func (s *Server) handleResizeSession(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "invalid_request", "Invalid request body", nil)
 		return
 	}
 
 	if req.Cols <= 0 || req.Rows <= 0 {
-		http.Error(w, "Cols and rows must be positive integers", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "invalid_request", "Cols and rows must be positive integers", nil)
+		return
+	}
+
+	if sess.IsPlayback() {
+		writeJSONError(w, http.StatusConflict, "session_read_only", "Session is read-only (playback session has no live process)", nil)
 		return
 	}
 
@@ -917,7 +1615,7 @@ func (s *Server) handleResizeSession(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := sess.Resize(req.Cols, req.Rows); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeSessionError(w, err)
 		return
 	}
 
@@ -937,12 +1635,12 @@ func (s *Server) handleResizeSession(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleNgrokStart(w http.ResponseWriter, r *http.Request) {
 	var req ngrok.StartRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "invalid_request", "Invalid request body", nil)
 		return
 	}
 
 	if req.AuthToken == "" {
-		http.Error(w, "Auth token is required", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "invalid_request", "Auth token is required", nil)
 		return
 	}
 
@@ -960,10 +1658,19 @@ func (s *Server) handleNgrokStart(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if s.cloudflareService.IsRunning() {
+		writeJSONError(w, http.StatusConflict, "tunnel_conflict", errTunnelConflict.Error(), nil)
+		return
+	}
+
 	// Start the tunnel
-	if err := s.ngrokService.Start(req.AuthToken, s.port); err != nil {
+	if err := s.ngrokService.Start(req.AuthToken, s.port, req.Options); err != nil {
+		if ngrokErr, ok := err.(ngrok.NgrokError); ok && ngrokErr.Code == ngrok.ErrInvalidOptions.Code {
+			writeJSONError(w, http.StatusBadRequest, "invalid_request", err.Error(), nil)
+			return
+		}
 		log.Printf("[ERROR] Failed to start ngrok tunnel: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, "internal_error", err.Error(), nil)
 		return
 	}
 
@@ -980,13 +1687,13 @@ func (s *Server) handleNgrokStart(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) handleNgrokStop(w http.ResponseWriter, r *http.Request) {
 	if !s.ngrokService.IsRunning() {
-		http.Error(w, "Ngrok tunnel is not running", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "tunnel_not_running", "Ngrok tunnel is not running", nil)
 		return
 	}
 
 	if err := s.ngrokService.Stop(); err != nil {
 		log.Printf("[ERROR] Failed to stop ngrok tunnel: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, "internal_error", err.Error(), nil)
 		return
 	}
 
@@ -1012,8 +1719,11 @@ func (s *Server) handleNgrokStatus(w http.ResponseWriter, r *http.Request) {
 }
 
 // StartNgrok is a convenience method for CLI integration
-func (s *Server) StartNgrok(authToken string) error {
-	return s.ngrokService.Start(authToken, s.port)
+func (s *Server) StartNgrok(authToken string, opts ngrok.Options) error {
+	if s.cloudflareService.IsRunning() {
+		return errTunnelConflict
+	}
+	return s.ngrokService.Start(authToken, s.port, opts)
 }
 
 // StopNgrok is a convenience method for CLI integration
@@ -1021,11 +1731,63 @@ func (s *Server) StopNgrok() error {
 	return s.ngrokService.Stop()
 }
 
+// WaitForNgrokURL returns the public ngrok URL once the tunnel is up
+func (s *Server) WaitForNgrokURL(timeout time.Duration) (string, error) {
+	return s.ngrokService.WaitForURL(timeout)
+}
+
 // GetNgrokStatus returns the current ngrok status
 func (s *Server) GetNgrokStatus() ngrok.StatusResponse {
 	return s.ngrokService.GetStatus()
 }
 
+// spawnLinuxTerminal prepares a session and opens a terminal window running
+// `vibetunnel --detached-session`, so the window's process owns the PTY and
+// the session survives server restarts. Missing GUI or terminal emulator is
+// reported as 503.
+func (s *Server) spawnLinuxTerminal(w http.ResponseWriter, r *http.Request, config session.Config) {
+	execPath, err := os.Executable()
+	if err != nil {
+		logRequestf(r, "[ERROR] Failed to locate vibetunnel binary: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "internal_error", "vibetunnel binary not found", nil)
+		return
+	}
+
+	sess, err := s.manager.PrepareSession(config)
+	if err != nil {
+		logRequestf(r, "[ERROR] Failed to create session: %v", err)
+		writeSessionError(w, err)
+		return
+	}
+
+	info := sess.GetInfo()
+	if err := terminal.SpawnDetachedSession(execPath, s.manager.ControlPath(), sess.ID, info.Args, info.Cwd); err != nil {
+		logRequestf(r, "[ERROR] Failed to spawn native terminal: %v", err)
+		// Clean up the session since terminal spawn failed
+		if err := s.manager.RemoveSession(sess.ID); err != nil {
+			logRequestf(r, "Failed to remove session: %v", err)
+		}
+		status, code := http.StatusInternalServerError, "spawn_failed"
+		if errors.Is(err, terminal.ErrNoDisplay) || errors.Is(err, terminal.ErrNoTerminal) {
+			status, code = http.StatusServiceUnavailable, "terminal_unavailable"
+		}
+		writeJSONError(w, status, code, fmt.Sprintf("Failed to spawn terminal: %v", err), nil)
+		return
+	}
+
+	logRequestf(r, "[INFO] Successfully spawned terminal session natively: %s", sess.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":   true,
+		"message":   "Terminal session spawned successfully (native)",
+		"error":     nil,
+		"sessionId": sess.ID,
+	}); err != nil {
+		logRequestf(r, "Failed to encode response: %v", err)
+	}
+}
+
 // findVTBinary locates the vt binary in common locations
 func findVTBinary() string {
 	// Get the directory of the current executable (vibetunnel)
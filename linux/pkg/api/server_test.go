@@ -0,0 +1,681 @@
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/vibetunnel/linux/pkg/protocol"
+	"github.com/vibetunnel/linux/pkg/session"
+)
+
+// newTestServer serves a Server without a password or static files over
+// httptest, with sessions kept in a temporary control directory
+func newTestServer(t *testing.T) (*Server, *httptest.Server) {
+	t.Helper()
+	s := NewServer(session.NewManager(t.TempDir()), "", "", 0)
+	ts := httptest.NewServer(s.createHandler())
+	t.Cleanup(ts.Close)
+	return s, ts
+}
+
+// postJSON posts body as JSON to url and returns the status code and the
+// decoded JSON response
+func postJSON(t *testing.T, url string, body interface{}) (int, map[string]interface{}) {
+	t.Helper()
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("POST %s: %v", url, err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			t.Logf("Failed to close response body: %v", err)
+		}
+	}()
+
+	// Some endpoints answer with no content
+	var result map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil && err != io.EOF {
+		t.Fatalf("POST %s: invalid JSON response: %v", url, err)
+	}
+	return resp.StatusCode, result
+}
+
+// apiErrorOf returns the error object of a failed request's response
+func apiErrorOf(result map[string]interface{}) map[string]interface{} {
+	apiErr, _ := result["error"].(map[string]interface{})
+	return apiErr
+}
+
+// startSession creates a session through the API, expecting success. The
+// session is killed, if still running, when the test ends.
+func startSession(t *testing.T, s *Server, ts *httptest.Server, body map[string]interface{}) *session.Session {
+	t.Helper()
+	status, result := postJSON(t, ts.URL+"/api/sessions", body)
+	if status != http.StatusOK {
+		t.Fatalf("create session: status %d, response %v", status, result)
+	}
+	id, _ := result["sessionId"].(string)
+	sess, err := s.manager.GetSession(id)
+	if err != nil {
+		t.Fatalf("GetSession(%q): %v", id, err)
+	}
+	t.Cleanup(func() {
+		if sess.IsAlive() {
+			if err := sess.Kill(); err != nil {
+				t.Logf("Failed to kill session: %v", err)
+			}
+		}
+		sess.Wait()
+	})
+	return sess
+}
+
+// createSession creates a session through the API, expecting success, and
+// waits for it to exit
+func createSession(t *testing.T, s *Server, ts *httptest.Server, body map[string]interface{}) *session.Session {
+	t.Helper()
+	sess := startSession(t, s, ts, body)
+	sess.Wait()
+	return sess
+}
+
+// waitForRecording waits until the events in the session's recording
+// contain want. The header line, which holds the command line, is skipped.
+func waitForRecording(t *testing.T, sess *session.Session, want string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		recording, err := os.ReadFile(sess.StreamOutPath())
+		_, events, _ := strings.Cut(string(recording), "\n")
+		if err == nil && strings.Contains(events, want) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("recording %q does not contain %q", recording, want)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// recordedOutput returns the output events of the session's recording
+// joined together, without the header, which also lists the command and
+// its environment
+func recordedOutput(t *testing.T, sess *session.Session) string {
+	t.Helper()
+	file, err := os.Open(sess.StreamOutPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			t.Logf("Failed to close recording: %v", err)
+		}
+	}()
+
+	var output strings.Builder
+	reader := protocol.NewStreamReader(file)
+	for {
+		event, err := reader.Next()
+		if err != nil {
+			t.Fatalf("invalid recording: %v", err)
+		}
+		if event.Type == "end" {
+			return output.String()
+		}
+		if event.Event != nil && event.Event.Type == protocol.EventOutput {
+			output.WriteString(event.Event.Data)
+		}
+	}
+}
+
+func TestCreateSessionUsesDefaultSize(t *testing.T) {
+	s, ts := newTestServer(t)
+	s.manager.SetDefaultSize(80, 24)
+
+	tests := []struct {
+		name       string
+		cols, rows int
+		wantWidth  int
+		wantHeight int
+	}{
+		{"defaults", 0, 0, 80, 24},
+		{"requested", 100, 40, 100, 40},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sess := createSession(t, s, ts, map[string]interface{}{
+				"command": []string{"true"},
+				"cols":    tt.cols,
+				"rows":    tt.rows,
+			})
+			info := sess.GetInfo()
+			if info.Width != tt.wantWidth || info.Height != tt.wantHeight {
+				t.Errorf("size = %dx%d, want %dx%d", info.Width, info.Height, tt.wantWidth, tt.wantHeight)
+			}
+		})
+	}
+}
+
+func TestCreateSessionWorkingDir(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skipf("no home directory: %v", err)
+	}
+	missing := filepath.Join(t.TempDir(), "missing")
+
+	t.Run("lenient", func(t *testing.T) {
+		s, ts := newTestServer(t)
+		sess := createSession(t, s, ts, map[string]interface{}{
+			"command":    []string{"true"},
+			"workingDir": missing,
+		})
+		if cwd := sess.GetInfo().Cwd; cwd != home {
+			t.Errorf("cwd = %q, want the home directory %q", cwd, home)
+		}
+	})
+
+	strict := []struct {
+		name      string
+		server    bool
+		strictCwd bool
+	}{
+		{"strict request", false, true},
+		{"strict server", true, false},
+	}
+	for _, tt := range strict {
+		t.Run(tt.name, func(t *testing.T) {
+			s, ts := newTestServer(t)
+			s.SetStrictCwd(tt.server)
+			status, result := postJSON(t, ts.URL+"/api/sessions", map[string]interface{}{
+				"command":    []string{"true"},
+				"workingDir": missing,
+				"strictCwd":  tt.strictCwd,
+			})
+			if status != http.StatusBadRequest || apiErrorOf(result)["code"] != "invalid_working_dir" {
+				t.Fatalf("got %d %v, want 400 invalid_working_dir", status, result)
+			}
+			details, _ := apiErrorOf(result)["details"].(map[string]interface{})
+			if details["workingDir"] != missing {
+				t.Errorf("details = %v, want workingDir %q", details, missing)
+			}
+			sessions, err := s.manager.ListSessions()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(sessions) != 0 {
+				t.Errorf("%d sessions were created", len(sessions))
+			}
+		})
+	}
+}
+
+func TestCreateSessionEnv(t *testing.T) {
+	s, ts := newTestServer(t)
+	sess := createSession(t, s, ts, map[string]interface{}{
+		"command": []string{"printenv", "FOO"},
+		"env":     map[string]string{"FOO": "bar"},
+	})
+	if output := recordedOutput(t, sess); output != "bar\r\n" {
+		t.Errorf("child printed %q, want %q", output, "bar\r\n")
+	}
+}
+
+func TestInputRecording(t *testing.T) {
+	for _, record := range []bool{false, true} {
+		t.Run(fmt.Sprintf("record=%v", record), func(t *testing.T) {
+			s, ts := newTestServer(t)
+			s.manager.SetRecordInput(record)
+
+			sess := startSession(t, s, ts, map[string]interface{}{
+				"command": []string{"/bin/sh", "-c", `echo ready; read line; stty -echo; echo password:; read secret; stty echo; echo done`},
+			})
+			input := func(text string) {
+				t.Helper()
+				if status, result := postJSON(t, ts.URL+"/api/sessions/"+sess.ID+"/input", map[string]string{"text": text}); status != http.StatusNoContent {
+					t.Fatalf("send input: status %d, response %v", status, result)
+				}
+			}
+			waitForRecording(t, sess, "ready")
+			input("visible\n")
+			waitForRecording(t, sess, "password:")
+			input("hunter2\n")
+			waitForRecording(t, sess, "done")
+			sess.Wait()
+
+			recording, err := os.ReadFile(sess.StreamOutPath())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if recorded := strings.Contains(string(recording), `"i","visible`); recorded != record {
+				t.Errorf("input recorded = %v, want %v", recorded, record)
+			}
+			if strings.Contains(string(recording), "hunter2") {
+				t.Error("input typed with echo off was recorded")
+			}
+		})
+	}
+}
+
+func TestInputTooLarge(t *testing.T) {
+	const maxInput = 1024
+	s, ts := newTestServer(t)
+	s.SetMaxInput(maxInput)
+	sess := startSession(t, s, ts, map[string]interface{}{
+		"command": []string{"/bin/sh", "-c", "sleep 30"},
+	})
+	url := ts.URL + "/api/sessions/" + sess.ID + "/input"
+
+	if status, result := postJSON(t, url, map[string]string{"text": strings.Repeat("x", maxInput)}); status != http.StatusNoContent {
+		t.Errorf("input at the limit: status %d, response %v", status, result)
+	}
+	// Too long once decoded, and a body too large to read at all
+	tests := map[string]string{
+		"text":  strings.Repeat("x", maxInput+1),
+		"input": strings.Repeat("\x00", 8*maxInput),
+	}
+	for field, text := range tests {
+		status, result := postJSON(t, url, map[string]string{field: text})
+		if status != http.StatusRequestEntityTooLarge || apiErrorOf(result)["code"] != "input_too_large" {
+			t.Errorf("%d bytes of %s: status %d, response %v, want 413 input_too_large", len(text), field, status, result)
+		}
+	}
+}
+
+func TestPasteBracketing(t *testing.T) {
+	const text = "a\nb\n"
+	bracketed := "\x1b[200~" + text + "\x1b[201~"
+	tests := []struct {
+		name      string
+		pasteMode bool
+		paste     bool
+		want      string
+	}{
+		{"paste mode on, paste", true, true, bracketed},
+		{"paste mode on, typed", true, false, text},
+		{"paste mode off, paste", false, true, text},
+		{"paste mode off, typed", false, false, text},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, ts := newTestServer(t)
+			enable := ""
+			if tt.pasteMode {
+				enable = `printf '\033[?2004h'; `
+			}
+			// The program reads exactly the bytes it expects and prints them
+			// in hex, so extra or missing markers show up as a mismatch
+			sess := startSession(t, s, ts, map[string]interface{}{
+				"command": []string{"/bin/sh", "-c", "stty raw -echo; " + enable + `echo ready; echo "got=$(head -c ` + fmt.Sprint(len(tt.want)) + ` | od -An -tx1 | tr -d ' \n')"`},
+			})
+			waitForRecording(t, sess, "ready")
+			// Output is scanned for the mode just after it's recorded
+			for deadline := time.Now().Add(time.Second); sess.IsBracketedPaste() != tt.pasteMode && time.Now().Before(deadline); {
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			status, result := postJSON(t, ts.URL+"/api/sessions/"+sess.ID+"/input", map[string]interface{}{"text": text, "paste": tt.paste})
+			if status != http.StatusNoContent {
+				t.Fatalf("send input: status %d, response %v", status, result)
+			}
+			waitForRecording(t, sess, "got="+hex.EncodeToString([]byte(tt.want)))
+		})
+	}
+}
+
+func TestSendKeys(t *testing.T) {
+	s, ts := newTestServer(t)
+	// In raw mode control characters reach the program instead of sending
+	// signals; it prints the bytes it gets in hex
+	const want = "\x03\x04\x1a\x1b[15~\rend"
+	sess := startSession(t, s, ts, map[string]interface{}{
+		"command": []string{"/bin/sh", "-c", `stty raw -echo; echo ready; echo "got=$(head -c ` + fmt.Sprint(len(want)) + ` | od -An -tx1 | tr -d ' \n')"`},
+	})
+	waitForRecording(t, sess, "ready")
+
+	url := ts.URL + "/api/sessions/" + sess.ID + "/input"
+	requests := []map[string]string{
+		{"type": "key", "key": "ctrl_c"},
+		{"type": "key", "key": "CTRL_D"},
+		{"key": "ctrl_z"},
+		{"type": "key", "input": "f5"},
+		{"text": "enter"}, // Sent as a key, like the Swift version does
+		{"text": "end"},   // Only the Swift version's keys are detected in text
+	}
+	for _, req := range requests {
+		if status, result := postJSON(t, url, req); status != http.StatusNoContent {
+			t.Fatalf("send %v: status %d, response %v", req, status, result)
+		}
+	}
+	waitForRecording(t, sess, "got="+hex.EncodeToString([]byte(want)))
+
+	if status, result := postJSON(t, url, map[string]string{"type": "key", "key": "ctrl_plus"}); status != http.StatusBadRequest || apiErrorOf(result)["code"] != "unknown_key" {
+		t.Errorf("unknown key: status %d, response %v, want 400 unknown_key", status, result)
+	}
+}
+
+// getHealth fetches /api/health with query and returns the status code and
+// the decoded response
+func getHealth(t *testing.T, ts *httptest.Server, query string) (int, HealthResponse) {
+	t.Helper()
+	resp, err := http.Get(ts.URL + "/api/health" + query)
+	if err != nil {
+		t.Fatalf("GET /api/health: %v", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			t.Logf("Failed to close response body: %v", err)
+		}
+	}()
+	var health HealthResponse
+	if err := json.NewDecoder(resp.Body).Decode(&health); err != nil {
+		t.Fatalf("invalid health response: %v", err)
+	}
+	return resp.StatusCode, health
+}
+
+func TestHealth(t *testing.T) {
+	s, ts := newTestServer(t)
+	createSession(t, s, ts, map[string]interface{}{"command": []string{"true"}})
+	running := startSession(t, s, ts, map[string]interface{}{"command": []string{"sleep", "30"}})
+
+	// The shallow probe counts sessions from memory and runs no checks
+	status, health := getHealth(t, ts, "")
+	if status != http.StatusOK || health.Status != "ok" {
+		t.Errorf("shallow: %d %q, want 200 ok", status, health.Status)
+	}
+	if health.Sessions != (HealthSessions{Active: 1, Exited: 1}) || health.Checks != nil {
+		t.Errorf("shallow: sessions %+v and checks %v, want 1 active and 1 exited and no checks", health.Sessions, health.Checks)
+	}
+
+	status, health = getHealth(t, ts, "?deep=true")
+	if status != http.StatusOK || health.Checks["controlDir"] != "ok" {
+		t.Errorf("deep: %d with checks %v, want 200 with controlDir ok", status, health.Checks)
+	}
+	if health.Sessions != (HealthSessions{Active: 1, Exited: 1}) {
+		t.Errorf("deep: sessions %+v, want 1 active and 1 exited", health.Sessions)
+	}
+
+	// A session whose process is gone counts as exited
+	if err := running.Kill(); err != nil {
+		t.Fatal(err)
+	}
+	running.Wait()
+	if _, health = getHealth(t, ts, ""); health.Sessions != (HealthSessions{Exited: 2}) {
+		t.Errorf("after kill: sessions %+v, want 2 exited", health.Sessions)
+	}
+}
+
+func TestHealthUnwritableControlDir(t *testing.T) {
+	// A control path below a file can't be created
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(session.NewManager(filepath.Join(file, "control")), "", "", 0)
+	ts := httptest.NewServer(s.createHandler())
+	defer ts.Close()
+
+	if status, _ := getHealth(t, ts, ""); status != http.StatusOK {
+		t.Errorf("shallow: status %d, want 200", status)
+	}
+	status, health := getHealth(t, ts, "?deep=true")
+	if status != http.StatusServiceUnavailable || health.Status != "unavailable" || health.Checks["controlDir"] == "ok" {
+		t.Errorf("deep: %d %q with checks %v, want 503 unavailable", status, health.Status, health.Checks)
+	}
+}
+
+func TestCreateSessionStartFailures(t *testing.T) {
+	t.Run("not executable", func(t *testing.T) {
+		s, ts := newTestServer(t)
+		path := filepath.Join(t.TempDir(), "tool")
+		if err := os.WriteFile(path, []byte{0x7f, 'E', 'L', 'F', 0, 0, 0, 0}, 0755); err != nil {
+			t.Fatal(err)
+		}
+		status, result := postJSON(t, ts.URL+"/api/sessions", map[string]interface{}{"command": []string{path}})
+		if status != http.StatusBadRequest || apiErrorOf(result)["code"] != "command_not_executable" {
+			t.Errorf("got %d %v, want 400 command_not_executable", status, result)
+		}
+		if sessions, err := s.manager.ListSessions(); err != nil || len(sessions) != 0 {
+			t.Errorf("sessions after the failure: %v, %v", sessions, err)
+		}
+	})
+
+	// Only running out of PTYs or file descriptors is the server's problem
+	t.Run("out of PTYs", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		writeSessionError(w, fmt.Errorf("failed to create PTY: %w", &session.PTYCreationError{
+			Hint: "system PTY limit reached",
+			Err:  &os.PathError{Op: "open", Path: "/dev/ptmx", Err: syscall.ENOSPC},
+		}))
+		var result map[string]interface{}
+		if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+			t.Fatal(err)
+		}
+		details, _ := apiErrorOf(result)["details"].(map[string]interface{})
+		if w.Code != http.StatusServiceUnavailable || apiErrorOf(result)["code"] != "pty_creation_failed" || details["hint"] != "system PTY limit reached" {
+			t.Errorf("got %d %v, want 503 pty_creation_failed with the hint", w.Code, result)
+		}
+	})
+}
+
+func TestResizeReplay(t *testing.T) {
+	s, ts := newTestServer(t)
+	sess := startSession(t, s, ts, map[string]interface{}{
+		"command": []string{"/bin/sh", "-c", "echo ready; read line; stty size"},
+		"cols":    80,
+		"rows":    24,
+	})
+	waitForRecording(t, sess, "ready")
+
+	// The first resize is recorded even though it matches the header, a
+	// repeat isn't, and the last one happens while the program runs
+	for _, size := range []map[string]int{{"cols": 80, "rows": 24}, {"cols": 80, "rows": 24}, {"cols": 120, "rows": 40}} {
+		if status, result := postJSON(t, ts.URL+"/api/sessions/"+sess.ID+"/resize", size); status != http.StatusOK {
+			t.Fatalf("resize to %v: status %d, response %v", size, status, result)
+		}
+	}
+	if status, result := postJSON(t, ts.URL+"/api/sessions/"+sess.ID+"/input", map[string]string{"text": "\n"}); status != http.StatusNoContent {
+		t.Fatalf("send input: status %d, response %v", status, result)
+	}
+	sess.Wait()
+
+	resp, err := http.Get(ts.URL + "/api/sessions/" + sess.ID + "/recording")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			t.Logf("Failed to close response body: %v", err)
+		}
+	}()
+	if cols, rows := resp.Header.Get("X-Terminal-Cols"), resp.Header.Get("X-Terminal-Rows"); cols != "120" || rows != "40" {
+		t.Errorf("download says %sx%s, want 120x40", cols, rows)
+	}
+
+	// Replay the cast, following the geometry as a player would
+	reader := protocol.NewStreamReader(resp.Body)
+	var resizes []string
+	var width, height uint32
+	var sizeOutput string
+	for {
+		event, err := reader.Next()
+		if err != nil {
+			t.Fatalf("invalid recording: %v", err)
+		}
+		if event.Type == "end" {
+			break
+		}
+		if event.Header != nil {
+			width, height = event.Header.Width, event.Header.Height
+			if width != 80 || height != 24 {
+				t.Errorf("header size = %dx%d, want the initial 80x24", width, height)
+			}
+			continue
+		}
+		switch event.Event.Type {
+		case protocol.EventResize:
+			resizes = append(resizes, event.Event.Data)
+			if width, height, err = protocol.ParseResize(event.Event.Data); err != nil {
+				t.Fatal(err)
+			}
+		case protocol.EventOutput:
+			if width == 120 && height == 40 {
+				sizeOutput += event.Event.Data
+			}
+		}
+	}
+	if strings.Join(resizes, ",") != "80x24,120x40" {
+		t.Errorf("resize events = %v, want [80x24 120x40]", resizes)
+	}
+	if width != 120 || height != 40 {
+		t.Errorf("replay ends at %dx%d, want 120x40", width, height)
+	}
+	// Output after the replayed resize was written at that size
+	if !strings.Contains(sizeOutput, "40 120") {
+		t.Errorf("output after the resize = %q, want the program to report 40 120", sizeOutput)
+	}
+}
+
+func TestDeleteExitedSessions(t *testing.T) {
+	s, ts := newTestServer(t)
+	running := startSession(t, s, ts, map[string]interface{}{"command": []string{"sleep", "30"}})
+	old := createSession(t, s, ts, map[string]interface{}{"command": []string{"true"}})
+	recent := createSession(t, s, ts, map[string]interface{}{"command": []string{"true"}})
+	exited := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(filepath.Join(old.Path(), "session.json"), exited, exited); err != nil {
+		t.Fatal(err)
+	}
+
+	deleteSessions := func(query string) (int, map[string]interface{}) {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodDelete, ts.URL+"/api/sessions"+query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("DELETE %s: %v", query, err)
+		}
+		defer func() {
+			if err := resp.Body.Close(); err != nil {
+				t.Logf("Failed to close response body: %v", err)
+			}
+		}()
+		var result map[string]interface{}
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			t.Fatalf("DELETE %s: invalid JSON response: %v", query, err)
+		}
+		return resp.StatusCode, result
+	}
+	remaining := func() []string {
+		t.Helper()
+		sessions, err := s.manager.ListSessions()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, info := range sessions {
+			ids = append(ids, info.ID)
+		}
+		return ids
+	}
+
+	for _, query := range []string{"", "?status=running", "?status=exited&olderThan=soon", "?status=exited&olderThan=-1h"} {
+		if status, result := deleteSessions(query); status != http.StatusBadRequest {
+			t.Errorf("DELETE %q: got %d %v, want 400", query, status, result)
+		}
+	}
+	if ids := remaining(); len(ids) != 3 {
+		t.Fatalf("sessions after rejected requests: %v, want all 3", ids)
+	}
+
+	// Only the session that exited long enough ago
+	status, result := deleteSessions("?status=exited&olderThan=1h")
+	if status != http.StatusOK || result["removed"] != float64(1) || fmt.Sprint(result["ids"]) != fmt.Sprint([]string{old.ID}) {
+		t.Errorf("olderThan=1h: got %d %v, want %s removed", status, result, old.ID)
+	}
+
+	// The running session is left alone
+	status, result = deleteSessions("?status=exited")
+	if status != http.StatusOK || result["removed"] != float64(1) || fmt.Sprint(result["ids"]) != fmt.Sprint([]string{recent.ID}) {
+		t.Errorf("status=exited: got %d %v, want %s removed", status, result, recent.ID)
+	}
+	if ids := remaining(); len(ids) != 1 || ids[0] != running.ID {
+		t.Errorf("sessions left: %v, want only the running %s", ids, running.ID)
+	}
+
+	// The older endpoint still works
+	createSession(t, s, ts, map[string]interface{}{"command": []string{"true"}})
+	if status, result := postJSON(t, ts.URL+"/api/cleanup-exited", nil); status != http.StatusNoContent {
+		t.Errorf("POST /api/cleanup-exited: got %d %v, want 204", status, result)
+	}
+	if ids := remaining(); len(ids) != 1 || ids[0] != running.ID {
+		t.Errorf("sessions left after cleanup-exited: %v, want only the running %s", ids, running.ID)
+	}
+}
+
+func TestBasicAuth(t *testing.T) {
+	s := NewServer(session.NewManager(t.TempDir()), "", "", 0)
+	s.SetPassword("secret")
+	handler := s.basicAuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	basic := func(credentials string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials))
+	}
+
+	tests := []struct {
+		name   string
+		auth   string
+		status int
+	}{
+		{"valid", basic("admin:secret"), http.StatusNoContent},
+		{"password with colon", basic("admin:secret:extra"), http.StatusUnauthorized},
+		{"no header", "", http.StatusUnauthorized},
+		{"no Basic prefix", base64.StdEncoding.EncodeToString([]byte("admin:secret")), http.StatusUnauthorized},
+		{"other scheme", "Bearer secret", http.StatusUnauthorized},
+		{"scheme only", "Basic", http.StatusUnauthorized},
+		{"prefix only", "Basic ", http.StatusUnauthorized},
+		{"invalid base64", "Basic !!not-base64!!", http.StatusUnauthorized},
+		{"no colon", basic("adminsecret"), http.StatusUnauthorized},
+		{"empty password", basic("admin:"), http.StatusUnauthorized},
+		{"empty credentials", basic(":"), http.StatusUnauthorized},
+		{"wrong username", basic("root:secret"), http.StatusUnauthorized},
+		{"wrong password", basic("admin:wrong"), http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/sessions", nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			if tt.status == http.StatusUnauthorized {
+				checkErrorResponse(t, w.Result(), http.StatusUnauthorized, "unauthorized", nil)
+				if w.Header().Get("WWW-Authenticate") == "" {
+					t.Error("no WWW-Authenticate challenge")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vibetunnel/linux/pkg/session"
+)
+
+// loadRecording stores recording as the stream-out of an exited session
+// and loads that session through a manager
+func loadRecording(t *testing.T, recording string) *session.Session {
+	t.Helper()
+	controlPath := t.TempDir()
+	id := "00000000-0000-0000-0000-000000000001"
+	sessionPath := filepath.Join(controlPath, id)
+	if err := os.MkdirAll(sessionPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	info := &session.Info{ID: id, Status: string(session.StatusExited), Width: 80, Height: 24}
+	if err := info.Save(sessionPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(sessionPath, "stream-out"), []byte(recording), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sess, err := session.NewManager(controlPath).GetSession(id)
+	if err != nil {
+		t.Fatalf("GetSession: %v", err)
+	}
+	return sess
+}
+
+func TestSnapshotReportsFinalSize(t *testing.T) {
+	sess := loadRecording(t, `{"version":2,"width":80,"height":24}
+[0.1,"o","hello\r\n"]
+[0.5,"r","120x40"]
+[0.6,"o","wider\r\n"]
+[1.0,"r","100x30"]
+[1.2,"o","done\r\n"]
+`)
+
+	snapshot, err := GetSessionSnapshot(sess, SnapshotOptions{Full: true})
+	if err != nil {
+		t.Fatalf("GetSessionSnapshot: %v", err)
+	}
+	if snapshot.Width != 100 || snapshot.Height != 30 {
+		t.Errorf("final size = %dx%d, want 100x30", snapshot.Width, snapshot.Height)
+	}
+	want := []SnapshotResize{
+		{Time: 0.5, Width: 120, Height: 40},
+		{Time: 1.0, Width: 100, Height: 30},
+	}
+	if len(snapshot.Resizes) != len(want) {
+		t.Fatalf("resizes = %+v, want %+v", snapshot.Resizes, want)
+	}
+	for i := range want {
+		if snapshot.Resizes[i] != want[i] {
+			t.Errorf("resize %d = %+v, want %+v", i, snapshot.Resizes[i], want[i])
+		}
+	}
+}
+
+func TestTrimmedSnapshotStartsAtEarlierSize(t *testing.T) {
+	sess := loadRecording(t, `{"version":2,"width":80,"height":24}
+[0.5,"r","120x40"]
+[1.0,"o","\u001b[2J"]
+[1.5,"o","after clear\r\n"]
+`)
+
+	snapshot, err := GetSessionSnapshot(sess, SnapshotOptions{})
+	if err != nil {
+		t.Fatalf("GetSessionSnapshot: %v", err)
+	}
+	if snapshot.Width != 120 || snapshot.Height != 40 {
+		t.Errorf("final size = %dx%d, want 120x40", snapshot.Width, snapshot.Height)
+	}
+	// The resize before the clear is kept at the start of the window
+	want := SnapshotResize{Time: 0, Width: 120, Height: 40}
+	if len(snapshot.Resizes) != 1 || snapshot.Resizes[0] != want {
+		t.Errorf("resizes = %+v, want [%+v]", snapshot.Resizes, want)
+	}
+}
@@ -1,13 +1,21 @@
 package api
 
 import (
+	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
+	"hash/fnv"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
@@ -16,18 +24,117 @@ import (
 )
 
 type SSEStreamer struct {
-	w       http.ResponseWriter
-	session *session.Session
-	flusher http.Flusher
+	w        http.ResponseWriter
+	ctx      context.Context // Cancelled when the client goes away
+	session  *session.Session
+	flusher  http.Flusher
+	done     chan struct{}
+	stopOnce sync.Once
+	tail     int // Replay only this many bytes of recent output on connect
+
+	maxBacklog int64 // Unsent output kept before skipping ahead, 0 for no limit
+
+	// The initial replay starts at the last clear screen within the final
+	// replayLimit bytes of stream-out, unless fullReplay is set
+	replayLimit int64
+	fullReplay  bool
+
+	// resumeFrom is the stream-out offset a reconnecting client last saw,
+	// from its Last-Event-ID; -1 if it isn't resuming. It's only valid in
+	// the segment resumeSegment identifies.
+	resumeFrom    int64
+	resumeSegment string
+
+	// A comment is sent after keepAlive without events, so proxies don't
+	// close quiet streams and disconnected clients are noticed
+	keepAlive time.Duration
+	lastSend  time.Time
+
+	segment   os.FileInfo // stream-out file last read, to notice rotations
+	segmentID string      // Identifies segment in event ids, once known
 }
 
-func NewSSEStreamer(w http.ResponseWriter, session *session.Session) *SSEStreamer {
+// NewSSEStreamer creates a streamer for session writing to w. The stream ends
+// as soon as r's context is cancelled, i.e. when the client disconnects.
+func NewSSEStreamer(w http.ResponseWriter, r *http.Request, session *session.Session) *SSEStreamer {
 	flusher, _ := w.(http.Flusher)
 	return &SSEStreamer{
 		w:       w,
+		ctx:     r.Context(),
 		session: session,
 		flusher: flusher,
+		done:    make(chan struct{}),
+
+		maxBacklog:  DefaultMaxStreamBacklog,
+		replayLimit: DefaultMaxReplay,
+		keepAlive:   DefaultSSEKeepAlive,
+		resumeFrom:  -1,
+	}
+}
+
+// DefaultMaxReplay bounds the output replayed to a stream client on connect
+const DefaultMaxReplay = 1024 * 1024
+
+// DefaultSSEKeepAlive is how long a stream may be silent before a keep-alive
+// comment is sent
+const DefaultSSEKeepAlive = 15 * time.Second
+
+// Stop cancels the output stream, as DELETE /api/streams/{streamId} does
+// for live and playback streams: Stream returns and the response ends.
+// Calls after the first do nothing.
+func (s *SSEStreamer) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.done)
+	})
+}
+
+// SetTail limits the initial replay to the last n bytes of output. For
+// sessions running in this process the replay is served from memory.
+func (s *SSEStreamer) SetTail(n int) {
+	s.tail = n
+}
+
+// SetReplayLimit caps the output replayed on connect to the last n bytes
+// of stream-out; n <= 0 only trims to the last clear screen
+func (s *SSEStreamer) SetReplayLimit(n int64) {
+	s.replayLimit = n
+}
+
+// SetFullReplay replays the whole recording on connect instead of trimming
+// it to the last clear screen and the replay limit
+func (s *SSEStreamer) SetFullReplay(full bool) {
+	s.fullReplay = full
+}
+
+// SetLastEventID resumes the stream after the event with the given id, which
+// names the stream-out segment and the offset in it the event ends at. Nothing
+// is replayed; output from that offset on is sent, so a reconnecting client
+// misses and repeats nothing. It reports whether id is one of ours; ids that
+// aren't, or that are from another segment, get the usual replay.
+func (s *SSEStreamer) SetLastEventID(id string) bool {
+	segment, offset, ok := strings.Cut(id, ":")
+	if !ok || segment == "" {
+		return false
+	}
+	n, err := strconv.ParseInt(offset, 10, 64)
+	if err != nil || n < 0 {
+		return false
 	}
+	s.resumeSegment, s.resumeFrom = segment, n
+	return true
+}
+
+// SetKeepAlive sets how long the stream may go without events before a
+// keep-alive comment is sent; 0 disables keep-alives
+func (s *SSEStreamer) SetKeepAlive(interval time.Duration) {
+	s.keepAlive = interval
+}
+
+// SetMaxBacklog sets how many bytes of output a client may fall behind by.
+// Beyond that, older output is skipped and a truncated event is sent, so a
+// slow client or a long recording doesn't have to be held in memory.
+func (s *SSEStreamer) SetMaxBacklog(n int64) {
+	s.maxBacklog = n
 }
 
 func (s *SSEStreamer) Stream() {
@@ -55,8 +162,9 @@ func (s *SSEStreamer) Stream() {
 		}
 	}()
 
-	// Add the stream file to the watcher
-	err = watcher.Add(streamPath)
+	// Watch the session directory rather than the file, so the stream is
+	// still followed once stream-out is rotated to a new file
+	err = watcher.Add(filepath.Dir(streamPath))
 	if err != nil {
 		log.Printf("[ERROR] SSE: Failed to watch stream file: %v", err)
 		if err := s.sendError(fmt.Sprintf("Failed to watch file: %v", err)); err != nil {
@@ -67,6 +175,71 @@ func (s *SSEStreamer) Stream() {
 
 	headerSent := false
 	seenBytes := int64(0)
+	resumed := false
+
+	// A client resuming with Last-Event-ID continues where it left off. An
+	// id from another segment is from before a rotation, and an offset past
+	// the end is from before a restart, so they get the usual replay instead.
+	if s.resumeFrom >= 0 {
+		if err := s.openSegment(streamPath); err == nil && s.segmentID == s.resumeSegment && s.resumeFrom <= s.segment.Size() {
+			debugLog("[DEBUG] SSE: Resuming session %s at offset %d", s.session.ID[:8], s.resumeFrom)
+			headerSent = s.resumeFrom > 0
+			seenBytes = s.resumeFrom
+			resumed = true
+		} else {
+			debugLog("[DEBUG] SSE: Can't resume session %s at offset %d, replaying", s.session.ID[:8], s.resumeFrom)
+		}
+	}
+
+	// Reconnecting clients that only need recent output are replayed from
+	// memory, then followed from the stream-out offset the replay ends at
+	if !resumed && s.tail > 0 {
+		if events, offset, ok := s.session.TailOutput(s.tail); ok {
+			if err := s.openSegment(streamPath); err != nil {
+				log.Printf("[ERROR] SSE: Failed to open stream file: %v", err)
+			}
+			for i := range events {
+				// Only the last event ends at a known offset
+				id := ""
+				if i == len(events)-1 {
+					id = s.eventID(offset)
+				}
+				if err := s.sendRawEvent(&protocol.StreamEvent{Type: "event", Event: &events[i]}, id); err != nil {
+					debugLog("[DEBUG] SSE: Client disconnected during tail replay: %v", err)
+					return
+				}
+			}
+			headerSent = true
+			seenBytes = offset
+		}
+	}
+
+	// Otherwise replay only the end of the recording, after a clear so the
+	// client starts from a blank screen at the right size
+	if !resumed && !headerSent && !s.fullReplay {
+		limit, toClear := s.replayLimit, true
+		if s.tail > 0 {
+			limit, toClear = int64(s.tail), false
+		}
+		start, err := replayStart(streamPath, limit, toClear)
+		if err != nil {
+			log.Printf("[ERROR] SSE: Failed to find replay start: %v", err)
+		} else if start > 0 {
+			info := s.session.GetInfo()
+			prelude := []protocol.AsciinemaEvent{
+				{Type: protocol.EventResize, Data: fmt.Sprintf("%dx%d", info.Width, info.Height)},
+				{Type: protocol.EventOutput, Data: "\x1b[H\x1b[2J"},
+			}
+			for i := range prelude {
+				if err := s.sendRawEvent(&protocol.StreamEvent{Type: "event", Event: &prelude[i]}, ""); err != nil {
+					debugLog("[DEBUG] SSE: Client disconnected during replay: %v", err)
+					return
+				}
+			}
+			headerSent = true
+			seenBytes = start
+		}
+	}
 
 	// Send initial content immediately and check for client disconnect
 	if err := s.processNewContent(streamPath, &headerSent, &seenBytes); err != nil {
@@ -74,16 +247,52 @@ func (s *SSEStreamer) Stream() {
 		return
 	}
 
+	// The timer is set for when the stream will have been silent for
+	// keepAlive, so no gap between sends exceeds it
+	var keepAlive <-chan time.Time
+	var keepAliveTimer *time.Timer
+	if s.keepAlive > 0 {
+		keepAliveTimer = time.NewTimer(s.keepAlive)
+		defer keepAliveTimer.Stop()
+		keepAlive = keepAliveTimer.C
+	}
+	s.lastSend = time.Now()
+
+	// A ticker rather than time.After, which the keep-alives would keep
+	// resetting
+	aliveCheck := time.NewTicker(30 * time.Second)
+	defer aliveCheck.Stop()
+
 	// Watch for file changes
 	for {
 		select {
+		case <-s.done:
+			debugLog("[DEBUG] SSE: Stream for session %s stopped", s.session.ID[:8])
+			return
+
+		case <-s.ctx.Done():
+			debugLog("[DEBUG] SSE: Client for session %s disconnected", s.session.ID[:8])
+			return
+
+		case <-keepAlive:
+			if idle := time.Since(s.lastSend); idle < s.keepAlive {
+				// Events were sent since the timer was set
+				keepAliveTimer.Reset(s.keepAlive - idle)
+			} else {
+				if err := s.sendKeepAlive(); err != nil {
+					debugLog("[DEBUG] SSE: Client disconnected during keep-alive: %v", err)
+					return
+				}
+				keepAliveTimer.Reset(s.keepAlive)
+			}
+
 		case event, ok := <-watcher.Events:
 			if !ok {
 				return
 			}
 
 			// Process file writes (new content) and check for client disconnect
-			if event.Op&fsnotify.Write == fsnotify.Write {
+			if isStreamWrite(event, streamPath) {
 				if err := s.processNewContent(streamPath, &headerSent, &seenBytes); err != nil {
 					debugLog("[DEBUG] SSE: Client disconnected during content streaming: %v", err)
 					return
@@ -96,7 +305,7 @@ func (s *SSEStreamer) Stream() {
 			}
 			log.Printf("[ERROR] SSE: File watcher error: %v", err)
 
-		case <-time.After(30 * time.Second):
+		case <-aliveCheck.C:
 			// Check if session is still alive less frequently for better performance
 			if !s.session.IsAlive() {
 				debugLog("[DEBUG] SSE: Session %s is dead, ending stream", s.session.ID[:8])
@@ -109,6 +318,12 @@ func (s *SSEStreamer) Stream() {
 	}
 }
 
+// isStreamWrite reports whether event, from a watch on the session
+// directory, is new output in stream-out
+func isStreamWrite(event fsnotify.Event, streamPath string) bool {
+	return event.Name == streamPath && event.Op&(fsnotify.Write|fsnotify.Create) != 0
+}
+
 func (s *SSEStreamer) processNewContent(streamPath string, headerSent *bool, seenBytes *int64) error {
 	// Open the file for reading
 	file, err := os.Open(streamPath)
@@ -131,11 +346,38 @@ func (s *SSEStreamer) processNewContent(streamPath string, headerSent *bool, see
 
 	currentSize := fileInfo.Size()
 
+	// A different or shrunken file means stream-out was rotated; follow the
+	// new segment
+	if newSegment(&s.segment, fileInfo) || currentSize < *seenBytes {
+		debugLog("[DEBUG] SSE: Stream for session %s was rotated", s.session.ID[:8])
+		*seenBytes = 0
+		*headerSent = false
+		s.segmentID = ""
+	}
+
 	// If file hasn't grown, nothing to do
 	if currentSize <= *seenBytes {
 		return nil
 	}
 
+	// Skip output the client can't catch up on rather than reading it all
+	next, skipped, err := skipBacklog(file, *seenBytes, currentSize, s.maxBacklog)
+	if err != nil {
+		log.Printf("[ERROR] SSE: Failed to skip backlog: %v", err)
+		return err
+	}
+	if skipped > 0 {
+		debugLog("[DEBUG] SSE: Skipped %d bytes of output for session %s", skipped, s.session.ID[:8])
+		*seenBytes = next
+		*headerSent = true // Only event lines follow
+		if err := s.sendEvent(&protocol.StreamEvent{
+			Type:    "truncated",
+			Message: fmt.Sprintf("%d bytes of output skipped", skipped),
+		}); err != nil {
+			return err
+		}
+	}
+
 	// Seek to the position we last read
 	if _, err := file.Seek(*seenBytes, 0); err != nil {
 		log.Printf("[ERROR] SSE: Failed to seek to position %d: %v", *seenBytes, err)
@@ -152,7 +394,8 @@ func (s *SSEStreamer) processNewContent(streamPath string, headerSent *bool, see
 		return err
 	}
 
-	// Update seen bytes
+	// Update seen bytes; offset tracks where each line ends for event ids
+	offset := *seenBytes
 	*seenBytes = currentSize
 
 	// Process the new content line by line
@@ -172,6 +415,7 @@ func (s *SSEStreamer) processNewContent(streamPath string, headerSent *bool, see
 	// Process complete lines
 	for i := 0; i < endIndex; i++ {
 		line := lines[i]
+		offset += int64(len(line)) + 1
 		if line == "" {
 			continue
 		}
@@ -204,8 +448,11 @@ func (s *SSEStreamer) processNewContent(streamPath string, headerSent *bool, see
 					},
 				}
 
+				if s.segmentID == "" {
+					s.segmentID = segmentID(file, fileInfo)
+				}
 				debugLog("[DEBUG] SSE: Sending event type=%s", event.Type)
-				if err := s.sendRawEvent(event); err != nil {
+				if err := s.sendRawEvent(event, s.eventID(offset)); err != nil {
 					log.Printf("[ERROR] SSE: Failed to send event: %v", err)
 					return err
 				}
@@ -234,11 +481,67 @@ func (s *SSEStreamer) sendEvent(event *protocol.StreamEvent) error {
 	if s.flusher != nil {
 		s.flusher.Flush()
 	}
+	s.lastSend = time.Now()
 
 	return nil
 }
 
-func (s *SSEStreamer) sendRawEvent(event *protocol.StreamEvent) error {
+// openSegment notes the stream-out file at streamPath as the segment being
+// followed, as processNewContent would on reading it
+func (s *SSEStreamer) openSegment(streamPath string) error {
+	file, err := os.Open(streamPath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Printf("[ERROR] SSE: Failed to close file: %v", err)
+		}
+	}()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	s.segment = info
+	s.segmentID = segmentID(file, info)
+	return nil
+}
+
+// eventID returns the SSE id of the event ending at offset in the current
+// segment, or "" if the segment isn't known
+func (s *SSEStreamer) eventID(offset int64) string {
+	if s.segmentID == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", s.segmentID, offset)
+}
+
+// segmentID identifies the stream-out segment file is. Offsets are only
+// meaningful within a segment, so event ids carry it. The inode changes when
+// stream-out is rotated; the header line, with the time the segment started,
+// tells a new segment apart from an old one whose inode was reused. It's ""
+// until the header has been written.
+func segmentID(file *os.File, info os.FileInfo) string {
+	header, err := bufio.NewReader(io.NewSectionReader(file, 0, info.Size())).ReadBytes('\n')
+	if err != nil {
+		return ""
+	}
+	hash := fnv.New64a()
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		fmt.Fprintf(hash, "%d:%d:", stat.Dev, stat.Ino)
+	}
+	hash.Write(header)
+	return strconv.FormatUint(hash.Sum64(), 36)
+}
+
+// sendRawEvent sends event, with id as its SSE id unless it's ""
+func (s *SSEStreamer) sendRawEvent(event *protocol.StreamEvent, id string) error {
+	if id != "" && event.Type == "event" && event.Event != nil {
+		if _, err := fmt.Fprintf(s.w, "id: %s\n", id); err != nil {
+			return err // Client disconnected
+		}
+	}
+
 	// Match Rust behavior exactly - send raw arrays for terminal events
 	if event.Type == "header" {
 		// Skip headers like Rust does
@@ -264,6 +567,7 @@ func (s *SSEStreamer) sendRawEvent(event *protocol.StreamEvent) error {
 		if s.flusher != nil {
 			s.flusher.Flush()
 		}
+		s.lastSend = time.Now()
 
 		return nil
 	}
@@ -287,10 +591,23 @@ func (s *SSEStreamer) sendRawEvent(event *protocol.StreamEvent) error {
 	if s.flusher != nil {
 		s.flusher.Flush()
 	}
+	s.lastSend = time.Now()
 
 	return nil
 }
 
+// sendKeepAlive writes an SSE comment, which EventSource clients ignore
+func (s *SSEStreamer) sendKeepAlive() error {
+	if _, err := fmt.Fprint(s.w, ": keep-alive\n\n"); err != nil {
+		return err // Client disconnected
+	}
+	if s.flusher != nil {
+		s.flusher.Flush()
+	}
+	s.lastSend = time.Now()
+	return nil
+}
+
 func (s *SSEStreamer) sendError(message string) error {
 	event := &protocol.StreamEvent{
 		Type:    "error",
@@ -303,9 +620,32 @@ type SessionSnapshot struct {
 	SessionID string                    `json:"session_id"`
 	Header    *protocol.AsciinemaHeader `json:"header"`
 	Events    []protocol.AsciinemaEvent `json:"events"`
+	// Terminal size after the last event, which differs from the header's
+	// if the session was resized
+	Width  uint32 `json:"width"`
+	Height uint32 `json:"height"`
+	// Resizes lists size changes within Events, on the same time base
+	Resizes []SnapshotResize `json:"resizes"`
+}
+
+// SnapshotResize is a point in a snapshot where the terminal changed size
+type SnapshotResize struct {
+	Time   float64 `json:"time"`
+	Width  uint32  `json:"width"`
+	Height uint32  `json:"height"`
 }
 
-func GetSessionSnapshot(sess *session.Session) (*SessionSnapshot, error) {
+// SnapshotOptions selects which part of a recording a snapshot covers. The
+// zero value trims to the last clear screen for a fast initial render.
+type SnapshotOptions struct {
+	// Full returns the entire history without trimming
+	Full bool
+	// Since, if positive, returns only events after this timestamp for
+	// incremental catch-up. Events keep their original timestamps.
+	Since float64
+}
+
+func GetSessionSnapshot(sess *session.Session, opts SnapshotOptions) (*SessionSnapshot, error) {
 	streamPath := sess.StreamOutPath()
 	file, err := os.Open(streamPath)
 	if err != nil {
@@ -321,11 +661,18 @@ func GetSessionSnapshot(sess *session.Session) (*SessionSnapshot, error) {
 	snapshot := &SessionSnapshot{
 		SessionID: sess.ID,
 		Events:    make([]protocol.AsciinemaEvent, 0),
+		Resizes:   make([]SnapshotResize, 0),
 	}
 
 	lastClearIndex := -1
 	eventIndex := 0
 
+	// Resize points by event index, so the ones before a trimmed window can
+	// be dropped
+	var resizes []SnapshotResize
+	var resizeIndexes []int
+
+scan:
 	for {
 		event, err := reader.Next()
 		if err != nil {
@@ -338,28 +685,178 @@ func GetSessionSnapshot(sess *session.Session) (*SessionSnapshot, error) {
 		switch event.Type {
 		case "header":
 			snapshot.Header = event.Header
+			snapshot.Width = event.Header.Width
+			snapshot.Height = event.Header.Height
 		case "event":
 			snapshot.Events = append(snapshot.Events, *event.Event)
-			if event.Event.Type == protocol.EventOutput && containsClearScreen(event.Event.Data) {
-				lastClearIndex = eventIndex
+			switch event.Event.Type {
+			case protocol.EventOutput:
+				if containsClearScreen(event.Event.Data) {
+					lastClearIndex = eventIndex
+				}
+			case protocol.EventResize:
+				if width, height, err := protocol.ParseResize(event.Event.Data); err == nil {
+					snapshot.Width, snapshot.Height = width, height
+					resizes = append(resizes, SnapshotResize{Time: event.Event.Time, Width: width, Height: height})
+					resizeIndexes = append(resizeIndexes, eventIndex)
+				}
 			}
 			eventIndex++
+		case "end":
+			break scan
 		}
 	}
 
-	if lastClearIndex >= 0 && lastClearIndex < len(snapshot.Events)-1 {
-		snapshot.Events = snapshot.Events[lastClearIndex:]
-		if len(snapshot.Events) > 0 {
-			firstTime := snapshot.Events[0].Time
-			for i := range snapshot.Events {
-				snapshot.Events[i].Time -= firstTime
+	// Pick the window of events to return. Only the trimmed mode shifts
+	// timestamps so the window starts at zero.
+	firstIndex := 0
+	firstTime := 0.0
+	windowStart := 0.0
+	switch {
+	case opts.Since > 0:
+		firstIndex = sort.Search(len(snapshot.Events), func(i int) bool {
+			return snapshot.Events[i].Time > opts.Since
+		})
+		windowStart = opts.Since
+	case !opts.Full && lastClearIndex >= 0 && lastClearIndex < len(snapshot.Events)-1:
+		firstIndex = lastClearIndex
+		firstTime = snapshot.Events[lastClearIndex].Time
+	}
+
+	snapshot.Events = snapshot.Events[firstIndex:]
+	if firstTime != 0 {
+		for i := range snapshot.Events {
+			snapshot.Events[i].Time -= firstTime
+		}
+	}
+
+	for i, resize := range resizes {
+		if resizeIndexes[i] < firstIndex {
+			// Earlier resizes only matter for the size the window starts at
+			if i == len(resizes)-1 || resizeIndexes[i+1] >= firstIndex {
+				snapshot.Resizes = append(snapshot.Resizes, SnapshotResize{Time: windowStart, Width: resize.Width, Height: resize.Height})
 			}
+			continue
 		}
+		resize.Time -= firstTime
+		snapshot.Resizes = append(snapshot.Resizes, resize)
 	}
 
 	return snapshot, nil
 }
 
+// GetSessionTail returns a snapshot holding only the last n bytes of output.
+// Running sessions are served from the in-memory output ring; otherwise, or
+// if the ring no longer covers n bytes, the recording is read from disk.
+func GetSessionTail(sess *session.Session, n int) (snapshot *SessionSnapshot, fromMemory bool, err error) {
+	if events, _, ok := sess.TailOutput(n); ok {
+		info := sess.GetInfo()
+		return &SessionSnapshot{
+			SessionID: sess.ID,
+			Header: &protocol.AsciinemaHeader{
+				Version: 2,
+				Width:   uint32(info.Width),
+				Height:  uint32(info.Height),
+				Command: info.Cmdline,
+			},
+			Events:  events,
+			Width:   uint32(info.Width),
+			Height:  uint32(info.Height),
+			Resizes: make([]SnapshotResize, 0),
+		}, true, nil
+	}
+
+	file, err := os.Open(sess.StreamOutPath())
+	if err != nil {
+		return nil, false, err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Printf("[ERROR] SSE: Failed to close file: %v", err)
+		}
+	}()
+
+	reader := protocol.NewStreamReader(file)
+	snapshot = &SessionSnapshot{SessionID: sess.ID, Resizes: make([]SnapshotResize, 0)}
+	events := make([]protocol.AsciinemaEvent, 0)
+	for {
+		event, err := reader.Next()
+		if err != nil {
+			if err != io.EOF {
+				return nil, false, err
+			}
+			break
+		}
+		if event.Type == "end" {
+			break
+		}
+
+		switch event.Type {
+		case "header":
+			snapshot.Header = event.Header
+			snapshot.Width = event.Header.Width
+			snapshot.Height = event.Header.Height
+		case "event":
+			events = append(events, *event.Event)
+			if event.Event.Type == protocol.EventResize {
+				if width, height, err := protocol.ParseResize(event.Event.Data); err == nil {
+					snapshot.Width, snapshot.Height = width, height
+				}
+			}
+		}
+	}
+
+	snapshot.Events = session.TailOutputEvents(events, n)
+	return snapshot, false, nil
+}
+
+// replayStart returns the stream-out offset a trimmed replay begins at: the
+// first complete line within the last maxBytes (anywhere if maxBytes <= 0)
+// or, with toClear, the last output event in that range that clears the
+// screen. 0 means the whole recording is replayed.
+func replayStart(path string, maxBytes int64, toClear bool) (int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Printf("[ERROR] SSE: Failed to close file: %v", err)
+		}
+	}()
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	start, _, err := skipBacklog(file, 0, info.Size(), maxBytes)
+	if err != nil || !toClear {
+		return start, err
+	}
+
+	reader := bufio.NewReader(io.NewSectionReader(file, start, info.Size()-start))
+	lastClear := int64(-1)
+	for offset := start; ; {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			break // Only complete lines count
+		}
+		var event []interface{}
+		if json.Unmarshal(line, &event) == nil && len(event) == 3 {
+			eventType, _ := event[1].(string)
+			data, _ := event[2].(string)
+			if eventType == string(protocol.EventOutput) && containsClearScreen(data) {
+				lastClear = offset
+			}
+		}
+		offset += int64(len(line))
+	}
+	if lastClear >= 0 {
+		return lastClear, nil
+	}
+	return start, nil
+}
+
 func containsClearScreen(data string) bool {
 	clearSequences := []string{
 		"\x1b[H\x1b[2J",
@@ -0,0 +1,281 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vibetunnel/linux/pkg/session"
+)
+
+func TestSilentStreamGetsHeartbeats(t *testing.T) {
+	const interval = 200 * time.Millisecond
+	s, ts := newTestServer(t)
+	s.SetSSEKeepAlive(interval)
+
+	sess, err := s.manager.CreateSession(session.Config{
+		Cmdline: []string{"/bin/sh", "-c", `echo ready; sleep 30`},
+	})
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	defer func() {
+		if err := sess.Kill(); err != nil {
+			t.Logf("Failed to kill session: %v", err)
+		}
+		sess.Wait()
+	}()
+
+	resp, err := http.Get(ts.URL + "/api/sessions/" + sess.ID + "/stream")
+	if err != nil {
+		t.Fatalf("GET stream: %v", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			t.Logf("Failed to close response body: %v", err)
+		}
+	}()
+
+	// Skip the replay, then time the keep-alives of the quiet stream
+	reader := bufio.NewReader(resp.Body)
+	last := time.Time{}
+	heartbeats := 0
+	for heartbeats < 5 {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("stream ended after %d keep-alives: %v", heartbeats, err)
+		}
+		now := time.Now()
+		switch {
+		case line == ": keep-alive\n":
+			// The stream is silent for at most the interval, not until a
+			// later tick
+			if gap := now.Sub(last); !last.IsZero() && gap > interval*3/2 {
+				t.Errorf("%v between keep-alives, want about %v", gap, interval)
+			}
+			heartbeats++
+			last = now
+		case strings.HasPrefix(line, "data: "):
+			if heartbeats > 0 {
+				t.Errorf("event %q on a silent session", line)
+			}
+			last = now
+		case line == "\n", strings.HasPrefix(line, "id: "):
+		default:
+			t.Errorf("unexpected line %q", line)
+		}
+	}
+}
+
+func TestStreamEndsWhenClientDisconnects(t *testing.T) {
+	m := session.NewManager(t.TempDir())
+	sess, err := m.CreateSession(session.Config{
+		Cmdline: []string{"/bin/sh", "-c", "sleep 30"},
+	})
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	defer func() {
+		if err := sess.Kill(); err != nil {
+			t.Logf("Failed to kill session: %v", err)
+		}
+		sess.Wait()
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := httptest.NewRequest(http.MethodGet, "/api/sessions/"+sess.ID+"/stream", nil).WithContext(ctx)
+	// Without keep-alives nothing is written to a silent session, so no
+	// write fails to reveal the disconnect
+	streamer := NewSSEStreamer(httptest.NewRecorder(), r, sess)
+	streamer.SetKeepAlive(0)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		streamer.Stream()
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("stream still running after the client disconnected")
+	}
+}
+
+// streamEvent is an output event read from a session stream, with its SSE id
+type streamEvent struct {
+	id        string
+	eventType string
+	data      string
+}
+
+// openStream opens the session's stream, resuming after lastID if it isn't ""
+func openStream(t *testing.T, ts *httptest.Server, sess *session.Session, lastID string) (*bufio.Reader, func()) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, ts.URL+"/api/sessions/"+sess.ID+"/stream?full=true", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastID != "" {
+		req.Header.Set("Last-Event-ID", lastID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET stream: %v", err)
+	}
+	return bufio.NewReader(resp.Body), func() {
+		if err := resp.Body.Close(); err != nil {
+			t.Logf("Failed to close response body: %v", err)
+		}
+	}
+}
+
+// readStreamEvent reads the next output or resize event from a stream
+func readStreamEvent(t *testing.T, reader *bufio.Reader) streamEvent {
+	t.Helper()
+	var event streamEvent
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("stream ended: %v", err)
+		}
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			event.id = strings.TrimSpace(strings.TrimPrefix(line, "id: "))
+		case strings.HasPrefix(line, "data: "):
+			var fields []interface{}
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &fields); err != nil || len(fields) != 3 {
+				event = streamEvent{}
+				continue
+			}
+			event.eventType, _ = fields[1].(string)
+			event.data, _ = fields[2].(string)
+			return event
+		}
+	}
+}
+
+// readStreamUntil reads events until one contains want, and returns the
+// output read and the last event id seen
+func readStreamUntil(t *testing.T, reader *bufio.Reader, want string) (string, string) {
+	t.Helper()
+	var output strings.Builder
+	lastID := ""
+	for !strings.Contains(output.String(), want) {
+		event := readStreamEvent(t, reader)
+		if event.eventType == "o" {
+			output.WriteString(event.data)
+		}
+		if event.id != "" {
+			lastID = event.id
+		}
+	}
+	return output.String(), lastID
+}
+
+func TestStreamResumesAfterLastEventID(t *testing.T) {
+	s, ts := newTestServer(t)
+	sess, err := s.manager.CreateSession(session.Config{
+		Cmdline: []string{"/bin/sh", "-c", `for i in 1 2 3; do echo "out-$i"; done; read line; for i in 4 5 6; do echo "out-$i"; done; sleep 30`},
+	})
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	defer func() {
+		if err := sess.Kill(); err != nil {
+			t.Logf("Failed to kill session: %v", err)
+		}
+		sess.Wait()
+	}()
+
+	reader, closeStream := openStream(t, ts, sess, "")
+	before, lastID := readStreamUntil(t, reader, "out-3")
+	closeStream()
+	if lastID == "" {
+		t.Fatal("no event ids sent")
+	}
+
+	// Output written while the client is away is picked up on reconnect
+	if err := sess.SendText("\n"); err != nil {
+		t.Fatalf("SendText: %v", err)
+	}
+	waitForRecording(t, sess, "out-6")
+	reader, closeStream = openStream(t, ts, sess, lastID)
+	defer closeStream()
+	after, _ := readStreamUntil(t, reader, "out-6")
+
+	output := before + after
+	for i := 1; i <= 6; i++ {
+		if n := strings.Count(output, fmt.Sprintf("out-%d", i)); n != 1 {
+			t.Errorf("out-%d received %d times, want once; output %q", i, n, output)
+		}
+	}
+}
+
+func TestStreamIgnoresLastEventIDFromOtherSegment(t *testing.T) {
+	s, ts := newTestServer(t)
+	s.manager.SetMaxRecordingSize(4096)
+	sess, err := s.manager.CreateSession(session.Config{
+		Cmdline: []string{"/bin/sh", "-c", `echo first; read line; i=0; while [ $i -lt 100 ]; do echo "line $i of output to fill the recording"; i=$((i+1)); done; echo last; cat`},
+	})
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	defer func() {
+		if err := sess.Kill(); err != nil {
+			t.Logf("Failed to kill session: %v", err)
+		}
+		sess.Wait()
+	}()
+
+	reader, closeStream := openStream(t, ts, sess, "")
+	_, staleID := readStreamUntil(t, reader, "first")
+	closeStream()
+	_, staleOffset, _ := strings.Cut(staleID, ":")
+	offset, err := strconv.ParseInt(staleOffset, 10, 64)
+	if err != nil {
+		t.Fatalf("event id %q has no offset: %v", staleID, err)
+	}
+
+	// Rotate stream-out, then grow the new segment past the stale offset,
+	// which a bare offset would resume in the middle of
+	if err := sess.SendText("\n"); err != nil {
+		t.Fatalf("SendText: %v", err)
+	}
+	waitForRecording(t, sess, "last")
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, rotated := os.Stat(sess.PreviousStreamOutPath())
+		info, err := os.Stat(sess.StreamOutPath())
+		if rotated == nil && err == nil && info.Size() > offset+512 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("stream-out wasn't rotated and grown past offset %d", offset)
+		}
+		if err := sess.SendText("more output\n"); err != nil {
+			t.Fatalf("SendText: %v", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// The stale id gets a replay from the start of the segment, which opens
+	// with a clear screen
+	reader, closeStream = openStream(t, ts, sess, staleID)
+	defer closeStream()
+	event := readStreamEvent(t, reader)
+	if event.eventType != "r" && !strings.Contains(event.data, "\x1b[H\x1b[2J") {
+		t.Errorf("first event after a stale id is %+v, want a replay from a clear screen", event)
+	}
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// StreamInfo describes an active streaming connection
+type StreamInfo struct {
+	ID        string    `json:"id"`
+	SessionID string    `json:"sessionId"`
+	Type      string    `json:"type"` // "sse", "multistream", "websocket", "playback", "notifications" or "events"
+	ClientIP  string    `json:"clientIp"`
+	StartedAt time.Time `json:"startedAt"`
+	Duration  float64   `json:"duration"` // Seconds since the stream started
+}
+
+type activeStream struct {
+	info   StreamInfo
+	cancel func()
+}
+
+// StreamRegistry keeps track of in-flight streaming connections so they can
+// be listed and terminated administratively
+type StreamRegistry struct {
+	mu      sync.RWMutex
+	streams map[string]*activeStream
+}
+
+func NewStreamRegistry() *StreamRegistry {
+	return &StreamRegistry{
+		streams: make(map[string]*activeStream),
+	}
+}
+
+// Register adds a stream and returns its ID. cancel is called when the
+// stream is terminated through Cancel.
+func (r *StreamRegistry) Register(sessionID, streamType, clientIP string, cancel func()) string {
+	id := uuid.New().String()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.streams[id] = &activeStream{
+		info: StreamInfo{
+			ID:        id,
+			SessionID: sessionID,
+			Type:      streamType,
+			ClientIP:  clientIP,
+			StartedAt: time.Now(),
+		},
+		cancel: cancel,
+	}
+
+	debugLog("[DEBUG] Streams: Registered %s stream %s for session %s", streamType, id[:8], sessionID)
+	return id
+}
+
+// SetSession updates the session a stream is attached to
+func (r *StreamRegistry) SetSession(id, sessionID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if stream, ok := r.streams[id]; ok {
+		stream.info.SessionID = sessionID
+	}
+}
+
+// Unregister removes a stream once it has finished
+func (r *StreamRegistry) Unregister(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.streams, id)
+}
+
+// List returns all active streams, oldest first
+func (r *StreamRegistry) List() []StreamInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := time.Now()
+	streams := make([]StreamInfo, 0, len(r.streams))
+	for _, stream := range r.streams {
+		info := stream.info
+		info.Duration = now.Sub(info.StartedAt).Seconds()
+		streams = append(streams, info)
+	}
+
+	sort.Slice(streams, func(i, j int) bool {
+		return streams[i].StartedAt.Before(streams[j].StartedAt)
+	})
+
+	return streams
+}
+
+// Cancel terminates a stream, returning false if it doesn't exist
+func (r *StreamRegistry) Cancel(id string) bool {
+	r.mu.Lock()
+	stream, ok := r.streams[id]
+	if ok {
+		delete(r.streams, id)
+	}
+	r.mu.Unlock()
+
+	if !ok {
+		return false
+	}
+
+	if stream.cancel != nil {
+		stream.cancel()
+	}
+	return true
+}
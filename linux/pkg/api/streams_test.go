@@ -0,0 +1,97 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// listStreams fetches /api/streams
+func listStreams(t *testing.T, serverURL string) []StreamInfo {
+	t.Helper()
+	resp, err := http.Get(serverURL + "/api/streams")
+	if err != nil {
+		t.Fatalf("GET /api/streams: %v", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			t.Logf("Failed to close response body: %v", err)
+		}
+	}()
+	var streams []StreamInfo
+	if err := json.NewDecoder(resp.Body).Decode(&streams); err != nil {
+		t.Fatalf("GET /api/streams: invalid JSON response: %v", err)
+	}
+	return streams
+}
+
+// cancelStream deletes a stream through the API and returns the status code
+func cancelStream(t *testing.T, serverURL, id string) int {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodDelete, serverURL+"/api/streams/"+id, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("DELETE stream: %v", err)
+	}
+	if err := resp.Body.Close(); err != nil {
+		t.Logf("Failed to close response body: %v", err)
+	}
+	return resp.StatusCode
+}
+
+func TestListAndCancelStream(t *testing.T) {
+	s, ts := newTestServer(t)
+	// Output makes the stream respond at once rather than at the first
+	// keep-alive
+	sess := startSession(t, s, ts, map[string]interface{}{"command": []string{"/bin/sh", "-c", "echo ready; exec sleep 30"}})
+	waitForRecording(t, sess, "ready")
+
+	reader, closeStream := openStream(t, ts, sess, "")
+	defer closeStream()
+
+	// The handler registers the stream once the request arrives
+	var streams []StreamInfo
+	deadline := time.Now().Add(5 * time.Second)
+	for streams = listStreams(t, ts.URL); len(streams) == 0; streams = listStreams(t, ts.URL) {
+		if time.Now().After(deadline) {
+			t.Fatal("open stream not listed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(streams) != 1 {
+		t.Fatalf("streams = %+v, want one", streams)
+	}
+	stream := streams[0]
+	if stream.SessionID != sess.ID || stream.Type != "sse" || stream.ClientIP != "127.0.0.1" {
+		t.Errorf("stream = %+v, want an sse stream of session %s from 127.0.0.1", stream, sess.ID)
+	}
+
+	if status := cancelStream(t, ts.URL, stream.ID); status != http.StatusOK {
+		t.Fatalf("DELETE stream: status %d, want 200", status)
+	}
+	ended := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(io.Discard, reader)
+		ended <- err
+	}()
+	select {
+	case err := <-ended:
+		if err != nil {
+			t.Errorf("stream ended with %v, want a clean end", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream still open after it was cancelled")
+	}
+
+	if streams := listStreams(t, ts.URL); len(streams) != 0 {
+		t.Errorf("streams after cancelling = %+v, want none", streams)
+	}
+	if status := cancelStream(t, ts.URL, stream.ID); status != http.StatusNotFound {
+		t.Errorf("DELETE cancelled stream: status %d, want 404", status)
+	}
+}
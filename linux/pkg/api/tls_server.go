@@ -1,6 +1,7 @@
 package api
 
 import (
+	"bytes"
 	"context"
 	"crypto/rand"
 	"crypto/rsa"
@@ -9,25 +10,61 @@ import (
 	"crypto/x509/pkix"
 	"encoding/pem"
 	"fmt"
+	"io"
 	"log"
 	"math/big"
 	"net"
 	"net/http"
+	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/caddyserver/certmagic"
+	"golang.org/x/crypto/ocsp"
 )
 
 // TLSConfig represents TLS configuration options
 type TLSConfig struct {
-	Enabled      bool   `json:"enabled"`
-	Port         int    `json:"port"`
-	Domain       string `json:"domain,omitempty"`    // Optional domain for Let's Encrypt
-	SelfSigned   bool   `json:"self_signed"`         // Use self-signed certificates
-	CertPath     string `json:"cert_path,omitempty"` // Custom cert path
-	KeyPath      string `json:"key_path,omitempty"`  // Custom key path
-	AutoRedirect bool   `json:"auto_redirect"`       // Redirect HTTP to HTTPS
+	Enabled      bool     `json:"enabled"`
+	Port         int      `json:"port"`
+	Domain       string   `json:"domain,omitempty"`     // Optional domain for Let's Encrypt
+	SelfSigned   bool     `json:"self_signed"`          // Use self-signed certificates
+	CertPath     string   `json:"cert_path,omitempty"`  // Custom cert path
+	KeyPath      string   `json:"key_path,omitempty"`   // Custom key path
+	AutoRedirect bool     `json:"auto_redirect"`        // Redirect HTTP to HTTPS
+	MinVersion   string   `json:"min_version"`          // Oldest TLS version accepted, "1.2" (default) or "1.3"
+	ExtraSANs    []string `json:"extra_sans,omitempty"` // Extra hostnames or IPs for the self-signed certificate
+}
+
+// tlsVersions maps the accepted MinVersion values to TLS versions
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// tlsCipherSuites are the TLS 1.2 cipher suites offered: ECDHE key exchange
+// for forward secrecy and AEAD ciphers only. TLS 1.3 suites aren't
+// configurable in Go and are all considered safe.
+var tlsCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+}
+
+// tlsCurves are the key exchange groups in order of preference, starting
+// with the post-quantum hybrid that current browsers support
+var tlsCurves = []tls.CurveID{
+	tls.X25519MLKEM768,
+	tls.X25519,
+	tls.CurveP256,
+	tls.CurveP384,
 }
 
 // TLSServer wraps the regular server with TLS capabilities
@@ -50,6 +87,7 @@ func (s *TLSServer) StartTLS(httpAddr, httpsAddr string) error {
 		// Fall back to regular HTTP
 		return s.Start(httpAddr)
 	}
+	s.tlsEnabled = true
 
 	// Set up TLS configuration
 	tlsConfig, err := s.setupTLS()
@@ -60,14 +98,14 @@ func (s *TLSServer) StartTLS(httpAddr, httpsAddr string) error {
 	// Create HTTP handler
 	handler := s.setupRoutes()
 
-	// Start HTTPS server
+	// Start HTTPS server. Like Start, there are no read or write timeouts,
+	// which would cut off SSE streams and WebSockets after a few seconds.
 	httpsServer := &http.Server{
-		Addr:         httpsAddr,
-		Handler:      handler,
-		TLSConfig:    tlsConfig,
-		ReadTimeout:  30 * time.Second,
-		WriteTimeout: 30 * time.Second,
-		IdleTimeout:  120 * time.Second,
+		Addr:              httpsAddr,
+		Handler:           handler,
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       120 * time.Second,
 	}
 
 	log.Printf("Starting HTTPS server on %s", httpsAddr)
@@ -77,31 +115,58 @@ func (s *TLSServer) StartTLS(httpAddr, httpsAddr string) error {
 		go s.startHTTPRedirect(httpAddr, httpsAddr)
 	}
 
-	// Start HTTPS server
-	if s.tlsConfig.SelfSigned || (s.tlsConfig.CertPath != "" && s.tlsConfig.KeyPath != "") {
-		return httpsServer.ListenAndServeTLS(s.tlsConfig.CertPath, s.tlsConfig.KeyPath)
-	} else {
-		// Use CertMagic for automatic certificates
-		return httpsServer.ListenAndServeTLS("", "")
-	}
+	// Start HTTPS server. The TLS config already provides the certificate,
+	// loading it again from CertPath would bypass OCSP stapling.
+	return httpsServer.ListenAndServeTLS("", "")
 }
 
 // setupTLS configures TLS based on the provided configuration
 func (s *TLSServer) setupTLS() (*tls.Config, error) {
-	if s.tlsConfig.SelfSigned {
-		return s.setupSelfSignedTLS()
+	minVersion, ok := tlsVersions[s.tlsConfig.MinVersion]
+	if s.tlsConfig.MinVersion == "" {
+		minVersion, ok = tls.VersionTLS12, true
 	}
-
-	if s.tlsConfig.CertPath != "" && s.tlsConfig.KeyPath != "" {
-		return s.setupCustomCertTLS()
+	if !ok {
+		return nil, fmt.Errorf("unsupported minimum TLS version %q, use 1.2 or 1.3", s.tlsConfig.MinVersion)
 	}
 
-	if s.tlsConfig.Domain != "" {
-		return s.setupCertMagicTLS()
+	var tlsConfig *tls.Config
+	var err error
+	switch {
+	case s.tlsConfig.SelfSigned:
+		tlsConfig, err = s.setupSelfSignedTLS()
+	case s.tlsConfig.CertPath != "" && s.tlsConfig.KeyPath != "":
+		tlsConfig, err = s.setupCustomCertTLS()
+	case s.tlsConfig.Domain != "":
+		tlsConfig, err = s.setupCertMagicTLS()
+	default:
+		// Default to self-signed
+		tlsConfig, err = s.setupSelfSignedTLS()
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	// Default to self-signed
-	return s.setupSelfSignedTLS()
+	hardenTLSConfig(tlsConfig, minVersion)
+	return tlsConfig, nil
+}
+
+// hardenTLSConfig applies the protocol settings shared by all certificate
+// sources on top of what the source configured
+func hardenTLSConfig(config *tls.Config, minVersion uint16) {
+	config.MinVersion = minVersion
+	config.CipherSuites = tlsCipherSuites
+	config.CurvePreferences = tlsCurves
+
+	// Offer HTTP/2, keeping protocols the source needs, such as CertMagic's
+	// acme-tls/1 for TLS-ALPN challenges. WebSockets keep working: the HTTP/2
+	// server doesn't announce extended CONNECT (RFC 8441), so browsers open
+	// them on a separate HTTP/1.1 connection.
+	for _, proto := range []string{"h2", "http/1.1"} {
+		if !slices.Contains(config.NextProtos, proto) {
+			config.NextProtos = append(config.NextProtos, proto)
+		}
+	}
 }
 
 // setupSelfSignedTLS creates a self-signed certificate
@@ -115,7 +180,6 @@ func (s *TLSServer) setupSelfSignedTLS() (*tls.Config, error) {
 	return &tls.Config{
 		Certificates: []tls.Certificate{cert},
 		ServerName:   "localhost",
-		MinVersion:   tls.VersionTLS12,
 	}, nil
 }
 
@@ -126,9 +190,17 @@ func (s *TLSServer) setupCustomCertTLS() (*tls.Config, error) {
 		return nil, fmt.Errorf("failed to load custom certificates: %w", err)
 	}
 
+	stapler, err := newOCSPStapler(cert)
+	if err != nil {
+		log.Printf("[INFO] OCSP stapling disabled: %v", err)
+		return &tls.Config{
+			Certificates: []tls.Certificate{cert},
+		}, nil
+	}
+	go stapler.run()
+
 	return &tls.Config{
-		Certificates: []tls.Certificate{cert},
-		MinVersion:   tls.VersionTLS12,
+		GetCertificate: stapler.getCertificate,
 	}, nil
 }
 
@@ -156,7 +228,8 @@ func (s *TLSServer) setupCertMagicTLS() (*tls.Config, error) {
 	return tlsConfig, nil
 }
 
-// generateSelfSignedCert creates a self-signed certificate for localhost
+// generateSelfSignedCert creates a self-signed certificate for localhost,
+// this machine's hostname and network addresses, and any extra SANs
 func (s *TLSServer) generateSelfSignedCert() (tls.Certificate, error) {
 	// Generate RSA private key
 	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
@@ -179,9 +252,8 @@ func (s *TLSServer) generateSelfSignedCert() (tls.Certificate, error) {
 		NotAfter:    time.Now().Add(365 * 24 * time.Hour), // Valid for 1 year
 		KeyUsage:    x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
 		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
-		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
-		DNSNames:    []string{"localhost"},
 	}
+	template.IPAddresses, template.DNSNames = s.selfSignedSANs()
 
 	// Generate certificate
 	certDER, err := x509.CreateCertificate(rand.Reader, &template, &template, &privateKey.PublicKey, privateKey)
@@ -208,33 +280,68 @@ func (s *TLSServer) generateSelfSignedCert() (tls.Certificate, error) {
 	return cert, nil
 }
 
-// startHTTPRedirect starts an HTTP server that redirects all requests to HTTPS
-func (s *TLSServer) startHTTPRedirect(httpAddr, httpsAddr string) {
-	redirectHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Extract host from httpsAddr for redirect
-		host := r.Host
-		if host == "" {
-			host = "localhost"
+// selfSignedSANs returns the addresses and names the self-signed certificate
+// is valid for, so the dashboard can be opened over the LAN without a
+// hostname mismatch
+func (s *TLSServer) selfSignedSANs() ([]net.IP, []string) {
+	ips := []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback}
+	names := []string{"localhost"}
+
+	addIP := func(ip net.IP) {
+		if !slices.ContainsFunc(ips, ip.Equal) {
+			ips = append(ips, ip)
+		}
+	}
+	addName := func(name string) {
+		if name != "" && !slices.Contains(names, name) {
+			names = append(names, name)
 		}
+	}
 
-		// Remove port if present and add HTTPS port
-		if colonIndex := len(host) - 1; host[colonIndex] == ':' {
-			// Remove existing port
-			for i := colonIndex - 1; i >= 0; i-- {
-				if host[i] == ':' {
-					host = host[:i]
-					break
-				}
+	if hostname, err := os.Hostname(); err == nil {
+		addName(hostname)
+	} else {
+		log.Printf("[WARNING] Failed to get hostname for TLS certificate: %v", err)
+	}
+
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		log.Printf("[WARNING] Failed to list network interfaces for TLS certificate: %v", err)
+	}
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			log.Printf("[WARNING] Failed to get addresses of %s: %v", iface.Name, err)
+			continue
+		}
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			// Link-local addresses need a zone, which URLs can't carry
+			if !ok || ipNet.IP.IsLinkLocalUnicast() {
+				continue
 			}
+			addIP(ipNet.IP)
 		}
+	}
 
-		// Add HTTPS port
-		if s.tlsConfig.Port != 443 {
-			host = fmt.Sprintf("%s:%d", host, s.tlsConfig.Port)
+	for _, san := range s.tlsConfig.ExtraSANs {
+		if ip := net.ParseIP(san); ip != nil {
+			addIP(ip)
+		} else {
+			addName(san)
 		}
+	}
+
+	return ips, names
+}
 
-		httpsURL := fmt.Sprintf("https://%s%s", host, r.RequestURI)
-		http.Redirect(w, r, httpsURL, http.StatusPermanentRedirect)
+// startHTTPRedirect starts an HTTP server that redirects all requests to HTTPS
+func (s *TLSServer) startHTTPRedirect(httpAddr, httpsAddr string) {
+	redirectHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, s.httpsURL(r), http.StatusPermanentRedirect)
 	})
 
 	server := &http.Server{
@@ -248,6 +355,129 @@ func (s *TLSServer) startHTTPRedirect(httpAddr, httpsAddr string) {
 	}
 }
 
+// ocspStapler keeps a current OCSP response stapled to a certificate so
+// clients don't have to ask the CA whether it was revoked
+type ocspStapler struct {
+	mu     sync.RWMutex
+	cert   tls.Certificate
+	leaf   *x509.Certificate
+	issuer *x509.Certificate
+}
+
+// ocspRetryInterval is how long to wait after a failed OCSP request
+const ocspRetryInterval = 10 * time.Minute
+
+// newOCSPStapler prepares stapling for cert, which needs an OCSP responder
+// URL and the issuing certificate in the chain
+func newOCSPStapler(cert tls.Certificate) (*ocspStapler, error) {
+	if len(cert.Certificate) < 2 {
+		return nil, fmt.Errorf("certificate chain has no issuer certificate")
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+	if len(leaf.OCSPServer) == 0 {
+		return nil, fmt.Errorf("certificate has no OCSP responder")
+	}
+	issuer, err := x509.ParseCertificate(cert.Certificate[1])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse issuer certificate: %w", err)
+	}
+	return &ocspStapler{cert: cert, leaf: leaf, issuer: issuer}, nil
+}
+
+// getCertificate serves the certificate with the latest stapled response
+func (st *ocspStapler) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	cert := st.cert
+	return &cert, nil
+}
+
+// run refreshes the stapled response halfway through its validity period.
+// Until the first request succeeds the certificate is served unstapled.
+func (st *ocspStapler) run() {
+	for {
+		resp, raw, err := st.fetch()
+		if err != nil {
+			log.Printf("[WARNING] OCSP stapling: %v", err)
+			time.Sleep(ocspRetryInterval)
+			continue
+		}
+
+		if resp.Status == ocsp.Revoked {
+			log.Printf("[WARNING] OCSP stapling: certificate was revoked at %s", resp.RevokedAt)
+		}
+
+		st.mu.Lock()
+		st.cert.OCSPStaple = raw
+		st.mu.Unlock()
+
+		refresh := time.Hour
+		if !resp.NextUpdate.IsZero() {
+			refresh = max(resp.NextUpdate.Sub(resp.ThisUpdate)/2, refresh)
+		}
+		time.Sleep(refresh)
+	}
+}
+
+// fetch asks the certificate's OCSP responder for its revocation status
+func (st *ocspStapler) fetch() (*ocsp.Response, []byte, error) {
+	request, err := ocsp.CreateRequest(st.leaf, st.issuer, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	httpResp, err := client.Post(st.leaf.OCSPServer[0], "application/ocsp-request", bytes.NewReader(request))
+	if err != nil {
+		return nil, nil, fmt.Errorf("request to %s failed: %w", st.leaf.OCSPServer[0], err)
+	}
+	defer func() {
+		if err := httpResp.Body.Close(); err != nil {
+			log.Printf("[ERROR] Failed to close OCSP response body: %v", err)
+		}
+	}()
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("%s returned %s", st.leaf.OCSPServer[0], httpResp.Status)
+	}
+
+	raw, err := io.ReadAll(io.LimitReader(httpResp.Body, 1<<20))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	resp, err := ocsp.ParseResponseForCert(raw, st.leaf, st.issuer)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid response: %w", err)
+	}
+	return resp, raw, nil
+}
+
+// httpsURL returns the HTTPS URL for r on the TLS port. The host is taken
+// from the request, without the HTTP port, so the redirect works for any
+// name or address the server was reached by, including IPv6 literals.
+func (s *TLSServer) httpsURL(r *http.Request) string {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	} else {
+		// No port; a bare IPv6 literal keeps its brackets
+		host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	}
+	if host == "" {
+		host = "localhost"
+	}
+
+	if s.tlsConfig.Port != 443 {
+		host = net.JoinHostPort(host, strconv.Itoa(s.tlsConfig.Port))
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+
+	return "https://" + host + r.URL.RequestURI()
+}
+
 // setupRoutes returns the configured HTTP handler (reusing existing Server logic)
 func (s *TLSServer) setupRoutes() http.Handler {
 	// Use the existing server's router setup
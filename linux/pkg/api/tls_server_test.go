@@ -0,0 +1,234 @@
+package api
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/vibetunnel/linux/pkg/session"
+)
+
+// startTLSTestServer serves a TLSServer with config the way StartTLS does,
+// on a free local port, and returns its address
+func startTLSTestServer(t *testing.T, config *TLSConfig) (*TLSServer, string) {
+	t.Helper()
+	s := NewTLSServer(NewServer(session.NewManager(t.TempDir()), "", "", 0), config)
+	tlsConfig, err := s.setupTLS()
+	if err != nil {
+		t.Fatalf("setupTLS: %v", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpsServer := &http.Server{
+		Handler:   s.setupRoutes(),
+		TLSConfig: tlsConfig,
+		// Rejected handshakes are expected in some tests
+		ErrorLog: log.New(io.Discard, "", 0),
+	}
+	go func() {
+		if err := httpsServer.ServeTLS(listener, "", ""); !errors.Is(err, http.ErrServerClosed) {
+			t.Errorf("ServeTLS: %v", err)
+		}
+	}()
+	t.Cleanup(func() {
+		if err := httpsServer.Close(); err != nil {
+			t.Logf("Failed to close server: %v", err)
+		}
+	})
+	return s, listener.Addr().String()
+}
+
+// insecureTLS returns a client config for the self-signed test certificate.
+// Only test clients skip verification.
+func insecureTLS() *tls.Config {
+	return &tls.Config{InsecureSkipVerify: true}
+}
+
+func TestTLSNegotiatesHTTP2(t *testing.T) {
+	_, addr := startTLSTestServer(t, &TLSConfig{Enabled: true, SelfSigned: true})
+
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   insecureTLS(),
+		ForceAttemptHTTP2: true,
+	}}
+	resp, err := client.Get("https://" + addr + "/api/health")
+	if err != nil {
+		t.Fatalf("GET /api/health: %v", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			t.Logf("Failed to close response body: %v", err)
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want 200", resp.StatusCode)
+	}
+	if resp.Proto != "HTTP/2.0" {
+		t.Errorf("protocol = %s, want HTTP/2.0", resp.Proto)
+	}
+	if resp.TLS.Version != tls.VersionTLS13 {
+		t.Errorf("TLS version = %s, want TLS 1.3", tls.VersionName(resp.TLS.Version))
+	}
+}
+
+func TestTLSVersions(t *testing.T) {
+	tests := []struct {
+		name          string
+		minVersion    string
+		clientMax     uint16
+		wantVersion   uint16
+		wantHandshake bool
+	}{
+		{"default accepts 1.2", "", tls.VersionTLS12, tls.VersionTLS12, true},
+		{"default prefers 1.3", "", tls.VersionTLS13, tls.VersionTLS13, true},
+		{"1.3 rejects 1.2", "1.3", tls.VersionTLS12, 0, false},
+		{"1.3 accepts 1.3", "1.3", tls.VersionTLS13, tls.VersionTLS13, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, addr := startTLSTestServer(t, &TLSConfig{Enabled: true, SelfSigned: true, MinVersion: tt.minVersion})
+
+			clientConfig := insecureTLS()
+			clientConfig.MaxVersion = tt.clientMax
+			conn, err := tls.Dial("tcp", addr, clientConfig)
+			if !tt.wantHandshake {
+				if err == nil {
+					t.Errorf("handshake succeeded with TLS %s", tls.VersionName(conn.ConnectionState().Version))
+					if err := conn.Close(); err != nil {
+						t.Logf("Failed to close connection: %v", err)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("handshake failed: %v", err)
+			}
+			defer func() {
+				if err := conn.Close(); err != nil {
+					t.Logf("Failed to close connection: %v", err)
+				}
+			}()
+
+			state := conn.ConnectionState()
+			if state.Version != tt.wantVersion {
+				t.Errorf("TLS version = %s, want %s", tls.VersionName(state.Version), tls.VersionName(tt.wantVersion))
+			}
+			if state.Version == tls.VersionTLS12 && !slices.Contains(tlsCipherSuites, state.CipherSuite) {
+				t.Errorf("cipher suite %s is not in the configured list", tls.CipherSuiteName(state.CipherSuite))
+			}
+		})
+	}
+}
+
+func TestTLSRejectsUnknownMinVersion(t *testing.T) {
+	s := NewTLSServer(NewServer(session.NewManager(t.TempDir()), "", "", 0), &TLSConfig{
+		Enabled:    true,
+		SelfSigned: true,
+		MinVersion: "1.1",
+	})
+	if _, err := s.setupTLS(); err == nil {
+		t.Error("setupTLS accepted TLS 1.1 as the minimum version")
+	}
+}
+
+func TestHTTPSRedirectURL(t *testing.T) {
+	tests := []struct {
+		host string
+		port int
+		want string
+	}{
+		{"example.com", 4443, "https://example.com:4443/path?q=1"},
+		{"example.com:8080", 4443, "https://example.com:4443/path?q=1"},
+		{"[::1]:8080", 4443, "https://[::1]:4443/path?q=1"},
+		{"[::1]", 4443, "https://[::1]:4443/path?q=1"},
+		{"192.168.1.10:8080", 4443, "https://192.168.1.10:4443/path?q=1"},
+		{"example.com:8080", 443, "https://example.com/path?q=1"},
+		{"[::1]:8080", 443, "https://[::1]/path?q=1"},
+		{"", 4443, "https://localhost:4443/path?q=1"},
+	}
+	for _, tt := range tests {
+		s := NewTLSServer(nil, &TLSConfig{Port: tt.port})
+		r := httptest.NewRequest(http.MethodGet, "http://placeholder/path?q=1", nil)
+		r.Host = tt.host
+		if got := s.httpsURL(r); got != tt.want {
+			t.Errorf("host %q, port %d: redirect to %q, want %q", tt.host, tt.port, got, tt.want)
+		}
+	}
+}
+
+func TestWebSocketOverTLS(t *testing.T) {
+	s, addr := startTLSTestServer(t, &TLSConfig{Enabled: true, SelfSigned: true})
+	sess, err := s.manager.CreateSession(session.Config{
+		Cmdline: []string{"/bin/sh", "-c", `echo over-wss; sleep 5`},
+	})
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	defer func() {
+		if err := sess.Kill(); err != nil {
+			t.Logf("Failed to kill session: %v", err)
+		}
+		sess.Wait()
+	}()
+
+	dialer := &websocket.Dialer{TLSClientConfig: insecureTLS()}
+	conn, _, err := dialer.Dial("wss://"+addr+"/buffers", nil)
+	if err != nil {
+		t.Fatalf("wss handshake failed: %v", err)
+	}
+	defer func() {
+		if err := conn.Close(); err != nil {
+			t.Logf("Failed to close WebSocket: %v", err)
+		}
+	}()
+
+	if err := conn.WriteJSON(map[string]string{"type": "subscribe", "sessionId": sess.ID}); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if err := conn.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("no output over wss: %v", err)
+		}
+		if messageType == websocket.BinaryMessage && data[0] == BufferMagicByte && bytes.Contains(data, []byte("over-wss")) {
+			return
+		}
+	}
+}
+
+func TestWebSocketRejectsHTTP2(t *testing.T) {
+	_, addr := startTLSTestServer(t, &TLSConfig{Enabled: true, SelfSigned: true})
+
+	// HTTP/2 has no Upgrade mechanism; the handler must say so instead of
+	// failing inside the upgrader
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   insecureTLS(),
+		ForceAttemptHTTP2: true,
+	}}
+	resp, err := client.Get("https://" + addr + "/buffers")
+	if err != nil {
+		t.Fatalf("GET /buffers: %v", err)
+	}
+	if err := resp.Body.Close(); err != nil {
+		t.Logf("Failed to close response body: %v", err)
+	}
+	if resp.Proto != "HTTP/2.0" || resp.StatusCode != http.StatusHTTPVersionNotSupported {
+		t.Errorf("got %s %d, want HTTP/2.0 505", resp.Proto, resp.StatusCode)
+	}
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// DefaultUnixSocketMode lets the owner and group, e.g. a reverse proxy's,
+// connect to the Unix socket
+const DefaultUnixSocketMode os.FileMode = 0660
+
+// listenUnix listens on a Unix socket at path with the given permissions.
+// A socket left behind by a server that didn't shut down cleanly is
+// replaced, but a live one or any other kind of file is an error. The
+// socket is removed when the listener is closed.
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if conn, err := net.Dial("unix", path); err == nil {
+			if err := conn.Close(); err != nil {
+				debugLog("[DEBUG] Failed to close probe connection: %v", err)
+			}
+			return nil, fmt.Errorf("%s is in use by another server", path)
+		}
+		debugLog("[DEBUG] Removing stale socket %s", path)
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+		}
+	}
+
+	// The socket is created with the umask's permissions, so it's made in
+	// a private directory and only moved to path once mode is set; nobody
+	// can connect in between
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".vibetunnel-socket-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create socket directory: %w", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			debugLog("[DEBUG] Failed to remove socket directory: %v", err)
+		}
+	}()
+
+	tmpPath := filepath.Join(dir, "socket")
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: tmpPath, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	// The listener would unlink tmpPath; path is removed instead
+	listener.SetUnlinkOnClose(false)
+	if err := os.Chmod(tmpPath, mode); err != nil {
+		if err := listener.Close(); err != nil {
+			debugLog("[DEBUG] Failed to close listener: %v", err)
+		}
+		return nil, fmt.Errorf("failed to set socket permissions: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		if err := listener.Close(); err != nil {
+			debugLog("[DEBUG] Failed to close listener: %v", err)
+		}
+		return nil, fmt.Errorf("failed to move socket into place: %w", err)
+	}
+	return &unixListener{UnixListener: listener, path: path}, nil
+}
+
+// unixListener removes its socket from the path it was moved to on Close
+type unixListener struct {
+	*net.UnixListener
+	path      string
+	closeOnce sync.Once
+}
+
+func (l *unixListener) Close() error {
+	err := l.UnixListener.Close()
+	l.closeOnce.Do(func() {
+		if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+			debugLog("[DEBUG] Failed to remove socket %s: %v", l.path, err)
+		}
+	})
+	return err
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vibetunnel/linux/pkg/session"
+)
+
+// unixClient returns an HTTP client that connects to the socket at path
+// whatever the URL's host
+func unixClient(path string) *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", path)
+		},
+	}}
+}
+
+func TestServeOverUnixSocket(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "vibetunnel.sock")
+	listener, err := listenUnix(path, 0600)
+	if err != nil {
+		t.Fatalf("listenUnix: %v", err)
+	}
+
+	s := NewServer(session.NewManager(t.TempDir()), "", "", 0)
+	srv := &http.Server{Handler: s.createHandler()}
+	served := make(chan error, 1)
+	go func() {
+		served <- srv.Serve(listener)
+	}()
+
+	stat, err := os.Lstat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Mode()&os.ModeSocket == 0 || stat.Mode().Perm() != 0600 {
+		t.Errorf("socket mode = %v, want a socket with 0600", stat.Mode())
+	}
+	// The private directory the socket was created in is gone
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("socket directory holds %d entries, want only the socket", len(entries))
+	}
+
+	resp, err := unixClient(path).Get("http://vibetunnel/api/health")
+	if err != nil {
+		t.Fatalf("GET /api/health over the socket: %v", err)
+	}
+	if err := resp.Body.Close(); err != nil {
+		t.Logf("Failed to close response body: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want 200", resp.StatusCode)
+	}
+
+	if err := srv.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-served; !errors.Is(err, http.ErrServerClosed) {
+		t.Errorf("Serve: %v", err)
+	}
+	if _, err := os.Lstat(path); !os.IsNotExist(err) {
+		t.Errorf("socket still exists after the server closed: %v", err)
+	}
+}
+
+func TestUnixSocketReplacesOnlyStaleSockets(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "vibetunnel.sock")
+
+	listener, err := listenUnix(path, 0600)
+	if err != nil {
+		t.Fatalf("listenUnix: %v", err)
+	}
+	if _, err := listenUnix(path, 0600); err == nil {
+		t.Error("listened on a socket another server is using")
+	}
+	if err := listener.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A socket whose server died without removing it
+	stale, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.SetUnlinkOnClose(false)
+	if err := stale.Close(); err != nil {
+		t.Fatal(err)
+	}
+	listener, err = listenUnix(path, 0600)
+	if err != nil {
+		t.Fatalf("listenUnix over a stale socket: %v", err)
+	}
+	if err := listener.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := listenUnix(path, 0600); err == nil {
+		t.Error("replaced a regular file with the socket")
+	}
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"runtime"
+)
+
+// VersionResponse is the body of /api/version
+type VersionResponse struct {
+	Version         string `json:"version"`
+	Commit          string `json:"commit"`
+	GoVersion       string `json:"goVersion"`
+	OS              string `json:"os"`
+	Arch            string `json:"arch"`
+	BufferProtocol  int    `json:"bufferProtocol"`  // Used by /buffers clients that don't say hello
+	BufferProtocols []int  `json:"bufferProtocols"` // Can be negotiated with a hello message
+}
+
+// handleVersion reports what the server was built from, so clients can
+// adapt to it or warn about an incompatible server. It needs no password.
+func (s *Server) handleVersion(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(VersionResponse{
+		Version:         s.version,
+		Commit:          s.commit,
+		GoVersion:       runtime.Version(),
+		OS:              runtime.GOOS,
+		Arch:            runtime.GOARCH,
+		BufferProtocol:  BufferProtocolVersion,
+		BufferProtocols: SupportedBufferProtocols,
+	}); err != nil {
+		log.Printf("Failed to encode version response: %v", err)
+	}
+}
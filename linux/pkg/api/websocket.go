@@ -1,6 +1,7 @@
 package api
 
 import (
+	"compress/flate"
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
@@ -8,7 +9,12 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
@@ -21,32 +27,162 @@ const (
 	// Magic byte for binary messages
 	BufferMagicByte = 0xbf
 
+	// BufferProtocolVersion is the version of the /buffers message format
+	// used by connections that don't negotiate one
+	BufferProtocolVersion = 1
+
 	// WebSocket timeouts
 	writeWait      = 10 * time.Second
 	pongWait       = 60 * time.Second
 	pingPeriod     = (pongWait * 9) / 10
 	maxMessageSize = 512 * 1024 // 512KB
-)
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		// Allow all origins for now
-		return true
-	},
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-}
+	// permessage-deflate settings. Terminal output compresses well even at
+	// the fastest level; messages below the threshold are sent uncompressed
+	// since deflate overhead outweighs the savings.
+	compressionLevel     = flate.BestSpeed
+	compressionThreshold = 256
+)
 
 type BufferWebSocketHandler struct {
-	manager *session.Manager
+	manager  *session.Manager
+	streams  *StreamRegistry
+	upgrader websocket.Upgrader
+
+	maxBacklog atomic.Int64 // Unsent output kept per subscriber, 0 for no limit
 }
 
-func NewBufferWebSocketHandler(manager *session.Manager) *BufferWebSocketHandler {
-	return &BufferWebSocketHandler{
+// NewBufferWebSocketHandler creates the /buffers handler. checkOrigin decides
+// which browser origins may connect.
+func NewBufferWebSocketHandler(manager *session.Manager, streams *StreamRegistry, checkOrigin func(r *http.Request) bool) *BufferWebSocketHandler {
+	h := &BufferWebSocketHandler{
 		manager: manager,
+		streams: streams,
+		upgrader: websocket.Upgrader{
+			CheckOrigin:       checkOrigin,
+			ReadBufferSize:    1024,
+			WriteBufferSize:   1024,
+			EnableCompression: true,
+		},
+	}
+	h.maxBacklog.Store(DefaultMaxStreamBacklog)
+	return h
+}
+
+// SetMaxBacklog sets how many bytes of output a subscriber may fall behind
+// by before older output is skipped and a truncated message is sent
+func (h *BufferWebSocketHandler) SetMaxBacklog(n int64) {
+	h.maxBacklog.Store(n)
+}
+
+// SupportedBufferProtocols lists the /buffers protocol versions the server
+// can speak, oldest first
+var SupportedBufferProtocols = []int{BufferProtocolVersion}
+
+// bufferProtocol is the protocol version of a single WebSocket connection.
+// Clients may negotiate it with a hello message until they first subscribe;
+// after that frames may be in flight and it can no longer change.
+type bufferProtocol struct {
+	mu      sync.Mutex
+	version int
+	fixed   bool
+}
+
+func newBufferProtocol() *bufferProtocol {
+	return &bufferProtocol{version: BufferProtocolVersion}
+}
+
+// negotiate picks the newest of the offered versions the server supports
+func (p *bufferProtocol) negotiate(offered []int) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.fixed {
+		return 0, fmt.Errorf("hello must be sent before subscribing")
+	}
+
+	chosen := 0
+	for _, version := range offered {
+		if version > chosen && slices.Contains(SupportedBufferProtocols, version) {
+			chosen = version
+		}
+	}
+	if chosen == 0 {
+		return 0, fmt.Errorf("no supported protocol version in %v", offered)
+	}
+	p.version = chosen
+	return chosen, nil
+}
+
+// fix stops the version from changing and returns it
+func (p *bufferProtocol) fix() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.fixed = true
+	return p.version
+}
+
+// subscriptions tracks the sessions a single WebSocket connection is
+// streaming. Each session has its own stop channel so it can be unsubscribed
+// without affecting the others.
+type subscriptions struct {
+	mu       sync.Mutex
+	sessions map[string]chan struct{}
+}
+
+func newSubscriptions() *subscriptions {
+	return &subscriptions{
+		sessions: make(map[string]chan struct{}),
+	}
+}
+
+// add subscribes to a session, returning its stop channel. ok is false if
+// the session is already subscribed.
+func (s *subscriptions) add(sessionID string) (stop chan struct{}, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.sessions[sessionID]; exists {
+		return nil, false
+	}
+	stop = make(chan struct{})
+	s.sessions[sessionID] = stop
+	return stop, true
+}
+
+// remove unsubscribes from a session and signals its stream to stop,
+// returning false if it wasn't subscribed
+func (s *subscriptions) remove(sessionID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stop, ok := s.sessions[sessionID]
+	if ok {
+		delete(s.sessions, sessionID)
+		close(stop)
+	}
+	return ok
+}
+
+// finished drops a session whose stream ended on its own. stop identifies
+// the subscription, so a newer subscription to the same session is kept.
+func (s *subscriptions) finished(sessionID string, stop chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sessions[sessionID] == stop {
+		delete(s.sessions, sessionID)
 	}
 }
 
+// ids returns the subscribed session IDs in sorted order
+func (s *subscriptions) ids() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := make([]string, 0, len(s.sessions))
+	for id := range s.sessions {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
 // safeSend safely sends data to a channel, returning false if the channel is closed
 func safeSend(send chan []byte, data []byte, done chan struct{}) bool {
 	defer func() {
@@ -65,7 +201,15 @@ func safeSend(send chan []byte, data []byte, done chan struct{}) bool {
 }
 
 func (h *BufferWebSocketHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+	// Upgrades need HTTP/1.1. Over TLS, clients that negotiated HTTP/2 for
+	// the page are expected to open a new HTTP/1.1 connection for WebSockets.
+	if r.ProtoMajor != 1 {
+		log.Printf("[WebSocket] Rejecting upgrade over %s", r.Proto)
+		http.Error(w, "WebSocket requires HTTP/1.1", http.StatusHTTPVersionNotSupported)
+		return
+	}
+
+	conn, err := h.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("[WebSocket] Failed to upgrade connection: %v", err)
 		return
@@ -78,6 +222,9 @@ func (h *BufferWebSocketHandler) ServeHTTP(w http.ResponseWriter, r *http.Reques
 
 	// Set up connection parameters
 	conn.SetReadLimit(maxMessageSize)
+	if err := conn.SetCompressionLevel(compressionLevel); err != nil {
+		log.Printf("[WebSocket] Failed to set compression level: %v", err)
+	}
 	if err := conn.SetReadDeadline(time.Now().Add(pongWait)); err != nil {
 		log.Printf("[WebSocket] Failed to set read deadline: %v", err)
 	}
@@ -104,6 +251,19 @@ func (h *BufferWebSocketHandler) ServeHTTP(w http.ResponseWriter, r *http.Reques
 		})
 	}
 
+	// Register the connection so it can be terminated administratively.
+	// Expiring the read deadline unblocks ReadMessage and ends the loop below.
+	streamID := h.streams.Register("", "websocket", clientIP(r), func() {
+		if err := conn.SetReadDeadline(time.Now()); err != nil {
+			log.Printf("[WebSocket] Failed to expire read deadline: %v", err)
+		}
+	})
+	defer h.streams.Unregister(streamID)
+
+	// Sessions this connection is streaming; all of them stop with done
+	subs := newSubscriptions()
+	proto := newBufferProtocol()
+
 	// Start writer goroutine
 	go h.writer(conn, send, ticker, done)
 
@@ -119,12 +279,12 @@ func (h *BufferWebSocketHandler) ServeHTTP(w http.ResponseWriter, r *http.Reques
 		}
 
 		if messageType == websocket.TextMessage {
-			h.handleTextMessage(conn, message, send, done, closeOnceFunc)
+			h.handleTextMessage(streamID, subs, proto, message, send, done)
 		}
 	}
 }
 
-func (h *BufferWebSocketHandler) handleTextMessage(conn *websocket.Conn, message []byte, send chan []byte, done chan struct{}, closeFunc func()) {
+func (h *BufferWebSocketHandler) handleTextMessage(streamID string, subs *subscriptions, proto *bufferProtocol, message []byte, send chan []byte, done chan struct{}) {
 	var msg map[string]interface{}
 	if err := json.Unmarshal(message, &msg); err != nil {
 		log.Printf("[WebSocket] Failed to parse message: %v", err)
@@ -144,22 +304,69 @@ func (h *BufferWebSocketHandler) handleTextMessage(conn *websocket.Conn, message
 			return
 		}
 
+	case "hello":
+		// {"type": "hello", "versions": [1, 2]} announces the protocol
+		// versions the client speaks; the reply carries the chosen one.
+		// Clients that never say hello get BufferProtocolVersion.
+		var offered []int
+		if versions, ok := msg["versions"].([]interface{}); ok {
+			for _, v := range versions {
+				if n, ok := v.(float64); ok {
+					offered = append(offered, int(n))
+				}
+			}
+		}
+
+		reply := map[string]interface{}{"supportedVersions": SupportedBufferProtocols}
+		if version, err := proto.negotiate(offered); err != nil {
+			reply["type"] = "error"
+			reply["message"] = err.Error()
+		} else {
+			reply["type"] = "hello"
+			reply["version"] = version
+		}
+		data, _ := json.Marshal(reply)
+		if !safeSend(send, data, done) {
+			return
+		}
+
 	case "subscribe":
 		sessionID, ok := msg["sessionId"].(string)
 		if !ok {
 			return
 		}
+		proto.fix()
+
+		stop, added := subs.add(sessionID)
+		if !added {
+			return // Already streaming this session
+		}
+		h.streams.SetSession(streamID, strings.Join(subs.ids(), ","))
 
-		// Start streaming session data
-		go h.streamSession(sessionID, send, done)
+		// Start streaming session data; frames carry the session ID so the
+		// client can route them
+		go func() {
+			h.streamSession(sessionID, send, done, stop)
+			subs.finished(sessionID, stop)
+			h.streams.SetSession(streamID, strings.Join(subs.ids(), ","))
+		}()
 
 	case "unsubscribe":
-		// Currently we just close the connection when unsubscribing
-		closeFunc()
+		sessionID, ok := msg["sessionId"].(string)
+		if !ok {
+			return
+		}
+
+		// Stop only this session's stream; the connection stays open
+		if subs.remove(sessionID) {
+			h.streams.SetSession(streamID, strings.Join(subs.ids(), ","))
+		}
 	}
 }
 
-func (h *BufferWebSocketHandler) streamSession(sessionID string, send chan []byte, done chan struct{}) {
+// streamSession forwards a session's output until the connection closes
+// (done) or the session is unsubscribed (stop)
+func (h *BufferWebSocketHandler) streamSession(sessionID string, send chan []byte, done, stop chan struct{}) {
 	sess, err := h.manager.GetSession(sessionID)
 	if err != nil {
 		log.Printf("[WebSocket] Session not found: %v", err)
@@ -208,8 +415,9 @@ func (h *BufferWebSocketHandler) streamSession(sessionID string, send chan []byt
 		}
 	}()
 
-	// Add the stream file to the watcher
-	err = watcher.Add(streamPath)
+	// Watch the session directory rather than the file, so the stream is
+	// still followed once stream-out is rotated to a new file
+	err = watcher.Add(filepath.Dir(streamPath))
 	if err != nil {
 		log.Printf("[WebSocket] Failed to watch file: %v", err)
 		errorMsg, _ := json.Marshal(map[string]string{
@@ -220,11 +428,12 @@ func (h *BufferWebSocketHandler) streamSession(sessionID string, send chan []byt
 		return
 	}
 
+	var segment os.FileInfo
 	headerSent := false
 	seenBytes := int64(0)
 
 	// Send initial content
-	h.processAndSendContent(sessionID, streamPath, &headerSent, &seenBytes, send, done)
+	h.processAndSendContent(sessionID, streamPath, &segment, &headerSent, &seenBytes, send, done)
 
 	// Watch for changes
 	for {
@@ -232,13 +441,16 @@ func (h *BufferWebSocketHandler) streamSession(sessionID string, send chan []byt
 		case <-done:
 			return
 
+		case <-stop:
+			return
+
 		case event, ok := <-watcher.Events:
 			if !ok {
 				return
 			}
 
-			if event.Op&fsnotify.Write == fsnotify.Write {
-				h.processAndSendContent(sessionID, streamPath, &headerSent, &seenBytes, send, done)
+			if isStreamWrite(event, streamPath) {
+				h.processAndSendContent(sessionID, streamPath, &segment, &headerSent, &seenBytes, send, done)
 			}
 
 		case err, ok := <-watcher.Errors:
@@ -259,7 +471,7 @@ func (h *BufferWebSocketHandler) streamSession(sessionID string, send chan []byt
 	}
 }
 
-func (h *BufferWebSocketHandler) processAndSendContent(sessionID, streamPath string, headerSent *bool, seenBytes *int64, send chan []byte, done chan struct{}) {
+func (h *BufferWebSocketHandler) processAndSendContent(sessionID, streamPath string, segment *os.FileInfo, headerSent *bool, seenBytes *int64, send chan []byte, done chan struct{}) {
 	file, err := os.Open(streamPath)
 	if err != nil {
 		log.Printf("[WebSocket] Failed to open stream file %s: %v", streamPath, err)
@@ -279,10 +491,41 @@ func (h *BufferWebSocketHandler) processAndSendContent(sessionID, streamPath str
 	}
 
 	currentSize := fileInfo.Size()
+
+	// A different or shrunken file means stream-out was rotated; follow the
+	// new segment
+	if newSegment(segment, fileInfo) || currentSize < *seenBytes {
+		*seenBytes = 0
+		*headerSent = false
+	}
+
 	if currentSize <= *seenBytes {
 		return
 	}
 
+	// A subscriber that can't keep up skips ahead instead of falling
+	// further behind. The header is always sent first so it has the size.
+	if *headerSent {
+		next, skipped, err := skipBacklog(file, *seenBytes, currentSize, h.maxBacklog.Load())
+		if err != nil {
+			log.Printf("[WebSocket] Failed to skip backlog: %v", err)
+			return
+		}
+		if skipped > 0 {
+			*seenBytes = next
+			truncatedData, _ := json.Marshal(map[string]interface{}{
+				"type":    "truncated",
+				"skipped": skipped,
+			})
+			if !safeSend(send, h.createBinaryMessage(sessionID, truncatedData), done) {
+				return
+			}
+			if currentSize <= *seenBytes {
+				return
+			}
+		}
+	}
+
 	// Seek to last position
 	if _, err := file.Seek(*seenBytes, 0); err != nil {
 		return
@@ -417,6 +660,10 @@ func (h *BufferWebSocketHandler) writer(conn *websocket.Conn, send chan []byte,
 				return
 			}
 
+			// Only compress messages large enough to benefit; this is a no-op
+			// if the client didn't negotiate permessage-deflate
+			conn.EnableWriteCompression(len(message) >= compressionThreshold)
+
 			// Check if it's a text message (JSON) or binary
 			if len(message) > 0 && message[0] == '{' {
 				// Text message
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/vibetunnel/linux/pkg/session"
+)
+
+// countingConn counts the bytes read from the underlying connection, which
+// for a WebSocket are the frames as sent, compressed or not
+type countingConn struct {
+	net.Conn
+	read atomic.Int64
+}
+
+func (c *countingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.read.Add(int64(n))
+	return n, err
+}
+
+func TestWebSocketCompression(t *testing.T) {
+	s, ts := newTestServer(t)
+	const screen = 4000
+	sess, err := s.manager.CreateSession(session.Config{
+		Cmdline: []string{"/bin/sh", "-c", "printf '%4000s' '' | tr ' ' x; exec sleep 30"},
+	})
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	defer func() {
+		if err := sess.Kill(); err != nil {
+			t.Logf("Failed to kill session: %v", err)
+		}
+		sess.Wait()
+	}()
+	waitForRecording(t, sess, strings.Repeat("x", 100))
+
+	var conn *countingConn
+	dialer := &websocket.Dialer{
+		EnableCompression: true,
+		NetDialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			c, err := (&net.Dialer{}).DialContext(ctx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+			conn = &countingConn{Conn: c}
+			return conn, nil
+		},
+	}
+	ws, resp, err := dialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/buffers", nil)
+	if err != nil {
+		t.Fatalf("WebSocket handshake failed: %v", err)
+	}
+	defer func() {
+		if err := ws.Close(); err != nil {
+			t.Logf("Failed to close WebSocket: %v", err)
+		}
+	}()
+	if ext := resp.Header.Get("Sec-WebSocket-Extensions"); !strings.Contains(ext, "permessage-deflate") {
+		t.Fatalf("extensions = %q, want permessage-deflate negotiated", ext)
+	}
+	if err := ws.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The screen of output arrives in one or more large frames
+	if err := ws.WriteJSON(map[string]string{"type": "subscribe", "sessionId": sess.ID}); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	before := conn.read.Load()
+	received, largest, output := 0, 0, ""
+	for strings.Count(output, "x") < screen {
+		messageType, data, err := ws.ReadMessage()
+		if err != nil {
+			t.Fatalf("got %d of %d characters of output: %v", strings.Count(output, "x"), screen, err)
+		}
+		received += len(data)
+		largest = max(largest, len(data))
+		if messageType != websocket.BinaryMessage || data[0] != BufferMagicByte {
+			continue
+		}
+		idLen := int(binary.LittleEndian.Uint32(data[1:5]))
+		var event struct {
+			Type string `json:"type"`
+			Data string `json:"data"`
+		}
+		if err := json.Unmarshal(data[5+idLen:], &event); err != nil {
+			t.Fatalf("invalid frame %q: %v", data, err)
+		}
+		if event.Type == "output" {
+			output += event.Data
+		}
+	}
+	if largest < compressionThreshold {
+		t.Errorf("largest frame is %d bytes, want one over the %d byte threshold", largest, compressionThreshold)
+	}
+	if wire := conn.read.Load() - before; wire >= int64(received)/2 {
+		t.Errorf("%d bytes of messages took %d bytes on the wire, want them compressed", received, wire)
+	}
+
+	// Small messages are sent uncompressed and still arrive
+	for i := 0; i < 3; i++ {
+		if err := ws.WriteJSON(map[string]string{"type": "ping"}); err != nil {
+			t.Fatalf("ping: %v", err)
+		}
+		var pong map[string]string
+		if err := ws.ReadJSON(&pong); err != nil || pong["type"] != "pong" {
+			t.Fatalf("got %v, %v, want a pong", pong, err)
+		}
+	}
+}
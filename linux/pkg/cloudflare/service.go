@@ -0,0 +1,229 @@
+package cloudflare
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// quickTunnelURL matches the hostname cloudflared prints for a quick tunnel
+var quickTunnelURL = regexp.MustCompile(`https://[a-z0-9-]+\.trycloudflare\.com`)
+
+// connectionRegistered is logged by cloudflared for each connection to
+// Cloudflare's edge; the first one means the tunnel is up
+const connectionRegistered = "Registered tunnel connection"
+
+// NewService creates a new Cloudflare tunnel service instance
+func NewService() *Service {
+	return &Service{
+		info: TunnelInfo{
+			Status: StatusDisconnected,
+		},
+	}
+}
+
+// Start launches cloudflared to expose localPort. See StartRequest for the
+// difference between quick tunnels and named tunnels.
+func (s *Service) Start(token, hostname string, localPort int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.info.Status == StatusConnected || s.info.Status == StatusConnecting {
+		return ErrAlreadyRunning
+	}
+
+	path, err := exec.LookPath("cloudflared")
+	if err != nil {
+		return ErrNotInstalled
+	}
+
+	localURL := fmt.Sprintf("http://127.0.0.1:%d", localPort)
+	var cmd *exec.Cmd
+	if token != "" {
+		// The named tunnel's ingress rules, configured in the Cloudflare
+		// dashboard, decide where traffic goes. The token is passed in the
+		// environment to keep it out of the process list.
+		cmd = exec.Command(path, "tunnel", "--no-autoupdate", "run")
+		cmd.Env = append(os.Environ(), "TUNNEL_TOKEN="+token)
+	} else {
+		cmd = exec.Command(path, "tunnel", "--no-autoupdate", "--url", localURL)
+	}
+
+	// cloudflared logs to stderr, including the quick tunnel hostname
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return fmt.Errorf("failed to capture cloudflared output: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start cloudflared: %w", err)
+	}
+
+	s.cmd = cmd
+	s.info = TunnelInfo{
+		Status:   StatusConnecting,
+		LocalURL: localURL,
+		Named:    token != "",
+	}
+	if hostname != "" {
+		s.info.URL = "https://" + strings.TrimPrefix(hostname, "https://")
+	}
+
+	go s.watch(cmd, stderr)
+
+	return nil
+}
+
+// watch follows cloudflared's log until it exits, tracking the tunnel state
+func (s *Service) watch(cmd *exec.Cmd, stderr io.Reader) {
+	lastError := ""
+	scanner := bufio.NewScanner(stderr)
+	for scanner.Scan() {
+		line := scanner.Text()
+		debugLog("[DEBUG] cloudflared: %s", line)
+
+		if strings.Contains(line, " ERR ") {
+			lastError = line
+		}
+
+		s.mu.Lock()
+		if s.cmd == cmd {
+			if url := quickTunnelURL.FindString(line); url != "" && !s.info.Named {
+				s.info.URL = url
+			}
+			if strings.Contains(line, connectionRegistered) && s.info.Status == StatusConnecting {
+				s.info.Status = StatusConnected
+				s.info.ConnectedAt = time.Now()
+				log.Printf("[INFO] Cloudflare tunnel established: %s -> %s", s.info.URL, s.info.LocalURL)
+			}
+		}
+		s.mu.Unlock()
+	}
+
+	err := cmd.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Stop already reset the state when it ended the process
+	if s.cmd != cmd {
+		return
+	}
+	s.cmd = nil
+	s.info.Status = StatusError
+	s.info.Error = fmt.Sprintf("cloudflared exited: %v", err)
+	if lastError != "" {
+		s.info.Error += " (" + lastError + ")"
+	}
+	log.Printf("[ERROR] Cloudflare tunnel failed: %s", s.info.Error)
+}
+
+// Stop terminates the Cloudflare tunnel
+func (s *Service) Stop() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.info.Status == StatusDisconnected {
+		return ErrNotConnected
+	}
+
+	if s.cmd != nil {
+		if err := s.cmd.Process.Signal(syscall.SIGTERM); err != nil {
+			log.Printf("[WARNING] Error stopping cloudflared: %v", err)
+		}
+		s.cmd = nil
+	}
+
+	// Reset status
+	s.info = TunnelInfo{Status: StatusDisconnected}
+
+	log.Printf("[INFO] Cloudflare tunnel stopped")
+	return nil
+}
+
+// GetStatus returns the current tunnel status
+func (s *Service) GetStatus() StatusResponse {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return StatusResponse{
+		TunnelInfo: s.info,
+		IsRunning:  s.info.Status == StatusConnected || s.info.Status == StatusConnecting,
+	}
+}
+
+// IsRunning returns true if the tunnel is active
+func (s *Service) IsRunning() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.info.Status == StatusConnected || s.info.Status == StatusConnecting
+}
+
+// GetURL returns the public tunnel URL
+func (s *Service) GetURL() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.info.URL
+}
+
+// WaitForURL waits up to timeout for the tunnel to connect and returns its
+// public URL, which is empty for named tunnels started without a hostname.
+// The status is polled with backoff like ngrok.Service.WaitForURL.
+func (s *Service) WaitForURL(timeout time.Duration) (string, error) {
+	deadline := time.Now().Add(timeout)
+	delay := 100 * time.Millisecond
+	for {
+		s.mu.RLock()
+		info := s.info
+		s.mu.RUnlock()
+
+		switch info.Status {
+		case StatusConnected:
+			return info.URL, nil
+		case StatusError:
+			err := ErrTunnelFailed
+			err.Details = info.Error
+			return "", err
+		case StatusDisconnected:
+			return "", ErrNotConnected
+		}
+
+		if time.Now().Add(delay).After(deadline) {
+			return "", fmt.Errorf("cloudflare tunnel not connected after %s", timeout)
+		}
+		time.Sleep(delay)
+		delay = min(delay*2, 2*time.Second)
+	}
+}
+
+// SetConfig updates the Cloudflare tunnel configuration
+func (s *Service) SetConfig(config Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.config = config
+}
+
+// GetConfig returns the current configuration
+func (s *Service) GetConfig() Config {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.config
+}
+
+// Cleanup stops cloudflared, which would otherwise outlive the server
+func (s *Service) Cleanup() {
+	if err := s.Stop(); err != nil && err != ErrNotConnected {
+		log.Printf("[WARNING] Error during cloudflare cleanup: %v", err)
+	}
+}
+
+func debugLog(format string, args ...interface{}) {
+	if os.Getenv("VIBETUNNEL_DEBUG") != "" {
+		log.Printf(format, args...)
+	}
+}
@@ -0,0 +1,79 @@
+package cloudflare
+
+import (
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// Status represents the current state of the Cloudflare tunnel
+type Status string
+
+const (
+	StatusDisconnected Status = "disconnected"
+	StatusConnecting   Status = "connecting"
+	StatusConnected    Status = "connected"
+	StatusError        Status = "error"
+)
+
+// TunnelInfo contains information about the active tunnel
+type TunnelInfo struct {
+	URL         string    `json:"url"`
+	Status      Status    `json:"status"`
+	ConnectedAt time.Time `json:"connected_at,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	LocalURL    string    `json:"local_url"`
+	Named       bool      `json:"named"` // Named tunnel run with a token, rather than a quick tunnel
+}
+
+// Config holds Cloudflare tunnel configuration
+type Config struct {
+	Token    string `json:"token"`
+	Hostname string `json:"hostname"`
+	Enabled  bool   `json:"enabled"`
+}
+
+// Service manages the cloudflared subprocess lifecycle
+type Service struct {
+	mu     sync.RWMutex
+	cmd    *exec.Cmd
+	info   TunnelInfo
+	config Config
+}
+
+// StartRequest represents the request to start a Cloudflare tunnel. Without
+// a token a quick tunnel on a random *.trycloudflare.com hostname is
+// created. With a token the named tunnel is run; its public hostname is
+// configured in the Cloudflare dashboard and only known here if given.
+type StartRequest struct {
+	Token    string `json:"token,omitempty"`
+	Hostname string `json:"hostname,omitempty"`
+}
+
+// StatusResponse represents the response for tunnel status
+type StatusResponse struct {
+	TunnelInfo
+	IsRunning bool `json:"is_running"`
+}
+
+// CloudflareError represents Cloudflare tunnel specific errors
+type CloudflareError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Details string `json:"details,omitempty"`
+}
+
+func (e CloudflareError) Error() string {
+	if e.Details != "" {
+		return e.Message + ": " + e.Details
+	}
+	return e.Message
+}
+
+// Common Cloudflare tunnel errors
+var (
+	ErrNotConnected   = CloudflareError{Code: "not_connected", Message: "Cloudflare tunnel is not connected"}
+	ErrAlreadyRunning = CloudflareError{Code: "already_running", Message: "Cloudflare tunnel is already running"}
+	ErrNotInstalled   = CloudflareError{Code: "not_installed", Message: "cloudflared not found in PATH"}
+	ErrTunnelFailed   = CloudflareError{Code: "tunnel_failed", Message: "Failed to establish tunnel"}
+)
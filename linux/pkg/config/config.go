@@ -4,20 +4,26 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/spf13/pflag"
+	"github.com/vibetunnel/linux/pkg/session"
 	"gopkg.in/yaml.v3"
 )
 
 // Config represents the VibeTunnel configuration
 // Mirrors the structure of VibeTunnel's settings system
 type Config struct {
-	ControlPath string   `yaml:"control_path"`
-	Server      Server   `yaml:"server"`
-	Security    Security `yaml:"security"`
-	Ngrok       Ngrok    `yaml:"ngrok"`
-	Advanced    Advanced `yaml:"advanced"`
-	Update      Update   `yaml:"update"`
+	ControlPath string     `yaml:"control_path"`
+	Server      Server     `yaml:"server"`
+	Security    Security   `yaml:"security"`
+	Ngrok       Ngrok      `yaml:"ngrok"`
+	Cloudflare  Cloudflare `yaml:"cloudflare"`
+	Advanced    Advanced   `yaml:"advanced"`
+	Update      Update     `yaml:"update"`
+	Session     Session    `yaml:"session"`
+	Webhook     Webhook    `yaml:"webhook"`
+	Cleanup     Cleanup    `yaml:"cleanup"`
 }
 
 // Server configuration (mirrors DashboardSettingsView.swift)
@@ -26,6 +32,39 @@ type Server struct {
 	AccessMode string `yaml:"access_mode"` // "localhost" or "network"
 	StaticPath string `yaml:"static_path"`
 	Mode       string `yaml:"mode"` // "native" or "rust"
+	// MaxConnections bounds concurrent non-streaming connections; extra
+	// connections get 503. 0 disables the limit.
+	MaxConnections int  `yaml:"max_connections"`
+	CORS           CORS `yaml:"cors"`
+	// MaxStreamBacklogMB is how far a streaming client may fall behind a
+	// session's output before older output is skipped. 0 disables the limit.
+	MaxStreamBacklogMB int `yaml:"max_stream_backlog_mb"`
+	// MaxReplayKB caps the output replayed to stream clients on connect,
+	// which starts at the last clear screen. 0 removes the cap.
+	MaxReplayKB int `yaml:"max_replay_kb"`
+	// MaxInputKB caps the input a client may send to a session in one
+	// request; larger requests get 413. 0 removes the cap.
+	MaxInputKB int `yaml:"max_input_kb"`
+	// SSEKeepAliveSeconds is how long a session stream may be silent before
+	// a keep-alive comment is sent. 0 disables keep-alives.
+	SSEKeepAliveSeconds int `yaml:"sse_keepalive_seconds"`
+	// Terminal size and TERM of sessions that don't request their own.
+	// Zero or empty keeps the built-in defaults of 120x30 and the host TERM.
+	DefaultCols int    `yaml:"default_cols"`
+	DefaultRows int    `yaml:"default_rows"`
+	DefaultTerm string `yaml:"default_term"`
+	// TrustedProxies lists the IP addresses and CIDR ranges, or "unix" for
+	// Unix socket peers, whose X-Forwarded-For and X-Real-IP headers are
+	// used for the client IP. Empty trusts no forwarding headers.
+	TrustedProxies []string `yaml:"trusted_proxies"`
+}
+
+// CORS configures which other origins may access the server
+type CORS struct {
+	// AllowedOrigins lists full origins (e.g. "https://example.com") allowed
+	// to call the API and open WebSocket connections besides the server's
+	// own origin. Empty means same-origin only.
+	AllowedOrigins []string `yaml:"allowed_origins"`
 }
 
 // Security configuration (mirrors dashboard password settings)
@@ -39,6 +78,16 @@ type Ngrok struct {
 	Enabled     bool   `yaml:"enabled"`
 	AuthToken   string `yaml:"auth_token"`
 	TokenStored bool   `yaml:"token_stored"`
+	Region      string `yaml:"region"` // e.g. "eu"; empty lets ngrok pick
+	Domain      string `yaml:"domain"` // Domain reserved on the ngrok account
+}
+
+// Cloudflare Tunnel configuration. Without a token a quick tunnel on a
+// random *.trycloudflare.com hostname is used.
+type Cloudflare struct {
+	Enabled  bool   `yaml:"enabled"`
+	Token    string `yaml:"token"`    // Named tunnel token from the Cloudflare dashboard
+	Hostname string `yaml:"hostname"` // Public hostname of the named tunnel, for display
 }
 
 // Advanced configuration (mirrors AdvancedSettingsView.swift)
@@ -55,15 +104,71 @@ type Update struct {
 	ShowNotifications bool   `yaml:"show_notifications"`
 }
 
+// Session configuration for newly created terminal sessions
+type Session struct {
+	// EnvAllowlist selects which host environment variables sessions inherit.
+	// Entries are shell globs (e.g. "LC_*").
+	EnvAllowlist []string `yaml:"env_allowlist"`
+	// EnvBlocklist lists variables withheld from sessions created with
+	// inheritEnv, which otherwise receive the full server environment
+	EnvBlocklist []string `yaml:"env_blocklist"`
+	// EnvRedact lists variable name patterns (e.g. "*_TOKEN") whose values
+	// are hidden in recordings and API responses
+	EnvRedact []string `yaml:"env_redact"`
+	// MaxRecordingMB caps each session's stream-out file. When reached the
+	// recording is rotated and only the previous segment is kept, so very
+	// long sessions lose their oldest history. 0, the default, keeps the
+	// full recording.
+	MaxRecordingMB int `yaml:"max_recording_mb"`
+
+	// OutputCoalesceMS merges session output arriving within this many
+	// milliseconds into one recorded event. 0 records every read.
+	OutputCoalesceMS int `yaml:"output_coalesce_ms"`
+
+	// RecordInput adds what is typed into sessions to their recordings.
+	// Input typed while echo is off, like passwords, is left out.
+	RecordInput bool `yaml:"record_input"`
+
+	// MaxRuntimeSeconds kills sessions that have run this long, whether or
+	// not they are active. Clients may ask for less. 0 means no limit.
+	MaxRuntimeSeconds int `yaml:"max_runtime_seconds"`
+}
+
+// Webhook configuration for session lifecycle notifications. Each session
+// start and exit is POSTed as JSON to URL; disabled while URL is empty.
+type Webhook struct {
+	URL string `yaml:"url"`
+	// Secret signs each payload with HMAC-SHA256, sent in the
+	// X-VibeTunnel-Signature header as "sha256=<hex>"
+	Secret string `yaml:"secret"`
+}
+
+// Cleanup configures removal of exited sessions while the server runs.
+// Disabled while IntervalSeconds is 0, leaving exited sessions until a
+// manual --cleanup-exited.
+type Cleanup struct {
+	// IntervalSeconds is how often exited sessions are looked for
+	IntervalSeconds int `yaml:"interval_seconds"`
+	// MaxAgeSeconds is how long a session is kept after it exits
+	MaxAgeSeconds int `yaml:"max_age_seconds"`
+}
+
 // DefaultConfig returns a configuration with VibeTunnel-compatible defaults
 func DefaultConfig() *Config {
 	homeDir, _ := os.UserHomeDir()
 	return &Config{
 		ControlPath: filepath.Join(homeDir, ".vibetunnel", "control"),
 		Server: Server{
-			Port:       "4020", // Matches VibeTunnel default
-			AccessMode: "localhost",
-			Mode:       "native",
+			Port:                "4020", // Matches VibeTunnel default
+			AccessMode:          "localhost",
+			Mode:                "native",
+			MaxConnections:      256,
+			MaxStreamBacklogMB:  16,
+			MaxReplayKB:         1024,
+			MaxInputKB:          1024,
+			SSEKeepAliveSeconds: 15,
+			DefaultCols:         120,
+			DefaultRows:         30,
 		},
 		Security: Security{
 			PasswordEnabled: false,
@@ -81,11 +186,33 @@ func DefaultConfig() *Config {
 			AutoCheck:         true,
 			ShowNotifications: true,
 		},
+		Session: Session{
+			// Copies, so changes to the config don't alter the session defaults
+			EnvAllowlist:     append([]string(nil), session.DefaultEnvAllowlist...),
+			EnvBlocklist:     append([]string(nil), session.DefaultEnvBlocklist...),
+			EnvRedact:        append([]string(nil), session.DefaultEnvRedact...),
+			OutputCoalesceMS: 5,
+		},
+		Cleanup: Cleanup{
+			IntervalSeconds: 0,
+			MaxAgeSeconds:   24 * 60 * 60,
+		},
 	}
 }
 
-// LoadConfig loads configuration from file, creates default if not exists
+// LoadConfig loads configuration from file, creates default if not exists.
+// VIBETUNNEL_* environment variables override the file (see ApplyEnv).
 func LoadConfig(filename string) *Config {
+	cfg := loadConfigFile(filename)
+	if err := cfg.ApplyEnv(); err != nil {
+		fmt.Printf("Warning: ignoring invalid environment overrides: %v\n", err)
+	}
+	return cfg
+}
+
+// loadConfigFile loads the configuration file without environment overrides,
+// which must not end up in the default config saved for a missing file
+func loadConfigFile(filename string) *Config {
 	cfg := DefaultConfig()
 
 	if filename == "" {
@@ -120,6 +247,25 @@ func LoadConfig(filename string) *Config {
 	return cfg
 }
 
+// ReadConfig reads the configuration file over the defaults and applies the
+// environment overrides, like LoadConfig. Unlike LoadConfig it fails when the
+// file is missing or broken rather than falling back to the defaults, so a
+// running server can keep its current settings.
+func ReadConfig(filename string) (*Config, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	cfg := DefaultConfig()
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	if err := cfg.ApplyEnv(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
 // Save saves the configuration to file
 func (c *Config) Save(filename string) error {
 	data, err := yaml.Marshal(c)
@@ -177,6 +323,36 @@ func (c *Config) MergeFlags(flags *pflag.FlagSet) {
 		}
 	}
 
+	if flags.Changed("ngrok-region") {
+		if val, err := flags.GetString("ngrok-region"); err == nil {
+			c.Ngrok.Region = val
+		}
+	}
+
+	if flags.Changed("ngrok-domain") {
+		if val, err := flags.GetString("ngrok-domain"); err == nil {
+			c.Ngrok.Domain = val
+		}
+	}
+
+	if flags.Changed("cloudflare") {
+		if val, err := flags.GetBool("cloudflare"); err == nil {
+			c.Cloudflare.Enabled = val
+		}
+	}
+
+	if flags.Changed("cloudflare-token") {
+		if val, err := flags.GetString("cloudflare-token"); err == nil && val != "" {
+			c.Cloudflare.Token = val
+		}
+	}
+
+	if flags.Changed("cloudflare-hostname") {
+		if val, err := flags.GetString("cloudflare-hostname"); err == nil {
+			c.Cloudflare.Hostname = val
+		}
+	}
+
 	if flags.Changed("debug") {
 		if val, err := flags.GetBool("debug"); err == nil {
 			c.Advanced.DebugMode = val
@@ -189,6 +365,66 @@ func (c *Config) MergeFlags(flags *pflag.FlagSet) {
 		}
 	}
 
+	if flags.Changed("max-connections") {
+		if val, err := flags.GetInt("max-connections"); err == nil {
+			c.Server.MaxConnections = val
+		}
+	}
+
+	if flags.Changed("max-replay") {
+		if val, err := flags.GetInt("max-replay"); err == nil {
+			c.Server.MaxReplayKB = val
+		}
+	}
+
+	if flags.Changed("max-input") {
+		if val, err := flags.GetInt("max-input"); err == nil {
+			c.Server.MaxInputKB = val
+		}
+	}
+
+	if flags.Changed("sse-keepalive") {
+		if val, err := flags.GetInt("sse-keepalive"); err == nil {
+			c.Server.SSEKeepAliveSeconds = val
+		}
+	}
+
+	if flags.Changed("max-stream-backlog") {
+		if val, err := flags.GetInt("max-stream-backlog"); err == nil {
+			c.Server.MaxStreamBacklogMB = val
+		}
+	}
+
+	if flags.Changed("max-runtime") {
+		if val, err := flags.GetInt("max-runtime"); err == nil {
+			c.Session.MaxRuntimeSeconds = val
+		}
+	}
+
+	if flags.Changed("record-input") {
+		if val, err := flags.GetBool("record-input"); err == nil {
+			c.Session.RecordInput = val
+		}
+	}
+
+	if flags.Changed("default-cols") {
+		if val, err := flags.GetInt("default-cols"); err == nil {
+			c.Server.DefaultCols = val
+		}
+	}
+
+	if flags.Changed("default-rows") {
+		if val, err := flags.GetInt("default-rows"); err == nil {
+			c.Server.DefaultRows = val
+		}
+	}
+
+	if flags.Changed("default-term") {
+		if val, err := flags.GetString("default-term"); err == nil {
+			c.Server.DefaultTerm = val
+		}
+	}
+
 	if flags.Changed("server-mode") {
 		if val, err := flags.GetString("server-mode"); err == nil {
 			c.Server.Mode = val
@@ -223,6 +459,15 @@ func (c *Config) Print() {
 	fmt.Printf("  Access Mode: %s\n", c.Server.AccessMode)
 	fmt.Printf("  Static Path: %s\n", c.Server.StaticPath)
 	fmt.Printf("  Mode: %s\n", c.Server.Mode)
+	fmt.Printf("  Max Connections: %d\n", c.Server.MaxConnections)
+	fmt.Printf("  Max Stream Backlog: %d MB\n", c.Server.MaxStreamBacklogMB)
+	fmt.Printf("  Max Replay: %d KB\n", c.Server.MaxReplayKB)
+	fmt.Printf("  Max Input: %d KB\n", c.Server.MaxInputKB)
+	fmt.Printf("  SSE Keep-Alive: %ds\n", c.Server.SSEKeepAliveSeconds)
+	fmt.Printf("  Default Size: %dx%d\n", c.Server.DefaultCols, c.Server.DefaultRows)
+	fmt.Printf("  Default TERM: %s\n", c.Server.DefaultTerm)
+	fmt.Printf("  Allowed Origins: %s\n", strings.Join(c.Server.CORS.AllowedOrigins, ", "))
+	fmt.Printf("  Trusted Proxies: %s\n", strings.Join(c.Server.TrustedProxies, ", "))
 	fmt.Println("\nSecurity:")
 	fmt.Printf("  Password Enabled: %t\n", c.Security.PasswordEnabled)
 	if c.Security.PasswordEnabled {
@@ -231,6 +476,19 @@ func (c *Config) Print() {
 	fmt.Println("\nNgrok:")
 	fmt.Printf("  Enabled: %t\n", c.Ngrok.Enabled)
 	fmt.Printf("  Token Stored: %t\n", c.Ngrok.TokenStored)
+	if c.Ngrok.Region != "" {
+		fmt.Printf("  Region: %s\n", c.Ngrok.Region)
+	}
+	if c.Ngrok.Domain != "" {
+		fmt.Printf("  Domain: %s\n", c.Ngrok.Domain)
+	}
+
+	fmt.Println("\nCloudflare:")
+	fmt.Printf("  Enabled: %t\n", c.Cloudflare.Enabled)
+	fmt.Printf("  Named Tunnel: %t\n", c.Cloudflare.Token != "")
+	if c.Cloudflare.Hostname != "" {
+		fmt.Printf("  Hostname: %s\n", c.Cloudflare.Hostname)
+	}
 	fmt.Println("\nAdvanced:")
 	fmt.Printf("  Debug Mode: %t\n", c.Advanced.DebugMode)
 	fmt.Printf("  Cleanup on Startup: %t\n", c.Advanced.CleanupStartup)
@@ -239,4 +497,28 @@ func (c *Config) Print() {
 	fmt.Printf("  Channel: %s\n", c.Update.Channel)
 	fmt.Printf("  Auto Check: %t\n", c.Update.AutoCheck)
 	fmt.Printf("  Show Notifications: %t\n", c.Update.ShowNotifications)
+	fmt.Println("\nSession:")
+	fmt.Printf("  Env Allowlist: %s\n", strings.Join(c.Session.EnvAllowlist, ", "))
+	fmt.Printf("  Env Blocklist: %s\n", strings.Join(c.Session.EnvBlocklist, ", "))
+	fmt.Printf("  Env Redact: %s\n", strings.Join(c.Session.EnvRedact, ", "))
+	fmt.Printf("  Max Recording Size: %d MB\n", c.Session.MaxRecordingMB)
+	fmt.Printf("  Output Coalesce Window: %d ms\n", c.Session.OutputCoalesceMS)
+	fmt.Printf("  Record Input: %t\n", c.Session.RecordInput)
+	if c.Session.MaxRuntimeSeconds > 0 {
+		fmt.Printf("  Max Runtime: %d seconds\n", c.Session.MaxRuntimeSeconds)
+	} else {
+		fmt.Printf("  Max Runtime: unlimited\n")
+	}
+	fmt.Println("\nWebhook:")
+	fmt.Printf("  Enabled: %t\n", c.Webhook.URL != "")
+	if c.Webhook.URL != "" {
+		fmt.Printf("  URL: %s\n", c.Webhook.URL)
+		fmt.Printf("  Signed: %t\n", c.Webhook.Secret != "")
+	}
+	fmt.Println("\nCleanup:")
+	fmt.Printf("  Enabled: %t\n", c.Cleanup.IntervalSeconds > 0)
+	if c.Cleanup.IntervalSeconds > 0 {
+		fmt.Printf("  Interval: %d seconds\n", c.Cleanup.IntervalSeconds)
+		fmt.Printf("  Max Age: %d seconds\n", c.Cleanup.MaxAgeSeconds)
+	}
 }
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// EnvPrefix starts the environment variables that override settings
+const EnvPrefix = "VIBETUNNEL_"
+
+// EnvName returns the environment variable overriding the config field at
+// a dotted YAML path, e.g. VIBETUNNEL_SERVER_PORT for server.port, or the
+// flag of that name, e.g. VIBETUNNEL_TLS_CERT for --tls-cert
+func EnvName(path string) string {
+	return EnvPrefix + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(path))
+}
+
+// ApplyEnv overrides config fields with the VIBETUNNEL_* variables named by
+// EnvName. Lists are comma-separated. Values that can't be parsed are
+// reported in the returned error and leave their field unchanged.
+func (c *Config) ApplyEnv() error {
+	var errs []error
+	applyEnv(reflect.ValueOf(c).Elem(), "", &errs)
+
+	// As with --password, setting a password turns password protection on
+	if value, ok := os.LookupEnv(EnvName("security.password")); ok && value != "" {
+		if _, ok := os.LookupEnv(EnvName("security.password_enabled")); !ok {
+			c.Security.PasswordEnabled = true
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// applyEnv sets the fields of struct v from the environment, recursing into
+// nested sections
+func applyEnv(v reflect.Value, prefix string, errs *[]error) {
+	for i := 0; i < v.NumField(); i++ {
+		name, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("yaml"), ",")
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		field := v.Field(i)
+		if field.Kind() == reflect.Struct {
+			applyEnv(field, name, errs)
+			continue
+		}
+
+		envName := EnvName(name)
+		value, ok := os.LookupEnv(envName)
+		if !ok {
+			continue
+		}
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(value)
+		case reflect.Bool:
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				*errs = append(*errs, fmt.Errorf("%s: %q is not true or false", envName, value))
+				continue
+			}
+			field.SetBool(b)
+		case reflect.Int:
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				*errs = append(*errs, fmt.Errorf("%s: %q is not a number", envName, value))
+				continue
+			}
+			field.SetInt(int64(n))
+		case reflect.Slice:
+			field.Set(reflect.ValueOf(splitList(value)))
+		}
+	}
+}
+
+// ApplyEnvToFlags sets the named flags that weren't given on the command
+// line from their VIBETUNNEL_* variables, for settings that only exist as
+// flags. Repeatable flags take a comma-separated list.
+func ApplyEnvToFlags(flags *pflag.FlagSet, names ...string) error {
+	var errs []error
+	for _, name := range names {
+		value, ok := os.LookupEnv(EnvName(name))
+		if !ok || flags.Changed(name) {
+			continue
+		}
+		flag := flags.Lookup(name)
+		if flag == nil {
+			continue
+		}
+
+		values := []string{value}
+		if strings.HasSuffix(flag.Value.Type(), "Array") || strings.HasSuffix(flag.Value.Type(), "Slice") {
+			values = splitList(value)
+		}
+		for _, v := range values {
+			if err := flags.Set(name, v); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", EnvName(name), err))
+				break
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// splitList splits a comma-separated list, dropping empty entries
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,154 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+// writeConfigFile writes content to a config file in a temporary directory
+// and returns its path
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestEnvName(t *testing.T) {
+	tests := map[string]string{
+		"server.port":                 "VIBETUNNEL_SERVER_PORT",
+		"server.cors.allowed_origins": "VIBETUNNEL_SERVER_CORS_ALLOWED_ORIGINS",
+		"tls-cert":                    "VIBETUNNEL_TLS_CERT",
+	}
+	for path, want := range tests {
+		if got := EnvName(path); got != want {
+			t.Errorf("EnvName(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestEnvOverridesFile(t *testing.T) {
+	path := writeConfigFile(t, `server:
+  port: "4020"
+  max_connections: 10
+ngrok:
+  region: eu
+`)
+	t.Setenv("VIBETUNNEL_SERVER_PORT", "8080")
+	t.Setenv("VIBETUNNEL_SERVER_MAX_CONNECTIONS", "50")
+	t.Setenv("VIBETUNNEL_SERVER_CORS_ALLOWED_ORIGINS", "https://a.example, https://b.example,")
+	t.Setenv("VIBETUNNEL_NGROK_ENABLED", "true")
+	t.Setenv("VIBETUNNEL_NGROK_AUTH_TOKEN", "token")
+
+	cfg, err := ReadConfig(path)
+	if err != nil {
+		t.Fatalf("ReadConfig: %v", err)
+	}
+	if cfg.Server.Port != "8080" {
+		t.Errorf("port = %q, want 8080", cfg.Server.Port)
+	}
+	if cfg.Server.MaxConnections != 50 {
+		t.Errorf("max_connections = %d, want 50", cfg.Server.MaxConnections)
+	}
+	if want := []string{"https://a.example", "https://b.example"}; !reflect.DeepEqual(cfg.Server.CORS.AllowedOrigins, want) {
+		t.Errorf("allowed_origins = %q, want %q", cfg.Server.CORS.AllowedOrigins, want)
+	}
+	if !cfg.Ngrok.Enabled || cfg.Ngrok.AuthToken != "token" {
+		t.Errorf("ngrok = %+v, want enabled with the token", cfg.Ngrok)
+	}
+	// Fields without a variable keep the file's value
+	if cfg.Ngrok.Region != "eu" {
+		t.Errorf("region = %q, want eu from the file", cfg.Ngrok.Region)
+	}
+}
+
+func TestEnvPasswordEnablesPassword(t *testing.T) {
+	path := writeConfigFile(t, "security:\n  password_enabled: false\n")
+
+	t.Run("password alone", func(t *testing.T) {
+		t.Setenv("VIBETUNNEL_SECURITY_PASSWORD", "secret")
+		cfg, err := ReadConfig(path)
+		if err != nil {
+			t.Fatalf("ReadConfig: %v", err)
+		}
+		if !cfg.Security.PasswordEnabled || cfg.Security.Password != "secret" {
+			t.Errorf("security = %+v, want the password enabled", cfg.Security)
+		}
+	})
+
+	t.Run("explicitly disabled", func(t *testing.T) {
+		t.Setenv("VIBETUNNEL_SECURITY_PASSWORD", "secret")
+		t.Setenv("VIBETUNNEL_SECURITY_PASSWORD_ENABLED", "false")
+		cfg, err := ReadConfig(path)
+		if err != nil {
+			t.Fatalf("ReadConfig: %v", err)
+		}
+		if cfg.Security.PasswordEnabled {
+			t.Error("password enabled despite VIBETUNNEL_SECURITY_PASSWORD_ENABLED=false")
+		}
+	})
+}
+
+func TestInvalidEnvOverride(t *testing.T) {
+	path := writeConfigFile(t, "server:\n  max_connections: 10\n")
+	t.Setenv("VIBETUNNEL_SERVER_MAX_CONNECTIONS", "many")
+
+	if _, err := ReadConfig(path); err == nil || !strings.Contains(err.Error(), "VIBETUNNEL_SERVER_MAX_CONNECTIONS") {
+		t.Errorf("ReadConfig error = %v, want one naming the variable", err)
+	}
+	// LoadConfig warns and keeps the file's value
+	if cfg := LoadConfig(path); cfg.Server.MaxConnections != 10 {
+		t.Errorf("max_connections = %d, want 10 from the file", cfg.Server.MaxConnections)
+	}
+}
+
+func TestFlagsOverrideEnv(t *testing.T) {
+	path := writeConfigFile(t, "server:\n  port: \"4020\"\n")
+	t.Setenv("VIBETUNNEL_SERVER_PORT", "8080")
+
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.String("port", "", "")
+	if err := flags.Parse([]string{"--port", "9090"}); err != nil {
+		t.Fatal(err)
+	}
+	cfg := LoadConfig(path)
+	cfg.MergeFlags(flags)
+	if cfg.Server.Port != "9090" {
+		t.Errorf("port = %q, want 9090 from the flag", cfg.Server.Port)
+	}
+}
+
+func TestApplyEnvToFlags(t *testing.T) {
+	t.Setenv("VIBETUNNEL_BIND", "10.0.0.1")
+	t.Setenv("VIBETUNNEL_TLS_SAN", "a.example,b.example")
+	t.Setenv("VIBETUNNEL_NO_SPAWN", "true")
+
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	bind := flags.String("bind", "", "")
+	san := flags.StringArray("tls-san", nil, "")
+	noSpawn := flags.Bool("no-spawn", false, "")
+	if err := flags.Parse([]string{"--no-spawn=false"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ApplyEnvToFlags(flags, "bind", "tls-san", "no-spawn"); err != nil {
+		t.Fatalf("ApplyEnvToFlags: %v", err)
+	}
+	if *bind != "10.0.0.1" {
+		t.Errorf("bind = %q, want 10.0.0.1", *bind)
+	}
+	if want := []string{"a.example", "b.example"}; !reflect.DeepEqual(*san, want) {
+		t.Errorf("tls-san = %q, want %q", *san, want)
+	}
+	// Given on the command line, so the environment doesn't apply
+	if *noSpawn {
+		t.Error("no-spawn set from the environment despite the flag")
+	}
+}
@@ -0,0 +1,110 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Allowed values of the enumerated settings
+var (
+	accessModes    = []string{"localhost", "network"}
+	serverModes    = []string{"native", "rust"}
+	updateChannels = []string{"stable", "prerelease"}
+)
+
+// Validate normalizes the configuration and checks it, returning every
+// problem found joined into one error, or nil. Enumerated values are
+// trimmed and lowercased first, so "Network " is accepted as "network".
+func (c *Config) Validate() error {
+	c.normalize()
+
+	var errs []error
+	fail := func(field, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", field, fmt.Sprintf(format, args...)))
+	}
+
+	if port, err := strconv.Atoi(c.Server.Port); err != nil || port < 1 || port > 65535 {
+		fail("server.port", "%q is not a port number (1-65535)", c.Server.Port)
+	}
+	if !slices.Contains(accessModes, c.Server.AccessMode) {
+		fail("server.access_mode", "%q is not one of %s", c.Server.AccessMode, strings.Join(accessModes, ", "))
+	}
+	if !slices.Contains(serverModes, c.Server.Mode) {
+		fail("server.mode", "%q is not one of %s", c.Server.Mode, strings.Join(serverModes, ", "))
+	}
+	if !slices.Contains(updateChannels, c.Update.Channel) {
+		fail("update.channel", "%q is not one of %s", c.Update.Channel, strings.Join(updateChannels, ", "))
+	}
+
+	if c.ControlPath == "" {
+		fail("control_path", "must not be empty")
+	} else if info, err := os.Stat(c.ControlPath); err == nil && !info.IsDir() {
+		fail("control_path", "%s is not a directory", c.ControlPath)
+	}
+	if c.Server.StaticPath != "" {
+		if info, err := os.Stat(c.Server.StaticPath); err != nil {
+			fail("server.static_path", "%v", err)
+		} else if !info.IsDir() {
+			fail("server.static_path", "%s is not a directory", c.Server.StaticPath)
+		}
+	}
+
+	for field, value := range map[string]int{
+		"server.max_connections":       c.Server.MaxConnections,
+		"server.max_stream_backlog_mb": c.Server.MaxStreamBacklogMB,
+		"server.max_replay_kb":         c.Server.MaxReplayKB,
+		"server.max_input_kb":          c.Server.MaxInputKB,
+		"server.sse_keepalive_seconds": c.Server.SSEKeepAliveSeconds,
+		"server.default_cols":          c.Server.DefaultCols,
+		"server.default_rows":          c.Server.DefaultRows,
+		"session.max_recording_mb":     c.Session.MaxRecordingMB,
+		"session.output_coalesce_ms":   c.Session.OutputCoalesceMS,
+		"session.max_runtime_seconds":  c.Session.MaxRuntimeSeconds,
+		"cleanup.interval_seconds":     c.Cleanup.IntervalSeconds,
+		"cleanup.max_age_seconds":      c.Cleanup.MaxAgeSeconds,
+	} {
+		if value < 0 {
+			fail(field, "%d must not be negative", value)
+		}
+	}
+
+	for _, origin := range c.Server.CORS.AllowedOrigins {
+		if u, err := url.Parse(origin); err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			fail("server.cors.allowed_origins", "%q is not an origin like https://example.com", origin)
+		}
+	}
+	for _, proxy := range c.Server.TrustedProxies {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "unix" || net.ParseIP(proxy) != nil {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(proxy); err != nil {
+			fail("server.trusted_proxies", "%q is not an IP address, CIDR range or \"unix\"", proxy)
+		}
+	}
+	if c.Webhook.URL != "" {
+		if u, err := url.Parse(c.Webhook.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			fail("webhook.url", "%q is not an http or https URL", c.Webhook.URL)
+		}
+	}
+
+	// Map iteration order is random; keep the report stable
+	slices.SortFunc(errs, func(a, b error) int {
+		return strings.Compare(a.Error(), b.Error())
+	})
+	return errors.Join(errs...)
+}
+
+// normalize trims and lowercases the enumerated settings
+func (c *Config) normalize() {
+	for _, value := range []*string{&c.Server.AccessMode, &c.Server.Mode, &c.Update.Channel} {
+		*value = strings.ToLower(strings.TrimSpace(*value))
+	}
+	c.Server.Port = strings.TrimSpace(c.Server.Port)
+}
@@ -2,9 +2,12 @@ package ngrok
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/url"
+	"slices"
+	"strings"
 	"time"
 
 	"golang.ngrok.com/ngrok"
@@ -23,8 +26,33 @@ func NewService() *Service {
 	}
 }
 
+// Validate checks the region code and that at most one of Domain and
+// Subdomain is set
+func (o Options) Validate() error {
+	if o.Region != "" && !slices.Contains(Regions, o.Region) {
+		err := ErrInvalidOptions
+		err.Details = fmt.Sprintf("unknown region %q, expected one of %s", o.Region, strings.Join(Regions, ", "))
+		return err
+	}
+	if o.Domain != "" && o.Subdomain != "" {
+		err := ErrInvalidOptions
+		err.Details = "domain and subdomain can't be combined"
+		return err
+	}
+	if o.Subdomain != "" && strings.Contains(o.Subdomain, ".") {
+		err := ErrInvalidOptions
+		err.Details = fmt.Sprintf("subdomain %q must be a single label; use domain for a full hostname", o.Subdomain)
+		return err
+	}
+	return nil
+}
+
 // Start initiates a new ngrok tunnel
-func (s *Service) Start(authToken string, localPort int) error {
+func (s *Service) Start(authToken string, localPort int, opts Options) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -38,7 +66,7 @@ func (s *Service) Start(authToken string, localPort int) error {
 
 	// Start tunnel in a goroutine
 	go func() {
-		if err := s.startTunnel(authToken, localPort); err != nil {
+		if err := s.startTunnel(authToken, localPort, opts); err != nil {
 			s.mu.Lock()
 			s.info.Status = StatusError
 			s.info.Error = err.Error()
@@ -51,16 +79,31 @@ func (s *Service) Start(authToken string, localPort int) error {
 }
 
 // startTunnel creates and maintains the ngrok tunnel
-func (s *Service) startTunnel(authToken string, localPort int) error {
+func (s *Service) startTunnel(authToken string, localPort int, opts Options) error {
 	// Create local URL for forwarding
 	localURL, err := url.Parse(fmt.Sprintf("http://127.0.0.1:%d", localPort))
 	if err != nil {
 		return fmt.Errorf("invalid local port: %w", err)
 	}
 
+	var endpointOpts []config.HTTPEndpointOption
+	if opts.Domain != "" {
+		endpointOpts = append(endpointOpts, config.WithDomain(opts.Domain))
+	}
+	if opts.Subdomain != "" {
+		endpointOpts = append(endpointOpts, config.WithSubdomain(opts.Subdomain))
+	}
+
 	// Create forwarder that automatically handles the tunnel and forwarding
-	forwarder, err := ngrok.ListenAndForward(s.ctx, localURL, config.HTTPEndpoint(), ngrok.WithAuthtoken(authToken))
+	forwarder, err := ngrok.ListenAndForward(s.ctx, localURL, config.HTTPEndpoint(endpointOpts...),
+		ngrok.WithAuthtoken(authToken), ngrok.WithRegion(opts.Region))
 	if err != nil {
+		// Choosing the domain is a paid feature unless the domain is reserved
+		// on the account, so explain rejections in those terms
+		var ngrokErr ngrok.Error
+		if (opts.Domain != "" || opts.Subdomain != "") && errors.As(err, &ngrokErr) {
+			return fmt.Errorf("ngrok rejected the requested domain (custom domains must be reserved on your ngrok account, and subdomains need a paid plan): %w", err)
+		}
 		return fmt.Errorf("failed to create ngrok tunnel: %w", err)
 	}
 
@@ -135,6 +178,36 @@ func (s *Service) GetURL() string {
 	return s.info.URL
 }
 
+// WaitForURL waits up to timeout for the tunnel to connect and returns its
+// public URL. The status is polled with backoff since connecting takes from
+// well under a second to several seconds.
+func (s *Service) WaitForURL(timeout time.Duration) (string, error) {
+	deadline := time.Now().Add(timeout)
+	delay := 100 * time.Millisecond
+	for {
+		s.mu.RLock()
+		info := s.info
+		s.mu.RUnlock()
+
+		switch info.Status {
+		case StatusConnected:
+			return info.URL, nil
+		case StatusError:
+			err := ErrTunnelFailed
+			err.Details = info.Error
+			return "", err
+		case StatusDisconnected:
+			return "", ErrNotConnected
+		}
+
+		if time.Now().Add(delay).After(deadline) {
+			return "", fmt.Errorf("ngrok tunnel not connected after %s", timeout)
+		}
+		time.Sleep(delay)
+		delay = min(delay*2, 2*time.Second)
+	}
+}
+
 // SetConfig updates the ngrok configuration
 func (s *Service) SetConfig(config Config) {
 	s.mu.Lock()
@@ -43,9 +43,21 @@ type Service struct {
 	cancel    context.CancelFunc
 }
 
+// Options selects where and under which name the tunnel is exposed. All
+// fields are optional; Domain and Subdomain are mutually exclusive.
+type Options struct {
+	Region    string `json:"region,omitempty"`    // ngrok region code, e.g. "eu"
+	Domain    string `json:"domain,omitempty"`    // Reserved domain, e.g. "term.example.com"
+	Subdomain string `json:"subdomain,omitempty"` // Subdomain of ngrok's default domain
+}
+
+// Regions lists the ngrok region codes accepted in Options.Region
+var Regions = []string{"us", "us-cal-1", "eu", "ap", "au", "sa", "jp", "in"}
+
 // StartRequest represents the request to start ngrok tunnel
 type StartRequest struct {
 	AuthToken string `json:"auth_token,omitempty"`
+	Options
 }
 
 // StatusResponse represents the response for tunnel status
@@ -74,4 +86,5 @@ var (
 	ErrAlreadyRunning   = NgrokError{Code: "already_running", Message: "Ngrok tunnel is already running"}
 	ErrInvalidAuthToken = NgrokError{Code: "invalid_auth_token", Message: "Invalid ngrok auth token"}
 	ErrTunnelFailed     = NgrokError{Code: "tunnel_failed", Message: "Failed to establish tunnel"}
+	ErrInvalidOptions   = NgrokError{Code: "invalid_options", Message: "Invalid ngrok tunnel options"}
 )
@@ -2,9 +2,12 @@ package protocol
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -28,6 +31,26 @@ const (
 	EventMarker EventType = "m"
 )
 
+// Encoding describes how raw terminal output bytes should be interpreted
+type Encoding string
+
+const (
+	EncodingUTF8   Encoding = "utf-8"
+	EncodingLatin1 Encoding = "latin1"
+)
+
+// ParseEncoding normalizes an encoding name, defaulting to UTF-8 when empty
+func ParseEncoding(name string) (Encoding, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "utf-8", "utf8":
+		return EncodingUTF8, nil
+	case "latin1", "latin-1", "iso-8859-1", "iso8859-1":
+		return EncodingLatin1, nil
+	default:
+		return "", fmt.Errorf("unsupported encoding: %s", name)
+	}
+}
+
 type AsciinemaEvent struct {
 	Time float64   `json:"time"`
 	Type EventType `json:"type"`
@@ -52,8 +75,39 @@ type StreamWriter struct {
 	flushTimer *time.Timer
 	syncTimer  *time.Timer
 	needsSync  bool
+
+	// Current terminal geometry. The header keeps the initial size; later
+	// changes are recorded as resize events so replays start correctly.
+	width   uint32
+	height  uint32
+	resized bool
+
+	encoding Encoding
+
+	// Bytes written so far and an optional callback invoked for every event
+	// line, used to mirror recent output in memory
+	offset   int64
+	observer func(event AsciinemaEvent, offset int64)
+
+	// Rotation: once the stream reaches maxSize bytes the file is renamed
+	// to archivePath and recording restarts in a new file
+	maxSize     int64
+	archivePath string
+
+	// Coalescing: output arriving within coalesceWindow of the first
+	// pending byte is merged into one event stamped with that byte's time
+	coalesceWindow time.Duration
+	pending        []byte
+	pendingSince   time.Time
+	coalesceTimer  *time.Timer
 }
 
+// maxCoalescedEvent flushes pending output early once this much has built up
+const maxCoalescedEvent = 64 * 1024
+
+// clearScreen starts each rotated segment so players begin from a blank screen
+const clearScreen = "\x1b[H\x1b[2J"
+
 func NewStreamWriter(writer io.Writer, header *AsciinemaHeader) *StreamWriter {
 	return &StreamWriter{
 		writer:    writer,
@@ -61,9 +115,53 @@ func NewStreamWriter(writer io.Writer, header *AsciinemaHeader) *StreamWriter {
 		startTime: time.Now(),
 		buffer:    make([]byte, 0, 4096),
 		lastWrite: time.Now(),
+		width:     header.Width,
+		height:    header.Height,
+		encoding:  EncodingUTF8,
 	}
 }
 
+// SetEncoding sets the encoding of the output bytes passed to WriteOutput.
+// Single-byte encodings skip UTF-8 boundary buffering and are transcoded to
+// UTF-8 before being recorded.
+func (w *StreamWriter) SetEncoding(encoding Encoding) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.encoding = encoding
+}
+
+// SetObserver registers a callback invoked after each event is written, with
+// the event and the stream offset just past it. It runs with the writer's
+// lock held, so it must be quick and must not call back into the writer.
+func (w *StreamWriter) SetObserver(observer func(event AsciinemaEvent, offset int64)) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.observer = observer
+}
+
+// SetRotation caps the stream at roughly maxSize bytes. Once reached, the
+// file is renamed to archivePath (replacing any earlier archive) and the
+// stream restarts in a new file at its old path, with a fresh header and a
+// clear screen. Readers following the path detect a rotation by the path
+// naming a different file (see os.SameFile) and start over at the new
+// segment. Rotation requires the writer to be an *os.File opened by path;
+// maxSize <= 0 disables it.
+func (w *StreamWriter) SetRotation(maxSize int64, archivePath string) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.maxSize = maxSize
+	w.archivePath = archivePath
+}
+
+// SetCoalesceWindow merges output written within window of each other into
+// a single event, cutting the number of events for programs that write in
+// many small pieces. Output is delayed by up to window; 0 disables it.
+func (w *StreamWriter) SetCoalesceWindow(window time.Duration) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.coalesceWindow = window
+}
+
 func (w *StreamWriter) WriteHeader() error {
 	w.mutex.Lock()
 	defer w.mutex.Unlock()
@@ -76,15 +174,64 @@ func (w *StreamWriter) WriteHeader() error {
 		w.header.Timestamp = w.startTime.Unix()
 	}
 
+	return w.writeHeaderLine()
+}
+
+// writeHeaderLine writes the header; callers must hold the mutex
+func (w *StreamWriter) writeHeaderLine() error {
 	data, err := json.Marshal(w.header)
 	if err != nil {
 		return err
 	}
 
-	_, err = fmt.Fprintf(w.writer, "%s\n", data)
+	n, err := fmt.Fprintf(w.writer, "%s\n", data)
+	w.offset += int64(n)
 	return err
 }
 
+// rotate archives the current segment and restarts the stream; callers must
+// hold the mutex. Renaming takes the same time however large the segment
+// is, so output isn't held up while a big recording is archived.
+func (w *StreamWriter) rotate() error {
+	file, ok := w.writer.(*os.File)
+	if !ok {
+		return fmt.Errorf("stream rotation requires a file")
+	}
+
+	path := file.Name()
+	if err := os.Rename(path, w.archivePath); err != nil {
+		return err
+	}
+	next, err := os.Create(path)
+	if err != nil {
+		// Output keeps going to the archived segment rather than being lost
+		return err
+	}
+	if err := file.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to close rotated asciinema file: %v\n", err)
+	}
+	w.writer = next
+
+	// The new segment starts at the current geometry and time
+	header := *w.header
+	header.Width = w.width
+	header.Height = w.height
+	w.startTime = time.Now()
+	header.Timestamp = w.startTime.Unix()
+	w.header = &header
+	w.offset = 0
+
+	// Don't let the restart itself trigger another rotation
+	maxSize := w.maxSize
+	w.maxSize = 0
+	defer func() { w.maxSize = maxSize }()
+
+	if err := w.writeHeaderLine(); err != nil {
+		return err
+	}
+	return w.writeEventLine(EventOutput, []byte(clearScreen))
+}
+
 func (w *StreamWriter) WriteOutput(data []byte) error {
 	return w.writeEvent(EventOutput, data)
 }
@@ -93,19 +240,77 @@ func (w *StreamWriter) WriteInput(data []byte) error {
 	return w.writeEvent(EventInput, data)
 }
 
+// WriteResize records a terminal resize. The first resize is always written,
+// even if it matches the header, so players pick up the live geometry;
+// subsequent resizes to the current size are skipped.
 func (w *StreamWriter) WriteResize(width, height uint32) error {
-	data := fmt.Sprintf("%dx%d", width, height)
-	return w.writeEvent(EventResize, []byte(data))
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.resized && width == w.width && height == w.height {
+		return nil
+	}
+	if err := w.writeEventLocked(EventResize, []byte(fmt.Sprintf("%dx%d", width, height))); err != nil {
+		return err
+	}
+	w.width = width
+	w.height = height
+	w.resized = true
+	return nil
+}
+
+// ParseResize parses the "WIDTHxHEIGHT" data of a resize event
+func ParseResize(data string) (width, height uint32, err error) {
+	w, h, ok := strings.Cut(data, "x")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid resize data %q", data)
+	}
+	width64, err := strconv.ParseUint(w, 10, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid resize width %q", w)
+	}
+	height64, err := strconv.ParseUint(h, 10, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid resize height %q", h)
+	}
+	return uint32(width64), uint32(height64), nil
+}
+
+// Dimensions returns the most recent terminal geometry written to the stream
+func (w *StreamWriter) Dimensions() (width, height uint32) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.width, w.height
 }
 
 func (w *StreamWriter) writeEvent(eventType EventType, data []byte) error {
 	w.mutex.Lock()
 	defer w.mutex.Unlock()
+	return w.writeEventLocked(eventType, data)
+}
 
+// writeEventLocked is writeEvent for callers that hold the mutex
+func (w *StreamWriter) writeEventLocked(eventType EventType, data []byte) error {
 	if w.closed {
 		return fmt.Errorf("stream writer closed")
 	}
 
+	// Only output needs UTF-8 boundary buffering; other events are written as-is
+	// so they never pick up partial output bytes. Output written before them
+	// goes first, to keep the order.
+	if eventType != EventOutput {
+		if err := w.flushPending(); err != nil {
+			return err
+		}
+		return w.writeEventLine(eventType, data)
+	}
+
+	// Every byte is a complete character in a single-byte encoding
+	if w.encoding == EncodingLatin1 {
+		w.lastWrite = time.Now()
+		return w.emitOutput(decodeLatin1(data))
+	}
+
 	w.buffer = append(w.buffer, data...)
 	w.lastWrite = time.Now()
 
@@ -120,19 +325,91 @@ func (w *StreamWriter) writeEvent(eventType EventType, data []byte) error {
 		return nil
 	}
 
-	elapsed := time.Since(w.startTime).Seconds()
-	event := []interface{}{elapsed, string(eventType), string(completeData)}
+	return w.emitOutput(completeData)
+}
+
+// emitOutput records complete output, holding it back for coalescing if
+// enabled; callers must hold the mutex
+func (w *StreamWriter) emitOutput(data []byte) error {
+	if w.coalesceWindow <= 0 {
+		return w.writeEventLine(EventOutput, data)
+	}
+
+	if len(w.pending) == 0 {
+		w.pendingSince = time.Now()
+		w.coalesceTimer = time.AfterFunc(w.coalesceWindow, func() {
+			w.mutex.Lock()
+			defer w.mutex.Unlock()
+			if w.closed {
+				return
+			}
+			if err := w.flushPending(); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: Failed to write coalesced asciinema event: %v\n", err)
+			}
+		})
+	}
+	w.pending = append(w.pending, data...)
+
+	if len(w.pending) >= maxCoalescedEvent {
+		return w.flushPending()
+	}
+	return nil
+}
+
+// flushPending writes coalesced output as one event stamped with the time
+// its first byte arrived; callers must hold the mutex
+func (w *StreamWriter) flushPending() error {
+	if w.coalesceTimer != nil {
+		w.coalesceTimer.Stop()
+		w.coalesceTimer = nil
+	}
+	if len(w.pending) == 0 {
+		return nil
+	}
+
+	data := w.pending
+	w.pending = nil
+	return w.writeEventLineAt(EventOutput, data, w.pendingSince)
+}
+
+// writeEventLine writes a single event line; callers must hold the mutex
+func (w *StreamWriter) writeEventLine(eventType EventType, data []byte) error {
+	return w.writeEventLineAt(eventType, data, time.Now())
+}
+
+// writeEventLineAt writes an event line for data that arrived at the given
+// time; callers must hold the mutex
+func (w *StreamWriter) writeEventLineAt(eventType EventType, data []byte, at time.Time) error {
+	if w.maxSize > 0 && w.offset >= w.maxSize {
+		if err := w.rotate(); err != nil {
+			// Keep recording into the oversized file rather than losing output
+			fmt.Fprintf(os.Stderr, "Warning: Failed to rotate asciinema file: %v\n", err)
+			w.maxSize = 0
+		}
+	}
+
+	// Coalesced output may predate a rotation that just restarted the clock
+	elapsed := at.Sub(w.startTime).Seconds()
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	event := []interface{}{elapsed, string(eventType), string(data)}
 
 	eventData, err := json.Marshal(event)
 	if err != nil {
 		return err
 	}
 
-	_, err = fmt.Fprintf(w.writer, "%s\n", eventData)
+	n, err := fmt.Fprintf(w.writer, "%s\n", eventData)
+	w.offset += int64(n)
 	if err != nil {
 		return err
 	}
 
+	if w.observer != nil {
+		w.observer(AsciinemaEvent{Time: elapsed, Type: eventType, Data: string(data)}, w.offset)
+	}
+
 	// Schedule sync instead of immediate sync for better performance
 	w.scheduleBatchSync()
 
@@ -156,23 +433,12 @@ func (w *StreamWriter) scheduleFlush() {
 		}
 
 		// Force flush incomplete UTF-8 data for real-time streaming
-		elapsed := time.Since(w.startTime).Seconds()
-		event := []interface{}{elapsed, string(EventOutput), string(w.buffer)}
-
-		eventData, err := json.Marshal(event)
-		if err != nil {
-			return
-		}
-
-		if _, err := fmt.Fprintf(w.writer, "%s\n", eventData); err != nil {
+		if err := w.emitOutput(w.buffer); err != nil {
 			// Log but don't fail - this is a best effort flush
 			// Cannot use log here as we might be in a defer/cleanup path
 			return
 		}
 
-		// Schedule sync instead of immediate sync for better performance
-		w.scheduleBatchSync()
-
 		// Clear buffer after flushing
 		w.buffer = w.buffer[:0]
 	})
@@ -189,15 +455,19 @@ func (w *StreamWriter) scheduleBatchSync() {
 
 	// Schedule sync after 1ms for better real-time performance
 	w.syncTimer = time.AfterFunc(1*time.Millisecond, func() {
-		if w.needsSync {
-			if file, ok := w.writer.(*os.File); ok {
-				if err := file.Sync(); err != nil {
-					// Sync failed - this is not critical for streaming operations
-					// Using fmt instead of log to avoid potential deadlock in timer context
-					fmt.Fprintf(os.Stderr, "Warning: Failed to sync asciinema file: %v\n", err)
-				}
+		// Rotation replaces the writer, so pick it up under the lock
+		w.mutex.Lock()
+		file, ok := w.writer.(*os.File)
+		sync := w.needsSync && ok && !w.closed
+		w.needsSync = false
+		w.mutex.Unlock()
+
+		if sync {
+			if err := file.Sync(); err != nil && !errors.Is(err, os.ErrClosed) {
+				// Sync failed - this is not critical for streaming operations
+				// Using fmt instead of log to avoid potential deadlock in timer context
+				fmt.Fprintf(os.Stderr, "Warning: Failed to sync asciinema file: %v\n", err)
 			}
-			w.needsSync = false
 		}
 	})
 }
@@ -218,15 +488,19 @@ func (w *StreamWriter) Close() error {
 		w.syncTimer.Stop()
 	}
 
+	if err := w.flushPending(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to write coalesced asciinema event: %v\n", err)
+	}
 	if len(w.buffer) > 0 {
-		elapsed := time.Since(w.startTime).Seconds()
-		event := []interface{}{elapsed, string(EventOutput), string(w.buffer)}
-		eventData, _ := json.Marshal(event)
-		if _, err := fmt.Fprintf(w.writer, "%s\n", eventData); err != nil {
+		if err := w.writeEventLine(EventOutput, w.buffer); err != nil {
 			// Write failed during close - log to stderr to avoid deadlock
 			fmt.Fprintf(os.Stderr, "Warning: Failed to write final asciinema event: %v\n", err)
 		}
 	}
+	// The writer is about to be closed; don't sync it afterwards
+	if w.syncTimer != nil {
+		w.syncTimer.Stop()
+	}
 
 	w.closed = true
 	if closer, ok := w.writer.(io.Closer); ok {
@@ -266,6 +540,16 @@ func extractCompleteUTF8(data []byte) (complete, remaining []byte) {
 	return data[:lastValid], data[lastValid:]
 }
 
+// decodeLatin1 transcodes ISO-8859-1 bytes to UTF-8; each byte maps to the
+// code point of the same value
+func decodeLatin1(data []byte) []byte {
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		runes[i] = rune(b)
+	}
+	return []byte(string(runes))
+}
+
 type StreamReader struct {
 	reader     io.Reader
 	decoder    *json.Decoder
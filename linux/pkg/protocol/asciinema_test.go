@@ -0,0 +1,221 @@
+package protocol
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamWriterRotatesByRenaming(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "stream-out")
+	archivePath := path + ".1"
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := NewStreamWriter(file, &AsciinemaHeader{Version: 2, Width: 80, Height: 24})
+	w.SetRotation(512, archivePath)
+	if err := w.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A reader following the first segment
+	reader, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	for i := 0; i < 12; i++ {
+		if err := w.WriteOutput([]byte(strings.Repeat("x", 40) + "\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.WriteResize(100, 30); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteOutput([]byte("after\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The file the reader has open became the archive
+	readerInfo, err := reader.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	archiveInfo, err := os.Stat(archivePath)
+	if err != nil {
+		t.Fatalf("no archived segment: %v", err)
+	}
+	if !os.SameFile(readerInfo, archiveInfo) {
+		t.Error("rotation didn't rename the open segment to the archive")
+	}
+	currentInfo, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if os.SameFile(readerInfo, currentInfo) {
+		t.Error("stream-out is still the rotated file")
+	}
+
+	// The new segment is a complete recording on its own
+	current, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer current.Close()
+	scanner := bufio.NewScanner(current)
+	if !scanner.Scan() {
+		t.Fatal("new segment is empty")
+	}
+	var header AsciinemaHeader
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		t.Fatalf("new segment doesn't start with a header: %v", err)
+	}
+	if info, err := current.Stat(); err != nil || info.Size() > 512+256 {
+		t.Errorf("new segment wasn't limited: %v, %v", info.Size(), err)
+	}
+	var lines []string
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if len(lines) == 0 || !strings.Contains(lines[0], `\u001b[H\u001b[2J`) {
+		t.Errorf("new segment doesn't start with a clear screen: %q", lines)
+	}
+	if !strings.Contains(lines[len(lines)-1], "after") {
+		t.Errorf("last event = %q, want the output written last", lines[len(lines)-1])
+	}
+}
+
+// lineCounter counts the lines written to it, one per header or event
+type lineCounter struct {
+	lines int
+}
+
+func (c *lineCounter) Write(p []byte) (int, error) {
+	c.lines += strings.Count(string(p), "\n")
+	return len(p), nil
+}
+
+// writeBurst writes n small pieces of output in quick succession, like a
+// program printing line by line, and returns the number of events recorded
+func writeBurst(tb testing.TB, window time.Duration, n int) int {
+	tb.Helper()
+	counter := &lineCounter{}
+	w := NewStreamWriter(counter, &AsciinemaHeader{Version: 2, Width: 80, Height: 24})
+	w.SetCoalesceWindow(window)
+	if err := w.WriteHeader(); err != nil {
+		tb.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		if err := w.WriteOutput([]byte("line of output\r\n")); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		tb.Fatal(err)
+	}
+	return counter.lines - 1 // Without the header
+}
+
+func BenchmarkBurstEvents(b *testing.B) {
+	const burst = 1000
+	for _, window := range []time.Duration{0, 5 * time.Millisecond} {
+		b.Run("window="+window.String(), func(b *testing.B) {
+			events := 0
+			for i := 0; i < b.N; i++ {
+				events += writeBurst(b, window, burst)
+			}
+			b.ReportMetric(float64(events)/float64(b.N), "events/op")
+		})
+	}
+}
+
+func TestCoalescingMergesBursts(t *testing.T) {
+	if events := writeBurst(t, 0, 100); events != 100 {
+		t.Errorf("without coalescing: %d events, want 100", events)
+	}
+	// A burst written well within the window ends up in very few events
+	if events := writeBurst(t, time.Second, 100); events > 2 {
+		t.Errorf("with coalescing: %d events, want at most 2", events)
+	}
+}
+
+func TestCoalescingKeepsSplitCharacters(t *testing.T) {
+	var buf strings.Builder
+	w := NewStreamWriter(&buf, &AsciinemaHeader{Version: 2, Width: 80, Height: 24})
+	w.SetCoalesceWindow(time.Second)
+	if err := w.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	// "é" and "€" split across writes
+	for _, piece := range []string{"caf\xc3", "\xa9 ", "\xe2\x82", "\xac5"} {
+		if err := w.WriteOutput([]byte(piece)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if output := recordedOutput(t, buf.String()); output != "café €5" {
+		t.Errorf("recorded output %q, want %q", output, "café €5")
+	}
+}
+
+// recordedOutput joins the text of the output events in a recording
+func recordedOutput(t *testing.T, recording string) string {
+	t.Helper()
+	var output strings.Builder
+	lines := strings.Split(strings.TrimSpace(recording), "\n")
+	for _, line := range lines[1:] {
+		var event []interface{}
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("invalid event %q: %v", line, err)
+		}
+		if event[1] == "o" {
+			output.WriteString(event[2].(string))
+		}
+	}
+	return output.String()
+}
+
+func TestLatin1Encoding(t *testing.T) {
+	// "café £5 ÿ" in ISO-8859-1; 0xE9 on its own is also the start of a
+	// three-byte UTF-8 sequence
+	pieces := []string{"caf\xe9", " \xa35 \xff"}
+	record := func(encoding Encoding) string {
+		var buf strings.Builder
+		w := NewStreamWriter(&buf, &AsciinemaHeader{Version: 2, Width: 80, Height: 24})
+		w.SetEncoding(encoding)
+		if err := w.WriteHeader(); err != nil {
+			t.Fatal(err)
+		}
+		for _, piece := range pieces {
+			if err := w.WriteOutput([]byte(piece)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return recordedOutput(t, buf.String())
+	}
+
+	if output := record(EncodingLatin1); output != "café £5 ÿ" {
+		t.Errorf("latin1: recorded output %q, want %q", output, "café £5 ÿ")
+	}
+	// As UTF-8, the default, the same bytes are invalid and each is replaced
+	if output := record(EncodingUTF8); output != "caf\uFFFD \uFFFD5 \uFFFD" {
+		t.Errorf("utf-8: recorded output %q, want %q", output, "caf\uFFFD \uFFFD5 \uFFFD")
+	}
+}
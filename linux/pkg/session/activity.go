@@ -0,0 +1,77 @@
+package session
+
+import (
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// activitySaveInterval bounds how often activity is written to session.json;
+// in between, the running session's in-memory time is more recent
+const activitySaveInterval = 10 * time.Second
+
+// activityTracker holds when a session last produced output or received
+// input, as Unix nanoseconds
+type activityTracker struct {
+	last  atomic.Int64
+	saved atomic.Int64   // When last written to session.json
+	saves sync.WaitGroup // Saves still running in the background
+}
+
+// touchActivity records output or input, saving it to session.json if it
+// hasn't been saved for activitySaveInterval. It's called on the output path
+// with the stream writer locked, so the save happens in the background.
+func (s *Session) touchActivity() {
+	now := time.Now()
+	s.activity.last.Store(now.UnixNano())
+
+	saved := s.activity.saved.Load()
+	if now.UnixNano()-saved < int64(activitySaveInterval) || !s.activity.saved.CompareAndSwap(saved, now.UnixNano()) {
+		return
+	}
+	s.activity.saves.Add(1)
+	go func() {
+		defer s.activity.saves.Done()
+		s.saveActivity(now)
+	}()
+}
+
+// saveActivity writes the time of the session's last activity to
+// session.json
+func (s *Session) saveActivity(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.After(s.info.LastActivity) {
+		s.info.LastActivity = now
+	}
+	// The session may have been removed meanwhile
+	if err := s.info.Save(s.Path()); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Printf("[ERROR] Failed to save activity of session %s: %v", s.ID, err)
+	}
+}
+
+// LastActivity returns when the session last produced output or received
+// input. Sessions running in another process report the saved time or the
+// recording's modification time, whichever is later, so input that wasn't
+// recorded may be missed for up to activitySaveInterval. Sessions without
+// any activity report their start time.
+func (s *Session) LastActivity() time.Time {
+	if last := s.activity.last.Load(); last != 0 {
+		return time.Unix(0, last)
+	}
+
+	s.mu.RLock()
+	last, started := s.info.LastActivity, s.info.StartedAt
+	s.mu.RUnlock()
+	if stat, err := os.Stat(s.StreamOutPath()); err == nil && stat.ModTime().After(last) {
+		last = stat.ModTime()
+	}
+	if last.IsZero() {
+		return started
+	}
+	return last
+}
@@ -0,0 +1,291 @@
+package session
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/vibetunnel/linux/pkg/protocol"
+	"golang.org/x/term"
+)
+
+// Attaching to a session
+//
+// Only the process that owns a session's PTY reads the PTY master, and it
+// records everything to stream-out. Attachers never touch the master; they
+// follow stream-out instead, so any number of them can watch a session from
+// this process or another one without stealing output from each other or
+// from the web clients.
+//
+// A read-write attacher also puts the local terminal in raw mode, forwards
+// its input through the stdin FIFO and keeps the session sized to the local
+// terminal. Only one read-write attacher is allowed at a time, enforced
+// with an exclusive lock on attach.lock in the session directory. Read-only
+// attachers leave the terminal alone and can be stopped with Ctrl-C.
+
+// ErrAttached is returned when a session already has a read-write attacher
+var ErrAttached = errors.New("session already has a read-write attacher")
+
+// attachPollInterval is how often attachers check that the session is alive
+const attachPollInterval = 250 * time.Millisecond
+
+// Attach connects the local terminal to the session for reading and
+// writing until the session exits
+func (s *Session) Attach() error {
+	if s.IsPlayback() {
+		return ErrReadOnly
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return fmt.Errorf("not a terminal")
+	}
+	if !s.IsAlive() {
+		return fmt.Errorf("session is not running")
+	}
+
+	lock, err := s.lockAttach()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// Closing the file releases the lock
+		if err := lock.Close(); err != nil {
+			log.Printf("[ERROR] Failed to close attach lock: %v", err)
+		}
+	}()
+
+	oldState, err := term.MakeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return fmt.Errorf("failed to set raw mode: %w", err)
+	}
+	defer func() {
+		if err := term.Restore(int(os.Stdin.Fd()), oldState); err != nil {
+			log.Printf("[ERROR] Attach: Failed to restore terminal: %v", err)
+		}
+	}()
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGWINCH)
+	defer signal.Stop(ch)
+	go func() {
+		for range ch {
+			s.resizeToTerminal()
+		}
+	}()
+	s.resizeToTerminal()
+
+	// The read blocks until the next keypress, so this goroutine outlives
+	// the attachment; it only forwards input while attached
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, err := os.Stdin.Read(buf)
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if n > 0 {
+				if err := s.sendInput(buf[:n]); err != nil {
+					log.Printf("[ERROR] Attach: Failed to send input: %v", err)
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	return s.followOutput(os.Stdout)
+}
+
+// AttachReadOnly copies the session's output to stdout until the session
+// exits, without reading input or changing the local terminal
+func (s *Session) AttachReadOnly() error {
+	if !s.IsAlive() {
+		return fmt.Errorf("session is not running")
+	}
+	return s.followOutput(os.Stdout)
+}
+
+// lockAttach takes the session's read-write attach lock
+func (s *Session) lockAttach() (*os.File, error) {
+	file, err := os.OpenFile(filepath.Join(s.Path(), "attach.lock"), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open attach lock: %w", err)
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		if closeErr := file.Close(); closeErr != nil {
+			log.Printf("[ERROR] Failed to close attach lock: %v", closeErr)
+		}
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, ErrAttached
+		}
+		return nil, fmt.Errorf("failed to lock attach lock: %w", err)
+	}
+	return file, nil
+}
+
+// resizeToTerminal sizes the session to the local terminal
+func (s *Session) resizeToTerminal() {
+	width, height, err := term.GetSize(int(os.Stdin.Fd()))
+	if err != nil {
+		log.Printf("[ERROR] Attach: Failed to get terminal size: %v", err)
+		return
+	}
+	// Terminals that don't know their size, e.g. some pseudo-terminals
+	// without a window, report 0x0
+	if width <= 0 || height <= 0 {
+		return
+	}
+
+	s.mu.RLock()
+	unchanged := s.info.Width == width && s.info.Height == height
+	s.mu.RUnlock()
+	if unchanged {
+		return
+	}
+
+	if err := s.Resize(width, height); err != nil {
+		log.Printf("[ERROR] Attach: Failed to resize session %s: %v", s.ID[:8], err)
+	}
+}
+
+// followOutput writes the session's output to w, starting with what the
+// current stream-out segment already holds, until the session exits
+func (s *Session) followOutput(w io.Writer) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create file watcher: %w", err)
+	}
+	defer func() {
+		if err := watcher.Close(); err != nil {
+			log.Printf("[ERROR] Failed to close file watcher: %v", err)
+		}
+	}()
+	if err := watcher.Add(s.StreamOutPath()); err != nil {
+		return fmt.Errorf("failed to watch stream: %w", err)
+	}
+
+	follower := &streamFollower{path: s.StreamOutPath()}
+	if err := follower.copyNew(w); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(attachPollInterval)
+	defer ticker.Stop()
+
+	// runDone is nil, and never fires, when another process owns the PTY
+	dead := false
+	for {
+		select {
+		case <-s.runDone:
+			// The stream writer is closed, so everything has been written
+			return follower.copyNew(w)
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Op&fsnotify.Write == fsnotify.Write {
+				if err := follower.copyNew(w); err != nil {
+					return err
+				}
+			}
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Printf("[ERROR] Attach: File watcher error: %v", err)
+
+		case <-ticker.C:
+			// Give another owner one more interval to flush its final
+			// output once the process is gone
+			if err := follower.copyNew(w); err != nil {
+				return err
+			}
+			if dead {
+				return nil
+			}
+			dead = !s.IsAlive()
+		}
+	}
+}
+
+// streamFollower reads the output events appended to a stream-out file
+type streamFollower struct {
+	path    string
+	offset  int64
+	partial []byte // Incomplete last line, completed by a later read
+}
+
+// copyNew writes the output of events appended since the last call to w
+func (f *streamFollower) copyNew(w io.Writer) error {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Printf("[ERROR] Failed to close stream file: %v", err)
+		}
+	}()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	// A shrunken file means stream-out was rotated; follow the new segment
+	if stat.Size() < f.offset {
+		f.offset = 0
+		f.partial = nil
+	}
+	if stat.Size() == f.offset {
+		return nil
+	}
+
+	data := make([]byte, stat.Size()-f.offset)
+	n, err := file.ReadAt(data, f.offset)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	f.offset += int64(n)
+
+	data = append(f.partial, data[:n]...)
+	for {
+		end := bytes.IndexByte(data, '\n')
+		if end < 0 {
+			break
+		}
+		line := data[:end]
+		data = data[end+1:]
+
+		// Skip the header and anything that isn't an output event
+		var event []interface{}
+		if err := json.Unmarshal(line, &event); err != nil || len(event) != 3 {
+			continue
+		}
+		if eventType, _ := event[1].(string); eventType != string(protocol.EventOutput) {
+			continue
+		}
+		output, _ := event[2].(string)
+		if _, err := io.WriteString(w, output); err != nil {
+			return err
+		}
+	}
+	f.partial = append([]byte(nil), data...)
+
+	return nil
+}
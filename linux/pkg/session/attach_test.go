@@ -0,0 +1,50 @@
+package session
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAttachedReadersSeeSameOutput(t *testing.T) {
+	controlPath := t.TempDir()
+	sess, err := NewManager(controlPath).CreateSession(Config{
+		Cmdline: []string{"/bin/sh", "-c", `sleep 0.2; for i in 1 2 3; do echo "line $i"; done`},
+	})
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	// One reader in the owning process and one through another manager,
+	// like a viewer attached from a second terminal
+	other, err := NewManager(controlPath).GetSession(sess.ID)
+	if err != nil {
+		t.Fatalf("GetSession: %v", err)
+	}
+
+	readers := []*Session{sess, other}
+	outputs := make([]strings.Builder, len(readers))
+	errs := make(chan error, len(readers))
+	for i, reader := range readers {
+		go func() {
+			errs <- reader.followOutput(&outputs[i])
+		}()
+	}
+	for range readers {
+		select {
+		case err := <-errs:
+			if err != nil {
+				t.Fatalf("followOutput: %v", err)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatal("readers did not finish after the session exited")
+		}
+	}
+
+	for i := range outputs {
+		output := strings.ReplaceAll(outputs[i].String(), "\r\n", "\n")
+		if output != "line 1\nline 2\nline 3\n" {
+			t.Errorf("reader %d saw %q", i, output)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package session
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// childExit is how a session's process ended. err is set if its status
+// couldn't be collected, e.g. because something else reaped it.
+type childExit struct {
+	status syscall.WaitStatus
+	err    error
+}
+
+// childReaper reaps the processes of the sessions in this process. A single
+// goroutine wakes on SIGCHLD and waits for the PIDs sessions have
+// registered, so exit statuses go to the right session however many are
+// running, and children other code waits for itself (ngrok, cloudflared,
+// ps) are never reaped out from under it. When orphans are reparented to
+// this process, as PID 1 in a container or as a subreaper, it also reaps
+// those (see reapOrphans).
+type childReaper struct {
+	mu      sync.Mutex
+	waiters map[int]chan childExit
+	wake    chan struct{}
+	start   sync.Once
+}
+
+var children = &childReaper{
+	waiters: make(map[int]chan childExit),
+	wake:    make(chan struct{}, 1),
+}
+
+// startWatched calls start, which starts a child of this process and
+// returns its PID, and registers the child. It returns a channel that
+// receives the child's exit once it has been reaped. Nothing else may wait
+// for the child. Orphans aren't reaped while start runs, so the child can't
+// be mistaken for one before it is registered.
+func (r *childReaper) startWatched(start func() (int, error)) (<-chan childExit, error) {
+	r.start.Do(func() {
+		sigCh := make(chan os.Signal, 1)
+		signal.Notify(sigCh, syscall.SIGCHLD)
+		go r.run(sigCh)
+	})
+
+	r.mu.Lock()
+	pid, err := start()
+	if err != nil {
+		r.mu.Unlock()
+		return nil, err
+	}
+	ch := make(chan childExit, 1)
+	r.waiters[pid] = ch
+	r.mu.Unlock()
+
+	// The child may have exited before it was registered, its SIGCHLD
+	// finding nothing to reap
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+	return ch, nil
+}
+
+// run reaps registered children whenever one may have exited. SIGCHLDs
+// arriving together are coalesced, so every registered PID is checked.
+func (r *childReaper) run(sigCh chan os.Signal) {
+	for {
+		select {
+		case <-sigCh:
+		case <-r.wake:
+		}
+		r.reap()
+	}
+}
+
+func (r *childReaper) reap() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for pid, ch := range r.waiters {
+		var status syscall.WaitStatus
+		wpid, err := syscall.Wait4(pid, &status, syscall.WNOHANG, nil)
+		if err == syscall.EINTR || (err == nil && wpid == 0) {
+			continue // Still running
+		}
+		if err == nil && !status.Exited() && !status.Signaled() {
+			continue // Stopped or continued, not gone
+		}
+
+		debugLog("[DEBUG] Reaped session process %d: %v", pid, err)
+		ch <- childExit{status: status, err: err}
+		delete(r.waiters, pid)
+	}
+
+	r.reapOrphans()
+}
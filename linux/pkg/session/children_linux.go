@@ -0,0 +1,96 @@
+//go:build linux
+// +build linux
+
+package session
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// adoptsOrphans reports whether orphaned processes are reparented to this
+// process, which then has to reap them: as PID 1, e.g. in a container, or
+// as a child subreaper
+func adoptsOrphans() bool {
+	if os.Getpid() == 1 {
+		return true
+	}
+	var subreaper int32
+	if err := unix.Prctl(unix.PR_GET_CHILD_SUBREAPER, uintptr(unsafe.Pointer(&subreaper)), 0, 0, 0); err != nil {
+		return false
+	}
+	return subreaper != 0
+}
+
+// reapOrphans reaps exited children that no session registered, when this
+// process adopts orphans. Only children outside this process's own session
+// are reaped: sessions' processes are started with Setsid, so what they
+// leave behind is too, while children other code started and waits for
+// itself stay in this process's session. Called with r.mu held.
+func (r *childReaper) reapOrphans() {
+	if !adoptsOrphans() {
+		return
+	}
+	self := os.Getpid()
+	ownSid, err := unix.Getsid(0)
+	if err != nil {
+		return
+	}
+
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		debugLog("[DEBUG] Failed to list processes: %v", err)
+		return
+	}
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		if _, registered := r.waiters[pid]; registered {
+			continue
+		}
+		state, ppid, sid, ok := procStat(pid)
+		if !ok || state != "Z" || ppid != self || sid == ownSid {
+			continue
+		}
+
+		var status syscall.WaitStatus
+		if wpid, err := syscall.Wait4(pid, &status, syscall.WNOHANG, nil); err == nil && wpid == pid {
+			debugLog("[DEBUG] Reaped orphaned process %d", pid)
+		}
+	}
+}
+
+// procStat reads the state, parent PID and session ID of process pid from
+// /proc/<pid>/stat
+func procStat(pid int) (state string, ppid, sid int, ok bool) {
+	data, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return "", 0, 0, false
+	}
+	// The command name in parentheses may contain spaces; the fields
+	// after it are state, ppid, pgrp and session
+	end := strings.LastIndexByte(string(data), ')')
+	if end < 0 {
+		return "", 0, 0, false
+	}
+	fields := strings.Fields(string(data[end+1:]))
+	if len(fields) < 4 {
+		return "", 0, 0, false
+	}
+	ppid, err = strconv.Atoi(fields[1])
+	if err != nil {
+		return "", 0, 0, false
+	}
+	sid, err = strconv.Atoi(fields[3])
+	if err != nil {
+		return "", 0, 0, false
+	}
+	return fields[0], ppid, sid, true
+}
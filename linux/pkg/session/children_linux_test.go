@@ -0,0 +1,63 @@
+//go:build linux
+// +build linux
+
+package session
+
+import (
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestReapsOrphans(t *testing.T) {
+	// Orphans are reparented to a subreaper like to PID 1 in a container
+	if err := unix.Prctl(unix.PR_SET_CHILD_SUBREAPER, 1, 0, 0, 0); err != nil {
+		t.Skipf("can't become a subreaper: %v", err)
+	}
+	defer func() {
+		if err := unix.Prctl(unix.PR_SET_CHILD_SUBREAPER, 0, 0, 0, 0); err != nil {
+			t.Errorf("Failed to stop being a subreaper: %v", err)
+		}
+	}()
+
+	// A child of our own, which must be left for its owner to wait for
+	own := exec.Command("sleep", "0.5")
+	if err := own.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The session leaves a background process behind, which becomes our
+	// child when the session's shell exits
+	m := NewManager(t.TempDir())
+	sess, err := m.CreateSession(Config{Cmdline: []string{"/bin/sh", "-c", `sleep 0.2 & echo "orphan=$!"`}})
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	sess.Wait()
+	match := regexp.MustCompile(`orphan=(\d+)`).FindStringSubmatch(waitForOutput(t, sess, "orphan="))
+	if match == nil {
+		t.Fatal("no orphan PID in the output")
+	}
+	orphan, _ := strconv.Atoi(match[1])
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		state, ppid, _, ok := procStat(orphan)
+		if !ok || ppid != os.Getpid() {
+			break // Reaped
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("orphan %d is still our child in state %s", orphan, state)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if err := own.Wait(); err != nil {
+		t.Errorf("waiting for our own child: %v", err)
+	}
+}
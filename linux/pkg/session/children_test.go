@@ -0,0 +1,43 @@
+package session
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestManySessionsSettle(t *testing.T) {
+	m := NewManager(t.TempDir())
+	const count = 8
+
+	// Started together so their exits race each other
+	sessions := make([]*Session, count)
+	var wg sync.WaitGroup
+	for i := range sessions {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sess, err := m.CreateSession(Config{Cmdline: []string{"/bin/sh", "-c", fmt.Sprintf("exit %d", i)}})
+			if err != nil {
+				t.Errorf("CreateSession: %v", err)
+				return
+			}
+			sessions[i] = sess
+		}(i)
+	}
+	wg.Wait()
+
+	for i, sess := range sessions {
+		if sess == nil {
+			continue
+		}
+		sess.Wait()
+		info, err := LoadInfo(sess.Path())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Status != string(StatusExited) || info.ExitCode == nil || *info.ExitCode != i {
+			t.Errorf("session %d: status %q, exit code %v, want exited with %d", i, info.Status, info.ExitCode, i)
+		}
+	}
+}
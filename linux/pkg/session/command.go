@@ -0,0 +1,134 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// ErrCommandNotFound matches errors for sessions whose command can't be
+// resolved to an executable; use errors.As with *CommandNotFoundError to get
+// the command name
+var ErrCommandNotFound = errors.New("command not found")
+
+// CommandNotFoundError reports the command a session could not start
+type CommandNotFoundError struct {
+	Command string
+}
+
+func (e *CommandNotFoundError) Error() string {
+	return fmt.Sprintf("command not found: %s", e.Command)
+}
+
+func (e *CommandNotFoundError) Is(target error) bool {
+	return target == ErrCommandNotFound
+}
+
+// ErrInvalidWorkingDir matches errors for working directories that don't
+// exist or can't be entered; use errors.As with *InvalidWorkingDirError to
+// get the path
+var ErrInvalidWorkingDir = errors.New("invalid working directory")
+
+// InvalidWorkingDirError reports a session working directory that can't be used
+type InvalidWorkingDirError struct {
+	Path string
+	Err  error
+}
+
+func (e *InvalidWorkingDirError) Error() string {
+	return fmt.Sprintf("working directory '%s' not accessible: %v", e.Path, e.Err)
+}
+
+func (e *InvalidWorkingDirError) Is(target error) bool {
+	return target == ErrInvalidWorkingDir
+}
+
+func (e *InvalidWorkingDirError) Unwrap() error {
+	return e.Err
+}
+
+// CheckWorkingDir returns an *InvalidWorkingDirError unless path is an
+// existing directory this process may enter
+func CheckWorkingDir(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return &InvalidWorkingDirError{Path: path, Err: err}
+	}
+	if !info.IsDir() {
+		return &InvalidWorkingDirError{Path: path, Err: errors.New("not a directory")}
+	}
+	if err := unix.Access(path, unix.X_OK); err != nil {
+		return &InvalidWorkingDirError{Path: path, Err: err}
+	}
+	return nil
+}
+
+// ResolveCommand returns the executable config's command runs. Names with a
+// slash are relative to config.Cwd; bare names are looked up in the PATH
+// from config.Env, falling back to the host PATH. An empty command resolves
+// the default shell.
+func ResolveCommand(config Config) (string, error) {
+	name := ""
+	if len(config.Cmdline) > 0 {
+		name = config.Cmdline[0]
+	} else {
+		name = os.Getenv("SHELL")
+		if name == "" {
+			name = "/bin/bash"
+		}
+	}
+	return lookCommand(name, config.Cwd, envSliceToMap(config.Env))
+}
+
+// lookCommand resolves name like a shell started in cwd with env would
+func lookCommand(name, cwd string, env map[string]string) (string, error) {
+	if name == "" {
+		return "", &CommandNotFoundError{Command: name}
+	}
+
+	if strings.Contains(name, "/") {
+		path := name
+		if !filepath.IsAbs(path) && cwd != "" {
+			path = filepath.Join(cwd, path)
+		}
+		if _, err := exec.LookPath(path); err != nil {
+			return "", &CommandNotFoundError{Command: name}
+		}
+		return absPath(path), nil
+	}
+
+	searchPath, ok := env["PATH"]
+	if !ok {
+		searchPath = os.Getenv("PATH")
+	}
+	for _, dir := range filepath.SplitList(searchPath) {
+		if dir == "" {
+			dir = "." // An empty entry means the working directory
+		}
+		if !filepath.IsAbs(dir) && cwd != "" {
+			dir = filepath.Join(cwd, dir)
+		}
+		path := filepath.Join(dir, name)
+		if !strings.Contains(path, "/") {
+			path = "./" + path
+		}
+		if _, err := exec.LookPath(path); err == nil {
+			return absPath(path), nil
+		}
+	}
+	return "", &CommandNotFoundError{Command: name}
+}
+
+// absPath makes path absolute, so it stays valid once the child has changed
+// to its working directory
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
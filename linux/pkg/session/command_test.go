@@ -0,0 +1,41 @@
+package session
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckWorkingDir(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := CheckWorkingDir(dir); err != nil {
+		t.Errorf("CheckWorkingDir(%q) = %v, want nil", dir, err)
+	}
+	for _, path := range []string{filepath.Join(dir, "missing"), file} {
+		err := CheckWorkingDir(path)
+		var invalid *InvalidWorkingDirError
+		if !errors.Is(err, ErrInvalidWorkingDir) || !errors.As(err, &invalid) || invalid.Path != path {
+			t.Errorf("CheckWorkingDir(%q) = %v, want an *InvalidWorkingDirError for it", path, err)
+		}
+	}
+}
+
+func TestCheckWorkingDirRequiresSearchPermission(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root may enter any directory")
+	}
+	dir := filepath.Join(t.TempDir(), "locked")
+	if err := os.Mkdir(dir, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := CheckWorkingDir(dir); !errors.Is(err, ErrInvalidWorkingDir) {
+		t.Errorf("CheckWorkingDir(%q) = %v, want ErrInvalidWorkingDir", dir, err)
+	}
+}
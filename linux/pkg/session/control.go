@@ -2,6 +2,7 @@ package session
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -10,11 +11,17 @@ import (
 	"time"
 )
 
-// ControlCommand represents a command sent through the control FIFO
+// ControlCommand represents a command sent through the control FIFO. The
+// FIFO carries one JSON object per line, read by the process that owns the
+// session's PTY:
+//
+//	{"cmd":"resize","cols":120,"rows":40}
+//	{"cmd":"signal","signal":"SIGTERM"}
 type ControlCommand struct {
-	Cmd  string `json:"cmd"`
-	Cols int    `json:"cols,omitempty"`
-	Rows int    `json:"rows,omitempty"`
+	Cmd    string `json:"cmd"`
+	Cols   int    `json:"cols,omitempty"`
+	Rows   int    `json:"rows,omitempty"`
+	Signal string `json:"signal,omitempty"` // Signal name or number
 }
 
 // createControlFIFO creates the control FIFO for a session
@@ -98,43 +105,43 @@ func (s *Session) handleControlCommand(cmd *ControlCommand) {
 				log.Printf("[ERROR] Failed to resize session %s: %v", s.ID[:8], err)
 			}
 		}
+	case "signal":
+		if err := s.Signal(cmd.Signal); err != nil {
+			log.Printf("[ERROR] Failed to signal session %s: %v", s.ID[:8], err)
+		}
 	default:
 		log.Printf("[WARN] Unknown control command: %s", cmd.Cmd)
 	}
 }
 
-// SendControlCommand sends a command to a session's control FIFO
+// SendControlCommand sends a command to a session's control FIFO. It fails
+// immediately, instead of blocking, when no process is listening.
 func SendControlCommand(sessionPath string, cmd *ControlCommand) error {
 	controlPath := filepath.Join(sessionPath, "control")
 
-	// Open FIFO with timeout
-	done := make(chan error, 1)
-	go func() {
-		file, err := os.OpenFile(controlPath, os.O_WRONLY, 0)
-		if err != nil {
-			done <- err
-			return
+	data, err := json.Marshal(cmd)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	// Opening a FIFO for writing without blocking fails with ENXIO when
+	// nothing has it open for reading, i.e. the owning process is gone
+	file, err := os.OpenFile(controlPath, os.O_WRONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		if errors.Is(err, syscall.ENXIO) {
+			return fmt.Errorf("no process is listening on the control FIFO")
 		}
-		defer func() {
-			if err := file.Close(); err != nil {
-				log.Printf("[ERROR] Failed to close control file: %v", err)
-			}
-		}()
-
-		encoder := json.NewEncoder(file)
-		if err := encoder.Encode(cmd); err != nil {
-			done <- err
-			return
+		return err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Printf("[ERROR] Failed to close control file: %v", err)
 		}
-
-		done <- nil
 	}()
 
-	// Wait with timeout
-	select {
-	case err := <-done:
-		return err
-	case <-time.After(1 * time.Second):
-		return fmt.Errorf("timeout sending control command")
-	}
+	// Commands are far smaller than PIPE_BUF, so the write is atomic and
+	// can't interleave with other writers
+	_, err = file.Write(data)
+	return err
 }
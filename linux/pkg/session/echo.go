@@ -0,0 +1,16 @@
+//go:build darwin || linux
+// +build darwin linux
+
+package session
+
+import "golang.org/x/sys/unix"
+
+// isEchoEnabled reads the termios of the terminal fd, which may be either
+// side of a PTY, and reports whether it echoes input
+func isEchoEnabled(fd int) (bool, error) {
+	termios, err := unix.IoctlGetTermios(fd, getTermiosRequest)
+	if err != nil {
+		return false, err
+	}
+	return termios.Lflag&unix.ECHO != 0, nil
+}
@@ -0,0 +1,9 @@
+//go:build darwin
+// +build darwin
+
+package session
+
+import "golang.org/x/sys/unix"
+
+// getTermiosRequest reads a terminal's termios on macOS
+const getTermiosRequest = unix.TIOCGETA
@@ -0,0 +1,9 @@
+//go:build linux
+// +build linux
+
+package session
+
+import "golang.org/x/sys/unix"
+
+// getTermiosRequest reads a terminal's termios on Linux
+const getTermiosRequest = unix.TCGETS
@@ -0,0 +1,93 @@
+//go:build linux
+// +build linux
+
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/creack/pty"
+	"golang.org/x/sys/unix"
+)
+
+// setEcho turns echo on the terminal fd on or off
+func setEcho(t *testing.T, fd int, on bool) {
+	t.Helper()
+	termios, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if on {
+		termios.Lflag |= unix.ECHO
+	} else {
+		termios.Lflag &^= unix.ECHO
+	}
+	if err := unix.IoctlSetTermios(fd, unix.TCSETS, termios); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEchoDetectionFollowsTermios(t *testing.T) {
+	master, tty, err := pty.Open()
+	if err != nil {
+		t.Skipf("no PTY available: %v", err)
+	}
+	defer func() {
+		if err := master.Close(); err != nil {
+			t.Logf("Failed to close PTY master: %v", err)
+		}
+		if err := tty.Close(); err != nil {
+			t.Logf("Failed to close PTY slave: %v", err)
+		}
+	}()
+
+	// The program changes echo on its side; the server reads the master
+	for _, on := range []bool{true, false, true} {
+		setEcho(t, int(tty.Fd()), on)
+		echo, err := isEchoEnabled(int(master.Fd()))
+		if err != nil {
+			t.Fatalf("isEchoEnabled: %v", err)
+		}
+		if echo != on {
+			t.Errorf("after setting echo to %v, detected %v", on, echo)
+		}
+	}
+}
+
+func TestSessionEchoState(t *testing.T) {
+	m := NewManager(t.TempDir())
+	sess, err := m.CreateSession(Config{
+		Cmdline: []string{"/bin/sh", "-c", `stty -echo; echo no-echo; read line; stty echo; echo echo; read line`},
+	})
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	defer func() {
+		if sess.IsAlive() {
+			if err := sess.Kill(); err != nil {
+				t.Logf("Failed to kill session: %v", err)
+			}
+		}
+		sess.Wait()
+	}()
+
+	waitForEcho := func(want bool) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for sess.IsEchoEnabled() != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("IsEchoEnabled() = %v, want %v", !want, want)
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+
+	waitForOutput(t, sess, "no-echo")
+	waitForEcho(false)
+	if err := sess.SendText("\n"); err != nil {
+		t.Fatalf("SendText: %v", err)
+	}
+	waitForOutput(t, sess, `echo\r\n`)
+	waitForEcho(true)
+}
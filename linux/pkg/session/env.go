@@ -0,0 +1,125 @@
+package session
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// DefaultEnvAllowlist lists the host environment variables passed through to
+// session processes when no allowlist is configured. Entries are shell globs
+// (see envNameMatches), so "LC_*" matches every locale variable.
+var DefaultEnvAllowlist = []string{"TERM", "SHELL", "LANG", "LC_ALL", "PATH", "USER", "HOME", "LC_*"}
+
+// secretEnvPatterns match the names of variables that usually hold
+// credentials, such as GITHUB_TOKEN, DB_PASSWORD or AWS_SECRET_ACCESS_KEY
+var secretEnvPatterns = []string{
+	"*_TOKEN", "*_SECRET", "*_PASSWORD", "*_PASSWD", "PASSWORD*",
+	"*_KEY", "*_PASSPHRASE", "*_CREDENTIALS",
+}
+
+// DefaultEnvBlocklist lists the variables withheld from sessions that inherit
+// the full host environment. TTY_SESSION_ID is excluded so a nested
+// vibetunnel doesn't mistake itself for a spawned session.
+var DefaultEnvBlocklist = append(append([]string(nil), secretEnvPatterns...), "TTY_SESSION_ID")
+
+// DefaultEnvRedact lists the variable name patterns whose values are hidden
+// in recordings and API responses when no redaction list is configured. It
+// covers the same secrets as DefaultEnvBlocklist.
+var DefaultEnvRedact = append([]string(nil), secretEnvPatterns...)
+
+// RedactedValue replaces the value of redacted environment variables
+const RedactedValue = "[REDACTED]"
+
+// envNameMatches reports whether a variable name matches any of the patterns.
+// Patterns use shell glob syntax, so '*' matches any run of characters.
+func envNameMatches(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, err := path.Match(pattern, name); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// filterEnv returns the KEY=VALUE entries of environ whose names match the allowlist
+func filterEnv(environ []string, allowlist []string) []string {
+	env := make([]string, 0)
+	for _, v := range environ {
+		name, _, ok := strings.Cut(v, "=")
+		if ok && envNameMatches(name, allowlist) {
+			env = append(env, v)
+		}
+	}
+	return env
+}
+
+// blockEnv returns the KEY=VALUE entries of environ whose names don't match
+// the blocklist
+func blockEnv(environ []string, blocklist []string) []string {
+	env := make([]string, 0, len(environ))
+	for _, v := range environ {
+		name, _, ok := strings.Cut(v, "=")
+		if ok && !envNameMatches(name, blocklist) {
+			env = append(env, v)
+		}
+	}
+	return env
+}
+
+// RedactEnv returns a copy of env with the values of variables matching any
+// of the patterns replaced by RedactedValue. The original map is unchanged.
+func RedactEnv(env map[string]string, patterns []string) map[string]string {
+	if env == nil {
+		return nil
+	}
+	redacted := make(map[string]string, len(env))
+	for name, value := range env {
+		if envNameMatches(name, patterns) {
+			value = RedactedValue
+		}
+		redacted[name] = value
+	}
+	return redacted
+}
+
+// envSliceToMap converts KEY=VALUE entries to a map, returning nil when empty.
+// Later entries win over earlier ones with the same key.
+func envSliceToMap(env []string) map[string]string {
+	if len(env) == 0 {
+		return nil
+	}
+	m := make(map[string]string, len(env))
+	for _, v := range env {
+		if name, value, ok := strings.Cut(v, "="); ok && name != "" {
+			m[name] = value
+		}
+	}
+	return m
+}
+
+// mergeEnv returns env with every variable in overrides replaced or added.
+// Overrides are appended in sorted order so the result is deterministic.
+func mergeEnv(env []string, overrides map[string]string) []string {
+	if len(overrides) == 0 {
+		return env
+	}
+
+	merged := make([]string, 0, len(env)+len(overrides))
+	for _, v := range env {
+		name, _, _ := strings.Cut(v, "=")
+		if _, ok := overrides[name]; !ok {
+			merged = append(merged, v)
+		}
+	}
+
+	names := make([]string, 0, len(overrides))
+	for name := range overrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		merged = append(merged, name+"="+overrides[name])
+	}
+	return merged
+}
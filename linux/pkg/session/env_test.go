@@ -0,0 +1,114 @@
+package session
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFilterEnvMatchesGlobs(t *testing.T) {
+	environ := []string{
+		"PATH=/usr/bin",
+		"LC_TIME=C",
+		"LC_ALL=C.UTF-8",
+		"LANG=en_US.UTF-8",
+		"LANGUAGE=en",
+		"GITHUB_TOKEN=secret",
+	}
+	got := filterEnv(environ, DefaultEnvAllowlist)
+	want := []string{"PATH=/usr/bin", "LC_TIME=C", "LC_ALL=C.UTF-8", "LANG=en_US.UTF-8"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("filterEnv = %v, want %v", got, want)
+	}
+
+	// '*' and '?' may appear anywhere, not only at the end
+	got = filterEnv(environ, []string{"*_TOKEN", "LAN?"})
+	want = []string{"LANG=en_US.UTF-8", "GITHUB_TOKEN=secret"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("filterEnv = %v, want %v", got, want)
+	}
+}
+
+func TestDefaultRedactCoversBlocklist(t *testing.T) {
+	env := map[string]string{
+		"GITHUB_TOKEN":          "t",
+		"DB_PASSWORD":           "p",
+		"AWS_SECRET_ACCESS_KEY": "k",
+		"GPG_PASSPHRASE":        "g",
+		"EDITOR":                "vim",
+	}
+	redacted := RedactEnv(env, DefaultEnvRedact)
+	for name := range env {
+		secret := envNameMatches(name, DefaultEnvBlocklist)
+		if got := redacted[name] == RedactedValue; got != secret {
+			t.Errorf("%s redacted = %v, but blocked = %v", name, got, secret)
+		}
+	}
+	if redacted["EDITOR"] != "vim" {
+		t.Errorf("EDITOR = %q, want it kept", redacted["EDITOR"])
+	}
+	if env["GITHUB_TOKEN"] != "t" {
+		t.Error("RedactEnv changed its input")
+	}
+}
+
+func TestInheritedEnvReachesChild(t *testing.T) {
+	t.Setenv("MY_VAR", "from-parent")
+	t.Setenv("GITHUB_TOKEN", "secret")
+	t.Setenv("DB_PASSWORD", "secret")
+	t.Setenv("TTY_SESSION_ID", "parent-session")
+
+	tests := []struct {
+		name    string
+		config  Config
+		want    []string
+		notWant []string
+	}{
+		{
+			name:    "allowlist",
+			config:  Config{},
+			want:    []string{"PATH="},
+			notWant: []string{"MY_VAR=", "GITHUB_TOKEN=", "DB_PASSWORD=", "TTY_SESSION_ID="},
+		},
+		{
+			name:    "inherit",
+			config:  Config{InheritEnv: true},
+			want:    []string{"PATH=", "MY_VAR=from-parent"},
+			notWant: []string{"GITHUB_TOKEN=", "DB_PASSWORD=", "TTY_SESSION_ID="},
+		},
+		{
+			name:    "inherit with blocklist",
+			config:  Config{InheritEnv: true, EnvBlocklist: []string{"MY_*"}},
+			want:    []string{"PATH=", "GITHUB_TOKEN=secret", "TTY_SESSION_ID=parent-session"},
+			notWant: []string{"MY_VAR="},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The header doesn't list inherited variables, so only the
+			// child's own output can match
+			config := tt.config
+			config.Cmdline = []string{"env"}
+			sess, err := NewManager(t.TempDir()).CreateSession(config)
+			if err != nil {
+				t.Fatalf("CreateSession: %v", err)
+			}
+			sess.Wait()
+			output, err := os.ReadFile(sess.StreamOutPath())
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, v := range tt.want {
+				if !strings.Contains(string(output), v) {
+					t.Errorf("child environment has no %s", v)
+				}
+			}
+			for _, v := range tt.notWant {
+				if strings.Contains(string(output), v) {
+					t.Errorf("child environment has %s", v)
+				}
+			}
+		})
+	}
+}
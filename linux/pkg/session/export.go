@@ -0,0 +1,450 @@
+package session
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ExportVersion is the bundle version Export produces and Import accepts
+const ExportVersion = 2
+
+// ErrInvalidExport matches errors for bundles Import can't restore
+var ErrInvalidExport = errors.New("invalid session export")
+
+// ErrSessionExists matches errors for imported sessions whose IDs are
+// already taken; use errors.As with *ImportConflictError to get the IDs
+var ErrSessionExists = errors.New("session already exists")
+
+// ImportConflictError lists the sessions in a bundle that already exist
+type ImportConflictError struct {
+	IDs []string
+}
+
+func (e *ImportConflictError) Error() string {
+	return fmt.Sprintf("sessions already exist: %s", strings.Join(e.IDs, ", "))
+}
+
+func (e *ImportConflictError) Is(target error) bool {
+	return target == ErrSessionExists
+}
+
+// ExportBundle is the format of an export: the metadata and recordings of
+// every session, for moving them to another server. Processes are not
+// carried over. Export and Import stream it rather than holding it in memory.
+type ExportBundle struct {
+	Version    int               `json:"version"`
+	ExportedAt time.Time         `json:"exportedAt"`
+	Sessions   []ExportedSession `json:"sessions"`
+}
+
+// ExportedSession is one session in an ExportBundle
+type ExportedSession struct {
+	// Session is the session.json as stored in the control directory, with
+	// sensitive environment values redacted
+	Session json.RawMessage `json:"session"`
+	// RecordingPath is where the recording was kept on the exporting host
+	RecordingPath string `json:"recordingPath"`
+	// Recording is the asciinema stream-out line by line, so it can be read
+	// and written a line at a time; empty if it wasn't included
+	Recording []string `json:"recording,omitempty"`
+}
+
+// Export writes an ExportBundle of every session in the control directory to
+// w. Only the current recording segment is included, and only with
+// includeRecordings; recordings are copied a line at a time. An error after
+// writing has started leaves the bundle truncated, which Import rejects.
+func (m *Manager) Export(w io.Writer, includeRecordings bool) error {
+	entries, err := os.ReadDir(m.controlPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	exportedAt, err := json.Marshal(time.Now())
+	if err != nil {
+		return err
+	}
+	out := bufio.NewWriter(w)
+	if _, err := fmt.Fprintf(out, `{"version":%d,"exportedAt":%s,"sessions":[`, ExportVersion, exportedAt); err != nil {
+		return err
+	}
+
+	first := true
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		sessionPath := filepath.Join(m.controlPath, entry.Name())
+		info, err := m.exportedInfo(filepath.Join(sessionPath, "session.json"))
+		if err != nil {
+			debugLog("[DEBUG] Skipping %s in export: %v", entry.Name(), err)
+			continue
+		}
+
+		if !first {
+			if err := out.WriteByte(','); err != nil {
+				return err
+			}
+		}
+		first = false
+		recordingPath := filepath.Join(sessionPath, "stream-out")
+		quotedPath, err := json.Marshal(recordingPath)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(out, `{"session":%s,"recordingPath":%s`, info, quotedPath); err != nil {
+			return err
+		}
+		if includeRecordings {
+			if err := exportRecording(out, recordingPath); err != nil {
+				return fmt.Errorf("failed to export recording of %s: %w", entry.Name(), err)
+			}
+		}
+		if err := out.WriteByte('}'); err != nil {
+			return err
+		}
+	}
+
+	if _, err := out.WriteString("]}\n"); err != nil {
+		return err
+	}
+	return out.Flush()
+}
+
+// exportedInfo reads a session.json for export. Bundles are downloadable,
+// so sensitive environment values are redacted as in recording headers;
+// other fields are passed through as stored.
+func (m *Manager) exportedInfo(path string) (json.RawMessage, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("invalid session.json: %w", err)
+	}
+	if raw, ok := fields["env"]; ok {
+		var env map[string]string
+		if err := json.Unmarshal(raw, &env); err != nil {
+			return nil, fmt.Errorf("invalid env in session.json: %w", err)
+		}
+		if fields["env"], err = json.Marshal(m.RedactEnv(env)); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(fields)
+}
+
+// exportRecording writes the recording at path to out as the recording
+// field of an ExportedSession, one line at a time
+func exportRecording(out *bufio.Writer, path string) error {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Printf("[WARN] Failed to close recording %s: %v", path, err)
+		}
+	}()
+
+	if _, err := out.WriteString(`,"recording":[`); err != nil {
+		return err
+	}
+	reader := bufio.NewReader(file)
+	for first := true; ; first = false {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if line == "" {
+			break
+		}
+		if !first {
+			if err := out.WriteByte(','); err != nil {
+				return err
+			}
+		}
+		data, err := json.Marshal(strings.TrimSuffix(line, "\n"))
+		if err != nil {
+			return err
+		}
+		if _, err := out.Write(data); err != nil {
+			return err
+		}
+	}
+	_, err = out.WriteString("]")
+	return err
+}
+
+// importedSession is a session read from a bundle and staged on disk
+type importedSession struct {
+	info *RustSessionInfo
+	dir  string // Staging directory holding its stream-out
+}
+
+// Import restores the sessions in the bundle read from r to the control
+// directory, marked exited. Unless overwrite is set, nothing is imported if
+// any of their IDs are taken; running sessions are never replaced. Each
+// session is staged on disk as it's read, so only one recording line is
+// held in memory at a time. Returns the imported IDs.
+func (m *Manager) Import(r io.Reader, overwrite bool) ([]string, error) {
+	if err := os.MkdirAll(m.controlPath, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create control directory: %w", err)
+	}
+	staging, err := os.MkdirTemp(m.controlPath, ".import-*")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		// Only what wasn't moved into place is left to remove
+		if err := os.RemoveAll(staging); err != nil {
+			log.Printf("[WARN] Failed to remove temporary import %s: %v", staging, err)
+		}
+	}()
+
+	// Read and validate everything before importing anything
+	sessions, err := readBundle(json.NewDecoder(r), staging)
+	if err != nil {
+		return nil, err
+	}
+
+	var conflicts []string
+	for _, imported := range sessions {
+		id := imported.info.ID
+		if _, err := os.Stat(filepath.Join(m.controlPath, id)); err == nil {
+			if !overwrite {
+				conflicts = append(conflicts, id)
+			} else if existing, err := loadSession(m.controlPath, id); err == nil && existing.IsAlive() {
+				conflicts = append(conflicts, id)
+			}
+		}
+	}
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return nil, &ImportConflictError{IDs: conflicts}
+	}
+
+	ids := make([]string, 0, len(sessions))
+	for _, imported := range sessions {
+		if err := m.importSession(imported); err != nil {
+			return ids, fmt.Errorf("failed to import session %s: %w", imported.info.ID, err)
+		}
+		ids = append(ids, imported.info.ID)
+	}
+	return ids, nil
+}
+
+// importSession moves a staged session into place, marked exited
+func (m *Manager) importSession(imported *importedSession) error {
+	info := imported.info
+	// The processes stayed behind on the exporting host, so these sessions
+	// can only be replayed
+	info.Status = string(StatusExited)
+	info.Pid = nil
+	info.SpawnType = SpawnTypePlayback
+
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(imported.dir, "session.json"), data, 0600); err != nil {
+		return err
+	}
+
+	sessionPath := filepath.Join(m.controlPath, info.ID)
+	if err := os.RemoveAll(sessionPath); err != nil {
+		return err
+	}
+	return os.Rename(imported.dir, sessionPath)
+}
+
+// readBundle reads an ExportBundle from dec, staging each session in its
+// own directory under staging. The version must come before the sessions.
+func readBundle(dec *json.Decoder, staging string) ([]*importedSession, error) {
+	if err := expectDelim(dec, '{'); err != nil {
+		return nil, err
+	}
+	version := 0
+	var sessions []*importedSession
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidExport, err)
+		}
+		switch key {
+		case "version":
+			if err := dec.Decode(&version); err != nil {
+				return nil, fmt.Errorf("%w: version: %w", ErrInvalidExport, err)
+			}
+		case "sessions":
+			if version != ExportVersion {
+				return nil, fmt.Errorf("%w: version %d, expected %d", ErrInvalidExport, version, ExportVersion)
+			}
+			if sessions, err = readSessions(dec, staging); err != nil {
+				return nil, err
+			}
+		default:
+			var skipped json.RawMessage
+			if err := dec.Decode(&skipped); err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrInvalidExport, err)
+			}
+		}
+	}
+	if err := expectDelim(dec, '}'); err != nil {
+		return nil, err
+	}
+	if version != ExportVersion {
+		return nil, fmt.Errorf("%w: version %d, expected %d", ErrInvalidExport, version, ExportVersion)
+	}
+	return sessions, nil
+}
+
+// readSessions reads the sessions array of a bundle
+func readSessions(dec *json.Decoder, staging string) ([]*importedSession, error) {
+	if err := expectDelim(dec, '['); err != nil {
+		return nil, err
+	}
+	var sessions []*importedSession
+	seen := make(map[string]bool)
+	for i := 0; dec.More(); i++ {
+		imported, err := readSession(dec, filepath.Join(staging, strconv.Itoa(i)))
+		if err != nil {
+			return nil, fmt.Errorf("session %d: %w", i, err)
+		}
+		id := imported.info.ID
+		if seen[id] {
+			return nil, fmt.Errorf("%w: session %s appears twice", ErrInvalidExport, id)
+		}
+		seen[id] = true
+		sessions = append(sessions, imported)
+	}
+	if err := expectDelim(dec, ']'); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// readSession reads one ExportedSession, writing its recording to dir
+func readSession(dec *json.Decoder, dir string) (*importedSession, error) {
+	if err := expectDelim(dec, '{'); err != nil {
+		return nil, err
+	}
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return nil, err
+	}
+	imported := &importedSession{dir: dir}
+	recordingPath := filepath.Join(dir, "stream-out")
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidExport, err)
+		}
+		switch key {
+		case "session":
+			imported.info = &RustSessionInfo{}
+			if err := dec.Decode(imported.info); err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrInvalidExport, err)
+			}
+			// IDs become directory names, so only UUIDs are accepted
+			if _, err := uuid.Parse(imported.info.ID); err != nil {
+				return nil, fmt.Errorf("%w: invalid id %q", ErrInvalidExport, imported.info.ID)
+			}
+		case "recording":
+			if err := readRecording(dec, recordingPath); err != nil {
+				return nil, err
+			}
+		default:
+			var skipped json.RawMessage
+			if err := dec.Decode(&skipped); err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrInvalidExport, err)
+			}
+		}
+	}
+	if err := expectDelim(dec, '}'); err != nil {
+		return nil, err
+	}
+	if imported.info == nil {
+		return nil, fmt.Errorf("%w: no session metadata", ErrInvalidExport)
+	}
+
+	// Sessions exported without a recording get an empty one
+	file, err := os.OpenFile(recordingPath, os.O_RDONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Printf("[WARN] Failed to close imported recording: %v", err)
+		}
+	}()
+	if stat, err := file.Stat(); err != nil {
+		return nil, err
+	} else if stat.Size() > 0 {
+		if _, err := scanRecording(file); err != nil {
+			return nil, fmt.Errorf("%w: session %s: %v", ErrInvalidExport, imported.info.ID, err)
+		}
+	}
+	return imported, nil
+}
+
+// readRecording writes the lines of a recording field to path as they're read
+func readRecording(dec *json.Decoder, path string) (err error) {
+	if err := expectDelim(dec, '['); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	out := bufio.NewWriter(file)
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("%w: recording: %w", ErrInvalidExport, err)
+		}
+		line, ok := token.(string)
+		if !ok {
+			return fmt.Errorf("%w: recording line %v isn't a string", ErrInvalidExport, token)
+		}
+		if _, err := out.WriteString(line + "\n"); err != nil {
+			return err
+		}
+	}
+	if err := expectDelim(dec, ']'); err != nil {
+		return err
+	}
+	return out.Flush()
+}
+
+// expectDelim reads the next token from dec, which must be delim
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidExport, err)
+	}
+	if token != delim {
+		return fmt.Errorf("%w: found %v where %v was expected", ErrInvalidExport, token, delim)
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package session
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const exportedID = "5d9e8c0e-6c4f-4d3e-9a53-0a8f3c1c2b11"
+
+// exportedRecording has the quotes, escapes and non-ASCII output that the
+// bundle has to carry through unchanged
+const exportedRecording = `{"version":2,"width":80,"height":24}
+[0.1,"o","say \"hi\"\r\n\u001b[1mbold\u001b[0m"]
+[0.2,"o","café €5 \\ done"]
+`
+
+// saveExportedSession stores a session to export in m's control directory
+func saveExportedSession(t *testing.T, m *Manager, env map[string]string) {
+	t.Helper()
+	dir := filepath.Join(m.controlPath, exportedID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	info := &Info{ID: exportedID, Name: "exported", Args: []string{"bash"}, Status: string(StatusExited), Env: env}
+	if err := info.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "stream-out"), []byte(exportedRecording), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExportRedactsEnv(t *testing.T) {
+	m := NewManager(t.TempDir())
+	saveExportedSession(t, m, map[string]string{"GITHUB_TOKEN": "secret", "TERM": "xterm"})
+
+	var buf bytes.Buffer
+	if err := m.Export(&buf, false); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	if strings.Contains(buf.String(), "secret") {
+		t.Errorf("export contains the token: %s", buf.String())
+	}
+
+	var bundle ExportBundle
+	if err := json.Unmarshal(buf.Bytes(), &bundle); err != nil {
+		t.Fatalf("invalid bundle: %v", err)
+	}
+	if len(bundle.Sessions) != 1 {
+		t.Fatalf("exported %d sessions, want 1", len(bundle.Sessions))
+	}
+	var info RustSessionInfo
+	if err := json.Unmarshal(bundle.Sessions[0].Session, &info); err != nil {
+		t.Fatal(err)
+	}
+	if info.Env["GITHUB_TOKEN"] != RedactedValue || info.Env["TERM"] != "xterm" {
+		t.Errorf("exported env = %v, want the token redacted and TERM kept", info.Env)
+	}
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	from := NewManager(t.TempDir())
+	saveExportedSession(t, from, nil)
+	to := NewManager(t.TempDir())
+
+	// Piped, as a download is posted to the other server
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(from.Export(writer, true))
+	}()
+	ids, err := to.Import(reader, false)
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if len(ids) != 1 || ids[0] != exportedID {
+		t.Fatalf("imported %v, want [%s]", ids, exportedID)
+	}
+
+	sess, err := to.GetSession(exportedID)
+	if err != nil {
+		t.Fatalf("GetSession: %v", err)
+	}
+	recording, err := os.ReadFile(sess.StreamOutPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(recording) != exportedRecording {
+		t.Errorf("imported recording %q, want %q", recording, exportedRecording)
+	}
+	if !sess.IsPlayback() {
+		t.Error("imported session is not playback")
+	}
+
+	// Nothing is left staged in the control directory
+	entries, err := os.ReadDir(to.controlPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("control directory holds %d entries, want only the session", len(entries))
+	}
+}
+
+func TestImportRejectsInvalidBundles(t *testing.T) {
+	m := NewManager(t.TempDir())
+	var buf bytes.Buffer
+	saveExportedSession(t, m, nil)
+	if err := m.Export(&buf, true); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	valid := buf.String()
+
+	tests := []struct {
+		name   string
+		bundle string
+	}{
+		{"old version", `{"version":1,"sessions":[]}`},
+		{"no version", `{"sessions":[]}`},
+		{"truncated", valid[:len(valid)/2]},
+		{"invalid id", `{"version":2,"sessions":[{"session":{"id":"../escape"}}]}`},
+		{"no metadata", `{"version":2,"sessions":[{"recording":[]}]}`},
+		{"invalid recording", `{"version":2,"sessions":[{"session":{"id":"` + exportedID + `"},"recording":["not a header"]}]}`},
+		{"recording line not a string", `{"version":2,"sessions":[{"session":{"id":"` + exportedID + `"},"recording":[1]}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			to := NewManager(t.TempDir())
+			if _, err := to.Import(strings.NewReader(tt.bundle), false); !errors.Is(err, ErrInvalidExport) {
+				t.Errorf("Import = %v, want ErrInvalidExport", err)
+			}
+			entries, err := os.ReadDir(to.controlPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 0 {
+				t.Errorf("rejected import left %d entries behind", len(entries))
+			}
+		})
+	}
+}
+
+func TestImportConflicts(t *testing.T) {
+	m := NewManager(t.TempDir())
+	saveExportedSession(t, m, nil)
+	var buf bytes.Buffer
+	if err := m.Export(&buf, true); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+
+	var conflict *ImportConflictError
+	if _, err := m.Import(bytes.NewReader(buf.Bytes()), false); !errors.As(err, &conflict) || conflict.IDs[0] != exportedID {
+		t.Errorf("Import over an existing session = %v, want a conflict for %s", err, exportedID)
+	}
+	if _, err := m.Import(bytes.NewReader(buf.Bytes()), true); err != nil {
+		t.Errorf("Import with overwrite: %v", err)
+	}
+}
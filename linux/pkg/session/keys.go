@@ -0,0 +1,57 @@
+package session
+
+import "strings"
+
+// keySequences maps key names to the bytes a terminal (xterm, in normal
+// cursor key mode) sends for them
+var keySequences = map[string]string{
+	// The keys of the Swift/macOS version
+	"arrow_up":    "\x1b[A",
+	"arrow_down":  "\x1b[B",
+	"arrow_right": "\x1b[C",
+	"arrow_left":  "\x1b[D",
+	"escape":      "\x1b",
+	"enter":       "\r",       // CR, not LF (to match Swift)
+	"ctrl_enter":  "\r",       // CR for ctrl+enter
+	"shift_enter": "\x1b\x0d", // ESC + CR for shift+enter
+
+	"tab":            "\t",
+	"shift_tab":      "\x1b[Z",
+	"backspace":      "\x7f",
+	"delete":         "\x1b[3~",
+	"insert":         "\x1b[2~",
+	"home":           "\x1b[H",
+	"end":            "\x1b[F",
+	"page_up":        "\x1b[5~",
+	"page_down":      "\x1b[6~",
+	"ctrl_space":     "\x00",
+	"ctrl_backslash": "\x1c", // SIGQUIT
+
+	"f1":  "\x1bOP",
+	"f2":  "\x1bOQ",
+	"f3":  "\x1bOR",
+	"f4":  "\x1bOS",
+	"f5":  "\x1b[15~",
+	"f6":  "\x1b[17~",
+	"f7":  "\x1b[18~",
+	"f8":  "\x1b[19~",
+	"f9":  "\x1b[20~",
+	"f10": "\x1b[21~",
+	"f11": "\x1b[23~",
+	"f12": "\x1b[24~",
+}
+
+func init() {
+	// ctrl_a to ctrl_z send 0x01 to 0x1a, e.g. ctrl_c (0x03) interrupts,
+	// ctrl_d (0x04) ends input and ctrl_z (0x1a) suspends
+	for c := byte('a'); c <= 'z'; c++ {
+		keySequences["ctrl_"+string(c)] = string([]byte{c - 'a' + 1})
+	}
+}
+
+// KeySequence returns the bytes to send for a named key such as "ctrl_c",
+// "f5" or "arrow_up". Names are case-insensitive.
+func KeySequence(name string) (string, bool) {
+	seq, ok := keySequences[strings.ToLower(name)]
+	return seq, ok
+}
@@ -0,0 +1,49 @@
+package session
+
+import "testing"
+
+func TestKeySequences(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"ctrl_a", "\x01"},
+		{"ctrl_c", "\x03"},
+		{"ctrl_d", "\x04"},
+		{"ctrl_l", "\x0c"},
+		{"ctrl_z", "\x1a"},
+		{"ctrl_space", "\x00"},
+		{"ctrl_backslash", "\x1c"},
+		{"CTRL_C", "\x03"},
+		{"f1", "\x1bOP"},
+		{"f2", "\x1bOQ"},
+		{"f3", "\x1bOR"},
+		{"f4", "\x1bOS"},
+		{"f5", "\x1b[15~"},
+		{"f6", "\x1b[17~"},
+		{"f7", "\x1b[18~"},
+		{"f8", "\x1b[19~"},
+		{"f9", "\x1b[20~"},
+		{"f10", "\x1b[21~"},
+		{"f11", "\x1b[23~"},
+		{"f12", "\x1b[24~"},
+		{"arrow_up", "\x1b[A"},
+		{"enter", "\r"},
+		{"shift_tab", "\x1b[Z"},
+		{"backspace", "\x7f"},
+		{"delete", "\x1b[3~"},
+		{"page_down", "\x1b[6~"},
+	}
+	for _, tt := range tests {
+		got, ok := KeySequence(tt.key)
+		if !ok || got != tt.want {
+			t.Errorf("KeySequence(%q) = %q, %v; want %q", tt.key, got, ok, tt.want)
+		}
+	}
+
+	for _, key := range []string{"", "ctrl_", "ctrl_1", "f13", "ctrl+c"} {
+		if seq, ok := KeySequence(key); ok {
+			t.Errorf("KeySequence(%q) = %q, want an unknown key", key, seq)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package session
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// sessionLogName is the file in the session directory holding its log
+const sessionLogName = "session.log"
+
+// LogPath returns the session's log file, where lifecycle events and, for
+// sessions run in their own process, that process's log output are written
+func (s *Session) LogPath() string {
+	return filepath.Join(s.Path(), sessionLogName)
+}
+
+// SessionLogPath returns the log file of session id in controlPath, for
+// processes that redirect their log output before loading the session
+func SessionLogPath(controlPath, id string) string {
+	return filepath.Join(controlPath, id, sessionLogName)
+}
+
+// logf appends a line to the session log in the format of the standard
+// logger, so lines written by a detached session process look the same
+func (s *Session) logf(format string, args ...interface{}) {
+	line := time.Now().Format("2006/01/02 15:04:05 ") + fmt.Sprintf(format, args...) + "\n"
+
+	// A single append per line keeps lines from interleaving with other writers
+	file, err := os.OpenFile(s.LogPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		log.Printf("[ERROR] Failed to open log of session %s: %v", s.ID[:8], err)
+		return
+	}
+	if _, err := file.WriteString(line); err != nil {
+		log.Printf("[ERROR] Failed to write log of session %s: %v", s.ID[:8], err)
+	}
+	if err := file.Close(); err != nil {
+		log.Printf("[ERROR] Failed to close session log: %v", err)
+	}
+}
+
+// ReadLog returns the complete lines of the session log written after
+// offset, a byte position in the log, and the offset to continue from. A
+// missing log has no lines yet.
+func (s *Session) ReadLog(offset int64) ([]string, int64, error) {
+	file, err := os.Open(s.LogPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, offset, nil
+		}
+		return nil, offset, err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Printf("[ERROR] Failed to close session log: %v", err)
+		}
+	}()
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return nil, offset, err
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, offset, err
+	}
+
+	var lines []string
+	for {
+		end := bytes.IndexByte(data, '\n')
+		if end < 0 {
+			break // Leave an incomplete line for the next read
+		}
+		lines = append(lines, string(data[:end]))
+		offset += int64(end + 1)
+		data = data[end+1:]
+	}
+	return lines, offset, nil
+}
+
+// TailLog returns the offset in the session log at which its last n lines
+// start, reading backwards from the end so large logs aren't read whole.
+// n <= 0 returns 0, the start of the log.
+func (s *Session) TailLog(n int) (int64, error) {
+	if n <= 0 {
+		return 0, nil
+	}
+	file, err := os.Open(s.LogPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Printf("[ERROR] Failed to close session log: %v", err)
+		}
+	}()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	// The trailing newline ends the last line rather than starting another
+	end := stat.Size()
+	last := make([]byte, 1)
+	if end > 0 {
+		if _, err := file.ReadAt(last, end-1); err != nil {
+			return 0, err
+		}
+		if last[0] == '\n' {
+			end--
+		}
+	}
+
+	buf := make([]byte, 32*1024)
+	for pos := end; pos > 0; {
+		size := int64(len(buf))
+		if pos < size {
+			size = pos
+		}
+		pos -= size
+		if _, err := file.ReadAt(buf[:size], pos); err != nil && err != io.EOF {
+			return 0, err
+		}
+		for i := size - 1; i >= 0; i-- {
+			if buf[i] != '\n' {
+				continue
+			}
+			if n--; n == 0 {
+				return pos + i + 1, nil
+			}
+		}
+	}
+	return 0, nil
+}
@@ -1,6 +1,7 @@
 package session
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -11,22 +12,169 @@ import (
 	"strings"
 	"sync"
 	"syscall"
+	"time"
+
+	"golang.org/x/term"
 )
 
 type Manager struct {
 	controlPath     string
 	runningSessions map[string]*Session
 	mutex           sync.RWMutex
+	envAllowlist    []string
+	envBlocklist    []string
+	envRedact       []string
+
+	maxRecordingSize int64
+	outputCoalesce   time.Duration
+	recordInput      bool
+	maxRuntime       time.Duration
+	webhook          *Webhook
+
+	defaultWidth  int
+	defaultHeight int
+	defaultTerm   string
 }
 
+// DefaultOutputCoalesce is the window within which output is merged into a
+// single recorded event
+const DefaultOutputCoalesce = 5 * time.Millisecond
+
 func NewManager(controlPath string) *Manager {
 	return &Manager{
 		controlPath:     controlPath,
 		runningSessions: make(map[string]*Session),
+		outputCoalesce:  DefaultOutputCoalesce,
 	}
 }
 
+// SetEnvAllowlist sets the host environment allowlist used for sessions
+// that don't specify their own
+func (m *Manager) SetEnvAllowlist(allowlist []string) {
+	m.envAllowlist = allowlist
+}
+
+// SetEnvBlocklist sets the variables withheld from sessions that inherit
+// the full host environment
+func (m *Manager) SetEnvBlocklist(blocklist []string) {
+	m.envBlocklist = blocklist
+}
+
+// SetEnvRedact sets the variable name patterns whose values are hidden in
+// recordings and API responses
+func (m *Manager) SetEnvRedact(patterns []string) {
+	m.envRedact = patterns
+}
+
+// SetMaxRecordingSize sets the stream-out size in bytes at which new sessions
+// rotate their recording. Zero or less, the default, disables rotation.
+func (m *Manager) SetMaxRecordingSize(size int64) {
+	m.maxRecordingSize = size
+}
+
+// SetDefaultSize sets the terminal size of sessions that don't specify
+// one. Zero keeps DefaultWidth or DefaultHeight.
+func (m *Manager) SetDefaultSize(cols, rows int) {
+	m.defaultWidth = cols
+	m.defaultHeight = rows
+}
+
+// SetDefaultTerm sets the TERM of sessions whose environment doesn't set
+// one. Empty keeps the host TERM.
+func (m *Manager) SetDefaultTerm(term string) {
+	m.defaultTerm = term
+}
+
+// SetOutputCoalesce sets the window within which new sessions merge output
+// into one recorded event. Zero or less records every read separately.
+func (m *Manager) SetOutputCoalesce(window time.Duration) {
+	m.outputCoalesce = window
+}
+
+// SetRecordInput makes new sessions record their input. Input typed while
+// echo is off, such as passwords, is never recorded.
+func (m *Manager) SetRecordInput(record bool) {
+	m.recordInput = record
+}
+
+// SetMaxRuntime kills new sessions once they have run for d. Sessions may
+// ask for a shorter limit but not a longer one. Zero or less is unlimited.
+func (m *Manager) SetMaxRuntime(d time.Duration) {
+	m.maxRuntime = d
+}
+
+// SetWebhook sets the webhook notified when sessions started by this
+// manager start and exit. nil disables notifications.
+func (m *Manager) SetWebhook(webhook *Webhook) {
+	m.webhook = webhook
+}
+
+// FlushWebhook waits briefly for pending webhook deliveries; call it before
+// the process exits
+func (m *Manager) FlushWebhook() {
+	if m.webhook != nil {
+		m.webhook.Flush()
+	}
+}
+
+// RedactEnv hides the values of sensitive variables in env using the
+// manager's redaction patterns
+func (m *Manager) RedactEnv(env map[string]string) map[string]string {
+	patterns := m.envRedact
+	if patterns == nil {
+		patterns = DefaultEnvRedact
+	}
+	return RedactEnv(env, patterns)
+}
+
+// withDefaults fills in the manager's settings where config leaves them unset
+func (m *Manager) withDefaults(config Config) Config {
+	if config.EnvAllowlist == nil {
+		config.EnvAllowlist = m.envAllowlist
+	}
+	if config.EnvBlocklist == nil {
+		config.EnvBlocklist = m.envBlocklist
+	}
+	if config.EnvRedact == nil {
+		config.EnvRedact = m.envRedact
+	}
+	if config.MaxRecordingSize == 0 {
+		config.MaxRecordingSize = m.maxRecordingSize
+	}
+	if config.OutputCoalesce == 0 {
+		config.OutputCoalesce = m.outputCoalesce
+	}
+	if m.recordInput {
+		config.RecordInput = true
+	}
+	config.MaxRuntime = m.capMaxRuntime(config.MaxRuntime)
+	if config.Width <= 0 {
+		config.Width = m.defaultWidth
+	}
+	if config.Height <= 0 {
+		config.Height = m.defaultHeight
+	}
+	if config.Term == "" {
+		config.Term = m.defaultTerm
+	}
+	return config
+}
+
+// capMaxRuntime limits a session's requested max runtime to the manager's
+func (m *Manager) capMaxRuntime(d time.Duration) time.Duration {
+	if m.maxRuntime > 0 && (d <= 0 || d > m.maxRuntime) {
+		return m.maxRuntime
+	}
+	return d
+}
+
+// ControlPath returns the directory sessions are stored in
+func (m *Manager) ControlPath() string {
+	return m.controlPath
+}
+
 func (m *Manager) CreateSession(config Config) (*Session, error) {
+	config = m.withDefaults(config)
 	if err := os.MkdirAll(m.controlPath, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create control directory: %w", err)
 	}
@@ -35,6 +183,7 @@ func (m *Manager) CreateSession(config Config) (*Session, error) {
 	if err != nil {
 		return nil, err
 	}
+	session.webhook = m.webhook
 
 	if err := session.Start(); err != nil {
 		if removeErr := os.RemoveAll(session.Path()); removeErr != nil {
@@ -42,6 +191,7 @@ func (m *Manager) CreateSession(config Config) (*Session, error) {
 		}
 		return nil, err
 	}
+	session.notify(NotificationStarted)
 
 	// Add to running sessions registry
 	m.mutex.Lock()
@@ -52,6 +202,7 @@ func (m *Manager) CreateSession(config Config) (*Session, error) {
 }
 
 func (m *Manager) CreateSessionWithID(id string, config Config) (*Session, error) {
+	config = m.withDefaults(config)
 	if err := os.MkdirAll(m.controlPath, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create control directory: %w", err)
 	}
@@ -60,6 +211,7 @@ func (m *Manager) CreateSessionWithID(id string, config Config) (*Session, error
 	if err != nil {
 		return nil, err
 	}
+	session.webhook = m.webhook
 
 	if err := session.Start(); err != nil {
 		if removeErr := os.RemoveAll(session.Path()); removeErr != nil {
@@ -67,6 +219,7 @@ func (m *Manager) CreateSessionWithID(id string, config Config) (*Session, error
 		}
 		return nil, err
 	}
+	session.notify(NotificationStarted)
 
 	// Add to running sessions registry
 	m.mutex.Lock()
@@ -76,6 +229,69 @@ func (m *Manager) CreateSessionWithID(id string, config Config) (*Session, error
 	return session, nil
 }
 
+// PrepareSession saves a new session's session.json with status "starting"
+// without running its command, so another process can start it with
+// RunDetachedSession
+func (m *Manager) PrepareSession(config Config) (*Session, error) {
+	config = m.withDefaults(config)
+	if err := os.MkdirAll(m.controlPath, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create control directory: %w", err)
+	}
+
+	return newSession(m.controlPath, config)
+}
+
+// RunDetachedSession starts a session whose session.json was prepared by
+// another process, such as the Mac app or the server's native terminal
+// spawner, and blocks until it exits. When stdin is a terminal it is
+// attached to the session; otherwise input, resize and signals arrive only
+// through the session's stdin and control FIFOs.
+func (m *Manager) RunDetachedSession(id string) error {
+	session, err := loadSession(m.controlPath, id)
+	if err != nil {
+		return fmt.Errorf("failed to load session %s: %w", id, err)
+	}
+	if session.info.Status != string(StatusStarting) {
+		return fmt.Errorf("session %s is already %s", id, session.info.Status)
+	}
+
+	session.envAllowlist = m.envAllowlist
+	session.envBlocklist = m.envBlocklist
+	session.envRedact = m.envRedact
+	session.maxRecordingSize = m.maxRecordingSize
+	session.outputCoalesce = m.outputCoalesce
+	session.recordInput = m.recordInput
+	session.maxRuntime = m.capMaxRuntime(session.info.MaxRuntime)
+	session.webhook = m.webhook
+	session.argv0 = session.info.Argv0
+	session.inheritEnv = session.info.InheritEnv
+	if session.info.User != "" {
+		// Checked again here: this process may not be allowed to switch
+		session.user, err = lookupSessionUser(session.info.User)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := session.Start(); err != nil {
+		return err
+	}
+	session.notify(NotificationStarted)
+
+	m.mutex.Lock()
+	m.runningSessions[session.ID] = session
+	m.mutex.Unlock()
+
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		if err := session.Attach(); err != nil {
+			log.Printf("[ERROR] Failed to attach to session %s: %v", id[:8], err)
+		}
+	}
+
+	session.Wait()
+	return nil
+}
+
 func (m *Manager) GetSession(id string) (*Session, error) {
 	// First check if we have this session in our running sessions registry
 	m.mutex.RLock()
@@ -89,19 +305,42 @@ func (m *Manager) GetSession(id string) (*Session, error) {
 	return loadSession(m.controlPath, id)
 }
 
+// ErrSessionNotFound matches FindSession errors for a name or ID that no
+// session has
+var ErrSessionNotFound = errors.New("session not found")
+
+// ErrAmbiguousSession matches FindSession errors for a prefix shared by more
+// than one session
+var ErrAmbiguousSession = errors.New("ambiguous session name")
+
+// FindSession looks a session up by ID or name. An exact match wins;
+// otherwise nameOrID may be a prefix of one session's ID or name. Other
+// errors than ErrSessionNotFound and ErrAmbiguousSession mean the sessions
+// couldn't be read.
 func (m *Manager) FindSession(nameOrID string) (*Session, error) {
 	sessions, err := m.ListSessions()
 	if err != nil {
 		return nil, err
 	}
 
+	var matches []*Info
 	for _, s := range sessions {
-		if s.ID == nameOrID || s.Name == nameOrID || strings.HasPrefix(s.ID, nameOrID) {
+		if s.ID == nameOrID || s.Name == nameOrID {
 			return m.GetSession(s.ID)
 		}
+		if nameOrID != "" && (strings.HasPrefix(s.ID, nameOrID) || strings.HasPrefix(s.Name, nameOrID)) {
+			matches = append(matches, s)
+		}
 	}
 
-	return nil, fmt.Errorf("session not found: %s", nameOrID)
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("%w: %s", ErrSessionNotFound, nameOrID)
+	case 1:
+		return m.GetSession(matches[0].ID)
+	default:
+		return nil, fmt.Errorf("%w: %q matches %d sessions; use more of the name or ID", ErrAmbiguousSession, nameOrID, len(matches))
+	}
 }
 
 func (m *Manager) ListSessions() ([]*Info, error) {
@@ -135,6 +374,16 @@ func (m *Manager) ListSessions() ([]*Info, error) {
 			}
 		}
 
+		// Sessions running here know their activity better than the disk
+		m.mutex.RLock()
+		running, ok := m.runningSessions[session.ID]
+		m.mutex.RUnlock()
+		if ok {
+			session.info.LastActivity = running.LastActivity()
+		} else {
+			session.info.LastActivity = session.LastActivity()
+		}
+
 		sessions = append(sessions, session.info)
 	}
 
@@ -145,6 +394,26 @@ func (m *Manager) ListSessions() ([]*Info, error) {
 	return sessions, nil
 }
 
+// SessionCounts counts the sessions held in memory by state without reading
+// the control directory, so it's cheap enough for every health probe.
+// Sessions started by another process that this one hasn't looked up aren't
+// included.
+func (m *Manager) SessionCounts() (active, exited int) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	for _, session := range m.runningSessions {
+		session.mu.RLock()
+		status := session.info.Status
+		session.mu.RUnlock()
+		if status == string(StatusExited) {
+			exited++
+		} else {
+			active++
+		}
+	}
+	return active, exited
+}
+
 // CleanupExitedSessions now only updates session status to match Rust behavior
 // Use RemoveExitedSessions for actual cleanup
 func (m *Manager) CleanupExitedSessions() error {
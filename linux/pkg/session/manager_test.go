@@ -0,0 +1,220 @@
+package session
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// runPrepared prepares a session with config, runs it the way a spawned
+// terminal window would and returns its recorded output
+func runPrepared(t *testing.T, m *Manager, config Config) (*Info, string) {
+	t.Helper()
+	sess, err := m.PrepareSession(config)
+	if err != nil {
+		t.Fatalf("PrepareSession: %v", err)
+	}
+
+	// The window's process has its own manager, configured from the
+	// server's flags but not from the request
+	runner := NewManager(m.ControlPath())
+	if err := runner.RunDetachedSession(sess.ID); err != nil {
+		t.Fatalf("RunDetachedSession: %v", err)
+	}
+
+	info, err := LoadInfo(sess.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := os.ReadFile(filepath.Join(sess.Path(), "stream-out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info, string(output)
+}
+
+func TestPreparedSessionKeepsArgv0(t *testing.T) {
+	m := NewManager(t.TempDir())
+	_, output := runPrepared(t, m, Config{
+		Cmdline: []string{"/bin/sh", "-c", `echo "argv0=$0"`},
+		Argv0:   "custom-shell",
+	})
+	if !strings.Contains(output, "argv0=custom-shell") {
+		t.Errorf("output %q does not show the requested argv0", output)
+	}
+}
+
+func TestPreparedSessionKeepsInheritEnv(t *testing.T) {
+	t.Setenv("VT_TEST_INHERITED", "from-server")
+	m := NewManager(t.TempDir())
+	_, output := runPrepared(t, m, Config{
+		Cmdline:    []string{"/bin/sh", "-c", `echo "inherited=$VT_TEST_INHERITED"`},
+		InheritEnv: true,
+	})
+	if !strings.Contains(output, "inherited=from-server") {
+		t.Errorf("output %q does not show the inherited variable", output)
+	}
+}
+
+func TestPreparedSessionKeepsUser(t *testing.T) {
+	current, err := user.Current()
+	if err != nil {
+		t.Skipf("no current user: %v", err)
+	}
+	t.Setenv("USER", "someone-else")
+	m := NewManager(t.TempDir())
+	_, output := runPrepared(t, m, Config{
+		Cmdline: []string{"/bin/sh", "-c", `echo "user=$USER"`},
+		User:    current.Username,
+	})
+	if !strings.Contains(output, "user="+current.Username) {
+		t.Errorf("output %q does not show the session user", output)
+	}
+}
+
+func TestMaxRuntimeKillsSession(t *testing.T) {
+	m := NewManager(t.TempDir())
+	sess, err := m.CreateSession(Config{
+		Cmdline:    []string{"sleep", "10"},
+		MaxRuntime: 200 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	start := time.Now()
+	sess.Wait()
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("session ran for %v despite its max runtime", elapsed)
+	}
+	info, err := LoadInfo(sess.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ExitReason != ExitReasonMaxRuntime {
+		t.Errorf("exit reason = %q, want %q", info.ExitReason, ExitReasonMaxRuntime)
+	}
+}
+
+func TestPreparedSessionKeepsMaxRuntime(t *testing.T) {
+	m := NewManager(t.TempDir())
+	start := time.Now()
+	info, _ := runPrepared(t, m, Config{
+		Cmdline:    []string{"sleep", "10"},
+		MaxRuntime: 200 * time.Millisecond,
+	})
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("session ran for %v despite its max runtime", elapsed)
+	}
+	if info.ExitReason != ExitReasonMaxRuntime {
+		t.Errorf("exit reason = %q, want %q", info.ExitReason, ExitReasonMaxRuntime)
+	}
+}
+
+// waitForOutput waits until the session's recorded events contain want and
+// returns them. The header line, which holds the command line, is skipped.
+func waitForOutput(t *testing.T, sess *Session, want string) string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		recording, err := os.ReadFile(sess.StreamOutPath())
+		_, output, _ := strings.Cut(string(recording), "\n")
+		if err == nil && strings.Contains(output, want) {
+			return output
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("output %q does not contain %q", output, want)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestResizeAfterManagerRestart(t *testing.T) {
+	controlPath := t.TempDir()
+	owner := NewManager(controlPath)
+	sess, err := owner.CreateSession(Config{
+		Cmdline: []string{"/bin/sh", "-c", `echo ready; read line; echo "size=$(stty size)"`},
+		Width:   80,
+		Height:  24,
+	})
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	defer func() {
+		if sess.IsAlive() {
+			if err := sess.Kill(); err != nil {
+				t.Logf("Failed to kill session: %v", err)
+			}
+		}
+		sess.Wait()
+	}()
+	waitForOutput(t, sess, "ready")
+
+	// A restarted server has no PTY for the session and must go through
+	// the owning process
+	restarted := NewManager(controlPath)
+	loaded, err := restarted.GetSession(sess.ID)
+	if err != nil {
+		t.Fatalf("GetSession: %v", err)
+	}
+	if err := loaded.Resize(100, 40); err != nil {
+		t.Fatalf("Resize: %v", err)
+	}
+
+	// The owner applies the resize and saves it. The save rewrites the
+	// file in place, so a read can catch it half written.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		info, err := LoadInfo(sess.Path())
+		if err == nil && info.Width == 100 && info.Height == 40 {
+			break
+		}
+		if time.Now().After(deadline) {
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Fatalf("saved size = %dx%d, want 100x40", info.Width, info.Height)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if err := loaded.SendText("\n"); err != nil {
+		t.Fatalf("SendText: %v", err)
+	}
+	waitForOutput(t, sess, "size=40 100")
+}
+
+func TestDefaultSizeAndTerm(t *testing.T) {
+	m := NewManager(t.TempDir())
+	m.SetDefaultSize(80, 24)
+	m.SetDefaultTerm("vt100")
+	command := []string{"/bin/sh", "-c", `echo "term=$TERM size=$(stty size)"`}
+
+	tests := []struct {
+		name   string
+		config Config
+		want   string
+	}{
+		{"defaults", Config{Cmdline: command}, "term=vt100 size=24 80"},
+		{"requested", Config{Cmdline: command, Width: 100, Height: 40, Env: []string{"TERM=xterm"}}, "term=xterm size=40 100"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sess, err := m.CreateSession(tt.config)
+			if err != nil {
+				t.Fatalf("CreateSession: %v", err)
+			}
+			sess.Wait()
+			output, err := os.ReadFile(sess.StreamOutPath())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(output), tt.want) {
+				t.Errorf("output %q does not contain %q", output, tt.want)
+			}
+		})
+	}
+}
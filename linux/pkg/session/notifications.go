@@ -0,0 +1,185 @@
+package session
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+// Notification types written to a session's notification-stream
+const (
+	NotificationStarted = "started"
+	NotificationExited  = "exited"
+	NotificationBell    = "bell"
+)
+
+// bellDebounce is the minimum time between bell notifications of a session,
+// so a program beeping repeatedly doesn't flood clients
+const bellDebounce = time.Second
+
+// Notification is one JSON line of a session's notification-stream
+type Notification struct {
+	Type      string    `json:"type"`
+	SessionID string    `json:"sessionId"`
+	Timestamp time.Time `json:"timestamp"`
+	ExitCode  *int      `json:"exitCode,omitempty"` // Only set for exited
+}
+
+// notify appends a notification to the session's notification-stream. The
+// lifecycle notifications also go to the webhook, if one is configured.
+func (s *Session) notify(kind string) {
+	n := Notification{
+		Type:      kind,
+		SessionID: s.ID,
+		Timestamp: time.Now(),
+	}
+	if kind == NotificationExited {
+		s.mu.RLock()
+		n.ExitCode = s.info.ExitCode
+		s.mu.RUnlock()
+	}
+
+	data, err := json.Marshal(n)
+	if err != nil {
+		log.Printf("[ERROR] Failed to encode %s notification: %v", kind, err)
+		return
+	}
+
+	// Each notification is a single append, so readers never see a
+	// partially written line from an interleaved write
+	file, err := os.OpenFile(s.NotificationPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		log.Printf("[ERROR] Failed to open notification stream for session %s: %v", s.ID[:8], err)
+	} else {
+		if _, err := file.Write(append(data, '\n')); err != nil {
+			log.Printf("[ERROR] Failed to write %s notification for session %s: %v", kind, s.ID[:8], err)
+		}
+		if err := file.Close(); err != nil {
+			log.Printf("[ERROR] Failed to close notification stream: %v", err)
+		}
+	}
+
+	if kind != NotificationBell {
+		s.notifyWebhook(kind)
+	}
+}
+
+// ReadNotifications returns the notifications written after offset, which
+// is a byte position in the notification-stream, and the offset to continue
+// from. A missing stream has no notifications yet.
+func (s *Session) ReadNotifications(offset int64) ([]Notification, int64, error) {
+	return ReadNotificationStream(s.NotificationPath(), offset)
+}
+
+// ReadNotificationStream reads the notification-stream at path like
+// Session.ReadNotifications, for callers following sessions by directory
+// that may not have a session.json yet
+func ReadNotificationStream(path string, offset int64) ([]Notification, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, offset, nil
+		}
+		return nil, offset, err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Printf("[ERROR] Failed to close notification stream: %v", err)
+		}
+	}()
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return nil, offset, err
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, offset, err
+	}
+
+	var notifications []Notification
+	for {
+		end := bytes.IndexByte(data, '\n')
+		if end < 0 {
+			break // Leave an incomplete line for the next read
+		}
+		var n Notification
+		if err := json.Unmarshal(data[:end], &n); err != nil {
+			log.Printf("[ERROR] Skipping malformed notification in %s: %v", path, err)
+		} else {
+			notifications = append(notifications, n)
+		}
+		offset += int64(end + 1)
+		data = data[end+1:]
+	}
+	return notifications, offset, nil
+}
+
+// NotificationStreamSize returns the current size of the notification-stream,
+// the offset to read from to see only new notifications
+func (s *Session) NotificationStreamSize() int64 {
+	stat, err := os.Stat(s.NotificationPath())
+	if err != nil {
+		return 0
+	}
+	return stat.Size()
+}
+
+// bellDetector finds BEL characters that ring the bell in terminal output.
+// BEL also terminates OSC sequences, such as the window title updates many
+// shell prompts emit, so BELs inside control strings are not bells.
+type bellDetector struct {
+	state int
+}
+
+const (
+	bellGround    = iota
+	bellEscape    // After ESC
+	bellString    // Inside an OSC, DCS, APC, PM or SOS string
+	bellStringEsc // After ESC inside a string, usually ST (ESC \)
+)
+
+// scan reports whether data rings the bell. State carries over between
+// calls, so sequences split across reads are handled.
+func (d *bellDetector) scan(data string) bool {
+	rang := false
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch d.state {
+		case bellGround:
+			switch c {
+			case '\x07':
+				rang = true
+			case '\x1b':
+				d.state = bellEscape
+			}
+		case bellEscape:
+			switch c {
+			case ']', 'P', '_', '^', 'X':
+				d.state = bellString
+			case '\x1b':
+				// Still an escape
+			default:
+				d.state = bellGround
+			}
+		case bellString:
+			switch c {
+			case '\x07':
+				d.state = bellGround
+			case '\x1b':
+				d.state = bellStringEsc
+			}
+		case bellStringEsc:
+			if c == '\\' {
+				d.state = bellGround
+			} else {
+				// Any other escape sequence also ends the string
+				d.state = bellEscape
+				i--
+			}
+		}
+	}
+	return rang
+}
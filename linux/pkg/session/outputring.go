@@ -0,0 +1,114 @@
+package session
+
+import (
+	"sync"
+	"unicode/utf8"
+
+	"github.com/vibetunnel/linux/pkg/protocol"
+)
+
+// outputRingSize bounds the recent output kept in memory per running session
+const outputRingSize = 256 * 1024
+
+// outputRing keeps the most recent output events of a running session so tail
+// snapshots and reconnects can be served without reading stream-out
+type outputRing struct {
+	mu      sync.Mutex
+	events  []protocol.AsciinemaEvent
+	size    int   // Bytes of output data currently held
+	limit   int   // Maximum bytes of output data to hold
+	dropped bool  // Whether older output has been evicted
+	offset  int64 // stream-out offset just past the newest event
+}
+
+func newOutputRing(limit int) *outputRing {
+	return &outputRing{limit: limit}
+}
+
+// observe records an event written to stream-out; only output is kept
+func (r *outputRing) observe(event protocol.AsciinemaEvent, offset int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.offset = offset
+	if event.Type != protocol.EventOutput {
+		return
+	}
+
+	r.events = append(r.events, event)
+	r.size += len(event.Data)
+
+	// Evict whole events from the front, then trim the oldest remaining one
+	evict := 0
+	for r.size-len(r.events[evict].Data) >= r.limit && evict < len(r.events)-1 {
+		r.size -= len(r.events[evict].Data)
+		evict++
+	}
+	if evict > 0 {
+		r.events = r.events[evict:]
+		r.dropped = true
+	}
+	if r.size > r.limit {
+		first := &r.events[0]
+		trimmed := tailString(first.Data, len(first.Data)-(r.size-r.limit))
+		r.size -= len(first.Data) - len(trimmed)
+		first.Data = trimmed
+		r.dropped = true
+	}
+}
+
+// tail returns the last n bytes of output (all held output when n <= 0) and
+// the stream-out offset the events end at. ok is false when older output
+// needed to satisfy the request has already been evicted.
+func (r *outputRing) tail(n int) (events []protocol.AsciinemaEvent, offset int64, ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.dropped && (n <= 0 || n > r.size) {
+		return nil, 0, false
+	}
+	return TailOutputEvents(r.events, n), r.offset, true
+}
+
+// TailOutputEvents returns the output events covering the last n bytes of
+// output, trimming the oldest included event at a character boundary. Non
+// output events are skipped. n <= 0 returns all output events.
+func TailOutputEvents(events []protocol.AsciinemaEvent, n int) []protocol.AsciinemaEvent {
+	start := len(events)
+	remaining := n
+	for start > 0 && (n <= 0 || remaining > 0) {
+		start--
+		if events[start].Type == protocol.EventOutput {
+			remaining -= len(events[start].Data)
+		}
+	}
+
+	tail := make([]protocol.AsciinemaEvent, 0, len(events)-start)
+	for i := start; i < len(events); i++ {
+		if events[i].Type != protocol.EventOutput {
+			continue
+		}
+		event := events[i]
+		if i == start && n > 0 && remaining < 0 {
+			event.Data = tailString(event.Data, len(event.Data)+remaining)
+		}
+		tail = append(tail, event)
+	}
+	return tail
+}
+
+// tailString returns at most the last n bytes of s, advancing to the next
+// rune start so a multi-byte character is never split
+func tailString(s string, n int) string {
+	if n >= len(s) {
+		return s
+	}
+	if n <= 0 {
+		return ""
+	}
+	start := len(s) - n
+	for start < len(s) && !utf8.RuneStart(s[start]) {
+		start++
+	}
+	return s[start:]
+}
@@ -0,0 +1,93 @@
+package session
+
+import (
+	"strings"
+	"sync/atomic"
+)
+
+// Bracketed paste markers, sent around pasted text so programs that enabled
+// bracketed paste mode don't run each pasted line as it arrives
+const (
+	pasteStart = "\x1b[200~"
+	pasteEnd   = "\x1b[201~"
+)
+
+// maxPasteModeParams bounds the CSI parameters collected while looking for
+// mode 2004; longer parameter lists are not mode changes anyone sends
+const maxPasteModeParams = 64
+
+// pasteModeTracker follows whether the program in a session has turned on
+// bracketed paste mode (CSI ? 2004 h) by watching its output
+type pasteModeTracker struct {
+	enabled atomic.Bool
+
+	// Parser state, only touched by the output goroutine
+	state   int
+	private bool   // The CSI started with '?'
+	params  []byte // Parameter bytes of the current CSI
+}
+
+const (
+	pasteGround = iota
+	pasteEscape // After ESC
+	pasteCSI    // Inside a control sequence
+)
+
+// scan updates the mode from output. State carries over between calls, so
+// sequences split across reads are handled.
+func (t *pasteModeTracker) scan(data string) {
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch t.state {
+		case pasteGround:
+			if c == '\x1b' {
+				t.state = pasteEscape
+			}
+		case pasteEscape:
+			switch c {
+			case '[':
+				t.state = pasteCSI
+				t.private = false
+				t.params = t.params[:0]
+			case 'c':
+				// Full reset (RIS) turns the mode off
+				t.enabled.Store(false)
+				t.state = pasteGround
+			case '\x1b':
+				// Still an escape
+			default:
+				t.state = pasteGround
+			}
+		case pasteCSI:
+			switch {
+			case c == '?' && len(t.params) == 0:
+				t.private = true
+			case c >= '0' && c <= '9' || c == ';':
+				if len(t.params) < maxPasteModeParams {
+					t.params = append(t.params, c)
+				}
+			case c == '\x1b':
+				t.state = pasteEscape
+			case c >= 0x40 && c <= 0x7e:
+				if t.private && (c == 'h' || c == 'l') {
+					for _, param := range strings.Split(string(t.params), ";") {
+						if param == "2004" {
+							t.enabled.Store(c == 'h')
+						}
+					}
+				}
+				t.state = pasteGround
+			case c >= 0x20:
+				// Intermediate bytes make it some other sequence
+				t.private = false
+			}
+		}
+	}
+}
+
+// bracketPaste wraps text in paste markers. An end marker inside the text
+// is dropped, so pasted text can't end the paste early and have the rest
+// run as typed input.
+func bracketPaste(text string) string {
+	return pasteStart + strings.ReplaceAll(text, pasteEnd, "") + pasteEnd
+}
@@ -0,0 +1,47 @@
+package session
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPasteModeTracking(t *testing.T) {
+	tests := []struct {
+		name   string
+		output []string // Separate reads
+		want   bool
+	}{
+		{"no mode change", []string{"plain output\r\n"}, false},
+		{"enabled", []string{"\x1b[?2004h"}, true},
+		{"enabled then disabled", []string{"\x1b[?2004h", "prompt$ ", "\x1b[?2004l"}, false},
+		{"split across reads", []string{"out\x1b", "[?20", "04h"}, true},
+		{"among other modes", []string{"\x1b[?1049;2004;25h"}, true},
+		{"other private mode", []string{"\x1b[?1049h"}, false},
+		{"not a private mode", []string{"\x1b[2004h"}, false},
+		{"intermediate byte", []string{"\x1b[?2004$h"}, false},
+		{"full reset", []string{"\x1b[?2004h", "\x1bc"}, false},
+		{"escape restarts the sequence", []string{"\x1b[?1\x1b[?2004h"}, true},
+		{"overlong parameters", []string{"\x1b[?" + strings.Repeat("1;", 50) + "2004h"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tracker pasteModeTracker
+			for _, output := range tt.output {
+				tracker.scan(output)
+			}
+			if got := tracker.enabled.Load(); got != tt.want {
+				t.Errorf("bracketed paste = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBracketPaste(t *testing.T) {
+	if got, want := bracketPaste("ls\nrm -rf /tmp/x\n"), "\x1b[200~ls\nrm -rf /tmp/x\n\x1b[201~"; got != want {
+		t.Errorf("bracketPaste = %q, want %q", got, want)
+	}
+	// Pasted text can't close the paste itself
+	if got, want := bracketPaste("a\x1b[201~b\n"), "\x1b[200~ab\n\x1b[201~"; got != want {
+		t.Errorf("bracketPaste = %q, want %q", got, want)
+	}
+}
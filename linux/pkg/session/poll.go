@@ -0,0 +1,239 @@
+//go:build darwin || linux
+// +build darwin linux
+
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// pollFds waits with poll(2), returning which of readFds are ready to read
+// and which of writeFds are ready to write. A negative timeout waits
+// indefinitely. Unlike select(2) there is no FD_SETSIZE limit, so a PTY
+// opened with a high descriptor number works like any other.
+func pollFds(readFds, writeFds []int, timeout time.Duration) ([]int, []int, error) {
+	if len(readFds) == 0 && len(writeFds) == 0 {
+		return nil, nil, fmt.Errorf("no file descriptors to poll")
+	}
+
+	pfds := make([]unix.PollFd, 0, len(readFds)+len(writeFds))
+	for _, fd := range readFds {
+		pfds = append(pfds, unix.PollFd{Fd: int32(fd), Events: unix.POLLIN})
+	}
+	for _, fd := range writeFds {
+		pfds = append(pfds, unix.PollFd{Fd: int32(fd), Events: unix.POLLOUT})
+	}
+
+	msec := -1
+	if timeout >= 0 {
+		msec = int(timeout.Milliseconds())
+	}
+	if _, err := unix.Poll(pfds, msec); err != nil {
+		if err == unix.EINTR || err == unix.EAGAIN {
+			return nil, nil, nil // Interrupted or would block
+		}
+		return nil, nil, err
+	}
+
+	// A hung-up or failed FD counts as ready, so the read or write that
+	// follows reports what happened, as it did with select
+	var readable, writable []int
+	for i, pfd := range pfds {
+		if pfd.Revents&unix.POLLNVAL != 0 {
+			return nil, nil, fmt.Errorf("poll: invalid file descriptor %d", pfd.Fd)
+		}
+		if pfd.Revents&(pfd.Events|unix.POLLHUP|unix.POLLERR) == 0 {
+			continue
+		}
+		if i < len(readFds) {
+			readable = append(readable, int(pfd.Fd))
+		} else {
+			writable = append(writable, int(pfd.Fd))
+		}
+	}
+
+	return readable, writable, nil
+}
+
+// pollLoop polls multiple file descriptors using poll
+func (p *PTY) pollLoop() error {
+	// Buffer for reading
+	buf := make([]byte, 32*1024)
+
+	// Get file descriptors
+	ptyFd := int(p.pty.Fd())
+	stdinFd := int(p.stdinPipe.Fd())
+
+	// Open control FIFO in non-blocking mode. Like stdin it is opened
+	// read-write so it never reports EOF once an external writer goes away.
+	controlPath := filepath.Join(p.session.Path(), "control")
+	controlFile, err := os.OpenFile(controlPath, os.O_RDWR|syscall.O_NONBLOCK, 0)
+	var controlFd = -1
+	if err == nil {
+		controlFd = int(controlFile.Fd())
+		defer func() {
+			if err := controlFile.Close(); err != nil {
+				log.Printf("[ERROR] Failed to close control file: %v", err)
+			}
+		}()
+	} else {
+		log.Printf("[WARN] Failed to open control FIFO: %v", err)
+	}
+
+	// The write end of this pipe is closed when the child exits, which makes
+	// the read end ready and wakes poll without needing a timeout
+	exitRead, exitWrite, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("failed to create exit pipe: %w", err)
+	}
+	defer func() {
+		if err := exitRead.Close(); err != nil {
+			log.Printf("[ERROR] Failed to close exit pipe: %v", err)
+		}
+	}()
+	go func() {
+		<-p.exited
+		if err := exitWrite.Close(); err != nil {
+			log.Printf("[ERROR] Failed to close exit pipe: %v", err)
+		}
+	}()
+	exitFd := int(exitRead.Fd())
+
+	// The PTY is written without blocking, so a program that stops reading
+	// its terminal can't stall the loop, which would stop its output being
+	// read and its exit being noticed. Input the PTY hasn't taken yet waits
+	// in pending, and the stdin FIFO isn't read meanwhile; writers to the
+	// FIFO then block on it being full rather than input piling up here.
+	if err := syscall.SetNonblock(ptyFd, true); err != nil {
+		return fmt.Errorf("failed to make PTY non-blocking: %w", err)
+	}
+	var pending []byte
+	// Written directly; the os.File would wait for the PTY to be writable
+	writePTY := func(data []byte) (int, error) {
+		n, err := syscall.Write(ptyFd, data)
+		return max(n, 0), err
+	}
+
+	for {
+		// Build FD lists
+		fds := []int{ptyFd, exitFd}
+		if controlFd >= 0 {
+			fds = append(fds, controlFd)
+		}
+		var writeFds []int
+		if len(pending) > 0 {
+			writeFds = []int{ptyFd}
+		} else {
+			fds = append(fds, stdinFd)
+		}
+
+		// Block until there is activity; process exit is signalled via exitFd
+		ready, writable, err := pollFds(fds, writeFds, -1)
+		if err != nil {
+			log.Printf("[ERROR] poll error: %v", err)
+			return err
+		}
+
+		// Once the process has exited and its output is drained, stop polling
+		exited, ptyReady := false, false
+		for _, fd := range ready {
+			switch fd {
+			case exitFd:
+				exited = true
+			case ptyFd:
+				ptyReady = true
+			}
+		}
+		if exited && !ptyReady {
+			return nil
+		}
+
+		// Process ready file descriptors
+		for _, fd := range ready {
+			switch fd {
+			case ptyFd:
+				// Read from PTY
+				n, err := syscall.Read(ptyFd, buf)
+				if err == syscall.EAGAIN {
+					continue
+				}
+				if err != nil {
+					if err == syscall.EIO {
+						// PTY closed
+						return nil
+					}
+					log.Printf("[ERROR] PTY read error: %v", err)
+					return err
+				}
+				if n > 0 {
+					// Write to output
+					if err := p.streamWriter.WriteOutput(buf[:n]); err != nil {
+						log.Printf("[ERROR] Failed to write to stream: %v", err)
+					}
+				}
+
+			case stdinFd:
+				// Read from stdin FIFO
+				n, err := syscall.Read(stdinFd, buf)
+				if err != nil && err != syscall.EAGAIN {
+					log.Printf("[ERROR] stdin read error: %v", err)
+					continue
+				}
+				if n > 0 {
+					// Write to PTY, keeping what it doesn't take yet
+					written, err := p.writeInput(writePTY, buf[:n])
+					if err != nil && !errors.Is(err, syscall.EAGAIN) {
+						log.Printf("[ERROR] Failed to write to PTY: %v", err)
+						continue
+					}
+					pending = append(pending, buf[written:n]...)
+				}
+
+			case controlFd:
+				// Read from control FIFO
+				n, err := syscall.Read(controlFd, buf)
+				if err != nil && err != syscall.EAGAIN {
+					log.Printf("[ERROR] control read error: %v", err)
+					continue
+				}
+				if n > 0 {
+					// Parse control commands
+					cmdStr := string(buf[:n])
+					for _, line := range strings.Split(cmdStr, "\n") {
+						line = strings.TrimSpace(line)
+						if line == "" {
+							continue
+						}
+
+						var cmd ControlCommand
+						if err := json.Unmarshal([]byte(line), &cmd); err != nil {
+							log.Printf("[ERROR] Failed to parse control command: %v", err)
+							continue
+						}
+
+						p.session.handleControlCommand(&cmd)
+					}
+				}
+			}
+		}
+
+		if len(writable) > 0 {
+			written, err := p.writeInput(writePTY, pending)
+			pending = pending[written:]
+			if err != nil && !errors.Is(err, syscall.EAGAIN) {
+				log.Printf("[ERROR] Failed to write to PTY: %v", err)
+				pending = nil
+			}
+		}
+	}
+}
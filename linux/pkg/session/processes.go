@@ -0,0 +1,153 @@
+package session
+
+import (
+	"sort"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// ProcessInfo describes a process running under a session
+type ProcessInfo struct {
+	PID        int     `json:"pid"`
+	PPID       int     `json:"ppid"`
+	Command    string  `json:"command"`
+	CPUPercent float64 `json:"cpuPercent"`
+	RSS        uint64  `json:"rss"` // Resident set size in bytes
+}
+
+// Processes returns the session's process and all of its descendants, in
+// tree order. An exited session has no processes.
+func (s *Session) Processes() ([]ProcessInfo, error) {
+	if s.info.Pid <= 0 || !s.IsAlive() {
+		return []ProcessInfo{}, nil
+	}
+	return ProcessTree(s.info.Pid)
+}
+
+// ProcessTree walks the process table and returns the process rooted at pid
+// followed by its descendants (depth first, children ordered by PID)
+func ProcessTree(pid int) ([]ProcessInfo, error) {
+	tree := make([]ProcessInfo, 0)
+	err := walkProcessTree(pid, func(p *process.Process, ppid int32) {
+		tree = append(tree, describeProcess(p, ppid))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
+
+// walkProcessTree calls visit for the process rooted at pid and each of its
+// descendants, depth first with children ordered by PID. Nothing is visited
+// if pid isn't running.
+func walkProcessTree(pid int, visit func(p *process.Process, ppid int32)) error {
+	root, err := process.NewProcess(int32(pid))
+	if err != nil {
+		if err == process.ErrorProcessNotRunning {
+			return nil
+		}
+		return err
+	}
+
+	// Build a parent -> children index in a single pass over the process table
+	procs, err := process.Processes()
+	if err != nil {
+		return err
+	}
+	children := make(map[int32][]*process.Process)
+	for _, p := range procs {
+		ppid, err := p.Ppid()
+		if err != nil {
+			continue // Process exited while we were walking
+		}
+		children[ppid] = append(children[ppid], p)
+	}
+
+	var walk func(p *process.Process, ppid int32)
+	walk = func(p *process.Process, ppid int32) {
+		visit(p, ppid)
+
+		kids := children[p.Pid]
+		sort.Slice(kids, func(i, j int) bool { return kids[i].Pid < kids[j].Pid })
+		for _, child := range kids {
+			walk(child, p.Pid)
+		}
+	}
+
+	ppid, _ := root.Ppid()
+	walk(root, ppid)
+
+	return nil
+}
+
+// describeProcess gathers what we report about a process. Individual fields
+// are best effort since the process may exit at any time.
+func describeProcess(p *process.Process, ppid int32) ProcessInfo {
+	info := ProcessInfo{
+		PID:  int(p.Pid),
+		PPID: int(ppid),
+	}
+
+	if cmdline, err := p.Cmdline(); err == nil && cmdline != "" {
+		info.Command = cmdline
+	} else if name, err := p.Name(); err == nil {
+		info.Command = name
+	}
+	if cpu, err := p.CPUPercent(); err == nil {
+		info.CPUPercent = cpu
+	}
+	if mem, err := p.MemoryInfo(); err == nil && mem != nil {
+		info.RSS = mem.RSS
+	}
+
+	return info
+}
+
+// ResourceUsage totals the resources used by a session's process tree
+type ResourceUsage struct {
+	// CPUPercent is each process's average since it started, summed; callers
+	// sampling repeatedly can use CPUTime for the rate in between instead
+	CPUPercent float64 `json:"cpuPercent"`
+	RSSBytes   uint64  `json:"rssBytes"`
+	NumThreads int32   `json:"numThreads"`
+	OpenFiles  int32   `json:"openFiles"`
+	// CPUTime is the user and system CPU seconds the processes have used
+	CPUTime float64 `json:"-"`
+}
+
+// Resources samples the session's process and its descendants. It returns
+// nil for an exited session.
+func (s *Session) Resources() (*ResourceUsage, error) {
+	if s.info.Pid <= 0 || !s.IsAlive() {
+		return nil, nil
+	}
+
+	usage := &ResourceUsage{}
+	visited := 0
+	err := walkProcessTree(s.info.Pid, func(p *process.Process, _ int32) {
+		// Best effort, like describeProcess: any process may exit mid-walk
+		visited++
+		if cpu, err := p.CPUPercent(); err == nil {
+			usage.CPUPercent += cpu
+		}
+		if times, err := p.Times(); err == nil {
+			usage.CPUTime += times.User + times.System
+		}
+		if mem, err := p.MemoryInfo(); err == nil && mem != nil {
+			usage.RSSBytes += mem.RSS
+		}
+		if threads, err := p.NumThreads(); err == nil {
+			usage.NumThreads += threads
+		}
+		if fds, err := p.NumFDs(); err == nil {
+			usage.OpenFiles += fds
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if visited == 0 {
+		return nil, nil
+	}
+	return usage, nil
+}
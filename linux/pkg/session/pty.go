@@ -1,12 +1,12 @@
 package session
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"os/exec"
-	"os/signal"
 	"strings"
 	"sync"
 	"syscall"
@@ -14,21 +14,119 @@ import (
 
 	"github.com/creack/pty"
 	"github.com/vibetunnel/linux/pkg/protocol"
-	"golang.org/x/term"
 )
 
-// useSelectPolling determines whether to use select-based polling
-// Enable this for better control FIFO integration
-const useSelectPolling = true
+// useSelectPolling determines whether to use poll-based polling
+// Enable this for better control FIFO integration; a variable so the
+// input latency benchmark can compare both paths
+var useSelectPolling = true
+
+// ErrPTYCreationFailed matches errors for sessions that couldn't get a
+// pseudo-terminal because the system or the server ran out of PTYs or file
+// descriptors, or PTY devices are missing; use errors.As with
+// *PTYCreationError for a hint at the cause
+var ErrPTYCreationFailed = errors.New("PTY creation failed")
+
+// PTYCreationError reports a failure to allocate a session's PTY. Hint says
+// what to check.
+type PTYCreationError struct {
+	Hint string
+	Err  error
+}
+
+func (e *PTYCreationError) Error() string {
+	return fmt.Sprintf("failed to start PTY: %v (%s)", e.Err, e.Hint)
+}
+
+func (e *PTYCreationError) Is(target error) bool {
+	return target == ErrPTYCreationFailed
+}
+
+func (e *PTYCreationError) Unwrap() error {
+	return e.Err
+}
+
+// ErrCommandNotExecutable matches errors for session commands that were
+// found but couldn't be executed, e.g. for lack of permission or in an
+// unknown format; use errors.As with *CommandNotExecutableError to get the
+// command
+var ErrCommandNotExecutable = errors.New("command not executable")
+
+// CommandNotExecutableError reports a session command the system refused
+// to execute
+type CommandNotExecutableError struct {
+	Command string
+	Err     error
+}
+
+func (e *CommandNotExecutableError) Error() string {
+	return fmt.Sprintf("cannot execute %s: %v", e.Command, e.Err)
+}
+
+func (e *CommandNotExecutableError) Is(target error) bool {
+	return target == ErrCommandNotExecutable
+}
+
+func (e *CommandNotExecutableError) Unwrap() error {
+	return e.Err
+}
+
+// startPTY starts a session's command on a new PTY; tests replace it to
+// simulate failures
+var startPTY = pty.Start
+
+// ptyStartError classifies a startPTY failure. Running out of PTYs or file
+// descriptors, or missing PTY devices, is a *PTYCreationError with a hint;
+// a working directory or command that can't be used is reported like the
+// checks made before starting. Anything else is returned wrapped.
+func ptyStartError(err error) error {
+	var hint string
+	switch {
+	case errors.Is(err, syscall.ENOSPC):
+		hint = "system PTY limit reached; close unused sessions or increase /proc/sys/kernel/pty/max"
+	case errors.Is(err, syscall.EMFILE):
+		hint = "the server has too many open files; close unused sessions or raise its limit with ulimit -n"
+	case errors.Is(err, syscall.ENFILE):
+		hint = "the system has too many open files; raise /proc/sys/fs/file-max"
+	case errors.Is(err, syscall.ENOENT) && isPTYDevice(err):
+		hint = "no PTY devices; make sure /dev/ptmx exists and devpts is mounted on /dev/pts"
+	case errors.Is(err, syscall.EACCES) && isPTYDevice(err):
+		hint = "no permission to open PTY devices; check the permissions of /dev/ptmx and /dev/pts"
+	}
+	if hint != "" {
+		return &PTYCreationError{Hint: hint, Err: err}
+	}
+
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) && !isPTYDevice(err) {
+		switch pathErr.Op {
+		case "chdir":
+			return &InvalidWorkingDirError{Path: pathErr.Path, Err: pathErr.Err}
+		case "fork/exec":
+			return &CommandNotExecutableError{Command: pathErr.Path, Err: pathErr.Err}
+		}
+	}
+	return fmt.Errorf("failed to start PTY: %w", err)
+}
+
+// isPTYDevice reports whether err is about opening /dev/ptmx or /dev/pts/*
+// rather than the command being started
+func isPTYDevice(err error) bool {
+	var pathErr *os.PathError
+	return errors.As(err, &pathErr) && (pathErr.Path == "/dev/ptmx" || strings.HasPrefix(pathErr.Path, "/dev/pts/"))
+}
 
 type PTY struct {
 	session      *Session
 	cmd          *exec.Cmd
 	pty          *os.File
-	oldState     *term.State
 	streamWriter *protocol.StreamWriter
 	stdinPipe    *os.File
 	resizeMutex  sync.Mutex
+	recent       *outputRing       // Most recent output, mirrored from streamWriter
+	paste        *pasteModeTracker // Whether the program wants pastes bracketed
+	exit         <-chan childExit  // Receives the child's exit once it's reaped
+	exited       chan struct{}     // Closed once the child process has been waited for
 }
 
 func NewPTY(session *Session) (*PTY, error) {
@@ -48,60 +146,101 @@ func NewPTY(session *Session) (*PTY, error) {
 
 	cmd := exec.Command(cmdline[0], cmdline[1:]...)
 
+	// Resolve the command the way it was validated, using the session's
+	// working directory and PATH rather than the server's
+	if path, err := lookCommand(cmdline[0], session.info.Cwd, session.info.Env); err == nil {
+		cmd.Path = path
+		cmd.Err = nil
+	}
+
+	// Multi-call binaries (e.g. busybox) dispatch on argv[0], so allow it to
+	// differ from the executable that is actually run
+	if session.argv0 != "" {
+		cmd.Args[0] = session.argv0
+		debugLog("[DEBUG] NewPTY: Using argv0 %q for %s", session.argv0, cmdline[0])
+	}
+
 	// Set working directory, ensuring it's valid
 	if session.info.Cwd != "" {
 		// Verify the directory exists and is accessible
-		if _, err := os.Stat(session.info.Cwd); err != nil {
-			log.Printf("[ERROR] NewPTY: Working directory '%s' not accessible: %v", session.info.Cwd, err)
-			return nil, fmt.Errorf("working directory '%s' not accessible: %w", session.info.Cwd, err)
+		if err := CheckWorkingDir(session.info.Cwd); err != nil {
+			log.Printf("[ERROR] NewPTY: %v", err)
+			return nil, err
 		}
 		cmd.Dir = session.info.Cwd
 		debugLog("[DEBUG] NewPTY: Set working directory to: %s", session.info.Cwd)
 	}
 
-	// Set up environment with filtered variables like Rust implementation
-	// Only pass safe environment variables
-	safeEnvVars := []string{"TERM", "SHELL", "LANG", "LC_ALL", "PATH", "USER", "HOME"}
-	env := make([]string, 0)
-
-	// Copy only safe environment variables from parent
-	for _, v := range os.Environ() {
-		parts := strings.SplitN(v, "=", 2)
-		if len(parts) == 2 {
-			for _, safe := range safeEnvVars {
-				if parts[0] == safe {
-					env = append(env, v)
-					break
-				}
-			}
+	// Set up environment with filtered variables like Rust implementation.
+	// By default only variables matching the allowlist are passed; sessions
+	// that opt in inherit everything except the blocklist.
+	var env []string
+	if session.inheritEnv {
+		blocklist := session.envBlocklist
+		if blocklist == nil {
+			blocklist = DefaultEnvBlocklist
+		}
+		env = blockEnv(os.Environ(), blocklist)
+	} else {
+		allowlist := session.envAllowlist
+		if allowlist == nil {
+			allowlist = DefaultEnvAllowlist
 		}
+		env = filterEnv(os.Environ(), allowlist)
 	}
 
-	// Ensure TERM and SHELL are set
-	hasTermVar := false
+	// TERM is resolved when the session is created, so it replaces any
+	// inherited value; make sure SHELL is set too
+	env = mergeEnv(env, map[string]string{"TERM": session.info.Term})
 	hasShellVar := false
 	for _, v := range env {
-		if strings.HasPrefix(v, "TERM=") {
-			hasTermVar = true
-		}
 		if strings.HasPrefix(v, "SHELL=") {
 			hasShellVar = true
 		}
 	}
 
-	if !hasTermVar {
-		env = append(env, "TERM="+session.info.Term)
-	}
 	if !hasShellVar {
 		env = append(env, "SHELL="+cmdline[0])
 	}
 
+	// A session running as another user gets that user's identity; setting
+	// the credential fails with EPERM if the server can't switch users
+	if session.user != nil {
+		if cred := session.user.credential(); cred != nil {
+			cmd.SysProcAttr = &syscall.SysProcAttr{Credential: cred}
+		}
+		env = mergeEnv(env, map[string]string{
+			"HOME":    session.user.Home,
+			"USER":    session.user.Name,
+			"LOGNAME": session.user.Name,
+		})
+		debugLog("[DEBUG] NewPTY: Running as %s (uid %d, gid %d)", session.user.Name, session.user.Uid, session.user.Gid)
+	}
+
+	// Variables requested for the session override inherited ones
+	env = mergeEnv(env, session.info.Env)
+
+	// Let programs in the session, like `vt --show-session-id`, find it
+	env = mergeEnv(env, map[string]string{"VIBETUNNEL_SESSION_ID": session.ID})
+
 	cmd.Env = env
 
-	ptmx, err := pty.Start(cmd)
+	// pty.Start runs the child with Setsid, making it the leader of its own
+	// process group so signals can be delivered to the whole job. The child
+	// is registered straight away so it is reaped even if it's killed below
+	// because the rest of the setup fails.
+	var ptmx *os.File
+	exit, err := children.startWatched(func() (int, error) {
+		var err error
+		if ptmx, err = startPTY(cmd); err != nil {
+			return 0, err
+		}
+		return cmd.Process.Pid, nil
+	})
 	if err != nil {
-		log.Printf("[ERROR] NewPTY: Failed to start PTY: %v", err)
-		return nil, fmt.Errorf("failed to start PTY: %w", err)
+		err = ptyStartError(err)
+		log.Printf("[ERROR] NewPTY: %v", err)
+		return nil, err
 	}
 
 	debugLog("[DEBUG] NewPTY: PTY started successfully, PID: %d", cmd.Process.Pid)
@@ -141,12 +280,48 @@ func NewPTY(session *Session) (*PTY, error) {
 		return nil, fmt.Errorf("failed to create stream-out: %w", err)
 	}
 
+	// Recordings are shared and downloadable, so sensitive values are
+	// hidden in the header; the child still received the real ones above
+	redact := session.envRedact
+	if redact == nil {
+		redact = DefaultEnvRedact
+	}
+
 	streamWriter := protocol.NewStreamWriter(streamOut, &protocol.AsciinemaHeader{
 		Version: 2,
 		Width:   uint32(session.info.Width),
 		Height:  uint32(session.info.Height),
 		Command: strings.Join(cmdline, " "),
-		Env:     session.info.Env,
+		Env:     RedactEnv(session.info.Env, redact),
+	})
+
+	if encoding, err := protocol.ParseEncoding(session.info.Encoding); err == nil {
+		streamWriter.SetEncoding(encoding)
+	}
+
+	if session.maxRecordingSize > 0 {
+		streamWriter.SetRotation(session.maxRecordingSize, session.PreviousStreamOutPath())
+	}
+	if session.outputCoalesce > 0 {
+		streamWriter.SetCoalesceWindow(session.outputCoalesce)
+	}
+
+	// Mirror recent output in memory so tail snapshots skip the disk, and
+	// watch it for the bell and bracketed paste mode
+	recent := newOutputRing(outputRingSize)
+	bells := &bellDetector{}
+	paste := &pasteModeTracker{}
+	var lastBell time.Time
+	streamWriter.SetObserver(func(event protocol.AsciinemaEvent, offset int64) {
+		recent.observe(event, offset)
+		if event.Type == protocol.EventOutput {
+			session.touchActivity()
+			paste.scan(event.Data)
+		}
+		if event.Type == protocol.EventOutput && bells.scan(event.Data) && time.Since(lastBell) >= bellDebounce {
+			lastBell = time.Now()
+			session.notify(NotificationBell)
+		}
 	})
 
 	if err := streamWriter.WriteHeader(); err != nil {
@@ -190,6 +365,10 @@ func NewPTY(session *Session) (*PTY, error) {
 		cmd:          cmd,
 		pty:          ptmx,
 		streamWriter: streamWriter,
+		recent:       recent,
+		paste:        paste,
+		exit:         exit,
+		exited:       make(chan struct{}),
 	}, nil
 }
 
@@ -209,7 +388,10 @@ func (p *PTY) Run() error {
 
 	debugLog("[DEBUG] PTY.Run: Starting PTY run for session %s, PID %d", p.session.ID[:8], p.cmd.Process.Pid)
 
-	stdinPipe, err := os.OpenFile(p.session.StdinPath(), os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	// Open the FIFO read-write so we always hold a writer ourselves. With a
+	// read-only end, poll reports EOF continuously after an external
+	// writer (e.g. --send-text) closes it, spinning the poll loop.
+	stdinPipe, err := os.OpenFile(p.session.StdinPath(), os.O_RDWR|syscall.O_NONBLOCK, 0)
 	if err != nil {
 		log.Printf("[ERROR] PTY.Run: Failed to open stdin pipe: %v", err)
 		return fmt.Errorf("failed to open stdin pipe: %w", err)
@@ -223,44 +405,15 @@ func (p *PTY) Run() error {
 
 	debugLog("[DEBUG] PTY.Run: Stdin pipe opened successfully")
 
-	// Set up SIGWINCH handling for terminal resize
-	winchCh := make(chan os.Signal, 1)
-	signal.Notify(winchCh, syscall.SIGWINCH)
-	defer signal.Stop(winchCh)
-
-	// Handle SIGWINCH in a separate goroutine
+	// Wait for the child in the background so its real exit code is recorded
+	waitCh := make(chan error, 1)
 	go func() {
-		for range winchCh {
-			// Get current terminal size if we're attached to a terminal
-			if term.IsTerminal(int(os.Stdin.Fd())) {
-				width, height, err := term.GetSize(int(os.Stdin.Fd()))
-				if err == nil {
-					debugLog("[DEBUG] PTY.Run: Received SIGWINCH, resizing to %dx%d", width, height)
-					if err := pty.Setsize(p.pty, &pty.Winsize{
-						Rows: uint16(height),
-						Cols: uint16(width),
-					}); err != nil {
-						log.Printf("[ERROR] PTY.Run: Failed to resize PTY: %v", err)
-					} else {
-						// Update session info
-						p.session.mu.Lock()
-						p.session.info.Width = width
-						p.session.info.Height = height
-						p.session.mu.Unlock()
-						
-						// Write resize event to stream
-						if err := p.streamWriter.WriteResize(uint32(width), uint32(height)); err != nil {
-							log.Printf("[ERROR] PTY.Run: Failed to write resize event: %v", err)
-						}
-					}
-				}
-			}
-		}
+		waitCh <- p.waitForExit()
 	}()
 
-	// Use select-based polling if available
+	// Use poll-based polling if enabled
 	if useSelectPolling {
-		return p.pollWithSelect()
+		return p.pollLoop()
 	}
 
 	// Fallback to goroutine-based implementation
@@ -308,7 +461,7 @@ func (p *PTY) Run() error {
 			n, err := stdinPipe.Read(buf)
 			if n > 0 {
 				debugLog("[DEBUG] PTY.Run: Read %d bytes from stdin, writing to PTY", n)
-				if _, err := p.pty.Write(buf[:n]); err != nil {
+				if _, err := p.writeInput(p.pty.Write, buf[:n]); err != nil {
 					log.Printf("[ERROR] PTY.Run: Failed to write to PTY: %v", err)
 					// Only exit if the PTY is really broken, not on temporary errors
 					if err != syscall.EPIPE && err != syscall.ECONNRESET {
@@ -327,6 +480,10 @@ func (p *PTY) Run() error {
 				time.Sleep(10 * time.Millisecond)
 				continue
 			}
+			if errors.Is(err, os.ErrClosed) {
+				// The session is over and Run has closed the pipe
+				return
+			}
 			if err == io.EOF {
 				// No writers to the FIFO yet, longer pause before retry
 				time.Sleep(50 * time.Millisecond)
@@ -342,40 +499,7 @@ func (p *PTY) Run() error {
 	}()
 
 	go func() {
-		debugLog("[DEBUG] PTY.Run: Starting process wait goroutine for PID %d", p.cmd.Process.Pid)
-		err := p.cmd.Wait()
-		debugLog("[DEBUG] PTY.Run: Process wait completed for PID %d, error: %v", p.cmd.Process.Pid, err)
-
-		if err != nil {
-			if exitErr, ok := err.(*exec.ExitError); ok {
-				if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
-					exitCode := status.ExitStatus()
-					p.session.info.ExitCode = &exitCode
-					debugLog("[DEBUG] PTY.Run: Process exited with code %d", exitCode)
-				}
-			} else {
-				debugLog("[DEBUG] PTY.Run: Process exited with non-exit error: %v", err)
-			}
-		} else {
-			exitCode := 0
-			p.session.info.ExitCode = &exitCode
-			debugLog("[DEBUG] PTY.Run: Process exited normally (code 0)")
-		}
-		p.session.info.Status = string(StatusExited)
-		if err := p.session.info.Save(p.session.Path()); err != nil {
-			log.Printf("[ERROR] PTY.Run: Failed to save session info: %v", err)
-		}
-
-		// Reap any zombie child processes
-		for {
-			var status syscall.WaitStatus
-			pid, err := syscall.Wait4(-1, &status, syscall.WNOHANG, nil)
-			if err != nil || pid <= 0 {
-				break
-			}
-			debugLog("[DEBUG] PTY.Run: Reaped zombie process PID %d", pid)
-		}
-
+		err := <-waitCh
 		debugLog("[DEBUG] PTY.Run: PROCESS WAIT GOROUTINE sending completion to errCh")
 		errCh <- err
 	}()
@@ -387,67 +511,61 @@ func (p *PTY) Run() error {
 	return result
 }
 
-func (p *PTY) Attach() error {
-	if !term.IsTerminal(int(os.Stdin.Fd())) {
-		return fmt.Errorf("not a terminal")
-	}
+// waitForExit waits for the child process to be reaped and persists its
+// exit code and status to session.json
+func (p *PTY) waitForExit() error {
+	defer close(p.exited)
 
-	oldState, err := term.MakeRaw(int(os.Stdin.Fd()))
-	if err != nil {
-		return fmt.Errorf("failed to set raw mode: %w", err)
-	}
-	p.oldState = oldState
+	pid := p.cmd.Process.Pid
+	debugLog("[DEBUG] PTY.Run: Starting process wait for PID %d", pid)
+	exit := <-p.exit
+	debugLog("[DEBUG] PTY.Run: Process wait completed for PID %d, error: %v", pid, exit.err)
 
-	defer func() {
-		if err := term.Restore(int(os.Stdin.Fd()), oldState); err != nil {
-			log.Printf("[ERROR] PTY.Attach: Failed to restore terminal: %v", err)
-		}
-	}()
-
-	ch := make(chan os.Signal, 1)
-	signal.Notify(ch, syscall.SIGWINCH)
-	go func() {
-		for range ch {
-			if err := p.updateSize(); err != nil {
-				log.Printf("[ERROR] PTY.Attach: Failed to update size: %v", err)
-			}
-		}
-	}()
-	defer signal.Stop(ch)
-
-	if err := p.updateSize(); err != nil {
-		log.Printf("[ERROR] PTY.Attach: Failed to update initial size: %v", err)
+	// The reaper collected the status, so the process handle is done with
+	if err := p.cmd.Process.Release(); err != nil {
+		log.Printf("[ERROR] PTY.Run: Failed to release process %d: %v", pid, err)
 	}
 
-	errCh := make(chan error, 2)
-
-	go func() {
-		_, err := io.Copy(p.pty, os.Stdin)
-		errCh <- err
-	}()
-
-	go func() {
-		_, err := io.Copy(os.Stdout, p.pty)
-		errCh <- err
-	}()
+	exitCode := exitCodeFromStatus(exit.status, exit.err)
+	debugLog("[DEBUG] PTY.Run: Process exited with code %d", exitCode)
+	if exit.err != nil {
+		p.session.logf("Process exited: %v", exit.err)
+	} else {
+		p.session.logf("Process exited with code %d", exitCode)
+	}
 
-	return <-errCh
-}
+	p.session.mu.Lock()
+	if last := p.session.activity.last.Load(); last != 0 {
+		p.session.info.LastActivity = time.Unix(0, last)
+	}
+	p.session.info.ExitCode = &exitCode
+	p.session.info.Status = string(StatusExited)
+	if err := p.session.info.Save(p.session.Path()); err != nil {
+		log.Printf("[ERROR] PTY.Run: Failed to save session info: %v", err)
+	}
+	p.session.mu.Unlock()
+	p.session.notify(NotificationExited)
 
-func (p *PTY) updateSize() error {
-	if !term.IsTerminal(int(os.Stdin.Fd())) {
-		return nil
+	if exit.err != nil {
+		return fmt.Errorf("failed to wait for process %d: %w", pid, exit.err)
 	}
+	if exitCode != 0 {
+		return fmt.Errorf("process exited with code %d", exitCode)
+	}
+	return nil
+}
 
-	width, height, err := term.GetSize(int(os.Stdin.Fd()))
+// exitCodeFromStatus converts a reaped wait status into an exit code.
+// Processes killed by a signal report 128+signal like shells do; -1 means
+// the code is unknown.
+func exitCodeFromStatus(status syscall.WaitStatus, err error) int {
 	if err != nil {
-		return err
+		return -1
 	}
-
-	return pty.Setsize(p.pty, &pty.Winsize{
-		Rows: uint16(height),
-		Cols: uint16(width),
-	})
+	if status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return status.ExitStatus()
 }
 
 func (p *PTY) Resize(width, height int) error {
@@ -501,13 +619,54 @@ func (p *PTY) Close() error {
 			}
 		}
 	}
-	if p.oldState != nil {
-		if err := term.Restore(int(os.Stdin.Fd()), p.oldState); err != nil {
-			log.Printf("[ERROR] PTY.Close: Failed to restore terminal: %v", err)
-			if firstErr == nil {
-				firstErr = err
-			}
+	return firstErr
+}
+
+// writeInput passes input to the child using write, which writes to the
+// PTY. With input recording enabled it is also written to the recording,
+// except while the terminal has echo turned off, as programs do when
+// prompting for a password.
+func (p *PTY) writeInput(write func([]byte) (int, error), data []byte) (int, error) {
+	record := p.session.recordInput && p.IsEchoEnabled()
+	n, err := write(data)
+	if n > 0 {
+		p.session.touchActivity()
+	}
+	if n > 0 && record {
+		if err := p.streamWriter.WriteInput(data[:n]); err != nil {
+			log.Printf("[ERROR] Failed to record input: %v", err)
 		}
 	}
-	return firstErr
+	return n, err
+}
+
+// IsBracketedPaste reports whether the program has turned on bracketed
+// paste mode
+func (p *PTY) IsBracketedPaste() bool {
+	return p.paste.enabled.Load()
+}
+
+// IsEchoEnabled reports whether the terminal echoes input. Programs turn echo
+// off while reading passwords, so input typed meanwhile must not be recorded
+// or logged. If the state can't be read it reports false.
+func (p *PTY) IsEchoEnabled() bool {
+	// Control keeps the fd open while it is read; the PTY may be closed
+	// concurrently when the process exits
+	conn, err := p.pty.SyscallConn()
+	if err != nil {
+		debugLog("[DEBUG] Failed to read terminal attributes: %v", err)
+		return false
+	}
+	var echo bool
+	var echoErr error
+	if err := conn.Control(func(fd uintptr) {
+		echo, echoErr = isEchoEnabled(int(fd))
+	}); err != nil {
+		echoErr = err
+	}
+	if err := echoErr; err != nil {
+		debugLog("[DEBUG] Failed to read terminal attributes: %v", err)
+		return false
+	}
+	return echo
 }
@@ -0,0 +1,165 @@
+package session
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// failStartPTY makes starting a PTY fail with err
+func failStartPTY(t *testing.T, err error) {
+	t.Helper()
+	orig := startPTY
+	t.Cleanup(func() { startPTY = orig })
+	startPTY = func(*exec.Cmd) (*os.File, error) {
+		return nil, err
+	}
+}
+
+func TestPTYStartFailures(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		want     error
+		wantHint string
+	}{
+		{"PTY limit", &os.PathError{Op: "open", Path: "/dev/ptmx", Err: syscall.ENOSPC}, ErrPTYCreationFailed, "/proc/sys/kernel/pty/max"},
+		{"server out of files", &os.PathError{Op: "open", Path: "/dev/ptmx", Err: syscall.EMFILE}, ErrPTYCreationFailed, "ulimit -n"},
+		{"system out of files", &os.PathError{Op: "open", Path: "/dev/ptmx", Err: syscall.ENFILE}, ErrPTYCreationFailed, "file-max"},
+		{"no devpts", &os.PathError{Op: "open", Path: "/dev/ptmx", Err: syscall.ENOENT}, ErrPTYCreationFailed, "devpts"},
+		{"PTY permissions", &os.PathError{Op: "open", Path: "/dev/pts/3", Err: syscall.EACCES}, ErrPTYCreationFailed, "permissions"},
+		// Failures of the command itself are the client's, not the system's
+		{"command permissions", &os.PathError{Op: "fork/exec", Path: "/usr/bin/tool", Err: syscall.EACCES}, ErrCommandNotExecutable, ""},
+		{"command format", &os.PathError{Op: "fork/exec", Path: "/usr/bin/tool", Err: syscall.ENOEXEC}, ErrCommandNotExecutable, ""},
+		{"working directory", &os.PathError{Op: "chdir", Path: "/gone", Err: syscall.ENOENT}, ErrInvalidWorkingDir, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failStartPTY(t, tt.err)
+			_, err := NewManager(t.TempDir()).CreateSession(Config{Cmdline: []string{"true"}})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+			var ptyErr *PTYCreationError
+			if isPTYErr := errors.As(err, &ptyErr); isPTYErr != (tt.wantHint != "") {
+				t.Errorf("err = %v, PTY creation error = %v", err, isPTYErr)
+			} else if isPTYErr && !strings.Contains(ptyErr.Hint, tt.wantHint) {
+				t.Errorf("hint = %q, want it to mention %q", ptyErr.Hint, tt.wantHint)
+			}
+		})
+	}
+}
+
+func TestPTYStartUnknownFailure(t *testing.T) {
+	failStartPTY(t, &os.PathError{Op: "open", Path: "/dev/ptmx", Err: syscall.EIO})
+	_, err := NewManager(t.TempDir()).CreateSession(Config{Cmdline: []string{"true"}})
+	if err == nil || errors.Is(err, ErrPTYCreationFailed) || errors.Is(err, ErrCommandNotExecutable) {
+		t.Errorf("err = %v, want an unclassified error", err)
+	}
+	if !errors.Is(err, syscall.EIO) {
+		t.Errorf("err = %v doesn't wrap the cause", err)
+	}
+}
+
+func TestCommandNotExecutable(t *testing.T) {
+	// Executable but neither a binary nor a script with an interpreter
+	path := filepath.Join(t.TempDir(), "tool")
+	if err := os.WriteFile(path, []byte{0x7f, 'E', 'L', 'F', 0, 0, 0, 0}, 0755); err != nil {
+		t.Fatal(err)
+	}
+	_, err := NewManager(t.TempDir()).CreateSession(Config{Cmdline: []string{path}})
+	var notExecutable *CommandNotExecutableError
+	if !errors.As(err, &notExecutable) || notExecutable.Command != path {
+		t.Errorf("err = %v, want a CommandNotExecutableError for %s", err, path)
+	}
+}
+
+func TestPollHighFileDescriptors(t *testing.T) {
+	// Use up the low descriptors so the session's PTY and FIFOs land above
+	// FD_SETSIZE (1024), which select couldn't watch
+	var fds []int
+	t.Cleanup(func() {
+		for _, fd := range fds {
+			if err := syscall.Close(fd); err != nil {
+				t.Logf("Failed to close fd %d: %v", fd, err)
+			}
+		}
+	})
+	for len(fds) == 0 || fds[len(fds)-1] < 1100 {
+		fd, err := syscall.Dup(0)
+		if err != nil {
+			t.Skipf("can't open enough file descriptors: %v", err)
+		}
+		fds = append(fds, fd)
+	}
+
+	sess, err := NewManager(t.TempDir()).CreateSession(Config{
+		Cmdline: []string{"/bin/sh", "-c", `read line; echo "got $line"`},
+	})
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	t.Cleanup(sess.Wait)
+	if err := sess.SendText("input\n"); err != nil {
+		t.Fatalf("SendText: %v", err)
+	}
+	waitForOutput(t, sess, "got input")
+}
+
+func BenchmarkInputLatency(b *testing.B) {
+	for _, bm := range []struct {
+		name string
+		poll bool
+	}{
+		{"poll", true},
+		{"goroutines", false},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			orig := useSelectPolling
+			useSelectPolling = bm.poll
+			defer func() { useSelectPolling = orig }()
+
+			// Coalescing would hold every echo back for its window
+			m := NewManager(b.TempDir())
+			m.SetOutputCoalesce(0)
+			sess, err := m.CreateSession(Config{Cmdline: []string{"cat"}})
+			if err != nil {
+				b.Fatalf("CreateSession: %v", err)
+			}
+			defer func() {
+				if err := sess.Kill(); err != nil {
+					b.Logf("Failed to kill session: %v", err)
+				}
+				sess.Wait()
+			}()
+
+			// Each keystroke is echoed by the terminal, so the time until the
+			// recording grows is the time for input to get through
+			outPath := sess.StreamOutPath()
+			size := func() int64 {
+				info, err := os.Stat(outPath)
+				if err != nil {
+					return 0
+				}
+				return info.Size()
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				before := size()
+				if err := sess.SendText("x"); err != nil {
+					b.Fatalf("SendText: %v", err)
+				}
+				for deadline := time.Now().Add(5 * time.Second); size() == before; {
+					if time.Now().After(deadline) {
+						b.Fatal("input was not echoed")
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package session
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// StartReaper periodically refreshes session statuses and removes sessions
+// that exited more than maxAge ago, checking every interval. Running
+// sessions are never removed. Call the returned function to stop it.
+func (m *Manager) StartReaper(interval, maxAge time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if _, err := m.ReapExitedSessions(maxAge); err != nil {
+					log.Printf("[WARN] Failed to reap exited sessions: %v", err)
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// ReapExitedSessions removes sessions that exited more than maxAge ago and
+// returns the IDs of those removed. A session's exit time is taken from when
+// its session.json was last written, which is when it was marked exited.
+func (m *Manager) ReapExitedSessions(maxAge time.Duration) ([]string, error) {
+	if err := m.UpdateAllSessionStatuses(); err != nil {
+		return nil, err
+	}
+	sessions, err := m.ListSessions()
+	if err != nil {
+		return nil, err
+	}
+
+	removed := make([]string, 0)
+	for _, info := range sessions {
+		if info.Status != string(StatusExited) {
+			continue
+		}
+		sessionPath := filepath.Join(m.controlPath, info.ID)
+		stat, err := os.Stat(filepath.Join(sessionPath, "session.json"))
+		if err != nil || time.Since(stat.ModTime()) < maxAge {
+			continue
+		}
+
+		if m.reapSession(info.ID, sessionPath) {
+			removed = append(removed, info.ID)
+			log.Printf("[INFO] Removed session %s, exited %s ago", info.ID[:8], time.Since(stat.ModTime()).Round(time.Second))
+		}
+	}
+	return removed, nil
+}
+
+// reapSession removes an exited session unless this process is still
+// tearing down its PTY. The manager mutex is held throughout so API
+// requests never see a half-removed session.
+func (m *Manager) reapSession(id, sessionPath string) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if sess, ok := m.runningSessions[id]; ok && sess.runDone != nil {
+		select {
+		case <-sess.runDone:
+		default:
+			return false
+		}
+	}
+
+	if err := os.RemoveAll(sessionPath); err != nil {
+		log.Printf("[WARN] Failed to remove session %s: %v", id[:8], err)
+		return false
+	}
+	delete(m.runningSessions, id)
+	return true
+}
@@ -0,0 +1,184 @@
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vibetunnel/linux/pkg/protocol"
+)
+
+// ErrRecordingNotFound is returned for unknown recording IDs
+var ErrRecordingNotFound = errors.New("recording not found")
+
+// RecordingInfo describes an imported asciinema recording
+type RecordingInfo struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Width     uint32    `json:"width"`
+	Height    uint32    `json:"height"`
+	Duration  float64   `json:"duration"` // Seconds until the last event
+	Events    int       `json:"events"`
+	Size      int64     `json:"size"` // Bytes
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// RecordingsPath returns the directory imported recordings are stored in.
+// Each recording is kept as <id>.cast with its metadata in <id>.json.
+func (m *Manager) RecordingsPath() string {
+	return filepath.Join(m.controlPath, "recordings")
+}
+
+// ImportRecording validates an asciinema v2 cast and stores it. name
+// defaults to the cast's title.
+func (m *Manager) ImportRecording(r io.Reader, name string) (*RecordingInfo, error) {
+	dir := m.RecordingsPath()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create recordings directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, ".upload-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recording file: %w", err)
+	}
+	defer func() {
+		// No-op once the upload has been renamed into place
+		if err := os.Remove(tmp.Name()); err != nil && !os.IsNotExist(err) {
+			log.Printf("[WARN] Failed to remove temporary recording %s: %v", tmp.Name(), err)
+		}
+	}()
+
+	// Parse while copying so the cast is validated in a single pass
+	info, err := scanRecording(io.TeeReader(r, tmp))
+	if closeErr := tmp.Close(); err == nil && closeErr != nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	stat, err := os.Stat(tmp.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	info.ID = uuid.New().String()
+	info.Size = stat.Size()
+	info.CreatedAt = time.Now()
+	if name != "" {
+		info.Name = name
+	}
+	if info.Name == "" {
+		info.Name = info.ID[:8]
+	}
+
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(filepath.Join(dir, info.ID+".json"), data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to save recording info: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, info.ID+".cast")); err != nil {
+		return nil, fmt.Errorf("failed to save recording: %w", err)
+	}
+
+	return info, nil
+}
+
+// scanRecording reads a whole asciinema v2 cast, returning its dimensions,
+// title, event count and duration
+func scanRecording(r io.Reader) (*RecordingInfo, error) {
+	reader := protocol.NewStreamReader(r)
+	info := &RecordingInfo{}
+
+	for {
+		event, err := reader.Next()
+		if err != nil {
+			return nil, fmt.Errorf("invalid asciinema recording: %w", err)
+		}
+
+		switch event.Type {
+		case "header":
+			if event.Header.Version != 2 {
+				return nil, fmt.Errorf("unsupported asciinema version %d, expected 2", event.Header.Version)
+			}
+			info.Width = event.Header.Width
+			info.Height = event.Header.Height
+			info.Name = event.Header.Title
+		case "event":
+			info.Events++
+			if event.Event.Time > info.Duration {
+				info.Duration = event.Event.Time
+			}
+		case "end":
+			return info, nil
+		}
+	}
+}
+
+// ListRecordings returns imported recordings, newest first
+func (m *Manager) ListRecordings() ([]*RecordingInfo, error) {
+	entries, err := os.ReadDir(m.RecordingsPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*RecordingInfo{}, nil
+		}
+		return nil, err
+	}
+
+	recordings := make([]*RecordingInfo, 0)
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		info, err := m.GetRecording(id)
+		if err != nil {
+			debugLog("[DEBUG] Failed to load recording %s: %v", id, err)
+			continue
+		}
+		recordings = append(recordings, info)
+	}
+
+	sort.Slice(recordings, func(i, j int) bool {
+		return recordings[i].CreatedAt.After(recordings[j].CreatedAt)
+	})
+
+	return recordings, nil
+}
+
+// GetRecording returns the metadata of an imported recording
+func (m *Manager) GetRecording(id string) (*RecordingInfo, error) {
+	// IDs are UUIDs; anything else could escape the recordings directory
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrRecordingNotFound
+	}
+
+	data, err := os.ReadFile(filepath.Join(m.RecordingsPath(), id+".json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrRecordingNotFound
+		}
+		return nil, err
+	}
+
+	var info RecordingInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// RecordingPath returns the cast file of an imported recording
+func (m *Manager) RecordingPath(id string) string {
+	return filepath.Join(m.RecordingsPath(), id+".cast")
+}
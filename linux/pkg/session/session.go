@@ -3,6 +3,7 @@ package session
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -17,6 +18,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/shirou/gopsutil/v3/process"
+	"github.com/vibetunnel/linux/pkg/protocol"
 )
 
 // GenerateID generates a new unique session ID
@@ -24,6 +26,18 @@ func GenerateID() string {
 	return uuid.New().String()
 }
 
+// ErrReadOnly is returned when input or resize is attempted on a session
+// without a live PTY, such as an imported playback session
+var ErrReadOnly = errors.New("session is read-only")
+
+// ErrInputBlocked is returned when the session stops taking input, e.g.
+// because the program isn't reading its terminal, before all of it is written
+var ErrInputBlocked = errors.New("session is not reading input")
+
+// SpawnTypePlayback marks sessions that replay a recording instead of
+// running a process
+const SpawnTypePlayback = "playback"
+
 type Status string
 
 const (
@@ -32,31 +46,90 @@ const (
 	StatusExited   Status = "exited"
 )
 
+// ExitReasonMaxRuntime is the exit reason of sessions killed for running
+// longer than their MaxRuntime
+const ExitReasonMaxRuntime = "max runtime exceeded"
+
+// Defaults for sessions that don't specify a size or TERM
+const (
+	DefaultWidth  = 120 // Better default for modern terminals
+	DefaultHeight = 30
+	DefaultTerm   = "xterm-256color"
+)
+
 type Config struct {
 	Name      string
 	Cmdline   []string
 	Cwd       string
 	Env       []string
-	Width     int
-	Height    int
-	IsSpawned bool // Whether this session was spawned in a terminal
+	Width     int    // Columns, defaults to DefaultWidth
+	Height    int    // Rows, defaults to DefaultHeight
+	Term      string // TERM when Env doesn't set it; defaults to the host TERM, then DefaultTerm
+	IsSpawned bool   // Whether this session was spawned in a terminal
+	Encoding  string // Output encoding (utf-8 or latin1), defaults to utf-8
+	Argv0     string // Overrides argv[0] of the command; Cmdline[0] is still executed
+	User      string // Username, uid or uid:gid to run the command as; requires root unless it's the server's user
+
+	// EnvAllowlist selects which host environment variables are inherited.
+	// Entries are shell globs such as "LC_*". Defaults to DefaultEnvAllowlist.
+	EnvAllowlist []string
+
+	// InheritEnv passes the full host environment, minus EnvBlocklist,
+	// instead of only the allowlisted variables
+	InheritEnv   bool
+	EnvBlocklist []string
+
+	// EnvRedact lists variable name patterns whose values are hidden in the
+	// recording header. Defaults to DefaultEnvRedact.
+	EnvRedact []string
+
+	// MaxRecordingSize caps stream-out in bytes; past it the recording is
+	// rotated, keeping one previous segment. Zero or less disables rotation.
+	MaxRecordingSize int64
+
+	// OutputCoalesce merges output arriving within this window into one
+	// recorded event. Zero or less records every read separately.
+	OutputCoalesce time.Duration
+
+	// RecordInput adds the input sent to the session to its recording as
+	// "i" events, except input typed while echo is off
+	RecordInput bool
+
+	// MaxRuntime kills the session once it has run this long, however
+	// active it is. Zero or less is unlimited.
+	MaxRuntime time.Duration
 }
 
 type Info struct {
-	ID        string            `json:"id"`
-	Name      string            `json:"name"`
-	Cmdline   string            `json:"cmdline"`
-	Cwd       string            `json:"cwd"`
-	Pid       int               `json:"pid,omitempty"`
-	Status    string            `json:"status"`
-	ExitCode  *int              `json:"exit_code,omitempty"`
-	StartedAt time.Time         `json:"started_at"`
-	Term      string            `json:"term"`
-	Width     int               `json:"width"`
-	Height    int               `json:"height"`
-	Env       map[string]string `json:"env,omitempty"`
-	Args      []string          `json:"-"`          // Internal use only
-	IsSpawned bool              `json:"is_spawned"` // Whether session was spawned in terminal
+	ID         string            `json:"id"`
+	Name       string            `json:"name"`
+	Cmdline    string            `json:"cmdline"`
+	Cwd        string            `json:"cwd"`
+	Pid        int               `json:"pid,omitempty"`
+	Status     string            `json:"status"`
+	ExitCode   *int              `json:"exit_code,omitempty"`
+	ExitReason string            `json:"exit_reason,omitempty"`
+	StartedAt  time.Time         `json:"started_at"`
+	Term       string            `json:"term"`
+	Width      int               `json:"width"`
+	Height     int               `json:"height"`
+	Env        map[string]string `json:"env,omitempty"`
+	Args       []string          `json:"-"`          // Internal use only
+	IsSpawned  bool              `json:"is_spawned"` // Whether session was spawned in terminal
+	Encoding   string            `json:"encoding,omitempty"`
+	SpawnType  string            `json:"spawn_type,omitempty"`
+
+	// LastActivity is the last output or input as saved periodically; use
+	// Session.LastActivity for the current value
+	LastActivity time.Time `json:"last_activity,omitempty"`
+
+	// Argv0, InheritEnv, User and MaxRuntime are saved so a session prepared
+	// here and started by another process, like a spawned terminal window,
+	// runs the same way
+	Argv0      string        `json:"argv0,omitempty"`
+	InheritEnv bool          `json:"inherit_env,omitempty"`
+	User       string        `json:"user,omitempty"`
+	MaxRuntime time.Duration `json:"max_runtime,omitempty"`
 }
 
 type Session struct {
@@ -67,6 +140,23 @@ type Session struct {
 	stdinPipe   *os.File
 	stdinMutex  sync.Mutex
 	mu          sync.RWMutex
+	runDone     chan struct{} // Closed when the PTY run loop started here ends
+
+	// Environment settings used when starting the PTY (not persisted)
+	envAllowlist []string
+	envBlocklist []string
+	envRedact    []string
+	inheritEnv   bool
+	argv0        string
+	user         *sessionUser // Account to run as, nil for the server's
+
+	maxRecordingSize int64
+	outputCoalesce   time.Duration
+	recordInput      bool
+	maxRuntime       time.Duration
+	webhook          *Webhook // Notified of lifecycle events, may be nil
+
+	activity activityTracker
 }
 
 func newSession(controlPath string, config Config) (*Session, error) {
@@ -83,10 +173,6 @@ func newSessionWithID(controlPath string, id string, config Config) (*Session, e
 			id[:8], config.Name, config.Cmdline, config.Cwd)
 	}
 
-	if err := os.MkdirAll(sessionPath, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create session directory: %w", err)
-	}
-
 	if config.Name == "" {
 		config.Name = id[:8]
 	}
@@ -103,6 +189,21 @@ func newSessionWithID(controlPath string, id string, config Config) (*Session, e
 		}
 	}
 
+	var runAs *sessionUser
+	if config.User != "" {
+		u, err := lookupSessionUser(config.User)
+		if err != nil {
+			return nil, err
+		}
+		runAs = u
+		if config.Cwd == "" {
+			config.Cwd = u.Home
+			if CheckWorkingDir(config.Cwd) != nil {
+				config.Cwd = "/" // System accounts often have no home
+			}
+		}
+	}
+
 	// Set default working directory if empty
 	if config.Cwd == "" {
 		cwd, err := os.Getwd()
@@ -119,19 +220,43 @@ func newSessionWithID(controlPath string, id string, config Config) (*Session, e
 		}
 	}
 
-	term := os.Getenv("TERM")
+	// Fail before anything is written if the command can't be started
+	if _, err := ResolveCommand(config); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(sessionPath, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create session directory: %w", err)
+	}
+
+	encoding, err := protocol.ParseEncoding(config.Encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	// Explicitly requested variables are persisted with the session and
+	// applied on top of the inherited environment when the PTY starts
+	userEnv := envSliceToMap(config.Env)
+
+	term := config.Term
+	if t, ok := userEnv["TERM"]; ok && t != "" {
+		term = t
+	}
 	if term == "" {
-		term = "xterm-256color"
+		term = os.Getenv("TERM")
+	}
+	if term == "" {
+		term = DefaultTerm
 	}
 
 	// Set default terminal dimensions if not provided
 	width := config.Width
 	if width <= 0 {
-		width = 120 // Better default for modern terminals
+		width = DefaultWidth
 	}
 	height := config.Height
 	if height <= 0 {
-		height = 30 // Better default for modern terminals
+		height = DefaultHeight
 	}
 
 	info := &Info{
@@ -144,8 +269,15 @@ func newSessionWithID(controlPath string, id string, config Config) (*Session, e
 		Term:      term,
 		Width:     width,
 		Height:    height,
+		Env:       userEnv,
 		Args:      config.Cmdline,
 		IsSpawned: config.IsSpawned,
+		Encoding:  string(encoding),
+
+		Argv0:      config.Argv0,
+		InheritEnv: config.InheritEnv,
+		User:       config.User,
+		MaxRuntime: config.MaxRuntime,
 	}
 
 	if err := info.Save(sessionPath); err != nil {
@@ -156,9 +288,20 @@ func newSessionWithID(controlPath string, id string, config Config) (*Session, e
 	}
 
 	return &Session{
-		ID:          id,
-		controlPath: controlPath,
-		info:        info,
+		ID:           id,
+		controlPath:  controlPath,
+		info:         info,
+		envAllowlist: config.EnvAllowlist,
+		envBlocklist: config.EnvBlocklist,
+		envRedact:    config.EnvRedact,
+		inheritEnv:   config.InheritEnv,
+		argv0:        config.Argv0,
+		user:         runAs,
+
+		maxRecordingSize: config.MaxRecordingSize,
+		outputCoalesce:   config.OutputCoalesce,
+		recordInput:      config.RecordInput,
+		maxRuntime:       config.MaxRuntime,
 	}, nil
 }
 
@@ -193,8 +336,9 @@ func loadSession(controlPath, id string) (*Session, error) {
 		}
 	}
 
-	// If session is running, we need to reconnect to the PTY for operations like resize
-	// For now, we'll handle this by checking if we need PTY access in individual methods
+	// A running session loaded from disk has no PTY in this process. Input
+	// goes through the stdin FIFO and resize through the control FIFO, both
+	// served by the process that owns the PTY.
 
 	return session, nil
 }
@@ -207,6 +351,22 @@ func (s *Session) StreamOutPath() string {
 	return filepath.Join(s.Path(), "stream-out")
 }
 
+// TailOutput returns the last n bytes of output (all buffered output when
+// n <= 0) from memory, along with the stream-out offset the events end at.
+// ok is false if the session isn't running in this process or the requested
+// output has already been evicted, in which case callers should read from disk.
+func (s *Session) TailOutput(n int) (events []protocol.AsciinemaEvent, offset int64, ok bool) {
+	if s.pty == nil || s.pty.recent == nil {
+		return nil, 0, false
+	}
+	return s.pty.recent.tail(n)
+}
+
+// PreviousStreamOutPath is where the last rotated-out recording segment is kept
+func (s *Session) PreviousStreamOutPath() string {
+	return s.StreamOutPath() + ".1"
+}
+
 func (s *Session) StdinPath() string {
 	return filepath.Join(s.Path(), "stdin")
 }
@@ -232,8 +392,13 @@ func (s *Session) Start() error {
 		return fmt.Errorf("failed to update session info: %w", err)
 	}
 
+	s.logf("Started %s in %s (pid %d)", s.info.Cmdline, s.info.Cwd, s.info.Pid)
+
+	s.runDone = make(chan struct{})
 	go func() {
+		defer close(s.runDone)
 		if err := s.pty.Run(); err != nil {
+			s.logf("Terminal I/O stopped: %v", err)
 			if os.Getenv("VIBETUNNEL_DEBUG") != "" {
 				log.Printf("[DEBUG] Session %s: PTY.Run() exited with error: %v", s.ID[:8], err)
 			}
@@ -244,6 +409,14 @@ func (s *Session) Start() error {
 		}
 	}()
 
+	if s.maxRuntime > 0 {
+		timer := time.AfterFunc(s.maxRuntime, s.killForMaxRuntime)
+		go func() {
+			<-s.pty.exited
+			timer.Stop()
+		}()
+	}
+
 	// Start control listener
 	s.startControlListener()
 
@@ -255,11 +428,39 @@ func (s *Session) Start() error {
 	return nil
 }
 
-func (s *Session) Attach() error {
+// killForMaxRuntime kills a session that has run for its MaxRuntime,
+// recording why so it can be told apart from one that was killed by hand
+func (s *Session) killForMaxRuntime() {
+	s.mu.Lock()
+	s.info.ExitReason = ExitReasonMaxRuntime
+	s.mu.Unlock()
+
+	log.Printf("[INFO] Session %s has run for its max runtime of %v, killing it", s.ID[:8], s.maxRuntime)
+	s.logf("Max runtime of %v exceeded, killing the session", s.maxRuntime)
+	if err := s.Kill(); err != nil {
+		log.Printf("[ERROR] Failed to kill session %s after its max runtime: %v", s.ID[:8], err)
+	}
+}
+
+// Wait blocks until a session started by this process has exited, with its
+// recording flushed and exit status and last activity saved
+func (s *Session) Wait() {
+	if s.runDone == nil {
+		return
+	}
+	<-s.runDone
+	<-s.pty.exited
+	s.activity.saves.Wait()
+}
+
+// IsEchoEnabled reports whether the session's terminal currently echoes
+// input. It is false when that can't be determined, as for sessions running
+// in another process, so callers treat the input as secret.
+func (s *Session) IsEchoEnabled() bool {
 	if s.pty == nil {
-		return fmt.Errorf("session not started")
+		return false
 	}
-	return s.pty.Attach()
+	return s.pty.IsEchoEnabled()
 }
 
 func (s *Session) SendKey(key string) error {
@@ -270,7 +471,48 @@ func (s *Session) SendText(text string) error {
 	return s.sendInput([]byte(text))
 }
 
+// IsBracketedPaste reports whether the program in the session has turned on
+// bracketed paste mode. It is false for sessions running in another process,
+// whose output this one doesn't watch.
+func (s *Session) IsBracketedPaste() bool {
+	if s.pty == nil {
+		return false
+	}
+	return s.pty.IsBracketedPaste()
+}
+
+// SendPaste sends text as a paste: wrapped in bracketed paste markers if the
+// program has asked for them, as is
+func (s *Session) SendPaste(text string) error {
+	if s.IsBracketedPaste() {
+		text = bracketPaste(text)
+	}
+	return s.sendInput([]byte(text))
+}
+
+// IsPlayback reports whether the session only replays a recording, such as
+// one imported from another server. Only sessions marked with
+// SpawnTypePlayback count; a live session whose PID isn't known yet is not
+// one.
+func (s *Session) IsPlayback() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.info.SpawnType == SpawnTypePlayback
+}
+
+// inputChunkSize is PIPE_BUF, the largest write to the stdin FIFO that
+// can't be interleaved with other writers
+const inputChunkSize = 4096
+
+// inputWriteTimeout is how long a write to the stdin FIFO may make no
+// progress before the input is given up on; tests shorten it
+var inputWriteTimeout = 5 * time.Second
+
 func (s *Session) sendInput(data []byte) error {
+	if s.IsPlayback() {
+		return ErrReadOnly
+	}
+
 	s.stdinMutex.Lock()
 	defer s.stdinMutex.Unlock()
 
@@ -288,19 +530,36 @@ func (s *Session) sendInput(data []byte) error {
 		s.stdinPipe = pipe
 	}
 
-	_, err := s.stdinPipe.Write(data)
-	if err != nil {
-		// If write fails, close and reset the pipe for next attempt
-		if err := s.stdinPipe.Close(); err != nil {
-			log.Printf("[ERROR] Failed to close stdin pipe: %v", err)
+	// Large pastes go in pipe-sized chunks, each written atomically, so the
+	// session can feed the PTY while the rest is still arriving and a failed
+	// write only hands what's left to the fallback. The FIFO blocks once the
+	// program stops reading, so each chunk gets a deadline rather than
+	// holding up the request, and every later input, indefinitely.
+	total := len(data)
+	for len(data) > 0 {
+		chunk := data[:min(len(data), inputChunkSize)]
+		if err := s.stdinPipe.SetWriteDeadline(time.Now().Add(inputWriteTimeout)); err != nil {
+			debugLog("[DEBUG] Can't set a deadline on the stdin pipe: %v", err)
 		}
-		s.stdinPipe = nil
+		n, err := s.stdinPipe.Write(chunk)
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			// The pipe is fine, just full; later input may get through
+			return fmt.Errorf("%w: %d of %d bytes written", ErrInputBlocked, total-len(data)+n, total)
+		}
+		if err != nil {
+			// If write fails, close and reset the pipe for next attempt
+			if err := s.stdinPipe.Close(); err != nil {
+				log.Printf("[ERROR] Failed to close stdin pipe: %v", err)
+			}
+			s.stdinPipe = nil
 
-		// Try Node.js proxy fallback like Rust
-		if os.Getenv("VIBETUNNEL_DEBUG") != "" {
-			log.Printf("[DEBUG] Failed to write to stdin pipe, trying Node.js proxy fallback: %v", err)
+			// Try Node.js proxy fallback like Rust
+			if os.Getenv("VIBETUNNEL_DEBUG") != "" {
+				log.Printf("[DEBUG] Failed to write to stdin pipe, trying Node.js proxy fallback: %v", err)
+			}
+			return s.proxyInputToNodeJS(data[n:])
 		}
-		return s.proxyInputToNodeJS(data)
+		data = data[len(chunk):]
 	}
 	return nil
 }
@@ -351,7 +610,14 @@ func (s *Session) proxyInputToNodeJS(data []byte) error {
 	return nil
 }
 
+// Signal sends a signal to the session process. sig may be a signal name
+// (SIGHUP, HUP) or number (1).
 func (s *Session) Signal(sig string) error {
+	signal, err := ParseSignal(sig)
+	if err != nil {
+		return err
+	}
+
 	if s.info.Pid == 0 {
 		return fmt.Errorf("no process to signal")
 	}
@@ -359,33 +625,29 @@ func (s *Session) Signal(sig string) error {
 	// Check if process is still alive before signaling
 	if !s.IsAlive() {
 		// Process is already dead, update status and return success
-		s.info.Status = string(StatusExited)
-		exitCode := 0
-		s.info.ExitCode = &exitCode
-		if err := s.info.Save(s.Path()); err != nil {
+		if err := s.markExited(); err != nil {
 			log.Printf("[ERROR] Failed to save session info: %v", err)
 		}
 		return nil
 	}
 
-	proc, err := os.FindProcess(s.info.Pid)
-	if err != nil {
-		return err
-	}
-
-	switch sig {
-	case "SIGTERM":
-		return proc.Signal(os.Interrupt)
-	case "SIGKILL":
-		err = proc.Kill()
-		// If kill fails with "process already finished", that's okay
-		if err != nil && strings.Contains(err.Error(), "process already finished") {
+	if s.pty == nil {
+		// Prefer the owning process, which may be the only one allowed to
+		// signal the child; fall back to signaling directly if it's gone
+		err := SendControlCommand(s.Path(), &ControlCommand{Cmd: "signal", Signal: sig})
+		if err == nil {
 			return nil
 		}
-		return err
-	default:
-		return fmt.Errorf("unsupported signal: %s", sig)
+		debugLog("[DEBUG] Signaling session %s directly: %v", s.ID[:8], err)
+	}
+
+	s.logf("Sending %s", sig)
+	err = signalProcessGroup(s.info.Pid, signal)
+	// If the process finished in the meantime, that's okay
+	if err == syscall.ESRCH {
+		return nil
 	}
+	return err
 }
 
 func (s *Session) Stop() error {
@@ -426,8 +688,8 @@ func (s *Session) cleanup() {
 }
 
 func (s *Session) Resize(width, height int) error {
-	if s.pty == nil {
-		return fmt.Errorf("session not started")
+	if s.IsPlayback() {
+		return ErrReadOnly
 	}
 
 	// Check if session is still alive
@@ -440,14 +702,31 @@ func (s *Session) Resize(width, height int) error {
 		return fmt.Errorf("invalid dimensions: width=%d, height=%d", width, height)
 	}
 
-	// Update session info
+	if s.pty == nil {
+		// The PTY belongs to another process, such as a CLI-attached session
+		// or one started before this server restarted. Ask its owner to
+		// resize through the control FIFO; the owner saves the new size.
+		if !s.IsAlive() {
+			return fmt.Errorf("session not started")
+		}
+		if err := SendControlCommand(s.Path(), &ControlCommand{Cmd: "resize", Cols: width, Rows: height}); err != nil {
+			return fmt.Errorf("failed to resize via control FIFO: %w", err)
+		}
+		s.mu.Lock()
+		s.info.Width = width
+		s.info.Height = height
+		s.mu.Unlock()
+		return nil
+	}
+
+	// Update and save session info under the lock the exit path also takes
+	s.mu.Lock()
 	s.info.Width = width
 	s.info.Height = height
-
-	// Save updated session info
 	if err := s.info.Save(s.Path()); err != nil {
 		log.Printf("[ERROR] Failed to save session info after resize: %v", err)
 	}
+	s.mu.Unlock()
 
 	// Resize the PTY
 	return s.pty.Resize(width, height)
@@ -528,15 +807,31 @@ func (s *Session) UpdateStatus() error {
 	}
 
 	if !alive {
-		s.info.Status = string(StatusExited)
-		exitCode := 0
-		s.info.ExitCode = &exitCode
-		return s.info.Save(s.Path())
+		return s.markExited()
 	}
 
 	return nil
 }
 
+// markExited records that the session process is gone. The exit code written
+// to session.json by the PTY wait goroutine is preferred; if none was ever
+// recorded the code is -1 (unknown).
+func (s *Session) markExited() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	exitCode := -1
+	if s.info.ExitCode != nil {
+		exitCode = *s.info.ExitCode
+	} else if diskInfo, err := LoadInfo(s.Path()); err == nil && diskInfo.ExitCode != nil {
+		exitCode = *diskInfo.ExitCode
+	}
+
+	s.info.Status = string(StatusExited)
+	s.info.ExitCode = &exitCode
+	return s.info.Save(s.Path())
+}
+
 // GetInfo returns the session info
 func (s *Session) GetInfo() *Info {
 	s.mu.RLock()
@@ -547,17 +842,23 @@ func (s *Session) GetInfo() *Info {
 func (i *Info) Save(sessionPath string) error {
 	// Convert to Rust format for saving
 	rustInfo := RustSessionInfo{
-		ID:        i.ID,
-		Name:      i.Name,
-		Cmdline:   i.Args, // Use Args array instead of Cmdline string
-		Cwd:       i.Cwd,
-		Status:    i.Status,
-		ExitCode:  i.ExitCode,
-		Term:      i.Term,
-		SpawnType: "pty", // Default spawn type
-		Cols:      &i.Width,
-		Rows:      &i.Height,
-		Env:       i.Env,
+		ID:         i.ID,
+		Name:       i.Name,
+		Cmdline:    i.Args, // Use Args array instead of Cmdline string
+		Cwd:        i.Cwd,
+		Status:     i.Status,
+		ExitCode:   i.ExitCode,
+		ExitReason: i.ExitReason,
+		Term:       i.Term,
+		SpawnType:  i.SpawnType,
+		Cols:       &i.Width,
+		Rows:       &i.Height,
+		Env:        i.Env,
+		Encoding:   i.Encoding,
+	}
+
+	if rustInfo.SpawnType == "" {
+		rustInfo.SpawnType = "pty" // Default spawn type
 	}
 
 	// Only include Pid if non-zero
@@ -569,30 +870,73 @@ func (i *Info) Save(sessionPath string) error {
 	if !i.StartedAt.IsZero() {
 		rustInfo.StartedAt = &i.StartedAt
 	}
+	if !i.LastActivity.IsZero() {
+		rustInfo.LastActivity = &i.LastActivity
+	}
+	rustInfo.Argv0 = i.Argv0
+	rustInfo.InheritEnv = i.InheritEnv
+	rustInfo.User = i.User
+	rustInfo.MaxRuntimeSeconds = i.MaxRuntime.Seconds()
 
 	data, err := json.MarshalIndent(rustInfo, "", "  ")
 	if err != nil {
 		return err
 	}
 
-	return os.WriteFile(filepath.Join(sessionPath, "session.json"), data, 0644)
+	// The session's environment may hold secrets, so only the owner may read
+	// it; replacing the file tightens ones saved by older versions too
+	return writeFileAtomic(filepath.Join(sessionPath, "session.json"), data, 0600)
+}
+
+// writeFileAtomic replaces the file at path with data, so readers see either
+// the old or the new contents, never a partial write
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Chmod(perm)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		if removeErr := os.Remove(tmp.Name()); removeErr != nil {
+			log.Printf("[WARN] Failed to remove %s: %v", tmp.Name(), removeErr)
+		}
+	}
+	return err
 }
 
 // RustSessionInfo represents the session format used by the Rust server
 type RustSessionInfo struct {
-	ID        string            `json:"id,omitempty"`
-	Name      string            `json:"name"`
-	Cmdline   []string          `json:"cmdline"`
-	Cwd       string            `json:"cwd"`
-	Pid       *int              `json:"pid,omitempty"`
-	Status    string            `json:"status"`
-	ExitCode  *int              `json:"exit_code,omitempty"`
-	StartedAt *time.Time        `json:"started_at,omitempty"`
-	Term      string            `json:"term"`
-	SpawnType string            `json:"spawn_type,omitempty"`
-	Cols      *int              `json:"cols,omitempty"`
-	Rows      *int              `json:"rows,omitempty"`
-	Env       map[string]string `json:"env,omitempty"`
+	ID         string            `json:"id,omitempty"`
+	Name       string            `json:"name"`
+	Cmdline    []string          `json:"cmdline"`
+	Cwd        string            `json:"cwd"`
+	Pid        *int              `json:"pid,omitempty"`
+	Status     string            `json:"status"`
+	ExitCode   *int              `json:"exit_code,omitempty"`
+	ExitReason string            `json:"exit_reason,omitempty"`
+	StartedAt  *time.Time        `json:"started_at,omitempty"`
+	Term       string            `json:"term"`
+	SpawnType  string            `json:"spawn_type,omitempty"`
+	Cols       *int              `json:"cols,omitempty"`
+	Rows       *int              `json:"rows,omitempty"`
+	Env        map[string]string `json:"env,omitempty"`
+	Encoding   string            `json:"encoding,omitempty"`
+
+	LastActivity *time.Time `json:"last_activity,omitempty"`
+	Argv0        string     `json:"argv0,omitempty"`
+	InheritEnv   bool       `json:"inherit_env,omitempty"`
+	User         string     `json:"user,omitempty"`
+
+	MaxRuntimeSeconds float64 `json:"max_runtime_seconds,omitempty"`
 }
 
 func LoadInfo(sessionPath string) (*Info, error) {
@@ -609,15 +953,18 @@ func LoadInfo(sessionPath string) (*Info, error) {
 
 	// Convert Rust format to internal Info format
 	info := Info{
-		ID:       rustInfo.ID,
-		Name:     rustInfo.Name,
-		Cmdline:  strings.Join(rustInfo.Cmdline, " "),
-		Cwd:      rustInfo.Cwd,
-		Status:   rustInfo.Status,
-		ExitCode: rustInfo.ExitCode,
-		Term:     rustInfo.Term,
-		Args:     rustInfo.Cmdline,
-		Env:      rustInfo.Env,
+		ID:         rustInfo.ID,
+		Name:       rustInfo.Name,
+		Cmdline:    strings.Join(rustInfo.Cmdline, " "),
+		Cwd:        rustInfo.Cwd,
+		Status:     rustInfo.Status,
+		ExitCode:   rustInfo.ExitCode,
+		ExitReason: rustInfo.ExitReason,
+		Term:       rustInfo.Term,
+		Args:       rustInfo.Cmdline,
+		Env:        rustInfo.Env,
+		Encoding:   rustInfo.Encoding,
+		SpawnType:  rustInfo.SpawnType,
 	}
 
 	// Handle PID conversion
@@ -629,12 +976,12 @@ func LoadInfo(sessionPath string) (*Info, error) {
 	if rustInfo.Cols != nil {
 		info.Width = *rustInfo.Cols
 	} else {
-		info.Width = 120
+		info.Width = DefaultWidth
 	}
 	if rustInfo.Rows != nil {
 		info.Height = *rustInfo.Rows
 	} else {
-		info.Height = 30
+		info.Height = DefaultHeight
 	}
 
 	// Handle timestamp
@@ -643,6 +990,13 @@ func LoadInfo(sessionPath string) (*Info, error) {
 	} else {
 		info.StartedAt = time.Now()
 	}
+	if rustInfo.LastActivity != nil {
+		info.LastActivity = *rustInfo.LastActivity
+	}
+	info.Argv0 = rustInfo.Argv0
+	info.InheritEnv = rustInfo.InheritEnv
+	info.User = rustInfo.User
+	info.MaxRuntime = time.Duration(rustInfo.MaxRuntimeSeconds * float64(time.Second))
 
 	// If ID is empty (Rust doesn't store it in JSON), derive it from directory name
 	if info.ID == "" {
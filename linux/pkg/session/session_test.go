@@ -0,0 +1,357 @@
+package session
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vibetunnel/linux/pkg/protocol"
+)
+
+func TestImportedSessionIsReadOnly(t *testing.T) {
+	m := NewManager(t.TempDir())
+	info, err := json.Marshal(RustSessionInfo{
+		ID:      "5d9e8c0e-6c4f-4d3e-9a53-0a8f3c1c2b11",
+		Name:    "imported",
+		Cmdline: []string{"bash"},
+		Status:  string(StatusRunning),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle, err := json.Marshal(ExportBundle{
+		Version:  ExportVersion,
+		Sessions: []ExportedSession{{Session: info}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids, err := m.Import(bytes.NewReader(bundle), false)
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+
+	sess, err := m.GetSession(ids[0])
+	if err != nil {
+		t.Fatalf("GetSession: %v", err)
+	}
+	if !sess.IsPlayback() {
+		t.Fatal("imported session is not playback")
+	}
+	if err := sess.SendText("ls\n"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("SendText = %v, want ErrReadOnly", err)
+	}
+	if err := sess.Resize(80, 24); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Resize = %v, want ErrReadOnly", err)
+	}
+}
+
+func TestSessionWithoutPidIsNotPlayback(t *testing.T) {
+	sess := &Session{ID: "live", info: &Info{Status: string(StatusRunning)}}
+	if sess.IsPlayback() {
+		t.Error("running session without a PID yet is reported as playback")
+	}
+}
+
+func TestSessionInfoIsPrivate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "session.json")
+	// Saved by an older version with the old permissions
+	if err := os.WriteFile(path, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info := &Info{ID: "id", Env: map[string]string{"GITHUB_TOKEN": "secret"}}
+	if err := info.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := stat.Mode().Perm(); mode != 0600 {
+		t.Errorf("session.json mode = %#o, want 0600", mode)
+	}
+}
+
+func TestSessionInfoSaveIsAtomic(t *testing.T) {
+	dir := t.TempDir()
+	info := &Info{ID: "id", Args: []string{"bash"}, Status: string(StatusRunning)}
+	if err := info.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		for i := 0; i < 500; i++ {
+			saved := &Info{ID: "id", Name: strings.Repeat("x", i*20), Args: []string{"bash"}, Status: string(StatusRunning)}
+			if err := saved.Save(dir); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+	for saving := true; saving; {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("Save: %v", err)
+			}
+			saving = false
+		default:
+		}
+		if _, err := LoadInfo(dir); err != nil {
+			t.Fatalf("LoadInfo while saving: %v", err)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("session directory holds %d entries, want only session.json", len(entries))
+	}
+}
+
+func TestActivitySaveDoesNotBlock(t *testing.T) {
+	controlPath := t.TempDir()
+	sess := &Session{ID: "id", controlPath: controlPath, info: &Info{ID: "id", Status: string(StatusRunning)}}
+	if err := os.Mkdir(sess.Path(), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// Output keeps flowing while something else holds the session
+	sess.mu.Lock()
+	touched := make(chan struct{})
+	go func() {
+		sess.touchActivity()
+		close(touched)
+	}()
+	select {
+	case <-touched:
+	case <-time.After(5 * time.Second):
+		sess.mu.Unlock()
+		t.Fatal("touchActivity blocked on the session lock")
+	}
+	sess.mu.Unlock()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		info, err := LoadInfo(sess.Path())
+		if err == nil && !info.LastActivity.IsZero() {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("activity wasn't saved: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestInputGivesUpWhenNotRead(t *testing.T) {
+	timeout := inputWriteTimeout
+	inputWriteTimeout = 200 * time.Millisecond
+	defer func() { inputWriteTimeout = timeout }()
+
+	m := NewManager(t.TempDir())
+	sess, err := m.CreateSession(Config{
+		// In raw mode the terminal holds unread input instead of dropping
+		// it, so the stdin pipe fills up behind it
+		Cmdline: []string{"/bin/sh", "-c", `stty raw -echo; echo ready; exec sleep 30`},
+	})
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	defer func() {
+		if err := sess.Kill(); err != nil {
+			t.Logf("Failed to kill session: %v", err)
+		}
+		sess.Wait()
+	}()
+	waitForOutput(t, sess, "ready")
+
+	start := time.Now()
+	err = sess.SendText(strings.Repeat("x", 4*1024*1024))
+	if !errors.Is(err, ErrInputBlocked) {
+		t.Fatalf("SendText = %v, want ErrInputBlocked", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("SendText took %v to give up", elapsed)
+	}
+
+	// A blocked write leaves the pipe usable, and other input isn't held up
+	start = time.Now()
+	if err := sess.SendText("more"); !errors.Is(err, ErrInputBlocked) {
+		t.Errorf("SendText to a full pipe = %v, want ErrInputBlocked", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("SendText to a full pipe took %v", elapsed)
+	}
+}
+
+func TestLargePasteArrivesWhole(t *testing.T) {
+	const size = 1024 * 1024
+	m := NewManager(t.TempDir())
+	sess, err := m.CreateSession(Config{
+		Cmdline: []string{"/bin/sh", "-c", `stty raw -echo; echo ready; echo "got $(head -c 1048576 | wc -c) bytes"`},
+	})
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	defer sess.Wait()
+	waitForOutput(t, sess, "ready")
+
+	if err := sess.SendText(strings.Repeat("x", size)); err != nil {
+		t.Fatalf("SendText: %v", err)
+	}
+	waitForOutput(t, sess, "got 1048576 bytes")
+}
+
+func TestTailOutputMatchesRecording(t *testing.T) {
+	// Well over the ring's size, with multi-byte characters to trim at
+	sess, err := NewManager(t.TempDir()).CreateSession(Config{
+		Cmdline: []string{"/bin/sh", "-c", `yes 'héllo wörld €' | head -n 40000`},
+	})
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	sess.Wait()
+
+	file, err := os.Open(sess.StreamOutPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			t.Logf("Failed to close recording: %v", err)
+		}
+	}()
+	var recorded strings.Builder
+	reader := protocol.NewStreamReader(file)
+	for {
+		event, err := reader.Next()
+		if err != nil {
+			t.Fatalf("invalid recording: %v", err)
+		}
+		if event.Type == "end" {
+			break
+		}
+		if event.Event != nil && event.Event.Type == protocol.EventOutput {
+			recorded.WriteString(event.Event.Data)
+		}
+	}
+	if recorded.Len() <= outputRingSize {
+		t.Fatalf("recorded %d bytes of output, want more than the ring's %d", recorded.Len(), outputRingSize)
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, n := range []int{1, 4097, 100000, outputRingSize - 3} {
+		events, offset, ok := sess.TailOutput(n)
+		if !ok {
+			t.Fatalf("TailOutput(%d) is not available", n)
+		}
+		var tail strings.Builder
+		for _, event := range events {
+			tail.WriteString(event.Data)
+		}
+		// Trimmed to a character boundary, so up to 3 bytes short
+		if tail.Len() > n || tail.Len() < n-3 {
+			t.Errorf("TailOutput(%d) returned %d bytes", n, tail.Len())
+		}
+		if !strings.HasSuffix(recorded.String(), tail.String()) {
+			t.Errorf("TailOutput(%d) is not the end of the recorded output", n)
+		}
+		if offset != stat.Size() {
+			t.Errorf("TailOutput(%d) offset = %d, want the recording's size %d", n, offset, stat.Size())
+		}
+	}
+
+	// Evicted output has to be read from disk
+	if _, _, ok := sess.TailOutput(0); ok {
+		t.Error("TailOutput(0) is available after output was evicted")
+	}
+	if _, _, ok := sess.TailOutput(outputRingSize + 1); ok {
+		t.Errorf("TailOutput(%d) is available after output was evicted", outputRingSize+1)
+	}
+}
+
+// exitCodeString formats an exit code that may not have been recorded
+func exitCodeString(code *int) string {
+	if code == nil {
+		return "none"
+	}
+	return strconv.Itoa(*code)
+}
+
+func TestMarkExitedKeepsExitCode(t *testing.T) {
+	sess, err := NewManager(t.TempDir()).CreateSession(Config{Cmdline: []string{"/bin/sh", "-c", "exit 3"}})
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	sess.Wait()
+	// Noticing the process is gone again mustn't replace the real code
+	if err := sess.markExited(); err != nil {
+		t.Fatal(err)
+	}
+	if code := sess.GetInfo().ExitCode; code == nil || *code != 3 {
+		t.Errorf("exit code = %s, want 3", exitCodeString(code))
+	}
+	saved, err := LoadInfo(sess.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.ExitCode == nil || *saved.ExitCode != 3 || saved.Status != string(StatusExited) {
+		t.Errorf("saved status %s, exit code %s, want exited with 3", saved.Status, exitCodeString(saved.ExitCode))
+	}
+}
+
+func TestMarkExitedFallback(t *testing.T) {
+	five, seven := 5, 7
+	tests := []struct {
+		name   string
+		memory *int // Exit code this process knows of
+		saved  *int // Exit code in session.json
+		want   int
+	}{
+		{"in memory", &five, nil, 5},
+		{"saved by the owner", nil, &seven, 7},
+		{"never recorded", nil, nil, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			controlPath := t.TempDir()
+			sess := &Session{ID: "id", controlPath: controlPath, info: &Info{ID: "id", Status: string(StatusRunning), ExitCode: tt.memory}}
+			if err := os.Mkdir(sess.Path(), 0755); err != nil {
+				t.Fatal(err)
+			}
+			saved := &Info{ID: "id", Status: string(StatusRunning), ExitCode: tt.saved}
+			if err := saved.Save(sess.Path()); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := sess.markExited(); err != nil {
+				t.Fatal(err)
+			}
+			if code := sess.GetInfo().ExitCode; code == nil || *code != tt.want {
+				t.Errorf("exit code = %s, want %d", exitCodeString(code), tt.want)
+			}
+			loaded, err := LoadInfo(sess.Path())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if loaded.ExitCode == nil || *loaded.ExitCode != tt.want || loaded.Status != string(StatusExited) {
+				t.Errorf("saved status %s, exit code %s, want exited with %d", loaded.Status, exitCodeString(loaded.ExitCode), tt.want)
+			}
+		})
+	}
+}
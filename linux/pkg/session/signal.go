@@ -0,0 +1,67 @@
+package session
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// signalNames maps signal names (without the SIG prefix) to signals
+var signalNames = map[string]syscall.Signal{
+	"HUP":   syscall.SIGHUP,
+	"INT":   syscall.SIGINT,
+	"QUIT":  syscall.SIGQUIT,
+	"KILL":  syscall.SIGKILL,
+	"USR1":  syscall.SIGUSR1,
+	"USR2":  syscall.SIGUSR2,
+	"PIPE":  syscall.SIGPIPE,
+	"ALRM":  syscall.SIGALRM,
+	"TERM":  syscall.SIGTERM,
+	"CHLD":  syscall.SIGCHLD,
+	"CONT":  syscall.SIGCONT,
+	"STOP":  syscall.SIGSTOP,
+	"TSTP":  syscall.SIGTSTP,
+	"TTIN":  syscall.SIGTTIN,
+	"TTOU":  syscall.SIGTTOU,
+	"WINCH": syscall.SIGWINCH,
+}
+
+// ParseSignal converts a signal name ("SIGHUP", "HUP", "hup") or number ("1")
+// into a syscall.Signal
+func ParseSignal(sig string) (syscall.Signal, error) {
+	sig = strings.TrimSpace(sig)
+	if sig == "" {
+		return 0, fmt.Errorf("empty signal")
+	}
+
+	if num, err := strconv.Atoi(sig); err == nil {
+		if num <= 0 || num >= 65 {
+			return 0, fmt.Errorf("invalid signal number: %d", num)
+		}
+		return syscall.Signal(num), nil
+	}
+
+	name := strings.TrimPrefix(strings.ToUpper(sig), "SIG")
+	if signal, ok := signalNames[name]; ok {
+		return signal, nil
+	}
+
+	return 0, fmt.Errorf("unsupported signal: %s", sig)
+}
+
+// signalProcessGroup delivers sig to the whole process group led by pid so
+// pipelines and background jobs started by the shell receive it too. PTY
+// children are started with Setsid, which already makes them the leader of a
+// new group; the group is only used when that's the case, otherwise (or if
+// the group call fails) the single process is signaled.
+func signalProcessGroup(pid int, sig syscall.Signal) error {
+	if pgid, err := syscall.Getpgid(pid); err == nil && pgid == pid {
+		err := syscall.Kill(-pgid, sig)
+		if err == nil {
+			return nil
+		}
+		debugLog("[DEBUG] Failed to signal process group %d, falling back to PID: %v", pgid, err)
+	}
+	return syscall.Kill(pid, sig)
+}
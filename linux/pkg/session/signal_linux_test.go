@@ -0,0 +1,45 @@
+//go:build linux
+// +build linux
+
+package session
+
+import (
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSignalReachesGrandchild(t *testing.T) {
+	// The shell's background job is in its process group but isn't its
+	// leader, so only signaling the group reaches it. It ignores SIGHUP,
+	// which it would otherwise get once the shell exits and the terminal
+	// hangs up.
+	sess, err := NewManager(t.TempDir()).CreateSession(Config{
+		Cmdline: []string{"/bin/sh", "-c", `trap "" HUP; sleep 30 & echo "grandchild=$!"; wait`},
+	})
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	t.Cleanup(sess.Wait)
+	match := regexp.MustCompile(`grandchild=(\d+)`).FindStringSubmatch(waitForOutput(t, sess, "grandchild="))
+	if match == nil {
+		t.Fatal("no grandchild PID in the output")
+	}
+	grandchild, _ := strconv.Atoi(match[1])
+
+	if err := sess.Signal("TERM"); err != nil {
+		t.Fatalf("Signal: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		state, _, _, ok := procStat(grandchild)
+		if !ok || state == "Z" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("grandchild %d is still running in state %s", grandchild, state)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
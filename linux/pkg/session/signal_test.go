@@ -0,0 +1,44 @@
+package session
+
+import (
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+func TestParseSignal(t *testing.T) {
+	tests := []struct {
+		sig  string
+		want syscall.Signal
+	}{
+		{"SIGHUP", syscall.SIGHUP},
+		{"HUP", syscall.SIGHUP},
+		{"hup", syscall.SIGHUP},
+		{"sigterm", syscall.SIGTERM},
+		{"TERM", syscall.SIGTERM},
+		{"SIGKILL", syscall.SIGKILL},
+		{"INT", syscall.SIGINT},
+		{"SIGWINCH", syscall.SIGWINCH},
+		{"USR1", syscall.SIGUSR1},
+		{" SIGCONT ", syscall.SIGCONT},
+	}
+	for _, tt := range tests {
+		got, err := ParseSignal(tt.sig)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseSignal(%q) = %v, %v, want %v", tt.sig, got, err, tt.want)
+		}
+	}
+
+	for num := 1; num <= 64; num++ {
+		got, err := ParseSignal(strconv.Itoa(num))
+		if err != nil || got != syscall.Signal(num) {
+			t.Errorf("ParseSignal(%d) = %v, %v, want %d", num, got, err, num)
+		}
+	}
+
+	for _, sig := range []string{"", " ", "0", "65", "-1", "-9", "1.5", "SIG", "FOO", "SIGFOO", "SIGSIGHUP", "HUP1"} {
+		if got, err := ParseSignal(sig); err == nil {
+			t.Errorf("ParseSignal(%q) = %v, want an error", sig, got)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package session
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/vibetunnel/linux/pkg/protocol"
+)
+
+// DefaultErrorPattern matches output lines that look like failures
+const DefaultErrorPattern = `(?i)\b(error|fatal|panic|exception|traceback|failed)\b`
+
+const (
+	// maxSummaryEventSize bounds a single line of a recording; a longer one
+	// means the file isn't a recording the writer produced
+	maxSummaryEventSize = 1024 * 1024
+
+	// maxSummaryLineLen bounds the output line matched against the error
+	// pattern. Longer lines are matched in pieces, keeping a little overlap
+	// so a match across the cut is still found.
+	maxSummaryLineLen  = 64 * 1024
+	summaryLineOverlap = 256
+)
+
+// Summary is a postmortem overview of a single session recording
+type Summary struct {
+	ID          string  `json:"id"`
+	Name        string  `json:"name"`
+	Command     string  `json:"command"`
+	Status      string  `json:"status"`
+	ExitCode    *int    `json:"exit_code,omitempty"`
+	Duration    float64 `json:"duration"` // Seconds, taken from the last recorded event
+	OutputBytes int64   `json:"output_bytes"`
+	HasErrors   bool    `json:"has_errors"`
+}
+
+// Summarize reads the recording of every session whose status matches
+// (all sessions when status is empty) and reports a summary for each.
+// Output lines are checked against errorPattern to flag likely failures.
+// Sessions whose recording can't be read are left out with a warning.
+func (m *Manager) Summarize(status string, errorPattern *regexp.Regexp) ([]Summary, error) {
+	sessions, err := m.ListSessions()
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := make([]Summary, 0, len(sessions))
+	for _, info := range sessions {
+		if status != "" && info.Status != status {
+			continue
+		}
+
+		streamPath := filepath.Join(m.controlPath, info.ID, "stream-out")
+		summary, err := SummarizeRecording(info, streamPath, errorPattern)
+		if err != nil {
+			log.Printf("[WARN] Skipping session %s: %v", info.ID, err)
+			continue
+		}
+		summaries = append(summaries, *summary)
+	}
+
+	return summaries, nil
+}
+
+// SummarizeRecording builds a Summary for a session from its asciinema
+// recording. A missing recording yields an empty summary rather than an error.
+func SummarizeRecording(info *Info, streamPath string, errorPattern *regexp.Regexp) (*Summary, error) {
+	summary := &Summary{
+		ID:       info.ID,
+		Name:     info.Name,
+		Command:  info.Cmdline,
+		Status:   info.Status,
+		ExitCode: info.ExitCode,
+	}
+
+	file, err := os.Open(streamPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return summary, nil
+		}
+		return nil, err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			debugLog("[DEBUG] Failed to close %s: %v", streamPath, err)
+		}
+	}()
+
+	// Output is matched line by line; a partial line is carried over to the
+	// next event so patterns split across writes are still found
+	var line []byte
+	matchLines := func(data []byte) {
+		line = append(line, data...)
+		for {
+			idx := bytes.IndexByte(line, '\n')
+			if idx < 0 {
+				break
+			}
+			if errorPattern != nil && errorPattern.Match(line[:idx]) {
+				summary.HasErrors = true
+			}
+			line = line[idx+1:]
+		}
+		if len(line) > maxSummaryLineLen {
+			if errorPattern != nil && errorPattern.Match(line) {
+				summary.HasErrors = true
+			}
+			line = append([]byte(nil), line[len(line)-summaryLineOverlap:]...)
+		}
+	}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxSummaryEventSize)
+	headerRead := false
+	var pending error // A bad line is only fatal if more lines follow it
+	for scanner.Scan() {
+		if pending != nil {
+			return nil, pending
+		}
+		data := scanner.Bytes()
+		if len(bytes.TrimSpace(data)) == 0 {
+			continue
+		}
+		if !headerRead {
+			var header protocol.AsciinemaHeader
+			if err := json.Unmarshal(data, &header); err != nil {
+				return nil, fmt.Errorf("invalid recording header: %w", err)
+			}
+			headerRead = true
+			continue
+		}
+
+		var event []interface{}
+		if err := json.Unmarshal(data, &event); err != nil || len(event) != 3 {
+			// The trailing event may still be being written
+			pending = fmt.Errorf("invalid event in recording")
+			continue
+		}
+		at, _ := event[0].(float64)
+		eventType, _ := event[1].(string)
+		output, _ := event[2].(string)
+
+		summary.Duration = at
+		if eventType == string(protocol.EventOutput) {
+			summary.OutputBytes += int64(len(output))
+			if !summary.HasErrors {
+				matchLines([]byte(output))
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+
+	if !summary.HasErrors && errorPattern != nil && errorPattern.Match(line) {
+		summary.HasErrors = true
+	}
+
+	return summary, nil
+}
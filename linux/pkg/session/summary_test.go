@@ -0,0 +1,73 @@
+package session
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// writeRecordedSession stores an exited session with the given recording
+func writeRecordedSession(t *testing.T, controlPath, id string, exitCode int, recording string) {
+	t.Helper()
+	dir := filepath.Join(controlPath, id)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	info := &Info{
+		ID:       id,
+		Name:     id,
+		Args:     []string{"make", "test"},
+		Status:   string(StatusExited),
+		ExitCode: &exitCode,
+	}
+	if err := info.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "stream-out"), []byte(recording), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	controlPath := t.TempDir()
+	header := `{"version":2,"width":80,"height":24}` + "\n"
+	writeRecordedSession(t, controlPath, "ok", 0,
+		header+`[0.5,"o","building\r\n"]`+"\n"+`[1.5,"o","done\r\n"]`+"\n")
+	writeRecordedSession(t, controlPath, "failing-session", 2,
+		header+`[0.1,"o","Err"]`+"\n"+`[0.2,"o","or: missing file\r\n"]`+"\n"+`[3,"i","q"]`+"\n")
+	writeRecordedSession(t, controlPath, "corrupt", 1,
+		header+`not an event`+"\n"+`[1,"o","x"]`+"\n")
+	// One long line without newlines, with an error near its end
+	writeRecordedSession(t, controlPath, "long-line", 0,
+		header+`[1,"o","`+strings.Repeat("x", 3*maxSummaryLineLen)+` fatal"]`+"\n")
+
+	summaries, err := NewManager(controlPath).Summarize("", regexp.MustCompile(DefaultErrorPattern))
+	if err != nil {
+		t.Fatalf("Summarize: %v", err)
+	}
+
+	got := make(map[string]Summary)
+	for _, s := range summaries {
+		got[s.ID] = s
+	}
+	if _, ok := got["corrupt"]; ok {
+		t.Error("corrupt recording was summarized instead of skipped")
+	}
+	if len(got) != 3 {
+		t.Fatalf("got %d summaries, want 3: %+v", len(got), summaries)
+	}
+
+	ok := got["ok"]
+	if ok.Duration != 1.5 || ok.OutputBytes != 16 || ok.HasErrors || *ok.ExitCode != 0 || ok.Command != "make test" {
+		t.Errorf("ok summary = %+v", ok)
+	}
+	failing := got["failing-session"]
+	if failing.Duration != 3 || failing.OutputBytes != 21 || !failing.HasErrors || *failing.ExitCode != 2 {
+		t.Errorf("failing summary = %+v", failing)
+	}
+	if !got["long-line"].HasErrors {
+		t.Error("error at the end of a long line wasn't found")
+	}
+}
@@ -0,0 +1,116 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// ErrInvalidUser matches errors for session users that can't be resolved or
+// switched to; use errors.As with *UserError to get the requested user
+var ErrInvalidUser = errors.New("invalid session user")
+
+// UserError reports a Config.User the session can't run as
+type UserError struct {
+	User string
+	Err  error
+}
+
+func (e *UserError) Error() string {
+	return fmt.Sprintf("cannot run session as %q: %v", e.User, e.Err)
+}
+
+func (e *UserError) Is(target error) bool {
+	return target == ErrInvalidUser
+}
+
+func (e *UserError) Unwrap() error {
+	return e.Err
+}
+
+// sessionUser is the account a session runs as
+type sessionUser struct {
+	Uid    uint32
+	Gid    uint32
+	Groups []uint32
+	Name   string
+	Home   string
+}
+
+// lookupSessionUser resolves spec, a username, uid, or uid:gid, and checks
+// the server may switch to it. Switching only ever drops privileges: unless
+// the server runs as root, the only user allowed is the server's own.
+func lookupSessionUser(spec string) (*sessionUser, error) {
+	fail := func(err error) (*sessionUser, error) {
+		return nil, &UserError{User: spec, Err: err}
+	}
+
+	name, gidSpec, hasGid := strings.Cut(spec, ":")
+	var account *user.User
+	var err error
+	if _, numErr := strconv.ParseUint(name, 10, 32); numErr == nil {
+		account, err = user.LookupId(name)
+		if err != nil && !hasGid {
+			return fail(err)
+		}
+	} else {
+		account, err = user.Lookup(name)
+		if err != nil {
+			return fail(err)
+		}
+	}
+
+	u := &sessionUser{Name: name, Home: "/"}
+	if account != nil {
+		u.Name = account.Username
+		u.Home = account.HomeDir
+		name = account.Uid
+		if !hasGid {
+			gidSpec = account.Gid
+		}
+	}
+
+	uid, err := strconv.ParseUint(name, 10, 32)
+	if err != nil {
+		return fail(fmt.Errorf("invalid uid %q", name))
+	}
+	gid, err := strconv.ParseUint(gidSpec, 10, 32)
+	if err != nil {
+		return fail(fmt.Errorf("invalid gid %q", gidSpec))
+	}
+	u.Uid = uint32(uid)
+	u.Gid = uint32(gid)
+
+	// Without supplementary groups the child would keep the server's
+	if account != nil && account.Gid == gidSpec {
+		if ids, err := account.GroupIds(); err == nil {
+			for _, id := range ids {
+				if g, err := strconv.ParseUint(id, 10, 32); err == nil {
+					u.Groups = append(u.Groups, uint32(g))
+				}
+			}
+		}
+	}
+
+	if euid := os.Geteuid(); euid != 0 && (int(u.Uid) != euid || int(u.Gid) != os.Getegid()) {
+		return fail(errors.New("switching users requires the server to run as root"))
+	}
+	return u, nil
+}
+
+// credential returns the credential the session's process starts with, or
+// nil when the server already runs as the user
+func (u *sessionUser) credential() *syscall.Credential {
+	if os.Geteuid() != 0 {
+		return nil // lookupSessionUser only allows the server's own user
+	}
+	return &syscall.Credential{
+		Uid:    u.Uid,
+		Gid:    u.Gid,
+		Groups: u.Groups,
+	}
+}
@@ -0,0 +1,159 @@
+package session
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Webhook event types
+const (
+	WebhookEventStarted = "started"
+	WebhookEventExited  = "exited"
+)
+
+// WebhookSignatureHeader carries the hex HMAC-SHA256 of the request body,
+// keyed with the webhook secret, as "sha256=<hex>"
+const WebhookSignatureHeader = "X-VibeTunnel-Signature"
+
+const (
+	webhookAttempts     = 3
+	webhookRetryDelay   = time.Second // Doubled after each failed attempt
+	webhookTimeout      = 10 * time.Second
+	webhookFlushTimeout = 5 * time.Second
+)
+
+// WebhookEvent is the JSON payload posted for a session lifecycle event
+type WebhookEvent struct {
+	Event      string    `json:"event"`
+	SessionID  string    `json:"sessionId"`
+	Name       string    `json:"name"`
+	Command    string    `json:"command"`
+	ExitCode   *int      `json:"exitCode,omitempty"`   // Only set for exited events
+	ExitReason string    `json:"exitReason,omitempty"` // Set when the server ended the session
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// Webhook posts session lifecycle events to a URL. Deliveries run in the
+// background and are retried on network errors and 5xx responses.
+type Webhook struct {
+	url     string
+	secret  string
+	client  *http.Client
+	pending sync.WaitGroup
+}
+
+// NewWebhook creates a webhook posting to url. If secret is set, payloads
+// are signed in the X-VibeTunnel-Signature header.
+func NewWebhook(url, secret string) *Webhook {
+	return &Webhook{
+		url:    url,
+		secret: secret,
+		client: &http.Client{Timeout: webhookTimeout},
+	}
+}
+
+// Send delivers event in the background
+func (w *Webhook) Send(event WebhookEvent) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("[ERROR] Webhook: Failed to encode %s event: %v", event.Event, err)
+		return
+	}
+
+	w.pending.Add(1)
+	go func() {
+		defer w.pending.Done()
+
+		delay := webhookRetryDelay
+		for attempt := 1; ; attempt++ {
+			err := w.post(body)
+			if err == nil {
+				return
+			}
+			if attempt == webhookAttempts {
+				log.Printf("[ERROR] Webhook: Failed to deliver %s event for session %s: %v", event.Event, event.SessionID[:8], err)
+				return
+			}
+			debugLog("[DEBUG] Webhook: Attempt %d failed, retrying in %s: %v", attempt, delay, err)
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}()
+}
+
+// post makes a single delivery attempt
+func (w *Webhook) post(body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "VibeTunnel-Webhook")
+	if w.secret != "" {
+		mac := hmac.New(sha256.New, []byte(w.secret))
+		mac.Write(body)
+		req.Header.Set(WebhookSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	if err := resp.Body.Close(); err != nil {
+		debugLog("[DEBUG] Webhook: Failed to close response body: %v", err)
+	}
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("server returned %s", resp.Status)
+	}
+	if resp.StatusCode >= 300 {
+		// Client errors won't go away by retrying
+		log.Printf("[WARN] Webhook: %s rejected the event: %s", w.url, resp.Status)
+	}
+	return nil
+}
+
+// Flush waits a few seconds for pending deliveries, so events sent just
+// before the process exits aren't lost
+func (w *Webhook) Flush() {
+	done := make(chan struct{})
+	go func() {
+		w.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(webhookFlushTimeout):
+		log.Printf("[WARN] Webhook: Gave up waiting for pending deliveries")
+	}
+}
+
+// notifyWebhook sends a lifecycle event for s if a webhook is configured
+func (s *Session) notifyWebhook(event string) {
+	if s.webhook == nil {
+		return
+	}
+
+	s.mu.RLock()
+	payload := WebhookEvent{
+		Event:     event,
+		SessionID: s.ID,
+		Name:      s.info.Name,
+		Command:   s.info.Cmdline,
+		Timestamp: time.Now(),
+	}
+	if event == WebhookEventExited {
+		payload.ExitCode = s.info.ExitCode
+		payload.ExitReason = s.info.ExitReason
+	}
+	s.mu.RUnlock()
+
+	s.webhook.Send(payload)
+}
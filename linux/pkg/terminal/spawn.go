@@ -1,12 +1,28 @@
 package terminal
 
 import (
+	"errors"
 	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"runtime"
 	"strings"
 )
 
+var (
+	// ErrNoDisplay is returned on Linux when there is no graphical session
+	// to open a terminal window in
+	ErrNoDisplay = errors.New("no graphical display available (DISPLAY and WAYLAND_DISPLAY are unset)")
+
+	// ErrNoTerminal is returned when no supported terminal emulator is installed
+	ErrNoTerminal = errors.New("no supported terminal emulator found")
+)
+
+// lookPath finds terminal emulators; tests replace it to fake which ones
+// are installed
+var lookPath = exec.LookPath
+
 // SpawnInTerminal opens a new terminal window running the specified command
 // This is used as a fallback when the Mac app's terminal service is not available
 func SpawnInTerminal(sessionID, vtBinaryPath string, cmdline []string, workingDir string) error {
@@ -19,12 +35,27 @@ func SpawnInTerminal(sessionID, vtBinaryPath string, cmdline []string, workingDi
 	case "darwin":
 		return spawnMacTerminal(vtCommand, workingDir)
 	case "linux":
-		return spawnLinuxTerminal(vtCommand, workingDir)
+		return spawnLinuxTerminal([]string{"bash", "-c", vtCommand}, workingDir)
 	default:
 		return fmt.Errorf("terminal spawning not supported on %s", runtime.GOOS)
 	}
 }
 
+// SpawnDetachedSession opens a new terminal window running
+// `vibetunnel --detached-session <id> -- <cmd>`, which starts a session
+// already prepared in controlPath and attaches the window to it. Only Linux
+// is supported; ErrNoDisplay and ErrNoTerminal report why no window could
+// be opened.
+func SpawnDetachedSession(vibetunnelPath, controlPath, sessionID string, cmdline []string, workingDir string) error {
+	if runtime.GOOS != "linux" {
+		return fmt.Errorf("terminal spawning not supported on %s", runtime.GOOS)
+	}
+
+	argv := []string{vibetunnelPath, "--control-path", controlPath, "--detached-session", sessionID, "--"}
+	argv = append(argv, cmdline...)
+	return spawnLinuxTerminal(argv, workingDir)
+}
+
 func spawnMacTerminal(command, workingDir string) error {
 	// Use osascript to open Terminal.app with the command
 	script := fmt.Sprintf(`
@@ -38,36 +69,86 @@ func spawnMacTerminal(command, workingDir string) error {
 	return cmd.Run()
 }
 
-func spawnLinuxTerminal(command, workingDir string) error {
-	// Try common Linux terminal emulators in order of preference
-	terminals := []struct {
-		name string
-		args func(string, string) []string
-	}{
-		{"gnome-terminal", func(cmd, wd string) []string {
-			return []string{"--working-directory=" + wd, "--", "bash", "-c", cmd}
-		}},
-		{"konsole", func(cmd, wd string) []string {
-			return []string{"--workdir", wd, "-e", "bash", "-c", cmd}
-		}},
-		{"xfce4-terminal", func(cmd, wd string) []string {
-			return []string{"--working-directory=" + wd, "-e", "bash -c " + shellQuote(cmd)}
-		}},
-		{"xterm", func(cmd, wd string) []string {
-			return []string{"-e", "bash", "-c", "cd " + shellQuote(wd) + " && " + cmd}
-		}},
-	}
+// linuxTerminals lists the terminal emulators tried in order of preference,
+// with the arguments that make each one run argv in a new window
+var linuxTerminals = []struct {
+	name string
+	args func(argv []string) []string
+}{
+	{"gnome-terminal", func(argv []string) []string {
+		return append([]string{"--"}, argv...)
+	}},
+	{"konsole", func(argv []string) []string {
+		return append([]string{"-e"}, argv...)
+	}},
+	{"xfce4-terminal", func(argv []string) []string {
+		// -e takes a single command string
+		return []string{"-e", shellQuoteArgs(argv)}
+	}},
+	{"alacritty", func(argv []string) []string {
+		return append([]string{"-e"}, argv...)
+	}},
+	{"kitty", func(argv []string) []string {
+		return argv
+	}},
+	{"xterm", func(argv []string) []string {
+		return append([]string{"-e"}, argv...)
+	}},
+}
 
-	for _, term := range terminals {
-		if _, err := exec.LookPath(term.name); err == nil {
-			cmd := exec.Command(term.name, term.args(command, workingDir)...)
-			if err := cmd.Start(); err == nil {
-				return nil
+// linuxTerminalCommand picks the terminal emulator to run argv in. $TERMINAL
+// wins when set; emulators it doesn't recognize are assumed to accept the
+// common `-e <command> [args...]` convention.
+func linuxTerminalCommand(argv []string) (string, []string, error) {
+	if value := os.Getenv("TERMINAL"); value != "" {
+		path, err := lookPath(value)
+		if err != nil {
+			return "", nil, fmt.Errorf("%w: $TERMINAL is set to %q, which was not found", ErrNoTerminal, value)
+		}
+		for _, term := range linuxTerminals {
+			if filepath.Base(path) == term.name {
+				return path, term.args(argv), nil
 			}
 		}
+		return path, append([]string{"-e"}, argv...), nil
 	}
 
-	return fmt.Errorf("no suitable terminal emulator found")
+	names := make([]string, len(linuxTerminals))
+	for i, term := range linuxTerminals {
+		if path, err := lookPath(term.name); err == nil {
+			return path, term.args(argv), nil
+		}
+		names[i] = term.name
+	}
+
+	return "", nil, fmt.Errorf("%w (tried %s; set $TERMINAL to choose one)", ErrNoTerminal, strings.Join(names, ", "))
+}
+
+func spawnLinuxTerminal(argv []string, workingDir string) error {
+	if os.Getenv("DISPLAY") == "" && os.Getenv("WAYLAND_DISPLAY") == "" {
+		return ErrNoDisplay
+	}
+
+	path, args, err := linuxTerminalCommand(argv)
+	if err != nil {
+		return err
+	}
+
+	// Not every emulator has a working directory option, so start it in
+	// the directory instead
+	cmd := exec.Command(path, args...)
+	cmd.Dir = workingDir
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start %s: %w", filepath.Base(path), err)
+	}
+
+	// Reap the emulator once it exits; some, like gnome-terminal, hand the
+	// window to a server process and return immediately
+	go func() {
+		_ = cmd.Wait()
+	}()
+
+	return nil
 }
 
 func shellQuote(s string) string {
@@ -0,0 +1,123 @@
+package terminal
+
+import (
+	"errors"
+	"os/exec"
+	"reflect"
+	"testing"
+)
+
+// fakeInstalled makes lookPath find only the named emulators, in /usr/bin
+func fakeInstalled(t *testing.T, names ...string) {
+	t.Helper()
+	orig := lookPath
+	t.Cleanup(func() { lookPath = orig })
+	lookPath = func(file string) (string, error) {
+		for _, name := range names {
+			if file == name || file == "/usr/bin/"+name {
+				return "/usr/bin/" + name, nil
+			}
+		}
+		return "", exec.ErrNotFound
+	}
+}
+
+var testArgv = []string{"/opt/vibetunnel", "--detached-session", "abc", "--", "bash", "-c", "echo hi"}
+
+func TestLinuxTerminalCommand(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"gnome-terminal", append([]string{"--"}, testArgv...)},
+		{"konsole", append([]string{"-e"}, testArgv...)},
+		{"xfce4-terminal", []string{"-e", `/opt/vibetunnel --detached-session abc -- bash -c 'echo hi'`}},
+		{"alacritty", append([]string{"-e"}, testArgv...)},
+		{"kitty", testArgv},
+		{"xterm", append([]string{"-e"}, testArgv...)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TERMINAL", "")
+			fakeInstalled(t, tt.name)
+
+			path, args, err := linuxTerminalCommand(testArgv)
+			if err != nil {
+				t.Fatalf("linuxTerminalCommand: %v", err)
+			}
+			if path != "/usr/bin/"+tt.name {
+				t.Errorf("path = %q, want /usr/bin/%s", path, tt.name)
+			}
+			if !reflect.DeepEqual(args, tt.args) {
+				t.Errorf("args = %q, want %q", args, tt.args)
+			}
+		})
+	}
+}
+
+func TestLinuxTerminalCommandPrefersFirstInstalled(t *testing.T) {
+	t.Setenv("TERMINAL", "")
+	fakeInstalled(t, "xterm", "konsole")
+
+	path, _, err := linuxTerminalCommand(testArgv)
+	if err != nil {
+		t.Fatalf("linuxTerminalCommand: %v", err)
+	}
+	if path != "/usr/bin/konsole" {
+		t.Errorf("path = %q, want /usr/bin/konsole", path)
+	}
+}
+
+func TestLinuxTerminalCommandHonorsTerminalEnv(t *testing.T) {
+	fakeInstalled(t, "gnome-terminal", "kitty", "foot")
+
+	t.Run("known emulator", func(t *testing.T) {
+		t.Setenv("TERMINAL", "kitty")
+		path, args, err := linuxTerminalCommand(testArgv)
+		if err != nil {
+			t.Fatalf("linuxTerminalCommand: %v", err)
+		}
+		if path != "/usr/bin/kitty" || !reflect.DeepEqual(args, testArgv) {
+			t.Errorf("got %q %q, want /usr/bin/kitty %q", path, args, testArgv)
+		}
+	})
+
+	t.Run("unknown emulator", func(t *testing.T) {
+		t.Setenv("TERMINAL", "foot")
+		path, args, err := linuxTerminalCommand(testArgv)
+		if err != nil {
+			t.Fatalf("linuxTerminalCommand: %v", err)
+		}
+		want := append([]string{"-e"}, testArgv...)
+		if path != "/usr/bin/foot" || !reflect.DeepEqual(args, want) {
+			t.Errorf("got %q %q, want /usr/bin/foot %q", path, args, want)
+		}
+	})
+
+	t.Run("missing emulator", func(t *testing.T) {
+		t.Setenv("TERMINAL", "wezterm")
+		if _, _, err := linuxTerminalCommand(testArgv); !errors.Is(err, ErrNoTerminal) {
+			t.Errorf("err = %v, want ErrNoTerminal", err)
+		}
+	})
+}
+
+func TestLinuxTerminalCommandWithoutEmulator(t *testing.T) {
+	t.Setenv("TERMINAL", "")
+	fakeInstalled(t)
+
+	if _, _, err := linuxTerminalCommand(testArgv); !errors.Is(err, ErrNoTerminal) {
+		t.Errorf("err = %v, want ErrNoTerminal", err)
+	}
+}
+
+func TestSpawnLinuxTerminalWithoutDisplay(t *testing.T) {
+	t.Setenv("DISPLAY", "")
+	t.Setenv("WAYLAND_DISPLAY", "")
+	fakeInstalled(t, "xterm")
+
+	if err := spawnLinuxTerminal(testArgv, t.TempDir()); !errors.Is(err, ErrNoDisplay) {
+		t.Errorf("err = %v, want ErrNoDisplay", err)
+	}
+}
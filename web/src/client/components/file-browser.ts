@@ -1,5 +1,6 @@
 import { LitElement, html } from 'lit';
 import { customElement, property, state } from 'lit/decorators.js';
+import { apiErrorMessage } from '../utils/api-error.js';
 
 interface FileInfo {
   name: string;
@@ -129,7 +130,7 @@ export class FileBrowser extends LitElement {
         this.handleCancelCreateFolder();
       } else {
         const error = await response.json();
-        alert(`Failed to create folder: ${error.error}`);
+        alert(`Failed to create folder: ${apiErrorMessage(error)}`);
       }
     } catch (error) {
       console.error('Error creating folder:', error);
@@ -1,5 +1,6 @@
 import { LitElement, html, PropertyValues } from 'lit';
 import { customElement, property, state } from 'lit/decorators.js';
+import { apiErrorMessage } from '../utils/api-error.js';
 import './file-browser.js';
 
 export interface SessionCreateData {
@@ -172,7 +173,7 @@ export class SessionCreateForm extends LitElement {
         const error = await response.json();
         this.dispatchEvent(
           new CustomEvent('error', {
-            detail: `Failed to create session: ${error.error}`,
+            detail: `Failed to create session: ${apiErrorMessage(error)}`,
           })
         );
       }
@@ -0,0 +1,22 @@
+/**
+ * API error messages
+ * The Go server nests errors as {error: {code, message, details}}, while the
+ * Node server answers with {error: "message"}
+ */
+
+export function apiErrorMessage(body: unknown): string {
+  const error = (body as { error?: unknown } | null)?.error;
+  if (typeof error === 'string') {
+    return error;
+  }
+  if (error && typeof error === 'object') {
+    const { message, code } = error as { message?: unknown; code?: unknown };
+    if (typeof message === 'string' && message) {
+      return message;
+    }
+    if (typeof code === 'string') {
+      return code;
+    }
+  }
+  return 'Unknown error';
+}